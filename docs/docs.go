@@ -65,7 +65,7 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "update a jobgroup",
+                "description": "update a jobgroup of the tenant of the caller, administrators update any jobgroup",
                 "consumes": [
                     "application/json"
                 ],
@@ -85,6 +85,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.JobGroup"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the update is based on, overrides resource_version",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -92,6 +98,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
                         }
                     },
                     "400": {
@@ -100,16 +112,28 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Quota exceeded, or JobGroup of another tenant",
+                        "schema": {
+                            "$ref": "#/definitions/service.QuotaExceededError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             },
             "post": {
-                "description": "create new jobgroup",
+                "description": "create new jobgroup, retries sent with the same Idempotency-Key return the job group created first",
                 "consumes": [
                     "text/plain"
                 ],
@@ -129,6 +153,28 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying the request across retries",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only validate the descriptor, as /jobmanager/groups/validate does",
+                        "name": "dryRun",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Value of a descriptor parameter, as name=value",
+                        "name": "param",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -136,20 +182,42 @@ const docTemplate = `{
                         "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            },
+                            "Idempotent-Replayed": {
+                                "type": "string",
+                                "description": "Set when the response is replayed for an idempotency key"
+                            }
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity",
+                    "403": {
+                        "description": "Quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/service.QuotaExceededError"
+                        }
+                    },
+                    "409": {
+                        "description": "Request with the same idempotency key in progress",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "422": {
+                        "description": "Invalid application descriptor",
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidationError"
+                        }
                     }
                 }
             }
         },
-        "/jobmanager/groups/undeploy/{group_uuid}": {
+        "/jobmanager/groups/redeploy/{group_uuid}": {
             "put": {
-                "description": "stop jobgroup by uuid",
+                "description": "deploy the jobs of a stopped jobgroup again, keeping their IDs, revisions and policies",
                 "consumes": [
                     "application/json"
                 ],
@@ -159,7 +227,7 @@ const docTemplate = `{
                 "tags": [
                     "jobgroups"
                 ],
-                "summary": "Stop JobGroup by UUID",
+                "summary": "Redeploy a stopped JobGroup by UUID",
                 "parameters": [
                     {
                         "type": "string",
@@ -167,6 +235,18 @@ const docTemplate = `{
                         "name": "group_uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Ask the matchmaker for new targets with the last descriptor of the jobgroup",
+                        "name": "matchmaking",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the redeployment is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -174,6 +254,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
                         }
                     },
                     "400": {
@@ -182,18 +268,30 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Quota exceeded, or JobGroup of another tenant",
+                        "schema": {
+                            "$ref": "#/definitions/service.QuotaExceededError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/jobmanager/groups/{group_uuid}": {
-            "get": {
-                "description": "get jobgroup by uuid",
+        "/jobmanager/groups/undeploy/{group_uuid}": {
+            "put": {
+                "description": "stop jobgroup by uuid",
                 "consumes": [
                     "application/json"
                 ],
@@ -203,7 +301,7 @@ const docTemplate = `{
                 "tags": [
                     "jobgroups"
                 ],
-                "summary": "Get JobGroup by UUID",
+                "summary": "Stop JobGroup by UUID",
                 "parameters": [
                     {
                         "type": "string",
@@ -211,6 +309,12 @@ const docTemplate = `{
                         "name": "group_uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the undeployment is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -218,6 +322,12 @@ const docTemplate = `{
                         "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
                         }
                     },
                     "400": {
@@ -226,18 +336,32 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "JobGroup of another tenant",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
-            },
-            "delete": {
-                "description": "delete job group by uuid",
+            }
+        },
+        "/jobmanager/groups/validate": {
+            "post": {
+                "description": "report the jobs an application descriptor would create and its problems, nothing is persisted",
                 "consumes": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "produces": [
                     "application/json"
@@ -245,21 +369,39 @@ const docTemplate = `{
                 "tags": [
                     "jobgroups"
                 ],
-                "summary": "delete job group by UUID",
+                "summary": "validate an application descriptor",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "JobGroup UUID",
-                        "name": "group_uuid",
-                        "in": "path",
-                        "required": true
+                        "description": "Application manifest YAML",
+                        "name": "application",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Ask the matchmaker for the targets of the components",
+                        "name": "matchmaking",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Value of a descriptor parameter, as name=value",
+                        "name": "param",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.JobGroupValidation"
                         }
                     },
                     "400": {
@@ -268,18 +410,18 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "422": {
+                        "description": "Unparsable application descriptor",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/service.ValidationError"
                         }
                     }
                 }
             }
         },
-        "/jobmanager/jobs": {
+        "/jobmanager/groups/{group_uuid}": {
             "get": {
-                "description": "get all jobs",
+                "description": "get jobgroup by uuid",
                 "consumes": [
                     "application/json"
                 ],
@@ -287,32 +429,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "jobgroups"
+                ],
+                "summary": "Get JobGroup by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "List all Jobs",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Job"
-                                }
+                            "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
                             }
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "404": {
-                        "description": "Can not find Jobs",
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             },
-            "put": {
-                "description": "update a job",
+            "delete": {
+                "description": "delete job group by uuid",
                 "consumes": [
                     "application/json"
                 ],
@@ -320,42 +477,51 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "jobgroups"
                 ],
-                "summary": "Update a Job",
+                "summary": "delete job group by UUID",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job UUID",
-                        "name": "job_uuid",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Job information",
-                        "name": "Job",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.Job"
-                        }
+                        "type": "string",
+                        "description": "ETag the deletion is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Job"
+                            "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Job UUID is required",
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "JobGroup of another tenant",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Can not find Job to update",
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
                         "schema": {
                             "type": "string"
                         }
@@ -363,9 +529,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobmanager/jobs/executable/{orchestrator}/{owner_id}": {
-            "get": {
-                "description": "get jobs to execute",
+        "/jobmanager/groups/{group_uuid}/approve": {
+            "post": {
+                "description": "approve the creation or update of a jobgroup pending approval, its jobs are executed. The approver needs the approver role in the tenant of the jobgroup, administrators review any jobgroup, and cannot be the user who requested the approval.",
                 "consumes": [
                     "application/json"
                 ],
@@ -373,90 +539,63 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "jobgroups"
                 ],
-                "summary": "List Jobs to Execute",
+                "summary": "Approve a JobGroup",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Orchestrator type [ocm | nuvla]",
-                        "name": "orchestrator",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Owner ID",
-                        "name": "owner_id",
-                        "in": "path",
-                        "required": true
+                        "description": "ETag the approval is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/models.Job"
-                                }
+                            "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
                             }
                         }
                     },
                     "400": {
-                        "description": "Orchestrator type is required",
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Can not find executable Jobs",
+                    "403": {
+                        "description": "Not an approver, an approver of another tenant, or the requester of the approval",
                         "schema": {
                             "type": "string"
                         }
-                    }
-                }
-            }
-        },
-        "/jobmanager/jobs/promote/{job_uuid}": {
-            "patch": {
-                "description": "promote job by uuid",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "jobs"
-                ],
-                "summary": "Promote Job by UUID",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Job UUID",
-                        "name": "job_uuid",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "204": {
-                        "description": "Job Promoted",
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "400": {
-                        "description": "Job UUID is required",
+                    "409": {
+                        "description": "JobGroup not pending approval",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "Can not find Job to promote",
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
                         "schema": {
                             "type": "string"
                         }
@@ -464,9 +603,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobmanager/jobs/{job_uuid}": {
+        "/jobmanager/groups/{group_uuid}/diff": {
             "get": {
-                "description": "get job by uuid",
+                "description": "report the added, removed and changed objects and fields of each component between two revisions of a jobgroup",
                 "consumes": [
                     "application/json"
                 ],
@@ -474,121 +613,201 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "jobgroups"
                 ],
-                "summary": "Get Job by UUID",
+                "summary": "Compare two states of a JobGroup",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job UUID",
-                        "name": "job_uuid",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision compared from, the current state by default",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Revision compared to, the current state by default",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Ok",
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Job"
+                            "$ref": "#/definitions/models.JobGroupDiff"
                         }
                     },
                     "400": {
-                        "description": "Job UUID is required",
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Can not find Job by UUID",
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             },
-            "delete": {
-                "description": "delete job by uuid",
+            "post": {
+                "description": "report what deploying an application descriptor would change in a jobgroup, nothing is persisted",
                 "consumes": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "jobs"
+                    "jobgroups"
                 ],
-                "summary": "Delete Job by UUID",
+                "summary": "Compare a JobGroup with a proposed descriptor",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job UUID",
-                        "name": "job_uuid",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
-                    }
+                    },
+                    {
+                        "description": "Application manifest YAML",
+                        "name": "application",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Ask the matchmaker for the targets of the components, the deployed ones are kept otherwise",
+                        "name": "matchmaking",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Value of a descriptor parameter, as name=value",
+                        "name": "param",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Ok",
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.JobGroupDiff"
                         }
                     },
                     "400": {
-                        "description": "Job UUID is required",
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Can not find Job to delete",
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "422": {
+                        "description": "Invalid application descriptor",
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidationError"
+                        }
                     }
                 }
             }
         },
-        "/jobmanager/policies/incompliance": {
+        "/jobmanager/groups/{group_uuid}/reject": {
             "post": {
-                "description": "create new policy incompliance",
+                "description": "reject the creation or update of a jobgroup pending approval for a reason, its jobs stay held back until the next update. The approver needs the approver role in the tenant of the jobgroup, administrators review any jobgroup, and cannot be the user who requested the approval.",
                 "consumes": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "policies"
+                    "jobgroups"
                 ],
-                "summary": "Create new Policy Incompliance",
+                "summary": "Reject a JobGroup",
                 "parameters": [
                     {
-                        "description": "Incompliance Object",
-                        "name": "application",
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason of the rejection",
+                        "name": "review",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.JobGroupReview"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the rejection is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Incompliance"
+                            "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Incompliance Object is not correct",
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity",
+                    "403": {
+                        "description": "Not an approver, an approver of another tenant, or the requester of the approval",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "JobGroup not pending approval",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
                         "schema": {
                             "type": "string"
                         }
@@ -596,9 +815,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobmanager/resources/status": {
-            "put": {
-                "description": "update resource status by uuid",
+        "/jobmanager/groups/{group_uuid}/revisions": {
+            "get": {
+                "description": "get the revisions of a jobgroup, the last one first",
                 "consumes": [
                     "application/json"
                 ],
@@ -606,42 +825,156 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "resources"
+                    "jobgroups"
                 ],
-                "summary": "Update resource status by UUID",
+                "summary": "Get the revisions of a JobGroup",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Resource UUID",
-                        "name": "id",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.JobGroupRevision"
+                            }
+                        }
                     },
-                    {
-                        "description": "Resource info",
-                        "name": "resource",
-                        "in": "body",
-                        "required": true,
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/models.Resource"
+                            "type": "string"
                         }
                     }
+                }
+            }
+        },
+        "/jobmanager/groups/{group_uuid}/revisions/{revision}": {
+            "get": {
+                "description": "get a revision of a jobgroup by its number",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobgroups"
+                ],
+                "summary": "Get a revision of a JobGroup",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision number",
+                        "name": "revision",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Resource updated",
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobGroupRevision"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/jobmanager/groups/{group_uuid}/rollback": {
+            "post": {
+                "description": "replace the deployments of a jobgroup with the ones of a revision, the rollback is recorded as a new revision",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobgroups"
+                ],
+                "summary": "Roll a JobGroup back to a revision",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Revision to roll back to",
+                        "name": "revision",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the rollback is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
+                        }
                     },
                     "400": {
-                        "description": "Resource UUID is required",
+                        "description": "Bad Request",
                         "schema": {
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Quota exceeded, or JobGroup of another tenant",
+                        "schema": {
+                            "$ref": "#/definitions/service.QuotaExceededError"
+                        }
+                    },
                     "404": {
-                        "description": "Can not find Resource to update",
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
                         "schema": {
                             "type": "string"
                         }
@@ -649,9 +982,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/jobmanager/resources/status/{job_uuid}": {
-            "get": {
-                "description": "get resource status by job uuid",
+        "/jobmanager/groups/{group_uuid}/rollout/resume": {
+            "post": {
+                "description": "deploy the remaining jobs of a paused canary rollout, or go on with a halted rollout once none of its jobs is degraded",
                 "consumes": [
                     "application/json"
                 ],
@@ -659,130 +992,2095 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "resources"
+                    "jobgroups"
                 ],
-                "summary": "Get resource status by job UUID",
+                "summary": "Resume the rollout of a JobGroup",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Job UUID",
-                        "name": "job_uuid",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the resumption is based on",
+                        "name": "If-Match",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Resource"
+                            "$ref": "#/definitions/models.JobGroup"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job group"
+                            }
                         }
                     },
                     "400": {
-                        "description": "Job UUID is required",
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "JobGroup of another tenant",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "404": {
-                        "description": "Can not find Job by UUID",
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "JobGroup rollout halted on a job still degraded",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "JobGroup modified since the ETag was read",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Condition": {
-            "type": "object",
-            "required": [
-                "lastTransitionTime",
-                "message",
-                "reason",
-                "status",
-                "type"
-            ],
-            "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "lastTransitionTime": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
-                },
-                "observedGeneration": {
-                    "type": "integer"
-                },
-                "reason": {
-                    "type": "string"
-                },
-                "status": {
-                    "$ref": "#/definitions/models.ConditionStatus"
+        },
+        "/jobmanager/groups/{group_uuid}/schedules": {
+            "get": {
+                "description": "get the schedules of a jobgroup of the tenant of the caller, the next to trigger first, with the outcome of their last run. Administrators get the schedules of any jobgroup.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Get the schedules of a JobGroup",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "JobGroup of another tenant",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "deploy (redeploy a stopped jobgroup) or undeploy a jobgroup of the tenant of the caller once at a time, or on every trigger of a cron expression evaluated in a time zone. Triggers missed while the job manager was down are run once or skipped, so are the ones finding the jobgroup already deployed or undeployed. Administrators schedule any jobgroup.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Schedule the deployment or undeployment of a JobGroup",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Action, and time or cron expression of the schedule",
+                        "name": "schedule",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "JobGroup of another tenant",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid schedule",
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidationError"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/groups/{group_uuid}/schedules/{schedule_uuid}": {
+            "delete": {
+                "description": "delete a schedule of a jobgroup of the tenant of the caller, its future triggers are cancelled. Administrators delete the schedules of any jobgroup.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "schedules"
+                ],
+                "summary": "Delete a schedule of a JobGroup",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JobGroup UUID",
+                        "name": "group_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Schedule UUID",
+                        "name": "schedule_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "JobGroup of another tenant",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/jobs": {
+            "get": {
+                "description": "get all jobs",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List all Jobs",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Job"
+                                }
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Jobs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "update a job",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Update a Job",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "job_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Job information",
+                        "name": "Job",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the update is based on, overrides resource_version",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Job UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Job of another tenant, or manifests of a job group gated by an approval",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Job to update",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Job modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/jobs/executable/{orchestrator}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKey": []
+                    }
+                ],
+                "description": "get jobs to execute, the owner is derived from the agent credential",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List Jobs to Execute",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Orchestrator type [ocm | nuvla]",
+                        "name": "orchestrator",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Job"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Orchestrator type is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential not bound to the orchestrator or owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find executable Jobs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/jobs/executable/{orchestrator}/{owner_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKey": []
+                    }
+                ],
+                "description": "get jobs to execute, the owner is derived from the agent credential",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "List Jobs to Execute",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Orchestrator type [ocm | nuvla]",
+                        "name": "orchestrator",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner ID (deprecated, must match the credential)",
+                        "name": "owner_id",
+                        "in": "path"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Job"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Orchestrator type is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Credential not bound to the orchestrator or owner",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find executable Jobs",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/jobs/promote/{job_uuid}": {
+            "patch": {
+                "security": [
+                    {
+                        "ApiKey": []
+                    }
+                ],
+                "description": "promote job by uuid, the authenticated agent becomes the owner of the job",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Promote Job by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "job_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the promotion is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Job Promoted",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the promoted job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Job UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Job not executable by the agent, or held back by the approval of its job group",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Job to promote",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Job modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/jobs/{job_uuid}": {
+            "get": {
+                "description": "get job by uuid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get Job by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "job_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ok",
+                        "schema": {
+                            "$ref": "#/definitions/models.Job"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Resource version of the job"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Job UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Job by UUID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "delete job by uuid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Delete Job by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "job_uuid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag the deletion is based on",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ok",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Job UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Job to delete",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "Job modified since the ETag was read",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/maintenance-windows": {
+            "get": {
+                "description": "get the maintenance windows of a cluster or of every cluster, telling which ones are open and until when",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get the maintenance windows",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Target cluster of the windows",
+                        "name": "cluster",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the open windows",
+                        "name": "active",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.MaintenanceWindow"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "define a maintenance window of a target cluster, once from a start to an end or for a duration from every trigger of a cron expression evaluated in a time zone. While it is open the jobs of the cluster are deferred, except the urgent ones (deletions and remediations by default, see MAINTENANCE_URGENT_JOBS). Needs the admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Define a maintenance window of a cluster",
+                "parameters": [
+                    {
+                        "description": "Cluster, and start and end or cron expression and duration of the window",
+                        "name": "window",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.MaintenanceWindow"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.MaintenanceWindow"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid maintenance window",
+                        "schema": {
+                            "$ref": "#/definitions/service.ValidationError"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/maintenance-windows/{window_uuid}": {
+            "get": {
+                "description": "get a maintenance window by its UUID, telling whether it is open and until when",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get a maintenance window",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Maintenance window UUID",
+                        "name": "window_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.MaintenanceWindow"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "delete a maintenance window, the jobs it deferred are executed again. Needs the admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Delete a maintenance window",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Maintenance window UUID",
+                        "name": "window_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/policies/incompliance": {
+            "post": {
+                "description": "create new policy incompliance",
+                "consumes": [
+                    "text/plain"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "policies"
+                ],
+                "summary": "Create new Policy Incompliance",
+                "parameters": [
+                    {
+                        "description": "Incompliance Object",
+                        "name": "application",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Incompliance"
+                        }
+                    },
+                    "400": {
+                        "description": "Incompliance Object is not correct",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Remediation quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/service.QuotaExceededError"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/quotas": {
+            "get": {
+                "description": "get the quotas configured for specific tenants",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "List all Quotas",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.Quota"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "create or replace the quota of a tenant, zero limits are unlimited. Needs the admin role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "Set the Quota of a tenant",
+                "parameters": [
+                    {
+                        "description": "Quota information",
+                        "name": "Quota",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Quota"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Quota"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/quotas/usage": {
+            "get": {
+                "description": "get the quota and current usage of the tenant of the caller",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "Get quota usage",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.QuotaUsage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/resources/status": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKey": []
+                    }
+                ],
+                "description": "update resource status by uuid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Update resource status by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Resource UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Resource info",
+                        "name": "resource",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Resource"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Resource updated",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Resource UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Job not owned by the agent",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Resource to update",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/resources/status/{job_uuid}": {
+            "get": {
+                "description": "get resource status by job uuid",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "resources"
+                ],
+                "summary": "Get resource status by job UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "job_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Resource"
+                        }
+                    },
+                    "400": {
+                        "description": "Job UUID is required",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Can not find Job by UUID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/serviceaccounts": {
+            "get": {
+                "description": "get all orchestrator service accounts, needs the admin role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "serviceaccounts"
+                ],
+                "summary": "List all Service Accounts",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.ServiceAccount"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "create a service account for an orchestrator agent, the API key is only returned once. Needs the admin role, an owner already bound to an agent of another orchestrator is refused.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "serviceaccounts"
+                ],
+                "summary": "Create new orchestrator Service Account",
+                "parameters": [
+                    {
+                        "description": "Service Account information",
+                        "name": "ServiceAccount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ServiceAccountDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.ServiceAccountCredentials"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/jobmanager/serviceaccounts/{account_uuid}": {
+            "delete": {
+                "description": "revoke the credentials of an orchestrator service account, needs the admin role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "serviceaccounts"
+                ],
+                "summary": "Revoke Service Account by UUID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Account UUID",
+                        "name": "account_uuid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ServiceAccount"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an administrator",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.ApprovalStatus": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "requestedBy": {
+                    "type": "string"
+                },
+                "reviewedAt": {
+                    "type": "string"
+                },
+                "reviewedBy": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ComponentDiff": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "type": "string"
+                },
+                "component": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ManifestDiff"
+                    }
+                }
+            }
+        },
+        "models.Condition": {
+            "type": "object",
+            "required": [
+                "lastTransitionTime",
+                "message",
+                "reason",
+                "status",
+                "type"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastTransitionTime": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "observedGeneration": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.ConditionStatus"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.ResourceState"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ConditionStatus": {
+            "type": "string",
+            "enum": [
+                "True",
+                "False",
+                "Unknown"
+            ],
+            "x-enum-varnames": [
+                "ConditionTrue",
+                "ConditionFalse",
+                "ConditionUnknown"
+            ]
+        },
+        "models.FieldChange": {
+            "type": "object",
+            "properties": {
+                "change": {
+                    "type": "string"
+                },
+                "from": {},
+                "path": {
+                    "type": "string"
+                },
+                "to": {}
+            }
+        },
+        "models.Incompliance": {
+            "type": "object",
+            "required": [
+                "policyName",
+                "remediation"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "currentValue": {
+                    "description": "ResourceID         string          ` + "`" + `gorm:\"type:char(36);not null\" json:\"id\" validate:\"omitempty,uuid4\"` + "`" + `",
+                    "type": "string"
+                },
+                "extraLabels": {
+                    "$ref": "#/definitions/models.StringMap"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "measurementBackend": {
+                    "type": "string"
+                },
+                "policyId": {
+                    "type": "string"
+                },
+                "policyName": {
+                    "type": "string"
+                },
+                "remediated": {
+                    "description": "Remediated is set once the remediation is applied, only remediated incompliances count against the quota",
+                    "type": "boolean"
+                },
+                "remediation": {
+                    "$ref": "#/definitions/models.RemediationType"
+                },
+                "subject": {
+                    "$ref": "#/definitions/models.Subject"
+                },
+                "threshold": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Job": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deferred": {
+                    "description": "Deferred is the reason a job waiting for an agent is withheld, such as a maintenance window of its cluster",
+                    "type": "string"
+                },
+                "dependsOn": {
+                    "description": "DependsOn names the components of the job group deployed before this one, and undeployed after it",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job_group_description": {
+                    "description": "add validation when unmocking mm",
+                    "type": "string"
+                },
+                "job_group_id": {
+                    "type": "string"
+                },
+                "job_group_name": {
+                    "description": "add validation when unmocking mm",
+                    "type": "string"
+                },
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.PlainManifest"
+                    }
+                },
+                "namespace": {
+                    "type": "string"
+                },
+                "orchestrator": {
+                    "description": "check why required fails when dm updates job for orchestrator",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrchestratorType"
+                        }
+                    ]
+                },
+                "owner_id": {
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "Priority is inherited from the job group, undeployments and remediations are executed as if it was at\nleast PriorityDelete and PriorityRemediation",
+                    "type": "integer"
+                },
+                "resource": {
+                    "$ref": "#/definitions/models.Resource"
+                },
+                "resource_version": {
+                    "description": "ResourceVersion is incremented on every write and exposed as the ETag of the job",
+                    "type": "integer"
+                },
+                "state": {
+                    "$ref": "#/definitions/models.JobState"
+                },
+                "sub_type": {
+                    "$ref": "#/definitions/models.RemediationType"
+                },
+                "targets": {
+                    "$ref": "#/definitions/models.Target"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.JobType"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobGroup": {
+            "type": "object",
+            "required": [
+                "jobs"
+            ],
+            "properties": {
+                "appDescription": {
+                    "description": "add validation when unmocking mm",
+                    "type": "string"
+                },
+                "appName": {
+                    "description": "add validation when unmocking mm",
+                    "type": "string"
+                },
+                "approval": {
+                    "description": "Approval holds back the jobs of a job group until an approver accepts its creation or update",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ApprovalStatus"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Job"
+                    }
+                },
+                "labels": {
+                    "description": "Labels of the application, matched by the approval gates",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StringMap"
+                        }
+                    ]
+                },
+                "overlays": {
+                    "description": "Overlays of the descriptor, the manifests of the jobs are stored without them and they are applied to the\nmanifests emitted, so that they follow the targets of the jobs. Their patches are sealed at rest and\nredacted in responses.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Overlay"
+                    }
+                },
+                "parameters": {
+                    "description": "Parameters holds the values the descriptor was rendered with, defaults included. They are sealed at rest\nand redacted in responses.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StringMap"
+                        }
+                    ]
+                },
+                "priority": {
+                    "description": "Priority of the jobs of the job group, from PriorityDefault to PriorityMax",
+                    "type": "integer"
+                },
+                "resource_version": {
+                    "description": "ResourceVersion is incremented on every write and exposed as the ETag of the job group",
+                    "type": "integer"
+                },
+                "rollout": {
+                    "description": "Rollout gates which of the jobs to deploy are executable, RolloutStatus tracks its progress",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RolloutStrategy"
+                        }
+                    ]
+                },
+                "rolloutStatus": {
+                    "$ref": "#/definitions/models.RolloutStatus"
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobGroupDiff": {
+            "type": "object",
+            "properties": {
+                "changed": {
+                    "type": "boolean"
+                },
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ComponentDiff"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "issues": {
+                    "description": "Issues are the ones of a proposed descriptor, a diff is reported as long as it can be built",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationIssue"
+                    }
+                },
+                "overlays": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "parameters": {
+                    "description": "Parameters are the changes of the values of the parameters, redacted, Overlays the ones of the overlays",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobGroupReview": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobGroupRevision": {
+            "type": "object",
+            "properties": {
+                "author": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "descriptor": {
+                    "description": "Descriptor is the rendered application descriptor, empty when the jobs were edited directly",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job_group_id": {
+                    "type": "string"
+                },
+                "jobs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RevisionJob"
+                    }
+                },
+                "overlays": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Overlay"
+                    }
+                },
+                "parameters": {
+                    "$ref": "#/definitions/models.StringMap"
+                },
+                "priority": {
+                    "type": "integer"
+                },
+                "revision": {
+                    "type": "integer"
+                },
+                "rollbackOf": {
+                    "description": "RollbackOf is the revision a rollback restored",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.JobGroupValidation": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationIssue"
+                    }
+                },
+                "jobGroup": {
+                    "$ref": "#/definitions/models.JobGroup"
+                },
+                "valid": {
+                    "type": "boolean"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationIssue"
+                    }
+                }
+            }
+        },
+        "models.JobState": {
+            "type": "integer",
+            "enum": [
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "JobCreated",
+                "JobProgressing",
+                "JobFinished",
+                "JobDegraded"
+            ]
+        },
+        "models.JobType": {
+            "type": "integer",
+            "enum": [
+                5,
+                6,
+                7,
+                8
+            ],
+            "x-enum-varnames": [
+                "CreateDeployment",
+                "DeleteDeployment",
+                "UpdateDeployment",
+                "ReplaceDeployment"
+            ]
+        },
+        "models.MaintenanceWindow": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Active and ActiveUntil tell whether the window is open when it is read, and until when",
+                    "type": "boolean"
+                },
+                "activeUntil": {
+                    "type": "string"
+                },
+                "author": {
+                    "type": "string"
+                },
+                "cluster": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "duration": {
+                    "type": "string"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "start": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "Timezone is the IANA time zone the cron expression is evaluated in, UTC by default",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ManifestDiff": {
+            "type": "object",
+            "properties": {
+                "apiVersion": {
+                    "type": "string"
+                },
+                "change": {
+                    "type": "string"
+                },
+                "fields": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldChange"
+                    }
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "namespace": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.OrchestratorType": {
+            "type": "string",
+            "enum": [
+                "ocm",
+                "nuvla",
+                ""
+            ],
+            "x-enum-varnames": [
+                "OCM",
+                "Nuvla",
+                "None"
+            ]
+        },
+        "models.Overlay": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "patches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OverlayPatch"
+                    }
+                },
+                "targets": {
+                    "$ref": "#/definitions/models.OverlayTargets"
+                }
+            }
+        },
+        "models.OverlayPatch": {
+            "type": "object",
+            "properties": {
+                "patch": {
+                    "type": "string"
+                },
+                "target": {
+                    "$ref": "#/definitions/models.PatchTarget"
+                }
+            }
+        },
+        "models.OverlayTargets": {
+            "type": "object",
+            "properties": {
+                "cluster": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "orchestrator": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PatchTarget": {
+            "type": "object",
+            "properties": {
+                "kind": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "namespace": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PlainManifest": {
+            "type": "object",
+            "required": [
+                "yamlString"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "yamlString": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Quota": {
+            "type": "object",
+            "required": [
+                "tenant"
+            ],
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "maxActiveJobs": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "maxJobGroups": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "maxManifestBytes": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "maxRemediationsPerHour": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "tenant": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.QuotaUsage": {
+            "type": "object",
+            "properties": {
+                "activeJobs": {
+                    "type": "integer"
+                },
+                "jobGroups": {
+                    "type": "integer"
+                },
+                "limits": {
+                    "$ref": "#/definitions/models.Quota"
+                },
+                "manifestBytes": {
+                    "type": "integer"
+                },
+                "remediationsLastHour": {
+                    "type": "integer"
+                },
+                "tenant": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RemediationType": {
+            "type": "string",
+            "enum": [
+                "scale-up",
+                "scale-down",
+                "scale-out",
+                "scale-in",
+                "patch",
+                "reallocation"
+            ],
+            "x-enum-varnames": [
+                "ScaleUp",
+                "ScaleDown",
+                "ScaleOut",
+                "ScaleIn",
+                "PatchDeployment",
+                "Reallocation"
+            ]
+        },
+        "models.Resource": {
+            "type": "object",
+            "properties": {
+                "conditions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Condition"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "resource_name": {
+                    "type": "string"
+                },
+                "resource_uuid": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResourceState": {
+            "type": "string",
+            "enum": [
+                "Progressing",
+                "Applied",
+                "Available",
+                "Degraded"
+            ],
+            "x-enum-varnames": [
+                "Progressing",
+                "Applied",
+                "Available",
+                "Degraded"
+            ]
+        },
+        "models.RevisionJob": {
+            "type": "object",
+            "properties": {
+                "component": {
+                    "type": "string"
+                },
+                "job_id": {
+                    "type": "string"
+                },
+                "manifests": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "namespace": {
+                    "type": "string"
+                },
+                "orchestrator": {
+                    "$ref": "#/definitions/models.OrchestratorType"
+                },
+                "targets": {
+                    "$ref": "#/definitions/models.Target"
+                }
+            }
+        },
+        "models.RolloutStatus": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "resumed": {
+                    "type": "boolean"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RolloutStrategy": {
+            "type": "object",
+            "properties": {
+                "canaryPercent": {
+                    "type": "integer"
+                },
+                "maxUnavailable": {
+                    "type": "integer"
                 },
                 "type": {
-                    "$ref": "#/definitions/models.ResourceState"
-                },
-                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "models.ConditionStatus": {
-            "type": "string",
-            "enum": [
-                "True",
-                "False",
-                "Unknown"
-            ],
-            "x-enum-varnames": [
-                "ConditionTrue",
-                "ConditionFalse",
-                "ConditionUnknown"
-            ]
-        },
-        "models.Incompliance": {
+        "models.Schedule": {
             "type": "object",
-            "required": [
-                "policyName",
-                "remediation"
-            ],
             "properties": {
-                "created_at": {
+                "action": {
                     "type": "string"
                 },
-                "currentValue": {
-                    "description": "ResourceID         string          ` + "`" + `gorm:\"type:char(36);not null\" json:\"id\" validate:\"omitempty,uuid4\"` + "`" + `",
+                "at": {
                     "type": "string"
                 },
-                "extraLabels": {
-                    "$ref": "#/definitions/models.StringMap"
+                "author": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "measurementBackend": {
+                "job_group_id": {
                     "type": "string"
                 },
-                "policyId": {
+                "lastError": {
                     "type": "string"
                 },
-                "policyName": {
+                "lastRun": {
                     "type": "string"
                 },
-                "remediation": {
-                    "$ref": "#/definitions/models.RemediationType"
+                "missedTriggers": {
+                    "description": "MissedTriggers tells whether the triggers missed while the job manager was down are run once or skipped",
+                    "type": "string"
                 },
-                "subject": {
-                    "$ref": "#/definitions/models.Subject"
+                "nextRun": {
+                    "type": "string"
                 },
-                "threshold": {
+                "timezone": {
+                    "description": "Timezone is the IANA time zone the cron expression is evaluated in, UTC by default",
                     "type": "string"
                 },
                 "updated_at": {
@@ -790,37 +3088,31 @@ const docTemplate = `{
                 }
             }
         },
-        "models.Job": {
+        "models.ServiceAccount": {
             "type": "object",
+            "required": [
+                "name",
+                "orchestrator",
+                "owner_id"
+            ],
             "properties": {
-                "created_at": {
+                "client_id": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "string"
-                },
-                "job_group_description": {
-                    "description": "add validation when unmocking mm",
-                    "type": "string"
-                },
-                "job_group_id": {
+                "created_at": {
                     "type": "string"
                 },
-                "job_group_name": {
-                    "description": "add validation when unmocking mm",
+                "id": {
                     "type": "string"
                 },
-                "manifests": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.PlainManifest"
-                    }
-                },
-                "namespace": {
+                "name": {
                     "type": "string"
                 },
                 "orchestrator": {
-                    "description": "check why required fails when dm updates job for orchestrator",
+                    "enum": [
+                        "ocm",
+                        "nuvla"
+                    ],
                     "allOf": [
                         {
                             "$ref": "#/definitions/models.OrchestratorType"
@@ -830,38 +3122,26 @@ const docTemplate = `{
                 "owner_id": {
                     "type": "string"
                 },
-                "resource": {
-                    "$ref": "#/definitions/models.Resource"
-                },
-                "state": {
-                    "$ref": "#/definitions/models.JobState"
-                },
-                "sub_type": {
-                    "$ref": "#/definitions/models.RemediationType"
-                },
-                "targets": {
-                    "$ref": "#/definitions/models.Target"
-                },
-                "type": {
-                    "$ref": "#/definitions/models.JobType"
+                "revoked": {
+                    "type": "boolean"
                 },
                 "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "models.JobGroup": {
+        "models.ServiceAccountCredentials": {
             "type": "object",
             "required": [
-                "jobs"
+                "name",
+                "orchestrator",
+                "owner_id"
             ],
             "properties": {
-                "appDescription": {
-                    "description": "add validation when unmocking mm",
+                "api_key": {
                     "type": "string"
                 },
-                "appName": {
-                    "description": "add validation when unmocking mm",
+                "client_id": {
                     "type": "string"
                 },
                 "created_at": {
@@ -870,143 +3150,48 @@ const docTemplate = `{
                 "id": {
                     "type": "string"
                 },
-                "jobs": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Job"
-                    }
-                },
-                "updated_at": {
-                    "type": "string"
-                }
-            }
-        },
-        "models.JobState": {
-            "type": "integer",
-            "enum": [
-                1,
-                2,
-                3,
-                4
-            ],
-            "x-enum-varnames": [
-                "JobCreated",
-                "JobProgressing",
-                "JobFinished",
-                "JobDegraded"
-            ]
-        },
-        "models.JobType": {
-            "type": "integer",
-            "enum": [
-                5,
-                6,
-                7,
-                8
-            ],
-            "x-enum-varnames": [
-                "CreateDeployment",
-                "DeleteDeployment",
-                "UpdateDeployment",
-                "ReplaceDeployment"
-            ]
-        },
-        "models.OrchestratorType": {
-            "type": "string",
-            "enum": [
-                "ocm",
-                "nuvla",
-                ""
-            ],
-            "x-enum-varnames": [
-                "OCM",
-                "Nuvla",
-                "None"
-            ]
-        },
-        "models.PlainManifest": {
-            "type": "object",
-            "required": [
-                "yamlString"
-            ],
-            "properties": {
-                "created_at": {
+                "name": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "orchestrator": {
+                    "enum": [
+                        "ocm",
+                        "nuvla"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrchestratorType"
+                        }
+                    ]
                 },
-                "updated_at": {
+                "owner_id": {
                     "type": "string"
                 },
-                "yamlString": {
+                "revoked": {
+                    "type": "boolean"
+                },
+                "updated_at": {
                     "type": "string"
                 }
             }
         },
-        "models.RemediationType": {
-            "type": "string",
-            "enum": [
-                "scale-up",
-                "scale-down",
-                "scale-out",
-                "scale-in",
-                "patch",
-                "reallocation"
-            ],
-            "x-enum-varnames": [
-                "ScaleUp",
-                "ScaleDown",
-                "ScaleOut",
-                "ScaleIn",
-                "PatchDeployment",
-                "Reallocation"
-            ]
-        },
-        "models.Resource": {
+        "models.ServiceAccountDTO": {
             "type": "object",
             "properties": {
-                "conditions": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Condition"
-                    }
-                },
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
+                "client_id": {
                     "type": "string"
                 },
-                "job_id": {
-                    "type": "string"
-                },
-                "resource_name": {
+                "name": {
                     "type": "string"
                 },
-                "resource_uuid": {
-                    "type": "string"
+                "orchestrator": {
+                    "$ref": "#/definitions/models.OrchestratorType"
                 },
-                "updated_at": {
+                "owner_id": {
                     "type": "string"
                 }
             }
         },
-        "models.ResourceState": {
-            "type": "string",
-            "enum": [
-                "Progressing",
-                "Applied",
-                "Available",
-                "Degraded"
-            ],
-            "x-enum-varnames": [
-                "Progressing",
-                "Applied",
-                "Available",
-                "Degraded"
-            ]
-        },
         "models.StringMap": {
             "type": "object",
             "additionalProperties": {
@@ -1058,6 +3243,14 @@ const docTemplate = `{
                 "id": {
                     "type": "integer"
                 },
+                "labels": {
+                    "description": "labels of the target, as reported by the matchmaker",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StringMap"
+                        }
+                    ]
+                },
                 "node_name": {
                     "type": "string"
                 },
@@ -1068,9 +3261,57 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "models.ValidationIssue": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.QuotaExceededError": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "type": "integer"
+                },
+                "quota": {
+                    "type": "string"
+                },
+                "requested": {
+                    "type": "integer"
+                },
+                "tenant": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ValidationError": {
+            "type": "object",
+            "properties": {
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ValidationIssue"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
+        "ApiKey": {
+            "description": "\"Job Manager issued API key of an orchestrator agent service account\"",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
         "Bearer": {
             "description": "\"Type 'Bearer TOKEN' to correctly set the API Key\"",
             "type": "apiKey",
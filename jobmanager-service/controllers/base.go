@@ -27,6 +27,7 @@ import (
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/service"
 	"icos/server/jobmanager-service/utils/logs"
+	"icos/server/jobmanager-service/utils/secrets"
 	"net/http"
 	"os"
 	"os/signal"
@@ -45,12 +46,17 @@ import (
 )
 
 type Server struct {
-	DB              *gorm.DB
-	Router          *mux.Router
-	JobService      service.JobService
-	JobGroupService service.JobGroupService
-	PolicyService   service.PolicyService
-	ResourceService service.ResourceService
+	DB                    *gorm.DB
+	Router                *mux.Router
+	JobService            service.JobService
+	JobGroupService       service.JobGroupService
+	PolicyService         service.PolicyService
+	ResourceService       service.ResourceService
+	ServiceAccountService service.ServiceAccountService
+	QuotaService          service.QuotaService
+	IdempotencyService    service.IdempotencyService
+	ScheduleService       service.ScheduleService
+	MaintenanceService    service.MaintenanceService
 }
 
 func (server *Server) Init() {
@@ -99,13 +105,25 @@ func (server *Server) Initialize(dbdriver, dbUser, dbPassword, dbPort, dbHost, d
 	server.DB.Debug().
 		AutoMigrate(
 			&models.JobGroup{},
+			&models.JobGroupRevision{},
 			&models.Job{},
 			&models.PlainManifest{},
 			&models.Target{},
 			&models.Resource{},
 			&models.Condition{},
 			&models.Incompliance{},
-			&models.Subject{})
+			&models.Subject{},
+			&models.ServiceAccount{},
+			&models.Quota{},
+			&models.IdempotencyKey{},
+			&models.Schedule{},
+			&models.MaintenanceWindow{},
+			&models.NamespaceOwner{})
+
+	// the encryption key is loaded upfront, a key file that cannot be read stops the server
+	if secrets.Enabled() {
+		logs.Logger.Println("Sensitive manifest fields are encrypted at rest")
+	}
 
 	server.Router = mux.NewRouter()
 
@@ -114,14 +132,24 @@ func (server *Server) Initialize(dbdriver, dbUser, dbPassword, dbPort, dbHost, d
 	jobGroupRepo := repository.NewJobGroupRepository(server.DB)
 	policyRepo := repository.NewPolicyRepository(server.DB)
 	resourceRepo := repository.NewResourceRepository(server.DB)
+	serviceAccountRepo := repository.NewServiceAccountRepository(server.DB)
+	quotaRepo := repository.NewQuotaRepository(server.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(server.DB)
+	scheduleRepo := repository.NewScheduleRepository(server.DB)
+	maintenanceRepo := repository.NewMaintenanceWindowRepository(server.DB)
 	httpClient := &http.Client{}
 
 	// Initialize services
-	server.JobService = service.NewJobService(jobRepo)
-	server.JobGroupService = service.NewJobGroupService(jobGroupRepo)
+	server.QuotaService = service.NewQuotaService(quotaRepo)
+	server.MaintenanceService = service.NewMaintenanceService(maintenanceRepo)
+	server.JobService = service.NewJobService(jobRepo, server.MaintenanceService)
+	server.JobGroupService = service.NewJobGroupService(jobGroupRepo, server.QuotaService, httpClient, server.MaintenanceService)
 	// TODO: we should reference a single httpclient for all services
-	server.PolicyService = service.NewPolicyService(policyRepo, jobRepo, httpClient)
-	server.ResourceService = service.NewResourceService(resourceRepo, jobRepo)
+	server.PolicyService = service.NewPolicyService(policyRepo, jobRepo, httpClient, server.QuotaService)
+	server.ResourceService = service.NewResourceService(resourceRepo, jobRepo, server.JobGroupService)
+	server.ServiceAccountService = service.NewServiceAccountService(serviceAccountRepo)
+	server.IdempotencyService = service.NewIdempotencyService(idempotencyRepo)
+	server.ScheduleService = service.NewScheduleService(scheduleRepo, server.JobGroupService, server.ResourceService)
 
 	// swagger
 	server.Router.PathPrefix("/jobmanager/swagger/").Handler(httpSwagger.Handler(
@@ -136,11 +164,31 @@ func (server *Server) Initialize(dbdriver, dbUser, dbPassword, dbPort, dbHost, d
 
 func (server *Server) Run(addr string) {
 	logs.Logger.Println("Listening to port " + addr + " ...")
-	handler := cors.AllowAll().Handler(server.Router)
+	// same as cors.AllowAll, exposing the headers of the API to browsers
+	handler := cors.New(cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders: []string{"*"},
+		ExposedHeaders: []string{"ETag", "Idempotent-Replayed"},
+	}).Handler(server.Router)
 
 	stop := make(chan os.Signal)
 	signal.Notify(stop, os.Interrupt)
 
+	// the scheduler runs alongside the API, it stops with the server
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	if server.ScheduleService != nil {
+		go server.ScheduleService.Run(schedulerCtx)
+	}
+
 	go func() {
 		// init server
 		if err := http.ListenAndServe(addr, handler); err != nil {
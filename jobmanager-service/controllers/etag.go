@@ -0,0 +1,58 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// setETag exposes the resource version of a job or job group, it must be called before writing the response
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatch returns the resource version required by the If-Match header, zero when the header is absent or "*"
+func ifMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("invalid If-Match header: %s", value)
+	}
+	return version, nil
+}
+
+// preconditionFailed writes a 412 response when the error is a resource version conflict
+func preconditionFailed(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, service.ErrVersionConflict) {
+		return false
+	}
+	responses.ERROR(w, http.StatusPreconditionFailed, err)
+	return true
+}
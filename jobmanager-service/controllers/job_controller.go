@@ -23,8 +23,10 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	m "icos/server/jobmanager-service/middlewares"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
 	"icos/server/jobmanager-service/utils/logs"
 	"io"
 	"net/http"
@@ -61,6 +63,7 @@ func (server *Server) GetAllJobs(w http.ResponseWriter, r *http.Request) {
 //	@Produce		json
 //	@Param			job_uuid	path		string		true	"Job UUID"
 //	@Success		200			{object}	models.Job	"Ok"
+//	@Header			200			{string}	ETag		"Resource version of the job"
 //	@Failure		400			{object}	string		"Job UUID is required"
 //	@Failure		404			{object}	string		"Can not find Job by UUID"
 //	@Router			/jobmanager/jobs/{job_uuid} [get]
@@ -79,6 +82,7 @@ func (server *Server) GetJobByUUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, jobGotten.ResourceVersion)
 	responses.JSON(w, http.StatusOK, jobGotten)
 
 }
@@ -86,20 +90,22 @@ func (server *Server) GetJobByUUID(w http.ResponseWriter, r *http.Request) {
 // GetJobsByState godoc
 //
 //	@Summary		List Jobs to Execute
-//	@Description	get jobs to execute
+//	@Description	get jobs to execute, the owner is derived from the agent credential
 //	@Tags			jobs
 //	@Accept			json
 //	@Produce		json
+//	@Security		ApiKey
 //	@Param			orchestrator	path		string	true	"Orchestrator type [ocm | nuvla]"
-//	@Param			owner_id		path		string	true	"Owner ID"
+//	@Param			owner_id		path		string	false	"Owner ID (deprecated, must match the credential)"
 //	@Success		200				{array}		[]models.Job
 //	@Failure		400				{object}	string	"Orchestrator type is required"
+//	@Failure		403				{object}	string	"Credential not bound to the orchestrator or owner"
 //	@Failure		404				{object}	string	"Can not find executable Jobs"
+//	@Router			/jobmanager/jobs/executable/{orchestrator} [get]
 //	@Router			/jobmanager/jobs/executable/{orchestrator}/{owner_id} [get]
 func (server *Server) GetJobsByState(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	orch := vars["orchestrator"]
-	ownerID := vars["owner_id"] // temporal solution to test
 	// state validation
 	if models.None == models.OrchestratorTypeMapper(orch) {
 		err := errors.New("no valid orchestrator type provided")
@@ -107,8 +113,23 @@ func (server *Server) GetJobsByState(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	agent, ok := m.AgentFromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("not authorized"))
+		return
+	}
+	if models.OrchestratorTypeMapper(orch) != agent.Orchestrator {
+		responses.ERROR(w, http.StatusForbidden, errors.New("credential is not bound to orchestrator "+orch))
+		return
+	}
+	// the owner used to be trusted from the path, keep accepting it as long as it matches the credential
+	if ownerID, ok := vars["owner_id"]; ok && ownerID != agent.OwnerID {
+		responses.ERROR(w, http.StatusForbidden, errors.New("credential is not bound to owner "+ownerID))
+		return
+	}
+
 	// Fetch jobs to execute
-	jobGotten, err := server.JobService.FindJobsToExecute(orch, ownerID)
+	jobGotten, err := server.JobService.FindJobsToExecute(string(agent.Orchestrator), agent.OwnerID)
 	if err != nil {
 		// Specific error handling for different scenarios
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -131,9 +152,11 @@ func (server *Server) GetJobsByState(w http.ResponseWriter, r *http.Request) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			job_uuid	path		string	true	"Job UUID"
+//	@Param			If-Match	header		string	false	"ETag the deletion is based on"
 //	@Success		200			{string}	string	"Ok"
 //	@Failure		400			{object}	string	"Job UUID is required"
 //	@Failure		404			{object}	string	"Can not find Job to delete"
+//	@Failure		412			{object}	string	"Job modified since the ETag was read"
 //	@Router			/jobmanager/jobs/{job_uuid} [delete]
 func (server *Server) DeleteJob(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -145,8 +168,17 @@ func (server *Server) DeleteJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobDeleted, err := server.JobService.DeleteJob(stringID)
+	version, err := ifMatch(r)
 	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobDeleted, err := server.JobService.DeleteJob(stringID, version)
+	if err != nil {
+		if preconditionFailed(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusServiceUnavailable, err)
 		return
 	}
@@ -163,9 +195,13 @@ func (server *Server) DeleteJob(w http.ResponseWriter, r *http.Request) {
 //	@Produce		json
 //	@Param			job_uuid	path		string		true	"Job UUID"
 //	@Param			Job			body		models.Job	true	"Job information"
+//	@Param			If-Match	header		string		false	"ETag the update is based on, overrides resource_version"
 //	@Success		200			{object}	models.Job
+//	@Header			200			{string}	ETag	"Resource version of the job"
 //	@Failure		400			{object}	string	"Job UUID is required"
+//	@Failure		403			{object}	string	"Job of another tenant, or manifests of a job group gated by an approval"
 //	@Failure		404			{object}	string	"Can not find Job to update"
+//	@Failure		412			{object}	string	"Job modified since the ETag was read"
 //	@Router			/jobmanager/jobs [put]
 func (server *Server) UpdateAJob(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close() // Ensure the body is closed after reading
@@ -185,6 +221,15 @@ func (server *Server) UpdateAJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if version != 0 {
+		job.ResourceVersion = version
+	}
+
 	logs.Logger.Println("Job to update: ", job)
 
 	// Temporary fix to allow for reallocation of jobs
@@ -214,34 +259,61 @@ func (server *Server) UpdateAJob(w http.ResponseWriter, r *http.Request) {
 		job.Resource = &models.Resource{}
 	}
 
-	jobUpdated, err := server.JobService.UpdateJob(&job)
+	jobUpdated, err := server.JobService.UpdateJob(&job, tenantScope(r))
 	if err != nil {
 		logs.Logger.Println("Error updating job:", err)
+		if forbidden(w, err) || preconditionFailed(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
+	setETag(w, jobUpdated.ResourceVersion)
 	responses.JSON(w, http.StatusOK, jobUpdated)
 }
 
 // PromoteJobByUUID godoc
 //
 //	@Summary		Promote Job by UUID
-//	@Description	promote job by uuid
+//	@Description	promote job by uuid, the authenticated agent becomes the owner of the job
 //	@Tags			jobs
 //	@Accept			json
 //	@Produce		json
+//	@Security		ApiKey
 //	@Param			job_uuid	path		string	true	"Job UUID"
+//	@Param			If-Match	header		string	false	"ETag the promotion is based on"
 //	@Success		204			{string}	string	"Job Promoted"
+//	@Header			204			{string}	ETag	"Resource version of the promoted job"
 //	@Failure		400			{object}	string	"Job UUID is required"
+//	@Failure		403			{object}	string	"Job not executable by the agent, or held back by the approval of its job group"
 //	@Failure		404			{object}	string	"Can not find Job to promote"
+//	@Failure		412			{object}	string	"Job modified since the ETag was read"
 //	@Router			/jobmanager/jobs/promote/{job_uuid} [patch]
 func (server *Server) PromoteJobByUUID(w http.ResponseWriter, r *http.Request) {
-	_, err := server.JobService.JobPromote(r)
+	vars := mux.Vars(r)
+	agent, ok := m.AgentFromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("not authorized"))
+		return
+	}
+
+	version, err := ifMatch(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
+	jobPromoted, err := server.JobService.JobPromote(vars["job_uuid"], agent, version)
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			responses.ERROR(w, http.StatusForbidden, err)
+		} else if !preconditionFailed(w, err) {
+			responses.ERROR(w, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	setETag(w, jobPromoted.ResourceVersion)
 	responses.JSON(w, http.StatusNoContent, http.NoBody)
 }
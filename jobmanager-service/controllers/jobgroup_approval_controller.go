@@ -0,0 +1,138 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// reviewError writes the response of a failed review: 403 for the requester, a non approver or an approver of
+// another tenant, 409 when the job group is not pending approval, 412 on a version conflict, 404 or 400 otherwise
+func reviewError(w http.ResponseWriter, err error) {
+	if preconditionFailed(w, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		responses.ERROR(w, http.StatusForbidden, err)
+	case errors.Is(err, service.ErrNotPendingApproval):
+		responses.ERROR(w, http.StatusConflict, err)
+	default:
+		revisionError(w, err)
+	}
+}
+
+// ApproveJobGroup godoc
+//
+//	@Summary		Approve a JobGroup
+//	@Description	approve the creation or update of a jobgroup pending approval, its jobs are executed. The approver needs the approver role in the tenant of the jobgroup, administrators review any jobgroup, and cannot be the user who requested the approval.
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			If-Match	header		string	false	"ETag the approval is based on"
+//	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag	"Resource version of the job group"
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"Not an approver, an approver of another tenant, or the requester of the approval"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Failure		409			{object}	string	"JobGroup not pending approval"
+//	@Failure		412			{object}	string	"JobGroup modified since the ETag was read"
+//	@Router			/jobmanager/groups/{group_uuid}/approve [post]
+func (server *Server) ApproveJobGroup(w http.ResponseWriter, r *http.Request) {
+	if !m.IsApprover(r.Context()) {
+		responses.ERROR(w, http.StatusForbidden, errors.New("approving a job group needs the approver role"))
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroup, err := server.JobGroupService.ApproveJobGroup(mux.Vars(r)["group_uuid"], m.UserFromContext(r.Context()), tenantScope(r), version)
+	if err != nil {
+		reviewError(w, err)
+		return
+	}
+
+	setETag(w, jobGroup.ResourceVersion)
+	responses.JSON(w, http.StatusOK, jobGroup)
+}
+
+// RejectJobGroup godoc
+//
+//	@Summary		Reject a JobGroup
+//	@Description	reject the creation or update of a jobgroup pending approval for a reason, its jobs stay held back until the next update. The approver needs the approver role in the tenant of the jobgroup, administrators review any jobgroup, and cannot be the user who requested the approval.
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string					true	"JobGroup UUID"
+//	@Param			review		body		models.JobGroupReview	true	"Reason of the rejection"
+//	@Param			If-Match	header		string					false	"ETag the rejection is based on"
+//	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag	"Resource version of the job group"
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"Not an approver, an approver of another tenant, or the requester of the approval"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Failure		409			{object}	string	"JobGroup not pending approval"
+//	@Failure		412			{object}	string	"JobGroup modified since the ETag was read"
+//	@Router			/jobmanager/groups/{group_uuid}/reject [post]
+func (server *Server) RejectJobGroup(w http.ResponseWriter, r *http.Request) {
+	if !m.IsApprover(r.Context()) {
+		responses.ERROR(w, http.StatusForbidden, errors.New("rejecting a job group needs the approver role"))
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	review := models.JobGroupReview{}
+	if err := json.Unmarshal(body, &review); err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroup, err := server.JobGroupService.RejectJobGroup(mux.Vars(r)["group_uuid"], m.UserFromContext(r.Context()), tenantScope(r), review.Reason, version)
+	if err != nil {
+		reviewError(w, err)
+		return
+	}
+
+	setETag(w, jobGroup.ResourceVersion)
+	responses.JSON(w, http.StatusOK, jobGroup)
+}
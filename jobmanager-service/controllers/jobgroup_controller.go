@@ -22,10 +22,16 @@ package controllers
 
 import (
 	"errors"
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
 	"icos/server/jobmanager-service/utils/logs"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
@@ -33,32 +39,211 @@ import (
 // CreateJobGroup godoc
 //
 //	@Summary		create new JobGroup
-//	@Description	create new jobgroup
+//	@Description	create new jobgroup, retries sent with the same Idempotency-Key return the job group created first
 //	@Tags			jobgroups
 //	@Accept			plain
 //	@Produce		json
-//	@Param			application	body		string			true	"Application manifest YAML"
-//	@Success		201			{object}	models.JobGroup	"Created"
-//	@Failure		422			{object}	string			"Unprocessable Entity"
+//	@Param			application		body		string			true	"Application manifest YAML"
+//	@Param			Idempotency-Key	header		string			false	"Key identifying the request across retries"
+//	@Param			dryRun			query		bool			false	"Only validate the descriptor, as /jobmanager/groups/validate does"
+//	@Param			param			query		[]string		false	"Value of a descriptor parameter, as name=value"	collectionFormat(multi)
+//	@Success		201				{object}	models.JobGroup				"Created"
+//	@Header			201				{string}	ETag						"Resource version of the job group"
+//	@Header			201				{string}	Idempotent-Replayed			"Set when the response is replayed for an idempotency key"
+//	@Failure		403				{object}	service.QuotaExceededError	"Quota exceeded"
+//	@Failure		409				{object}	string						"Request with the same idempotency key in progress"
+//	@Failure		422				{object}	service.ValidationError		"Invalid application descriptor"
 //	@Router			/jobmanager/groups [post]
 func (server *Server) CreateJobGroup(w http.ResponseWriter, r *http.Request) {
+	if dryRun, err := queryBool(r, "dryRun"); err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	} else if dryRun {
+		server.ValidateJobGroup(w, r)
+		return
+	}
+
+	params, err := queryParams(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	jobGroup, err := server.JobGroupService.CreateJobGroup(bodyBytes, r.Header)
+	tenant := m.TenantFromContext(r.Context())
+	var idempotencyKey *models.IdempotencyKey
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		// the parameter values are part of the request identified by the key
+		request := bodyBytes
+		if len(params) > 0 {
+			request = append(append([]byte{}, bodyBytes...), []byte("\n"+encodeParams(params))...)
+		}
+		idempotencyKey, err = server.IdempotencyService.Reserve(tenant, key, request)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrIdempotencyKeyInProgress):
+				responses.ERROR(w, http.StatusConflict, err)
+			default:
+				responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			}
+			return
+		}
+		if idempotencyKey.Completed() {
+			jobGroup, err := server.JobGroupService.FindJobGroupByUUID(idempotencyKey.JobGroupID)
+			if err != nil {
+				responses.ERROR(w, http.StatusNotFound, err)
+				return
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			setETag(w, jobGroup.ResourceVersion)
+			responses.JSON(w, idempotencyKey.StatusCode, jobGroup)
+			return
+		}
+	}
+
+	jobGroup, err := server.JobGroupService.CreateJobGroup(bodyBytes, params, r.Header, tenant, m.UserFromContext(r.Context()))
 	if err != nil {
+		if idempotencyKey != nil {
+			if err := server.IdempotencyService.Release(idempotencyKey); err != nil {
+				logs.Logger.Println("Error releasing idempotency key:", err)
+			}
+		}
+		if quotaExceeded(w, err) || invalidDescriptor(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
 
+	if idempotencyKey != nil {
+		if err := server.IdempotencyService.Complete(idempotencyKey, jobGroup.ID, http.StatusCreated); err != nil {
+			logs.Logger.Println("Error completing idempotency key:", err)
+		}
+	}
+
 	// notify policy manager
 	server.PolicyService.NotifyPolicyManager(string(bodyBytes), jobGroup, r.Header.Get("Authorization"))
+	setETag(w, jobGroup.ResourceVersion)
 	responses.JSON(w, http.StatusCreated, jobGroup)
 }
 
+// ValidateJobGroup godoc
+//
+//	@Summary		validate an application descriptor
+//	@Description	report the jobs an application descriptor would create and its problems, nothing is persisted
+//	@Tags			jobgroups
+//	@Accept			plain
+//	@Produce		json
+//	@Param			application	body		string	true	"Application manifest YAML"
+//	@Param			matchmaking	query		bool	false	"Ask the matchmaker for the targets of the components"
+//	@Param			param		query		[]string	false	"Value of a descriptor parameter, as name=value"	collectionFormat(multi)
+//	@Success		200			{object}	models.JobGroupValidation
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		422			{object}	service.ValidationError	"Unparsable application descriptor"
+//	@Router			/jobmanager/groups/validate [post]
+func (server *Server) ValidateJobGroup(w http.ResponseWriter, r *http.Request) {
+	matchmaking, err := queryBool(r, "matchmaking")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	params, err := queryParams(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	validation, err := server.JobGroupService.ValidateJobGroup(bodyBytes, params, r.Header, m.TenantFromContext(r.Context()), matchmaking)
+	if err != nil {
+		if invalidDescriptor(w, err) {
+			return
+		}
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, validation)
+}
+
+// invalidDescriptor writes a 422 response listing the issues when the error is a descriptor validation error
+func invalidDescriptor(w http.ResponseWriter, err error) bool {
+	var validationErr *service.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	responses.JSON(w, http.StatusUnprocessableEntity, struct {
+		Error string `json:"error"`
+		*service.ValidationError
+	}{
+		Error:           validationErr.Error(),
+		ValidationError: validationErr,
+	})
+	return true
+}
+
+// forbidden writes a 403 response when the error is a denied access
+func forbidden(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, service.ErrForbidden) {
+		return false
+	}
+	responses.ERROR(w, http.StatusForbidden, err)
+	return true
+}
+
+// tenantScope returns the tenant whose job groups the user manages, administrators manage every job group
+func tenantScope(r *http.Request) string {
+	if m.IsAdmin(r.Context()) {
+		return ""
+	}
+	return m.TenantFromContext(r.Context())
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("invalid value for query parameter " + name + ": " + value)
+	}
+	return parsed, nil
+}
+
+// queryParams parses the descriptor parameter values, given as repeated param=name=value query parameters
+func queryParams(r *http.Request) (map[string]string, error) {
+	params := map[string]string{}
+	for _, param := range r.URL.Query()["param"] {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return nil, errors.New("invalid value for query parameter param, expected name=value: " + param)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// encodeParams encodes the parameter values in a stable order
+func encodeParams(params map[string]string) string {
+	values := url.Values{}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+	return values.Encode()
+}
+
 // GetJobGroupByUUID godoc
 //
 //	@Summary		Get JobGroup by UUID
@@ -68,6 +253,7 @@ func (server *Server) CreateJobGroup(w http.ResponseWriter, r *http.Request) {
 //	@Produce		json
 //	@Param			group_uuid	path		string	true	"JobGroup UUID"
 //	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag	"Resource version of the job group"
 //	@Failure		400			{object}	string	"Bad Request"
 //	@Failure		404			{object}	string	"Not Found"
 //	@Router			/jobmanager/groups/{group_uuid} [get]
@@ -86,6 +272,7 @@ func (server *Server) GetJobGroupByUUID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	setETag(w, jobGroupGotten.ResourceVersion)
 	responses.JSON(w, http.StatusOK, jobGroupGotten)
 }
 
@@ -97,9 +284,12 @@ func (server *Server) GetJobGroupByUUID(w http.ResponseWriter, r *http.Request)
 //	@Accept			json
 //	@Produce		json
 //	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			If-Match	header		string	false	"ETag the deletion is based on"
 //	@Success		200			{string}	string
 //	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"JobGroup of another tenant"
 //	@Failure		404			{object}	string	"Not Found"
+//	@Failure		412			{object}	string	"JobGroup modified since the ETag was read"
 //	@Router			/jobmanager/groups/{group_uuid} [delete]
 func (server *Server) DeleteJobGroup(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -111,9 +301,18 @@ func (server *Server) DeleteJobGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	// Handle the deletion through the service
-	jobGroupDeleted, err := server.JobGroupService.DeleteJobGroupByID(stringID)
+	jobGroupDeleted, err := server.JobGroupService.DeleteJobGroupByID(stringID, tenantScope(r), version)
 	if err != nil {
+		if preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
@@ -150,9 +349,13 @@ func (server *Server) GetAllJobGroups(w http.ResponseWriter, r *http.Request) {
 //	@Accept			json
 //	@Produce		json
 //	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			If-Match	header		string	false	"ETag the undeployment is based on"
 //	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag	"Resource version of the job group"
 //	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"JobGroup of another tenant"
 //	@Failure		404			{object}	string	"Not Found"
+//	@Failure		412			{object}	string	"JobGroup modified since the ETag was read"
 //	@Router			/jobmanager/groups/undeploy/{group_uuid} [put]
 func (server *Server) StopJobGroupByUUID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -163,27 +366,131 @@ func (server *Server) StopJobGroupByUUID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
 	// Handle the stopping through the service
-	jobGroupStopped, err := server.JobGroupService.StopJobGroupByID(id)
+	jobGroupStopped, err := server.JobGroupService.StopJobGroupByID(id, tenantScope(r), version)
 	if err != nil {
+		if preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
+	setETag(w, jobGroupStopped.ResourceVersion)
 	responses.JSON(w, http.StatusOK, jobGroupStopped)
 }
 
+// RedeployJobGroupByUUID godoc
+//
+//	@Summary		Redeploy a stopped JobGroup by UUID
+//	@Description	deploy the jobs of a stopped jobgroup again, keeping their IDs, revisions and policies
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			matchmaking	query		bool	false	"Ask the matchmaker for new targets with the last descriptor of the jobgroup"
+//	@Param			If-Match	header		string	false	"ETag the redeployment is based on"
+//	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag						"Resource version of the job group"
+//	@Failure		400			{object}	string						"Bad Request"
+//	@Failure		403			{object}	service.QuotaExceededError	"Quota exceeded, or JobGroup of another tenant"
+//	@Failure		404			{object}	string						"Not Found"
+//	@Failure		412			{object}	string						"JobGroup modified since the ETag was read"
+//	@Router			/jobmanager/groups/redeploy/{group_uuid} [put]
+func (server *Server) RedeployJobGroupByUUID(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["group_uuid"]
+	if id == "" {
+		err := errors.New("ID Cannot be empty")
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	matchmaking, err := queryBool(r, "matchmaking")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroupRedeployed, err := server.JobGroupService.RedeployJobGroupByID(id, matchmaking, r.Header, tenantScope(r), m.UserFromContext(r.Context()), version)
+	if err != nil {
+		if quotaExceeded(w, err) || preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	setETag(w, jobGroupRedeployed.ResourceVersion)
+	responses.JSON(w, http.StatusOK, jobGroupRedeployed)
+}
+
+// ResumeJobGroupRollout godoc
+//
+//	@Summary		Resume the rollout of a JobGroup
+//	@Description	deploy the remaining jobs of a paused canary rollout, or go on with a halted rollout once none of its jobs is degraded
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			If-Match	header		string	false	"ETag the resumption is based on"
+//	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag	"Resource version of the job group"
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"JobGroup of another tenant"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Failure		409			{object}	string	"JobGroup rollout halted on a job still degraded"
+//	@Failure		412			{object}	string	"JobGroup modified since the ETag was read"
+//	@Router			/jobmanager/groups/{group_uuid}/rollout/resume [post]
+func (server *Server) ResumeJobGroupRollout(w http.ResponseWriter, r *http.Request) {
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroup, err := server.JobGroupService.ResumeRollout(mux.Vars(r)["group_uuid"], tenantScope(r), version)
+	if err != nil {
+		if preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrRolloutDegraded) {
+			responses.ERROR(w, http.StatusConflict, err)
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	setETag(w, jobGroup.ResourceVersion)
+	responses.JSON(w, http.StatusOK, jobGroup)
+}
+
 // UpdateJobGroup godoc
 //
 //	@Summary		update a JobGroup
-//	@Description	update a jobgroup
+//	@Description	update a jobgroup of the tenant of the caller, administrators update any jobgroup
 //	@Tags			jobgroups
 //	@Accept			json
 //	@Produce		json
 //	@Param			JobGroup	body		models.JobGroup	true	"JobGroup information"
+//	@Param			If-Match	header		string			false	"ETag the update is based on, overrides resource_version"
 //	@Success		200			{object}	models.JobGroup
-//	@Failure		400			{object}	string	"Bad Request"
-//	@Failure		404			{object}	string	"Not Found"
+//	@Header			200			{string}	ETag						"Resource version of the job group"
+//	@Failure		400			{object}	string						"Bad Request"
+//	@Failure		403			{object}	service.QuotaExceededError	"Quota exceeded, or JobGroup of another tenant"
+//	@Failure		404			{object}	string						"Not Found"
+//	@Failure		412			{object}	string						"JobGroup modified since the ETag was read"
 //	@Router			/jobmanager/groups [put]
 func (server *Server) UpdateJobGroup(w http.ResponseWriter, r *http.Request) {
 	bodyJob, err := io.ReadAll(r.Body)
@@ -193,12 +500,22 @@ func (server *Server) UpdateJobGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jobGroupUpdated, err := server.JobGroupService.UpdateJobGroup(bodyJob)
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroupUpdated, err := server.JobGroupService.UpdateJobGroup(bodyJob, tenantScope(r), m.UserFromContext(r.Context()), version)
 	if err != nil {
 		logs.Logger.Println("Error updating job group:", err)
+		if quotaExceeded(w, err) || preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
 
+	setETag(w, jobGroupUpdated.ResourceVersion)
 	responses.JSON(w, http.StatusOK, jobGroupUpdated)
 }
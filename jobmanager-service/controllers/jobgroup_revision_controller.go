@@ -0,0 +1,241 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"errors"
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/responses"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// revisionNumber parses the number of a revision
+func revisionNumber(value string) (int64, error) {
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, errors.New("invalid revision: " + value)
+	}
+	return revision, nil
+}
+
+// queryRevision parses an optional revision query parameter, 0 standing for the current state
+func queryRevision(r *http.Request, name string) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" || value == "current" {
+		return 0, nil
+	}
+	return revisionNumber(value)
+}
+
+// revisionError writes a 404 response when the job group or the revision does not exist, 400 otherwise
+func revisionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	responses.ERROR(w, http.StatusBadRequest, err)
+}
+
+// GetJobGroupRevisions godoc
+//
+//	@Summary		Get the revisions of a JobGroup
+//	@Description	get the revisions of a jobgroup, the last one first
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Success		200			{array}		models.JobGroupRevision
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Router			/jobmanager/groups/{group_uuid}/revisions [get]
+func (server *Server) GetJobGroupRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := server.JobGroupService.FindJobGroupRevisions(mux.Vars(r)["group_uuid"])
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, revisions)
+}
+
+// GetJobGroupRevision godoc
+//
+//	@Summary		Get a revision of a JobGroup
+//	@Description	get a revision of a jobgroup by its number
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			revision	path		int		true	"Revision number"
+//	@Success		200			{object}	models.JobGroupRevision
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Router			/jobmanager/groups/{group_uuid}/revisions/{revision} [get]
+func (server *Server) GetJobGroupRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	revision, err := revisionNumber(vars["revision"])
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroupRevision, err := server.JobGroupService.FindJobGroupRevision(vars["group_uuid"], revision)
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, jobGroupRevision)
+}
+
+// RollbackJobGroup godoc
+//
+//	@Summary		Roll a JobGroup back to a revision
+//	@Description	replace the deployments of a jobgroup with the ones of a revision, the rollback is recorded as a new revision
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			revision	query		int		true	"Revision to roll back to"
+//	@Param			If-Match	header		string	false	"ETag the rollback is based on"
+//	@Success		200			{object}	models.JobGroup
+//	@Header			200			{string}	ETag						"Resource version of the job group"
+//	@Failure		400			{object}	string						"Bad Request"
+//	@Failure		403			{object}	service.QuotaExceededError	"Quota exceeded, or JobGroup of another tenant"
+//	@Failure		404			{object}	string						"Not Found"
+//	@Failure		412			{object}	string						"JobGroup modified since the ETag was read"
+//	@Router			/jobmanager/groups/{group_uuid}/rollback [post]
+func (server *Server) RollbackJobGroup(w http.ResponseWriter, r *http.Request) {
+	revision, err := revisionNumber(r.URL.Query().Get("revision"))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	version, err := ifMatch(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	jobGroup, err := server.JobGroupService.RollbackJobGroup(mux.Vars(r)["group_uuid"], revision,
+		tenantScope(r), m.UserFromContext(r.Context()), version)
+	if err != nil {
+		if quotaExceeded(w, err) || preconditionFailed(w, err) || forbidden(w, err) {
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	setETag(w, jobGroup.ResourceVersion)
+	responses.JSON(w, http.StatusOK, jobGroup)
+}
+
+// DiffJobGroupRevisions godoc
+//
+//	@Summary		Compare two states of a JobGroup
+//	@Description	report the added, removed and changed objects and fields of each component between two revisions of a jobgroup
+//	@Tags			jobgroups
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Param			from		query		string	false	"Revision compared from, the current state by default"
+//	@Param			to			query		string	false	"Revision compared to, the current state by default"
+//	@Success		200			{object}	models.JobGroupDiff
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Router			/jobmanager/groups/{group_uuid}/diff [get]
+func (server *Server) DiffJobGroupRevisions(w http.ResponseWriter, r *http.Request) {
+	from, err := queryRevision(r, "from")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := queryRevision(r, "to")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	diff, err := server.JobGroupService.DiffJobGroup(mux.Vars(r)["group_uuid"], from, to)
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, diff)
+}
+
+// DiffJobGroupDescriptor godoc
+//
+//	@Summary		Compare a JobGroup with a proposed descriptor
+//	@Description	report what deploying an application descriptor would change in a jobgroup, nothing is persisted
+//	@Tags			jobgroups
+//	@Accept			plain
+//	@Produce		json
+//	@Param			group_uuid	path		string		true	"JobGroup UUID"
+//	@Param			application	body		string		true	"Application manifest YAML"
+//	@Param			matchmaking	query		bool		false	"Ask the matchmaker for the targets of the components, the deployed ones are kept otherwise"
+//	@Param			param		query		[]string	false	"Value of a descriptor parameter, as name=value"	collectionFormat(multi)
+//	@Success		200			{object}	models.JobGroupDiff
+//	@Failure		400			{object}	string					"Bad Request"
+//	@Failure		404			{object}	string					"Not Found"
+//	@Failure		422			{object}	service.ValidationError	"Invalid application descriptor"
+//	@Router			/jobmanager/groups/{group_uuid}/diff [post]
+func (server *Server) DiffJobGroupDescriptor(w http.ResponseWriter, r *http.Request) {
+	matchmaking, err := queryBool(r, "matchmaking")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	params, err := queryParams(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	diff, err := server.JobGroupService.DiffJobGroupDescriptor(mux.Vars(r)["group_uuid"], bodyBytes, params, r.Header,
+		m.TenantFromContext(r.Context()), matchmaking)
+	if err != nil {
+		if invalidDescriptor(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			responses.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,140 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/responses"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateMaintenanceWindow godoc
+//
+//	@Summary		Define a maintenance window of a cluster
+//	@Description	define a maintenance window of a target cluster, once from a start to an end or for a duration from every trigger of a cron expression evaluated in a time zone. While it is open the jobs of the cluster are deferred, except the urgent ones (deletions and remediations by default, see MAINTENANCE_URGENT_JOBS). Needs the admin role.
+//	@Tags			maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			window	body		models.MaintenanceWindow	true	"Cluster, and start and end or cron expression and duration of the window"
+//	@Success		201		{object}	models.MaintenanceWindow
+//	@Failure		400		{object}	string					"Bad Request"
+//	@Failure		403		{object}	string					"Not an administrator"
+//	@Failure		422		{object}	service.ValidationError	"Invalid maintenance window"
+//	@Router			/jobmanager/maintenance-windows [post]
+func (server *Server) CreateMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "defining a maintenance window") {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	window, err := server.MaintenanceService.CreateMaintenanceWindow(body, m.UserFromContext(r.Context()))
+	if err != nil {
+		if invalidDescriptor(w, err) {
+			return
+		}
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusCreated, window)
+}
+
+// GetMaintenanceWindows godoc
+//
+//	@Summary		Get the maintenance windows
+//	@Description	get the maintenance windows of a cluster or of every cluster, telling which ones are open and until when
+//	@Tags			maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			cluster	query		string	false	"Target cluster of the windows"
+//	@Param			active	query		bool	false	"Only return the open windows"
+//	@Success		200		{array}		models.MaintenanceWindow
+//	@Failure		400		{object}	string	"Bad Request"
+//	@Router			/jobmanager/maintenance-windows [get]
+func (server *Server) GetMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	activeOnly, err := queryBool(r, "active")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	windows, err := server.MaintenanceService.FindMaintenanceWindows(r.URL.Query().Get("cluster"), activeOnly)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, windows)
+}
+
+// GetMaintenanceWindow godoc
+//
+//	@Summary		Get a maintenance window
+//	@Description	get a maintenance window by its UUID, telling whether it is open and until when
+//	@Tags			maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			window_uuid	path		string	true	"Maintenance window UUID"
+//	@Success		200			{object}	models.MaintenanceWindow
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Router			/jobmanager/maintenance-windows/{window_uuid} [get]
+func (server *Server) GetMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	window, err := server.MaintenanceService.FindMaintenanceWindow(mux.Vars(r)["window_uuid"])
+	if err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, window)
+}
+
+// DeleteMaintenanceWindow godoc
+//
+//	@Summary		Delete a maintenance window
+//	@Description	delete a maintenance window, the jobs it deferred are executed again. Needs the admin role.
+//	@Tags			maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			window_uuid	path	string	true	"Maintenance window UUID"
+//	@Success		204
+//	@Failure		400	{object}	string	"Bad Request"
+//	@Failure		403	{object}	string	"Not an administrator"
+//	@Failure		404	{object}	string	"Not Found"
+//	@Router			/jobmanager/maintenance-windows/{window_uuid} [delete]
+func (server *Server) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "deleting a maintenance window") {
+		return
+	}
+	if err := server.MaintenanceService.DeleteMaintenanceWindow(mux.Vars(r)["window_uuid"]); err != nil {
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusNoContent, http.NoBody)
+}
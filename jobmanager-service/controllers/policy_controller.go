@@ -36,8 +36,9 @@ import (
 //	@Produce		json
 //	@Param			application	body		string	true	"Incompliance Object"
 //	@Success		200			{object}	models.Incompliance
-//	@Failure		400			{object}	string	"Incompliance Object is not correct"
-//	@Failure		422			{object}	string	"Unprocessable Entity"
+//	@Failure		400			{object}	string						"Incompliance Object is not correct"
+//	@Failure		422			{object}	string						"Unprocessable Entity"
+//	@Failure		429			{object}	service.QuotaExceededError	"Remediation quota exceeded"
 //	@Router			/jobmanager/policies/incompliance [post]
 func (server *Server) CreatePolicyIncompliance(w http.ResponseWriter, r *http.Request) {
 	incomplianceBody, err := io.ReadAll(r.Body)
@@ -51,6 +52,9 @@ func (server *Server) CreatePolicyIncompliance(w http.ResponseWriter, r *http.Re
 	incompliance, err := server.PolicyService.HandlePolicyIncompliance(incomplianceBody)
 	if err != nil {
 		logs.Logger.Println("ERROR " + err.Error())
+		if quotaExceeded(w, err) {
+			return
+		}
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
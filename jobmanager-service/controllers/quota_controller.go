@@ -0,0 +1,124 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"errors"
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
+	"icos/server/jobmanager-service/utils/logs"
+	"io"
+	"net/http"
+)
+
+// GetQuotaUsage godoc
+//
+//	@Summary		Get quota usage
+//	@Description	get the quota and current usage of the tenant of the caller
+//	@Tags			quotas
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	models.QuotaUsage
+//	@Failure		400	{object}	string	"Bad Request"
+//	@Router			/jobmanager/quotas/usage [get]
+func (server *Server) GetQuotaUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := server.QuotaService.GetUsage(m.TenantFromContext(r.Context()))
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, usage)
+}
+
+// GetAllQuotas godoc
+//
+//	@Summary		List all Quotas
+//	@Description	get the quotas configured for specific tenants
+//	@Tags			quotas
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		[]models.Quota
+//	@Failure		400	{object}	string	"Bad Request"
+//	@Router			/jobmanager/quotas [get]
+func (server *Server) GetAllQuotas(w http.ResponseWriter, r *http.Request) {
+	quotas, err := server.QuotaService.FindAllQuotas()
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, quotas)
+}
+
+// UpdateQuota godoc
+//
+//	@Summary		Set the Quota of a tenant
+//	@Description	create or replace the quota of a tenant, zero limits are unlimited. Needs the admin role.
+//	@Tags			quotas
+//	@Accept			json
+//	@Produce		json
+//	@Param			Quota	body		models.Quota	true	"Quota information"
+//	@Success		200		{object}	models.Quota
+//	@Failure		403		{object}	string	"Not an administrator"
+//	@Failure		422		{object}	string	"Unprocessable Entity"
+//	@Router			/jobmanager/quotas [put]
+func (server *Server) UpdateQuota(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "setting a quota") {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	quota, err := server.QuotaService.SaveQuota(body)
+	if err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, quota)
+}
+
+// quotaExceeded answers with the quota that was hit, 429 for rates and 403 otherwise
+func quotaExceeded(w http.ResponseWriter, err error) bool {
+	var quotaErr *service.QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	status := http.StatusForbidden
+	if quotaErr.RateLimited() {
+		status = http.StatusTooManyRequests
+	}
+	responses.JSON(w, status, struct {
+		Error string `json:"error"`
+		*service.QuotaExceededError
+	}{
+		Error:              quotaErr.Error(),
+		QuotaExceededError: quotaErr,
+	})
+	return true
+}
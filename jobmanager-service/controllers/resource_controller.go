@@ -23,8 +23,10 @@ package controllers
 import (
 	"encoding/json"
 	"errors"
+	m "icos/server/jobmanager-service/middlewares"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/service"
 	"icos/server/jobmanager-service/utils/logs"
 	"io"
 	"net/http"
@@ -83,10 +85,12 @@ func (server *Server) GetResourceStateByJobUUID(w http.ResponseWriter, r *http.R
 //	@Tags			resources
 //	@Accept			json
 //	@Produce		json
+//	@Security		ApiKey
 //	@Param			id			path		string			true	"Resource UUID"
 //	@Param			resource	body		models.Resource	true	"Resource info"
 //	@Success		200			{object}	string			"Resource updated"
 //	@Failure		400			{object}	string			"Resource UUID is required"
+//	@Failure		403			{object}	string			"Job not owned by the agent"
 //	@Failure		404			{object}	string			"Can not find Resource to update"
 //	@Router			/jobmanager/resources/status [put]
 func (server *Server) UpdateResourceStateByUUID(w http.ResponseWriter, r *http.Request) {
@@ -98,10 +102,18 @@ func (server *Server) UpdateResourceStateByUUID(w http.ResponseWriter, r *http.R
 	}
 	logs.Logger.Println("Resource contents: " + string(resourceBody))
 
-	updatedResource, err := server.ResourceService.UpdateResourceState(resourceBody)
+	agent, ok := m.AgentFromContext(r.Context())
+	if !ok {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("not authorized"))
+		return
+	}
+
+	updatedResource, err := server.ResourceService.UpdateResourceState(resourceBody, agent)
 	if err != nil {
 		logs.Logger.Println("ERROR " + err.Error())
-		if err.Error() == "not found" {
+		if errors.Is(err, service.ErrForbidden) {
+			responses.ERROR(w, http.StatusForbidden, err)
+		} else if err.Error() == "not found" {
 			responses.ERROR(w, http.StatusNotFound, err)
 		} else {
 			responses.ERROR(w, http.StatusBadRequest, err)
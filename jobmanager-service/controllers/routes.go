@@ -33,6 +33,13 @@ func (s *Server) initializeRoutes() {
 		m.JWTValidation,
 	}
 
+	// Orchestrator agents authenticate with an API key or a client-credentials token bound to a service account
+	agentMiddlewares := []func(http.HandlerFunc) http.HandlerFunc{
+		m.SetMiddlewareLog,
+		m.SetMiddlewareJSON,
+		m.AgentAuthentication(s.ServiceAccountService),
+	}
+
 	// Home Route
 	s.Router.HandleFunc("/jobmanager", applyMiddlewares(s.Home, middlewares[0], middlewares[1])).Methods("GET")
 
@@ -42,22 +49,52 @@ func (s *Server) initializeRoutes() {
 	// Job Routes
 	s.Router.HandleFunc("/jobmanager/jobs", applyMiddlewares(s.GetAllJobs, middlewares...)).Methods("GET")
 	s.Router.HandleFunc("/jobmanager/jobs", applyMiddlewares(s.UpdateAJob, middlewares...)).Methods("PUT")
-	s.Router.HandleFunc("/jobmanager/jobs/executable/{orchestrator}/{owner_id}", applyMiddlewares(s.GetJobsByState, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/jobs/executable/{orchestrator}", applyMiddlewares(s.GetJobsByState, agentMiddlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/jobs/executable/{orchestrator}/{owner_id}", applyMiddlewares(s.GetJobsByState, agentMiddlewares...)).Methods("GET")
 	s.Router.HandleFunc("/jobmanager/jobs/{job_uuid}", applyMiddlewares(s.GetJobByUUID, middlewares...)).Methods("GET")
 	s.Router.HandleFunc("/jobmanager/jobs/{job_uuid}", applyMiddlewares(s.DeleteJob, middlewares...)).Methods("DELETE")
-	s.Router.HandleFunc("/jobmanager/jobs/promote/{job_uuid}", applyMiddlewares(s.PromoteJobByUUID, middlewares...)).Methods("PATCH")
+	s.Router.HandleFunc("/jobmanager/jobs/promote/{job_uuid}", applyMiddlewares(s.PromoteJobByUUID, agentMiddlewares...)).Methods("PATCH")
 
 	// Job Group Routes
 	s.Router.HandleFunc("/jobmanager/groups", applyMiddlewares(s.CreateJobGroup, middlewares...)).Methods("POST")
 	s.Router.HandleFunc("/jobmanager/groups", applyMiddlewares(s.GetAllJobGroups, middlewares...)).Methods("GET")
 	s.Router.HandleFunc("/jobmanager/groups", applyMiddlewares(s.UpdateJobGroup, middlewares...)).Methods("PUT")
+	s.Router.HandleFunc("/jobmanager/groups/validate", applyMiddlewares(s.ValidateJobGroup, middlewares...)).Methods("POST")
 	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}", applyMiddlewares(s.GetJobGroupByUUID, middlewares...)).Methods("GET")
 	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}", applyMiddlewares(s.DeleteJobGroup, middlewares...)).Methods("DELETE")
 	s.Router.HandleFunc("/jobmanager/groups/undeploy/{group_uuid}", applyMiddlewares(s.StopJobGroupByUUID, middlewares...)).Methods("PUT")
+	s.Router.HandleFunc("/jobmanager/groups/redeploy/{group_uuid}", applyMiddlewares(s.RedeployJobGroupByUUID, middlewares...)).Methods("PUT")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/revisions", applyMiddlewares(s.GetJobGroupRevisions, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/revisions/{revision}", applyMiddlewares(s.GetJobGroupRevision, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/rollback", applyMiddlewares(s.RollbackJobGroup, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/rollout/resume", applyMiddlewares(s.ResumeJobGroupRollout, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/approve", applyMiddlewares(s.ApproveJobGroup, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/reject", applyMiddlewares(s.RejectJobGroup, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/schedules", applyMiddlewares(s.CreateSchedule, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/schedules", applyMiddlewares(s.GetSchedules, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/schedules/{schedule_uuid}", applyMiddlewares(s.DeleteSchedule, middlewares...)).Methods("DELETE")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/diff", applyMiddlewares(s.DiffJobGroupRevisions, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/groups/{group_uuid}/diff", applyMiddlewares(s.DiffJobGroupDescriptor, middlewares...)).Methods("POST")
 
 	// Resource Routes
 	s.Router.HandleFunc("/jobmanager/resources/status/{job_uuid}", applyMiddlewares(s.GetResourceStateByJobUUID, middlewares...)).Methods("GET")
-	s.Router.HandleFunc("/jobmanager/resources/status", applyMiddlewares(s.UpdateResourceStateByUUID, middlewares...)).Methods("PUT")
+	s.Router.HandleFunc("/jobmanager/resources/status", applyMiddlewares(s.UpdateResourceStateByUUID, agentMiddlewares...)).Methods("PUT")
+
+	// Service Account Routes
+	s.Router.HandleFunc("/jobmanager/serviceaccounts", applyMiddlewares(s.CreateServiceAccount, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/serviceaccounts", applyMiddlewares(s.GetAllServiceAccounts, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/serviceaccounts/{account_uuid}", applyMiddlewares(s.RevokeServiceAccount, middlewares...)).Methods("DELETE")
+
+	// Quota Routes
+	s.Router.HandleFunc("/jobmanager/quotas", applyMiddlewares(s.GetAllQuotas, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/quotas", applyMiddlewares(s.UpdateQuota, middlewares...)).Methods("PUT")
+	s.Router.HandleFunc("/jobmanager/quotas/usage", applyMiddlewares(s.GetQuotaUsage, middlewares...)).Methods("GET")
+
+	// Maintenance Window Routes
+	s.Router.HandleFunc("/jobmanager/maintenance-windows", applyMiddlewares(s.CreateMaintenanceWindow, middlewares...)).Methods("POST")
+	s.Router.HandleFunc("/jobmanager/maintenance-windows", applyMiddlewares(s.GetMaintenanceWindows, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/maintenance-windows/{window_uuid}", applyMiddlewares(s.GetMaintenanceWindow, middlewares...)).Methods("GET")
+	s.Router.HandleFunc("/jobmanager/maintenance-windows/{window_uuid}", applyMiddlewares(s.DeleteMaintenanceWindow, middlewares...)).Methods("DELETE")
 
 	// Policy Incompliance
 	s.Router.HandleFunc("/jobmanager/policies/incompliance", applyMiddlewares(s.CreatePolicyIncompliance, middlewares...)).Methods("POST")
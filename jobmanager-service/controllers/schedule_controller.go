@@ -0,0 +1,117 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/responses"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateSchedule godoc
+//
+//	@Summary		Schedule the deployment or undeployment of a JobGroup
+//	@Description	deploy (redeploy a stopped jobgroup) or undeploy a jobgroup of the tenant of the caller once at a time, or on every trigger of a cron expression evaluated in a time zone. Triggers missed while the job manager was down are run once or skipped, so are the ones finding the jobgroup already deployed or undeployed. Administrators schedule any jobgroup.
+//	@Tags			schedules
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string			true	"JobGroup UUID"
+//	@Param			schedule	body		models.Schedule	true	"Action, and time or cron expression of the schedule"
+//	@Success		201			{object}	models.Schedule
+//	@Failure		400			{object}	string						"Bad Request"
+//	@Failure		403			{object}	string						"JobGroup of another tenant"
+//	@Failure		404			{object}	string						"Not Found"
+//	@Failure		422			{object}	service.ValidationError	"Invalid schedule"
+//	@Router			/jobmanager/groups/{group_uuid}/schedules [post]
+func (server *Server) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	schedule, err := server.ScheduleService.CreateSchedule(mux.Vars(r)["group_uuid"], body, m.UserFromContext(r.Context()), tenantScope(r))
+	if err != nil {
+		if invalidDescriptor(w, err) || forbidden(w, err) {
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusCreated, schedule)
+}
+
+// GetSchedules godoc
+//
+//	@Summary		Get the schedules of a JobGroup
+//	@Description	get the schedules of a jobgroup of the tenant of the caller, the next to trigger first, with the outcome of their last run. Administrators get the schedules of any jobgroup.
+//	@Tags			schedules
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid	path		string	true	"JobGroup UUID"
+//	@Success		200			{array}		models.Schedule
+//	@Failure		400			{object}	string	"Bad Request"
+//	@Failure		403			{object}	string	"JobGroup of another tenant"
+//	@Failure		404			{object}	string	"Not Found"
+//	@Router			/jobmanager/groups/{group_uuid}/schedules [get]
+func (server *Server) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := server.ScheduleService.FindSchedules(mux.Vars(r)["group_uuid"], tenantScope(r))
+	if err != nil {
+		if forbidden(w, err) {
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, schedules)
+}
+
+// DeleteSchedule godoc
+//
+//	@Summary		Delete a schedule of a JobGroup
+//	@Description	delete a schedule of a jobgroup of the tenant of the caller, its future triggers are cancelled. Administrators delete the schedules of any jobgroup.
+//	@Tags			schedules
+//	@Accept			json
+//	@Produce		json
+//	@Param			group_uuid		path	string	true	"JobGroup UUID"
+//	@Param			schedule_uuid	path	string	true	"Schedule UUID"
+//	@Success		204
+//	@Failure		400	{object}	string	"Bad Request"
+//	@Failure		403	{object}	string	"JobGroup of another tenant"
+//	@Failure		404	{object}	string	"Not Found"
+//	@Router			/jobmanager/groups/{group_uuid}/schedules/{schedule_uuid} [delete]
+func (server *Server) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := server.ScheduleService.DeleteSchedule(vars["group_uuid"], vars["schedule_uuid"], tenantScope(r)); err != nil {
+		if forbidden(w, err) {
+			return
+		}
+		revisionError(w, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusNoContent, http.NoBody)
+}
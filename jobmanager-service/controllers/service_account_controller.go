@@ -0,0 +1,131 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package controllers
+
+import (
+	"errors"
+	m "icos/server/jobmanager-service/middlewares"
+	"icos/server/jobmanager-service/responses"
+	"icos/server/jobmanager-service/utils/logs"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// requireAdmin writes a 403 response when the user is not an administrator of the job manager
+func requireAdmin(w http.ResponseWriter, r *http.Request, action string) bool {
+	if m.IsAdmin(r.Context()) {
+		return true
+	}
+	responses.ERROR(w, http.StatusForbidden, errors.New(action+" needs the admin role"))
+	return false
+}
+
+// CreateServiceAccount godoc
+//
+//	@Summary		Create new orchestrator Service Account
+//	@Description	create a service account for an orchestrator agent, the API key is only returned once. Needs the admin role, an owner already bound to an agent of another orchestrator is refused.
+//	@Tags			serviceaccounts
+//	@Accept			json
+//	@Produce		json
+//	@Param			ServiceAccount	body		models.ServiceAccountDTO			true	"Service Account information"
+//	@Success		201				{object}	models.ServiceAccountCredentials	"Created"
+//	@Failure		403				{object}	string								"Not an administrator"
+//	@Failure		422				{object}	string								"Unprocessable Entity"
+//	@Router			/jobmanager/serviceaccounts [post]
+func (server *Server) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "creating a service account") {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	credentials, err := server.ServiceAccountService.CreateServiceAccount(body)
+	if err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusCreated, credentials)
+}
+
+// GetAllServiceAccounts godoc
+//
+//	@Summary		List all Service Accounts
+//	@Description	get all orchestrator service accounts, needs the admin role
+//	@Tags			serviceaccounts
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		[]models.ServiceAccount
+//	@Failure		400	{object}	string	"Bad Request"
+//	@Failure		403	{object}	string	"Not an administrator"
+//	@Router			/jobmanager/serviceaccounts [get]
+func (server *Server) GetAllServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "listing the service accounts") {
+		return
+	}
+	serviceAccounts, err := server.ServiceAccountService.FindAllServiceAccounts()
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, serviceAccounts)
+}
+
+// RevokeServiceAccount godoc
+//
+//	@Summary		Revoke Service Account by UUID
+//	@Description	revoke the credentials of an orchestrator service account, needs the admin role
+//	@Tags			serviceaccounts
+//	@Accept			json
+//	@Produce		json
+//	@Param			account_uuid	path		string	true	"Service Account UUID"
+//	@Success		200				{object}	models.ServiceAccount
+//	@Failure		400				{object}	string	"Bad Request"
+//	@Failure		403				{object}	string	"Not an administrator"
+//	@Failure		404				{object}	string	"Not Found"
+//	@Router			/jobmanager/serviceaccounts/{account_uuid} [delete]
+func (server *Server) RevokeServiceAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "revoking a service account") {
+		return
+	}
+	vars := mux.Vars(r)
+	stringID := vars["account_uuid"]
+	if stringID == "" {
+		err := errors.New("ID Cannot be empty")
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+
+	serviceAccount, err := server.ServiceAccountService.RevokeServiceAccount(stringID)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+
+	responses.JSON(w, http.StatusOK, serviceAccount)
+}
@@ -21,11 +21,13 @@
 package middlewares
 
 import (
+	"context"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/responses"
 	"icos/server/jobmanager-service/utils/logs"
 	"net/http"
@@ -38,6 +40,28 @@ import (
 
 var (
 	base64EncodedPublicKey = os.Getenv("KEYCLOAK_PUBLIC_KEY")
+	// claim identifying the tenant of a user, tokens without it fall back to the subject
+	tenantClaim = os.Getenv("TENANT_CLAIM")
+	// role of the users reviewing the job groups pending approval
+	approverRole = os.Getenv("APPROVER_ROLE")
+	// role of the users administering the job manager: agent credentials, quotas and maintenance windows
+	adminRole = os.Getenv("ADMIN_ROLE")
+)
+
+// Role reviewing the job groups pending approval when APPROVER_ROLE is not set
+const defaultApproverRole = "jobmanager-approver"
+
+// Role administering the job manager when ADMIN_ROLE is not set
+const defaultAdminRole = "jobmanager-admin"
+
+// Header used by orchestrator agents to present a job-manager issued API key
+const apiKeyHeader = "X-API-Key"
+
+type contextKey string
+
+const (
+	claimsContextKey contextKey = "claims"
+	agentContextKey  contextKey = "agent"
 )
 
 func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
@@ -56,52 +80,211 @@ func SetMiddlewareLog(next http.HandlerFunc) http.HandlerFunc {
 
 func JWTValidation(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		splitToken := strings.Split(tokenString, "Bearer")
-		if len(splitToken) < 2 {
-			err := errors.New("not authorized")
-			responses.ERROR(w, http.StatusUnauthorized, err)
+		claims, status, err := validateBearerToken(r)
+		if err != nil {
+			responses.ERROR(w, status, err)
 			return
 		}
-		reqToken := splitToken[1]
-		reqToken = strings.TrimSpace(reqToken)
 
-		// example token to validate
-		// tokenString := "eyJhbGciOiJSUzI1NiIsInR5cCIgOiAiSldUIiwia2lkIiA6ICJ6MXdOWU9YVFRpd2RhQjJyS1NqNElrUG1YTDJHbXVTOVFRNERJeXpWbWlrIn0.eyJleHAiOjE2ODIxMTQ3MjgsImlhdCI6MTY4MjExNDQyOCwianRpIjoiZDE5NGZlMmEtZDUyOC00YjhhLWI3OWItODEwNDMxNWExNzIxIiwiaXNzIjoiaHR0cDovL2xvY2FsaG9zdDo4MDgwL3JlYWxtcy9BcnFncmlmbyIsImF1ZCI6ImFjY291bnQiLCJzdWIiOiJmOWExMTYxYi00YzhiLTRmM2MtODViYy0xYmM1MDE0MWFmNTYiLCJ0eXAiOiJCZWFyZXIiLCJhenAiOiJ3ZWItYXBwIiwiYWNyIjoiMSIsImFsbG93ZWQtb3JpZ2lucyI6WyIvKiJdLCJyZWFsbV9hY2Nlc3MiOnsicm9sZXMiOlsiZGVmYXVsdC1yb2xlcy1hcnFncmlmbyIsIm9mZmxpbmVfYWNjZXNzIiwidW1hX2F1dGhvcml6YXRpb24iXX0sInJlc291cmNlX2FjY2VzcyI6eyJ3ZWItYXBwIjp7InJvbGVzIjpbInVtYV9wcm90ZWN0aW9uIl19LCJhY2NvdW50Ijp7InJvbGVzIjpbIm1hbmFnZS1hY2NvdW50IiwibWFuYWdlLWFjY291bnQtbGlua3MiLCJ2aWV3LXByb2ZpbGUiXX19LCJzY29wZSI6InByb2ZpbGUgZW1haWwiLCJjbGllbnRJZCI6IndlYi1hcHAiLCJlbWFpbF92ZXJpZmllZCI6ZmFsc2UsImNsaWVudEhvc3QiOiIxNzIuMTcuMC4xIiwicHJlZmVycmVkX3VzZXJuYW1lIjoic2VydmljZS1hY2NvdW50LXdlYi1hcHAiLCJjbGllbnRBZGRyZXNzIjoiMTcyLjE3LjAuMSJ9.vqFWx5mMESEMww8E5t1J8ZmoCw1R9qv1qlgaYaG7FQcd8B_sN223cDYMoqJF5y-Xv9zaJ094fUmyDtJHv-ZTkxw3R9AtjG0cCjqMxgBn1X2irlNYEmR5ZX73YXDUxY6XuABLyTGdh00bEcaUIyFR1Pver2UDjMf2okcV1FgEd0Z_94j4pjqtcY0nbsWIKnLoVoor7QV6ytWRpMG25DvrSVxciaOpogOHlUhaWtTfMz-mvfFg64i_S6rIuT84APnVe6weAuj92YS6bUzBif_gcgNeMdLrJChxWdPMK9G5mDAgLOqUv-X5fPOw1arigInV0nCJmKV7LG6Yc1UlDHdmiA"
+		logs.Logger.Println("Claims:", claims)
+		ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// AgentResolver resolves the service account an orchestrator agent credential is bound to
+type AgentResolver interface {
+	FindServiceAccountByAPIKey(apiKey string) (*models.ServiceAccount, error)
+	FindServiceAccountByClientID(clientID string) (*models.ServiceAccount, error)
+}
 
-		// The base64-encoded public key downloaded from Keycloak.
-		// The screenshot in the question shows the correct place to get it.
-		// It's much longer than "P184S3h7Ugjl56l31qeJ4FKvmBB4iikc".
-		// base64EncodedPublicKey := `replaced with the public key downloaded from Keycloak`
-		publicKey, err := parseKeycloakRSAPublicKey(base64EncodedPublicKey)
-		if err != nil {
-			responses.ERROR(w, http.StatusInternalServerError, err)
-			return
+// AgentAuthentication authenticates orchestrator agents either with a job-manager issued API key
+// or with a client-credentials token whose client is bound to a service account. The resolved
+// service account is stored in the request context, see AgentFromContext.
+func AgentAuthentication(resolver AgentResolver) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var agent *models.ServiceAccount
+			ctx := r.Context()
+
+			if apiKey := r.Header.Get(apiKeyHeader); apiKey != "" {
+				sa, err := resolver.FindServiceAccountByAPIKey(apiKey)
+				if err != nil {
+					responses.ERROR(w, http.StatusUnauthorized, errors.New("not authorized"))
+					return
+				}
+				agent = sa
+			} else {
+				claims, status, err := validateBearerToken(r)
+				if err != nil {
+					responses.ERROR(w, status, err)
+					return
+				}
+				sa, err := resolver.FindServiceAccountByClientID(clientIDFromClaims(claims))
+				if err != nil {
+					responses.ERROR(w, http.StatusForbidden, errors.New("token is not bound to an orchestrator service account"))
+					return
+				}
+				agent = sa
+				ctx = context.WithValue(ctx, claimsContextKey, claims)
+			}
+
+			logs.Logger.Printf("Authenticated %s agent %s (owner %s)", agent.Orchestrator, agent.Name, agent.OwnerID)
+			ctx = context.WithValue(ctx, agentContextKey, agent)
+			next(w, r.WithContext(ctx))
 		}
+	}
+}
+
+// AgentFromContext returns the orchestrator agent authenticated by AgentAuthentication
+func AgentFromContext(ctx context.Context) (*models.ServiceAccount, bool) {
+	agent, ok := ctx.Value(agentContextKey).(*models.ServiceAccount)
+	return agent, ok && agent != nil
+}
+
+// ClaimsFromContext returns the claims of the bearer token validated for the request
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(jwt.MapClaims)
+	return claims, ok
+}
 
-		token, err := jwt.Parse(reqToken, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// TenantFromContext returns the tenant of the user that issued the request
+func TenantFromContext(ctx context.Context) string {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	claim := tenantClaim
+	if claim == "" {
+		claim = "tenant"
+	}
+	switch tenant := claims[claim].(type) {
+	case string:
+		if tenant != "" {
+			return tenant
+		}
+	case []interface{}:
+		// group-like claims, the first entry is the tenant
+		if len(tenant) > 0 {
+			if first, ok := tenant[0].(string); ok {
+				return first
 			}
+		}
+	}
 
-			// return the public key that is used to validate the token.
-			return publicKey, nil
-		})
-		if err != nil {
-			// fmt.Println("Error parsing or validating token:", err)
-			responses.ERROR(w, http.StatusInternalServerError, err)
-			return
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// UserFromContext returns the user that issued the request, as recorded in the revisions of job groups
+func UserFromContext(ctx context.Context) string {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if username, _ := claims["preferred_username"].(string); username != "" {
+		return username
+	}
+	subject, _ := claims["sub"].(string)
+	return subject
+}
+
+// RolesFromContext returns the realm and client roles of the user that issued the request, as set by Keycloak
+func RolesFromContext(ctx context.Context) []string {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	roles := []string{}
+	collect := func(access interface{}) {
+		if access, ok := access.(map[string]interface{}); ok {
+			if values, ok := access["roles"].([]interface{}); ok {
+				for _, value := range values {
+					if role, ok := value.(string); ok {
+						roles = append(roles, role)
+					}
+				}
+			}
 		}
+	}
+	collect(claims["realm_access"])
+	if clients, ok := claims["resource_access"].(map[string]interface{}); ok {
+		for _, client := range clients {
+			collect(client)
+		}
+	}
+	return roles
+}
 
-		if !token.Valid {
-			responses.ERROR(w, http.StatusUnauthorized, err)
-			return
+// hasRole tells whether the user that issued the request was granted a role, or its default when not configured
+func hasRole(ctx context.Context, role, defaultRole string) bool {
+	if role == "" {
+		role = defaultRole
+	}
+	for _, granted := range RolesFromContext(ctx) {
+		if granted == role {
+			return true
 		}
+	}
+	return false
+}
 
-		claims := token.Claims.(jwt.MapClaims)
-		logs.Logger.Println("Claims:", claims)
-		next(w, r)
+// IsApprover tells whether the user that issued the request may review the job groups pending approval
+func IsApprover(ctx context.Context) bool {
+	return hasRole(ctx, approverRole, defaultApproverRole)
+}
+
+// IsAdmin tells whether the user that issued the request administers the job manager
+func IsAdmin(ctx context.Context) bool {
+	return hasRole(ctx, adminRole, defaultAdminRole)
+}
+
+// validateBearerToken parses and validates the bearer token of the request, returning the
+// HTTP status code to answer with when it is not valid
+func validateBearerToken(r *http.Request) (jwt.MapClaims, int, error) {
+	tokenString := r.Header.Get("Authorization")
+	splitToken := strings.Split(tokenString, "Bearer")
+	if len(splitToken) < 2 {
+		return nil, http.StatusUnauthorized, errors.New("not authorized")
+	}
+	reqToken := splitToken[1]
+	reqToken = strings.TrimSpace(reqToken)
+
+	// The base64-encoded public key downloaded from Keycloak.
+	publicKey, err := parseKeycloakRSAPublicKey(base64EncodedPublicKey)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	token, err := jwt.Parse(reqToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		// return the public key that is used to validate the token.
+		return publicKey, nil
+	})
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if !token.Valid {
+		return nil, http.StatusUnauthorized, errors.New("not authorized")
+	}
+
+	return token.Claims.(jwt.MapClaims), http.StatusOK, nil
+}
+
+// Keycloak client-credentials tokens carry the client in "clientId", other issuers use "azp"
+func clientIDFromClaims(claims jwt.MapClaims) string {
+	for _, claim := range []string{"clientId", "client_id", "azp"} {
+		if clientID, ok := claims[claim].(string); ok && clientID != "" {
+			return clientID
+		}
 	}
+	return ""
 }
 
 func parseKeycloakRSAPublicKey(base64Encoded string) (*rsa.PublicKey, error) {
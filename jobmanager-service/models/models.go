@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"icos/server/jobmanager-service/utils/logs"
+	"icos/server/jobmanager-service/utils/secrets"
 	"os"
 	"time"
 
@@ -62,7 +63,51 @@ type JobGroup struct {
 	BaseUUID
 	AppName        string `json:"appName"`        // add validation when unmocking mm
 	AppDescription string `json:"appDescription"` // add validation when unmocking mm
-	Jobs           []Job  `json:"jobs" validate:"dive,required"`
+	Tenant         string `gorm:"type:varchar(255);index" json:"tenant,omitempty"`
+	// Parameters holds the values the descriptor was rendered with, defaults included. They are sealed at rest
+	// and redacted in responses.
+	Parameters StringMap `gorm:"type:text" json:"parameters,omitempty"`
+	// Overlays of the descriptor, the manifests of the jobs are stored without them and they are applied to the
+	// manifests emitted, so that they follow the targets of the jobs. Their patches are sealed at rest and
+	// redacted in responses.
+	Overlays OverlayList `gorm:"type:text" json:"overlays,omitempty"`
+	// Rollout gates which of the jobs to deploy are executable, RolloutStatus tracks its progress
+	Rollout       RolloutStrategy `gorm:"embedded;embeddedPrefix:rollout_" json:"rollout"`
+	RolloutStatus RolloutStatus   `gorm:"embedded;embeddedPrefix:rollout_status_" json:"rolloutStatus"`
+	// Labels of the application, matched by the approval gates
+	Labels StringMap `gorm:"type:text" json:"labels,omitempty"`
+	// Approval holds back the jobs of a job group until an approver accepts its creation or update
+	Approval ApprovalStatus `gorm:"embedded;embeddedPrefix:approval_" json:"approval"`
+	// Priority of the jobs of the job group, from PriorityDefault to PriorityMax
+	Priority int `gorm:"not null;default:0" json:"priority"`
+	// ResourceVersion is incremented on every write and exposed as the ETag of the job group
+	ResourceVersion int64 `gorm:"not null;default:1" json:"resource_version"`
+	Jobs            []Job `json:"jobs" validate:"dive,required"`
+}
+
+// RolloutStrategy deploys the jobs of a job group all at once, a few at a time (rolling) or a share of them
+// first (canary), the other ones waiting for the rollout to be resumed
+type RolloutStrategy struct {
+	Type           string `gorm:"type:varchar(32)" json:"type,omitempty" yaml:"type,omitempty"`
+	MaxUnavailable int    `json:"maxUnavailable,omitempty" yaml:"maxUnavailable,omitempty"`
+	CanaryPercent  int    `json:"canaryPercent,omitempty" yaml:"canaryPercent,omitempty"`
+}
+
+// RolloutStatus is the state of the rollout of a job group, a halted rollout or a paused canary waits to be resumed.
+// A failed update rolled back automatically keeps its reason until the next update.
+type RolloutStatus struct {
+	State   string `gorm:"type:varchar(32)" json:"state,omitempty"`
+	Message string `gorm:"type:text" json:"message,omitempty"`
+	Resumed bool   `json:"resumed,omitempty"`
+}
+
+// ApprovalStatus is the state of the approval of a job group, the user requesting it cannot review it
+type ApprovalStatus struct {
+	State       string     `gorm:"type:varchar(32);index" json:"state,omitempty"`
+	RequestedBy string     `gorm:"type:varchar(255)" json:"requestedBy,omitempty"`
+	ReviewedBy  string     `gorm:"type:varchar(255)" json:"reviewedBy,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewedAt,omitempty"`
+	Reason      string     `gorm:"type:text" json:"reason,omitempty"`
 }
 
 func (jg *JobGroup) Validate() error {
@@ -74,6 +119,9 @@ func (jg *JobGroup) BeforeCreate(tx *gorm.DB) (err error) {
 	if jg.ID == "" {
 		jg.ID = uuid.New().String()
 	}
+	if jg.ResourceVersion == 0 {
+		jg.ResourceVersion = 1
+	}
 	logs.Logger.Print("JobGroup ID: ", jg.ID)
 	return nil
 }
@@ -89,6 +137,88 @@ func (jg *JobGroup) BeforeUpdate(tx *gorm.DB) (err error) {
 	return jg.Validate()
 }
 
+// BeforeSave seals the values of the parameters and the patches of the overlays, they may hold credentials
+func (jg *JobGroup) BeforeSave(tx *gorm.DB) (err error) {
+	if err = jg.Parameters.rewriteValues(secrets.SealValue); err != nil {
+		return err
+	}
+	return jg.Overlays.rewritePatches(secrets.SealValue)
+}
+
+// AfterSave opens the sealed values again, the saved job group keeps being used
+func (jg *JobGroup) AfterSave(tx *gorm.DB) (err error) {
+	return jg.AfterFind(tx)
+}
+
+// AfterFind opens the sealed values of the parameters and the patches of the overlays
+func (jg *JobGroup) AfterFind(tx *gorm.DB) (err error) {
+	if err = jg.Parameters.rewriteValues(secrets.OpenValue); err != nil {
+		return err
+	}
+	return jg.Overlays.rewritePatches(secrets.OpenValue)
+}
+
+// JobGroupRevision entity, an immutable snapshot of the jobs of a job group taken on every accepted change
+type JobGroupRevision struct {
+	BaseUINT
+	JobGroupID string `gorm:"type:char(36);not null;uniqueIndex:idx_job_group_revision" json:"job_group_id"`
+	Revision   int64  `gorm:"not null;uniqueIndex:idx_job_group_revision" json:"revision"`
+	// Descriptor is the rendered application descriptor, empty when the jobs were edited directly
+	Descriptor string       `gorm:"type:text" json:"descriptor,omitempty"`
+	Parameters StringMap    `gorm:"type:text" json:"parameters,omitempty"`
+	Overlays   OverlayList  `gorm:"type:text" json:"overlays,omitempty"`
+	Priority   int          `gorm:"not null;default:0" json:"priority"`
+	Jobs       RevisionJobs `gorm:"type:text" json:"jobs"`
+	Author     string       `gorm:"type:varchar(255)" json:"author,omitempty"`
+	// RollbackOf is the revision a rollback restored
+	RollbackOf int64 `json:"rollbackOf,omitempty"`
+}
+
+// RevisionJob is the state of a job in a revision
+type RevisionJob struct {
+	JobID        string           `json:"job_id"`
+	Component    string           `json:"component,omitempty"`
+	Manifests    []string         `json:"manifests"`
+	Targets      Target           `json:"targets"`
+	Orchestrator OrchestratorType `json:"orchestrator"`
+	Namespace    string           `json:"namespace,omitempty"`
+}
+
+// BeforeSave seals the sensitive fields of the manifests, the values of the parameters, the patches of the
+// overlays and the descriptor of the revision, the descriptor holds the manifests of the application
+func (r *JobGroupRevision) BeforeSave(tx *gorm.DB) (err error) {
+	if r.Descriptor, err = secrets.SealValue(r.Descriptor); err != nil {
+		return err
+	}
+	if err = r.Parameters.rewriteValues(secrets.SealValue); err != nil {
+		return err
+	}
+	if err = r.Overlays.rewritePatches(secrets.SealValue); err != nil {
+		return err
+	}
+	return r.Jobs.rewriteManifests(secrets.SealManifest)
+}
+
+// AfterSave opens the sealed fields again, the saved revision keeps being used
+func (r *JobGroupRevision) AfterSave(tx *gorm.DB) (err error) {
+	return r.AfterFind(tx)
+}
+
+// AfterFind opens the sealed fields of the manifests, the parameters, the overlays and the descriptor of the
+// revision
+func (r *JobGroupRevision) AfterFind(tx *gorm.DB) (err error) {
+	if r.Descriptor, err = secrets.OpenValue(r.Descriptor); err != nil {
+		return err
+	}
+	if err = r.Parameters.rewriteValues(secrets.OpenValue); err != nil {
+		return err
+	}
+	if err = r.Overlays.rewritePatches(secrets.OpenValue); err != nil {
+		return err
+	}
+	return r.Jobs.rewriteManifests(secrets.OpenManifest)
+}
+
 // Job entity
 type Job struct {
 	BaseUUID
@@ -104,6 +234,15 @@ type Job struct {
 	Orchestrator        OrchestratorType `gorm:"type:text" json:"orchestrator"` // check why required fails when dm updates job for orchestrator
 	Resource            *Resource        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"resource,omitempty"`
 	Namespace           string           `gorm:"type:text" json:"namespace,omitempty" validate:"omitempty"`
+	// DependsOn names the components of the job group deployed before this one, and undeployed after it
+	DependsOn StringList `gorm:"type:text" json:"dependsOn,omitempty"`
+	// Priority is inherited from the job group, undeployments and remediations are executed as if it was at
+	// least PriorityDelete and PriorityRemediation
+	Priority int `gorm:"not null;default:0;index" json:"priority"`
+	// ResourceVersion is incremented on every write and exposed as the ETag of the job
+	ResourceVersion int64 `gorm:"not null;default:1" json:"resource_version"`
+	// Deferred is the reason a job waiting for an agent is withheld, such as a maintenance window of its cluster
+	Deferred string `gorm:"-" json:"deferred,omitempty"`
 }
 
 func (j *Job) Validate() error {
@@ -115,6 +254,9 @@ func (j *Job) BeforeCreate(tx *gorm.DB) (err error) {
 	if j.ID == "" {
 		j.ID = uuid.New().String()
 	}
+	if j.ResourceVersion == 0 {
+		j.ResourceVersion = 1
+	}
 	logs.Logger.Print("Job ID: ", j.ID)
 	return nil
 }
@@ -175,6 +317,24 @@ type PlainManifest struct {
 	YamlString string `gorm:"type:text" json:"yamlString" validate:"required"`
 }
 
+// BeforeSave seals the sensitive fields of the manifest, they are encrypted at rest
+func (pm *PlainManifest) BeforeSave(tx *gorm.DB) (err error) {
+	pm.YamlString, err = secrets.SealManifest(pm.YamlString)
+	return err
+}
+
+// AfterSave opens the sealed fields again, the saved manifest keeps being used
+func (pm *PlainManifest) AfterSave(tx *gorm.DB) (err error) {
+	pm.YamlString, err = secrets.OpenManifest(pm.YamlString)
+	return err
+}
+
+// AfterFind opens the sealed fields of the manifest
+func (pm *PlainManifest) AfterFind(tx *gorm.DB) (err error) {
+	pm.YamlString, err = secrets.OpenManifest(pm.YamlString)
+	return err
+}
+
 // Target entity
 type Target struct {
 	BaseUINT
@@ -182,6 +342,7 @@ type Target struct {
 	ClusterName  string           `json:"cluster_name" validate:"required"`
 	NodeName     string           `json:"node_name,omitempty" validate:"omitempty"`
 	Orchestrator OrchestratorType `gorm:"type:text" json:"orchestrator" validate:"required"`
+	Labels       StringMap        `gorm:"type:text" json:"labels,omitempty"` // labels of the target, as reported by the matchmaker
 }
 
 // Incompliance entity
@@ -196,6 +357,8 @@ type Incompliance struct {
 	ExtraLabels        StringMap       `gorm:"type:json" json:"extraLabels,omitempty" validate:"omitempty"`
 	Subject            Subject         `json:"subject,omitempty"`
 	Remediation        RemediationType `gorm:"type:text" json:"remediation" validate:"required"`
+	// Remediated is set once the remediation is applied, only remediated incompliances count against the quota
+	Remediated bool `gorm:"not null;default:false" json:"remediated"`
 }
 
 // GORM hooks for Resource TODO: Add validation
@@ -227,6 +390,121 @@ func (s *Subject) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// ServiceAccount entity binds an orchestrator agent credential to an orchestrator type and owner ID
+type ServiceAccount struct {
+	BaseUUID
+	Name         string           `gorm:"type:text" json:"name" validate:"required"`
+	Orchestrator OrchestratorType `gorm:"type:text" json:"orchestrator" validate:"required,oneof=ocm nuvla"`
+	OwnerID      string           `gorm:"type:char(36);not null" json:"owner_id" validate:"required,uuid4"`
+	ClientID     string           `gorm:"type:varchar(255);index" json:"client_id,omitempty" validate:"omitempty"`
+	APIKeyHash   string           `gorm:"type:char(64);index" json:"-"`
+	Revoked      bool             `json:"revoked"`
+}
+
+func (sa *ServiceAccount) Validate() error {
+	return validate.Struct(sa)
+}
+
+// GORM hooks for ServiceAccount
+func (sa *ServiceAccount) BeforeCreate(tx *gorm.DB) (err error) {
+	if sa.ID == "" {
+		sa.ID = uuid.New().String()
+	}
+	if sa.OwnerID == "" {
+		sa.OwnerID = uuid.New().String()
+	}
+	return sa.Validate()
+}
+
+// Quota entity holds the limits of a tenant, a zero limit means unlimited
+type Quota struct {
+	Metadata
+	Tenant                 string `gorm:"type:varchar(255);primary_key" json:"tenant" validate:"required"`
+	MaxJobGroups           int64  `json:"maxJobGroups" validate:"gte=0"`
+	MaxActiveJobs          int64  `json:"maxActiveJobs" validate:"gte=0"`
+	MaxManifestBytes       int64  `json:"maxManifestBytes" validate:"gte=0"`
+	MaxRemediationsPerHour int64  `json:"maxRemediationsPerHour" validate:"gte=0"`
+}
+
+func (q *Quota) Validate() error {
+	return validate.Struct(q)
+}
+
+// IdempotencyKey entity records the job group created by a request sent with an Idempotency-Key header,
+// so that retries of the request return it instead of creating another one
+type IdempotencyKey struct {
+	Metadata
+	Tenant      string `gorm:"type:varchar(255);primary_key" json:"tenant"`
+	Key         string `gorm:"type:varchar(255);primary_key" json:"key"`
+	RequestHash string `gorm:"type:char(64);not null" json:"-"`
+	JobGroupID  string `gorm:"type:char(36);default:''" json:"job_group_id,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+}
+
+// Completed tells whether the request holding the key has finished, otherwise it is still in progress
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
+
+// NamespaceOwner entity, the tenant whose job groups deploy to a namespace. Its primary key keeps the job
+// groups of other tenants, saved concurrently, out of the namespace.
+type NamespaceOwner struct {
+	Metadata
+	Namespace string `gorm:"type:varchar(63);primaryKey" json:"namespace"`
+	Tenant    string `gorm:"type:varchar(255);not null;default:''" json:"tenant"`
+}
+
+// Schedule entity deploys or undeploys a job group once at a time, or on every trigger of a cron expression
+type Schedule struct {
+	BaseUUID
+	JobGroupID string     `gorm:"type:char(36);not null;index" json:"job_group_id"`
+	Action     string     `gorm:"type:varchar(32);not null" json:"action"`
+	At         *time.Time `json:"at,omitempty"`
+	Cron       string     `gorm:"type:varchar(255)" json:"cron,omitempty"`
+	// Timezone is the IANA time zone the cron expression is evaluated in, UTC by default
+	Timezone string `gorm:"type:varchar(64)" json:"timezone,omitempty"`
+	// MissedTriggers tells whether the triggers missed while the job manager was down are run once or skipped
+	MissedTriggers string     `gorm:"type:varchar(32)" json:"missedTriggers,omitempty"`
+	NextRun        *time.Time `gorm:"index" json:"nextRun,omitempty"`
+	LastRun        *time.Time `json:"lastRun,omitempty"`
+	LastError      string     `gorm:"type:text" json:"lastError,omitempty"`
+	Author         string     `gorm:"type:varchar(255)" json:"author,omitempty"`
+}
+
+// BeforeCreate sets the ID of a new schedule
+func (s *Schedule) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// MaintenanceWindow entity withholds the non-urgent jobs of a cluster, once from Start to End, or for Duration
+// from every trigger of a cron expression
+type MaintenanceWindow struct {
+	BaseUUID
+	Cluster     string     `gorm:"type:varchar(255);not null;index" json:"cluster"`
+	Description string     `gorm:"type:text" json:"description,omitempty"`
+	Start       *time.Time `json:"start,omitempty"`
+	End         *time.Time `json:"end,omitempty"`
+	Cron        string     `gorm:"type:varchar(255)" json:"cron,omitempty"`
+	Duration    string     `gorm:"type:varchar(32)" json:"duration,omitempty"`
+	// Timezone is the IANA time zone the cron expression is evaluated in, UTC by default
+	Timezone string `gorm:"type:varchar(64)" json:"timezone,omitempty"`
+	Author   string `gorm:"type:varchar(255)" json:"author,omitempty"`
+	// Active and ActiveUntil tell whether the window is open when it is read, and until when
+	Active      bool       `gorm:"-" json:"active"`
+	ActiveUntil *time.Time `gorm:"-" json:"activeUntil,omitempty"`
+}
+
+// BeforeCreate sets the ID of a new maintenance window
+func (w *MaintenanceWindow) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // Policy Manager DTOs
 type (
 	Notification struct {
@@ -252,11 +530,80 @@ type (
 type (
 	// Matchmaker DTOs
 	JobGroupHeader struct {
-		Name        string                   `json:"name"`
-		Description string                   `json:"description"`
-		Components  []Component              `json:"components"`
-		Policies    []Policy                 `json:"policies"`
-		Manifests   []map[string]interface{} `json:"manifests"`
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Components  []Component `json:"components"`
+		Policies    []Policy    `json:"policies"`
+		// Each manifest is either an object, a `kind: List` object whose items are the manifests, or a
+		// string holding `---` separated manifests
+		Manifests []interface{} `json:"manifests"`
+		Overlays  []Overlay     `json:"overlays,omitempty" yaml:"overlays,omitempty"`
+		// Namespace of the jobs, a DNS-1123 label defaulting to the application name
+		Namespace       string             `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+		CreateNamespace *NamespaceTemplate `json:"createNamespace,omitempty" yaml:"createNamespace,omitempty"`
+		// Parameters are referenced in the descriptor as {{ .params.<name> }}, the descriptor is rendered with
+		// ParameterValues, the values resolved at ingestion
+		Parameters      []Parameter       `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+		ParameterValues map[string]string `json:"-" yaml:"-"`
+		// Rollout is the strategy deploying the jobs, all at once by default
+		Rollout *RolloutStrategy `json:"rollout,omitempty" yaml:"rollout,omitempty"`
+		// Labels of the application, they may require its deployments to be approved
+		Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		// Priority of the jobs of the application, from PriorityDefault to PriorityMax
+		Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	}
+
+	// NamespaceTemplate asks for the namespace to be created on every target, as the first manifests of the jobs:
+	// a Namespace with the labels and annotations, and a ResourceQuota with the quota as hard limits if any
+	NamespaceTemplate struct {
+		Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+		Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+		Quota       map[string]string `json:"quota,omitempty" yaml:"quota,omitempty"`
+	}
+
+	// Parameter declares a typed parameter of the descriptor: string (the default), integer, number or boolean.
+	// Parameters without value take their default, required parameters have none.
+	Parameter struct {
+		Name        string        `json:"name" yaml:"name"`
+		Type        string        `json:"type,omitempty" yaml:"type,omitempty"`
+		Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+		Default     interface{}   `json:"default,omitempty" yaml:"default,omitempty"`
+		Required    bool          `json:"required,omitempty" yaml:"required,omitempty"`
+		Enum        []interface{} `json:"enum,omitempty" yaml:"enum,omitempty"`
+		Pattern     string        `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+		Minimum     *float64      `json:"minimum,omitempty" yaml:"minimum,omitempty"`
+		Maximum     *float64      `json:"maximum,omitempty" yaml:"maximum,omitempty"`
+	}
+
+	// Overlay patches the manifests of the jobs whose target it selects, so that one descriptor serves targets
+	// needing small differences. The overlay applies to all the components unless it lists some.
+	Overlay struct {
+		Name       string         `json:"name" yaml:"name"`
+		Targets    OverlayTargets `json:"targets" yaml:"targets"`
+		Components []string       `json:"components,omitempty" yaml:"components,omitempty"`
+		Patches    []OverlayPatch `json:"patches" yaml:"patches"`
+	}
+
+	// OverlayTargets selects targets by cluster name, a glob pattern, by orchestrator and by labels. Empty
+	// fields select any target.
+	OverlayTargets struct {
+		Cluster      string            `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+		Orchestrator string            `json:"orchestrator,omitempty" yaml:"orchestrator,omitempty"`
+		Labels       map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	}
+
+	// OverlayPatch is a kustomize style patch: a strategic merge patch, or a JSON 6902 patch when it is a
+	// list of operations. It applies to the manifests selected by its target, all of them when unset.
+	OverlayPatch struct {
+		Target *PatchTarget `json:"target,omitempty" yaml:"target,omitempty"`
+		Patch  string       `json:"patch" yaml:"patch"`
+	}
+
+	// PatchTarget selects manifests by kind, name and namespace, empty fields select any manifest
+	PatchTarget struct {
+		Kind      string `json:"kind,omitempty" yaml:"kind,omitempty"`
+		Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+		Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 	}
 	/* The `targets` field in the `Component` struct is defined as an `interface{}` because it can
 	contain either an object (a single target) or an empty array. This variability requires
@@ -268,6 +615,18 @@ type (
 		Requirements Requirement   `json:"requirements,omitempty" yaml:"requirements"`
 		Policies     []Policy      `json:"policies,omitempty" yaml:"policies"`
 		Targets      interface{}   `json:"targets" yaml:"targets"`
+		Chart        *HelmChart    `json:"chart,omitempty" yaml:"chart,omitempty"` // only for helm components, rendered into the manifests of the job
+		// DependsOn names the components that must be available before the component is deployed
+		DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	}
+
+	// HelmChart is the chart of a helm component, either embedded as a base64 packaged chart or taken from the
+	// local chart repository of the job manager
+	HelmChart struct {
+		Archive     string                 `json:"archive,omitempty" yaml:"archive,omitempty"`
+		Path        string                 `json:"path,omitempty" yaml:"path,omitempty"`
+		ReleaseName string                 `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`
+		Values      map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
 	}
 
 	Policy struct {
@@ -302,17 +661,101 @@ type (
 		Architecture string `json:"architecture,omitempty" yaml:"architecture"`
 	}
 
+	// ManifestDTO references a manifest of the descriptor by name, the optional kind, apiVersion and namespace
+	// tell apart manifests sharing a name
 	ManifestDTO struct {
-		Name string `json:"name" yaml:"name"`
+		Name       string `json:"name" yaml:"name"`
+		Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`
+		APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+		Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	}
+
+	// JobGroupValidation reports the job group an application descriptor would create, nothing is persisted
+	JobGroupValidation struct {
+		Valid    bool              `json:"valid"`
+		JobGroup JobGroup          `json:"jobGroup"`
+		Warnings []ValidationIssue `json:"warnings"`
+		Errors   []ValidationIssue `json:"errors"`
 	}
-	// Deploy Manager
-	JobOwnershipDTO struct {
-		OwnerID string `json:"owner_id"`
+
+	// ValidationIssue locates a problem of an application descriptor, the path is relative to its root
+	// (e.g. components[2].manifests[0].name)
+	ValidationIssue struct {
+		Path    string `json:"path"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
 	}
 
+	// JobGroupReview is the body of the rejection of a job group pending approval
+	JobGroupReview struct {
+		Reason string `json:"reason"`
+	}
+
+	// JobGroupDiff reports what changes between two states of a job group, per component and manifest
+	JobGroupDiff struct {
+		From       string          `json:"from"`
+		To         string          `json:"to"`
+		Changed    bool            `json:"changed"`
+		Components []ComponentDiff `json:"components"`
+		// Parameters are the changes of the values of the parameters, redacted, Overlays the ones of the overlays
+		Parameters []FieldChange `json:"parameters,omitempty"`
+		Overlays   []FieldChange `json:"overlays,omitempty"`
+		// Issues are the ones of a proposed descriptor, a diff is reported as long as it can be built
+		Issues []ValidationIssue `json:"issues,omitempty"`
+	}
+
+	// ComponentDiff reports the changes of the job of a component
+	ComponentDiff struct {
+		Component string         `json:"component"`
+		Change    string         `json:"change"`
+		Fields    []FieldChange  `json:"fields,omitempty"`
+		Manifests []ManifestDiff `json:"manifests"`
+	}
+
+	// ManifestDiff reports the changes of a Kubernetes object, identified by its kind, namespace and name
+	ManifestDiff struct {
+		APIVersion string        `json:"apiVersion"`
+		Kind       string        `json:"kind"`
+		Namespace  string        `json:"namespace,omitempty"`
+		Name       string        `json:"name"`
+		Change     string        `json:"change"`
+		Fields     []FieldChange `json:"fields,omitempty"`
+	}
+
+	// FieldChange reports the change of a field, its path is relative to the object (e.g. spec.replicas)
+	FieldChange struct {
+		Path   string      `json:"path"`
+		Change string      `json:"change"`
+		From   interface{} `json:"from,omitempty"`
+		To     interface{} `json:"to,omitempty"`
+	}
 	Manifest struct {
 		Name string `json:"name"`
 	}
+
+	// Service account DTOs
+	ServiceAccountDTO struct {
+		Name         string           `json:"name"`
+		Orchestrator OrchestratorType `json:"orchestrator"`
+		OwnerID      string           `json:"owner_id,omitempty"`
+		ClientID     string           `json:"client_id,omitempty"`
+	}
+
+	// ServiceAccountCredentials is only returned once, when the service account is created
+	ServiceAccountCredentials struct {
+		ServiceAccount
+		APIKey string `json:"api_key,omitempty"`
+	}
+
+	// QuotaUsage reports the consumption of a tenant against its quota
+	QuotaUsage struct {
+		Tenant               string `json:"tenant"`
+		Limits               Quota  `json:"limits"`
+		JobGroups            int64  `json:"jobGroups"`
+		ActiveJobs           int64  `json:"activeJobs"`
+		ManifestBytes        int64  `json:"manifestBytes"`
+		RemediationsLastHour int64  `json:"remediationsLastHour"`
+	}
 )
 
 // Enum-like Types
@@ -324,6 +767,9 @@ type (
 	JobType          int
 	OrchestratorType string
 	StringMap        map[string]string
+	StringList       []string
+	RevisionJobs     []RevisionJob
+	OverlayList      []Overlay
 )
 
 // RemediationType Enum
@@ -351,6 +797,50 @@ const (
 	ConditionUnknown ConditionStatus = "Unknown"
 )
 
+// Component types of the application descriptor
+const (
+	ManifestComponent = "manifest"
+	HelmComponent     = "helm"
+)
+
+// Rollout strategies and states
+const (
+	RolloutAllAtOnce = "all-at-once"
+	RolloutRolling   = "rolling"
+	RolloutCanary    = "canary"
+
+	RolloutProgressing = "progressing"
+	RolloutPaused      = "paused"
+	RolloutHalted      = "halted"
+	RolloutCompleted   = "completed"
+	RolloutFailed      = "failed"
+)
+
+// Actions and missed trigger policies of schedules
+const (
+	ScheduleDeploy   = "deploy"
+	ScheduleUndeploy = "undeploy"
+
+	MissedTriggersRun  = "run"
+	MissedTriggersSkip = "skip"
+)
+
+// Priorities of jobs, the ones of job groups range from PriorityDefault to PriorityMax. Undeployments and
+// remediations come before the deployments of any job group.
+const (
+	PriorityDefault     = 0
+	PriorityMax         = 100
+	PriorityDelete      = 200
+	PriorityRemediation = 300
+)
+
+// Approval states of job groups
+const (
+	ApprovalPending  = "PendingApproval"
+	ApprovalApproved = "Approved"
+	ApprovalRejected = "Rejected"
+)
+
 // OrchestratorType Enum
 const (
 	OCM   OrchestratorType = "ocm"
@@ -396,13 +886,158 @@ func (m *StringMap) Scan(value interface{}) error {
 		*m = nil
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch value := value.(type) {
+	case []byte:
+		bytes = value
+	case string:
+		bytes = []byte(value)
+	default:
 		return errors.New("type assertion to []byte failed")
 	}
 	return json.Unmarshal(bytes, m)
 }
 
+// rewriteValues replaces the values of the map with the result of the rewrite function
+func (m StringMap) rewriteValues(rewrite func(string) (string, error)) (err error) {
+	for key, value := range m {
+		if m[key], err = rewrite(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sealed returns a copy of the map with its values sealed, for the writes the hooks do not see
+func (m StringMap) Sealed() (StringMap, error) {
+	if m == nil {
+		return nil, nil
+	}
+	sealed := make(StringMap, len(m))
+	for key, value := range m {
+		sealed[key] = value
+	}
+	return sealed, sealed.rewriteValues(secrets.SealValue)
+}
+
+// StringList Mapper
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	var bytes []byte
+	switch value := value.(type) {
+	case []byte:
+		bytes = value
+	case string:
+		bytes = []byte(value)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// RevisionJobs Mapper
+func (jobs RevisionJobs) Value() (driver.Value, error) {
+	if jobs == nil {
+		return nil, nil
+	}
+	return json.Marshal(jobs)
+}
+
+func (jobs *RevisionJobs) Scan(value interface{}) error {
+	if value == nil {
+		*jobs = nil
+		return nil
+	}
+	var bytes []byte
+	switch value := value.(type) {
+	case []byte:
+		bytes = value
+	case string:
+		bytes = []byte(value)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, jobs)
+}
+
+// rewriteManifests replaces the manifests of the jobs with the result of the rewrite function
+func (jobs RevisionJobs) rewriteManifests(rewrite func(string) (string, error)) (err error) {
+	for i := range jobs {
+		for m := range jobs[i].Manifests {
+			if jobs[i].Manifests[m], err = rewrite(jobs[i].Manifests[m]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OverlayList Mapper
+func (overlays OverlayList) Value() (driver.Value, error) {
+	if overlays == nil {
+		return nil, nil
+	}
+	return json.Marshal(overlays)
+}
+
+func (overlays *OverlayList) Scan(value interface{}) error {
+	if value == nil {
+		*overlays = nil
+		return nil
+	}
+	var bytes []byte
+	switch value := value.(type) {
+	case []byte:
+		bytes = value
+	case string:
+		bytes = []byte(value)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, overlays)
+}
+
+// Copy returns a copy of the overlays whose patches can be rewritten
+func (overlays OverlayList) Copy() OverlayList {
+	if overlays == nil {
+		return nil
+	}
+	copied := make(OverlayList, len(overlays))
+	for i, overlay := range overlays {
+		copied[i] = overlay
+		copied[i].Patches = append([]OverlayPatch(nil), overlay.Patches...)
+	}
+	return copied
+}
+
+// Sealed returns a copy of the overlays with their patches sealed, for the writes the hooks do not see
+func (overlays OverlayList) Sealed() (OverlayList, error) {
+	sealed := overlays.Copy()
+	return sealed, sealed.rewritePatches(secrets.SealValue)
+}
+
+// rewritePatches rewrites the patches of the overlays as a whole
+func (overlays OverlayList) rewritePatches(rewrite func(string) (string, error)) (err error) {
+	for i := range overlays {
+		for p := range overlays[i].Patches {
+			if overlays[i].Patches[p].Patch, err = rewrite(overlays[i].Patches[p].Patch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 var (
 	PolicyManagerBaseURL = os.Getenv("POLICYMANAGER_URL")
 	// lighthouseBaseURL  = os.Getenv("LIGHTHOUSE_BASE_URL")
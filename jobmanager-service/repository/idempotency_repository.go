@@ -0,0 +1,92 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepository interface defines the methods for idempotency keys
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(*models.IdempotencyKey) (bool, error)
+	CompleteIdempotencyKey(*models.IdempotencyKey) error
+	DeleteIdempotencyKey(*models.IdempotencyKey) error
+	FindIdempotencyKey(tenant, key string) (*models.IdempotencyKey, error)
+	DeleteIdempotencyKeysBefore(completedBefore, inProgressBefore time.Time) (int64, error)
+}
+
+// idempotencyRepository is the implementation of IdempotencyRepository
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository returns a new instance of idempotencyRepository
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// ReserveIdempotencyKey stores a new idempotency key, it returns false if the tenant already holds the key
+func (repo *idempotencyRepository) ReserveIdempotencyKey(key *models.IdempotencyKey) (bool, error) {
+	result := repo.db.Debug().Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CompleteIdempotencyKey records the outcome of the request holding the key
+func (repo *idempotencyRepository) CompleteIdempotencyKey(key *models.IdempotencyKey) error {
+	return repo.db.Debug().Model(&models.IdempotencyKey{}).
+		Where("tenant = ? AND `key` = ?", key.Tenant, key.Key).
+		Updates(map[string]interface{}{"job_group_id": key.JobGroupID, "status_code": key.StatusCode}).Error
+}
+
+// DeleteIdempotencyKey releases a key, so that the request holding it can be retried
+func (repo *idempotencyRepository) DeleteIdempotencyKey(key *models.IdempotencyKey) error {
+	return repo.db.Debug().Where("tenant = ? AND `key` = ?", key.Tenant, key.Key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// FindIdempotencyKey finds the idempotency key of a tenant
+func (repo *idempotencyRepository) FindIdempotencyKey(tenant, key string) (*models.IdempotencyKey, error) {
+	idempotencyKey := models.IdempotencyKey{}
+	err := repo.db.Debug().Where("tenant = ? AND `key` = ?", tenant, key).Take(&idempotencyKey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("idempotency key not found")
+		}
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+// DeleteIdempotencyKeysBefore deletes the completed keys created before the end of the retention window,
+// and the keys still in progress created before the end of their lease
+func (repo *idempotencyRepository) DeleteIdempotencyKeysBefore(completedBefore, inProgressBefore time.Time) (int64, error) {
+	result := repo.db.Debug().
+		Where("(status_code <> 0 AND created_at < ?) OR (status_code = 0 AND created_at < ?)", completedBefore, inProgressBefore).
+		Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,105 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	mocks "icos/server/jobmanager-service/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func initIdempotencyRepo(db *gorm.DB) interface{} {
+	return NewIdempotencyRepository(db)
+}
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	repo := mocks.SetupTest(t, initIdempotencyRepo).(IdempotencyRepository)
+
+	reserved, err := repo.ReserveIdempotencyKey(&models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: "hash"})
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// the key is already held by the tenant
+	reserved, err = repo.ReserveIdempotencyKey(&models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: "other"})
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+
+	// keys are scoped by tenant
+	reserved, err = repo.ReserveIdempotencyKey(&models.IdempotencyKey{Tenant: "team-b", Key: "key-1", RequestHash: "hash"})
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestCompleteIdempotencyKey(t *testing.T) {
+	repo := mocks.SetupTest(t, initIdempotencyRepo).(IdempotencyRepository)
+
+	key := &models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: "hash"}
+	repo.ReserveIdempotencyKey(key)
+
+	key.JobGroupID = "27a69131-f34d-44b3-9063-81501a1c0fc8"
+	key.StatusCode = 201
+	assert.NoError(t, repo.CompleteIdempotencyKey(key))
+
+	result, err := repo.FindIdempotencyKey("team-a", "key-1")
+	assert.NoError(t, err)
+	assert.True(t, result.Completed())
+	assert.Equal(t, key.JobGroupID, result.JobGroupID)
+	assert.Equal(t, "hash", result.RequestHash)
+}
+
+func TestDeleteIdempotencyKey(t *testing.T) {
+	repo := mocks.SetupTest(t, initIdempotencyRepo).(IdempotencyRepository)
+
+	key := &models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: "hash"}
+	repo.ReserveIdempotencyKey(key)
+
+	assert.NoError(t, repo.DeleteIdempotencyKey(key))
+	_, err := repo.FindIdempotencyKey("team-a", "key-1")
+	assert.Error(t, err)
+}
+
+func TestDeleteIdempotencyKeysBefore(t *testing.T) {
+	repo := mocks.SetupTest(t, initIdempotencyRepo).(IdempotencyRepository)
+
+	expired := &models.IdempotencyKey{Metadata: models.Metadata{CreatedAt: time.Now().Add(-48 * time.Hour)}, Tenant: "team-a", Key: "key-1", RequestHash: "hash", StatusCode: 201}
+	repo.ReserveIdempotencyKey(expired)
+	completed := &models.IdempotencyKey{Metadata: models.Metadata{CreatedAt: time.Now().Add(-time.Hour)}, Tenant: "team-a", Key: "key-2", RequestHash: "hash", StatusCode: 201}
+	repo.ReserveIdempotencyKey(completed)
+	abandoned := &models.IdempotencyKey{Metadata: models.Metadata{CreatedAt: time.Now().Add(-time.Hour)}, Tenant: "team-a", Key: "key-3", RequestHash: "hash"}
+	repo.ReserveIdempotencyKey(abandoned)
+	repo.ReserveIdempotencyKey(&models.IdempotencyKey{Tenant: "team-a", Key: "key-4", RequestHash: "hash"})
+
+	// the completed key past the retention window and the key in progress past its lease are deleted
+	rowsAffected, err := repo.DeleteIdempotencyKeysBefore(time.Now().Add(-24*time.Hour), time.Now().Add(-5*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rowsAffected)
+
+	_, err = repo.FindIdempotencyKey("team-a", "key-2")
+	assert.NoError(t, err)
+	_, err = repo.FindIdempotencyKey("team-a", "key-3")
+	assert.Error(t, err)
+	_, err = repo.FindIdempotencyKey("team-a", "key-4")
+	assert.NoError(t, err)
+}
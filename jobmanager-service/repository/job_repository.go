@@ -35,12 +35,19 @@ import (
 type JobRepository interface {
 	SaveJob(*models.Job) (*models.Job, error)
 	UpdateJob(*models.Job) (*models.Job, error)
-	DeleteJob(string) (int64, error)
+	DeleteJob(id string, version int64) (int64, error)
 	FindJobByUUID(string) (*models.Job, error)
 	FindJobByResourceUUID(string) (*models.Job, error)
 	FindAllJobs() (*[]models.Job, error)
 	FindJobsByState(state int) (*[]models.Job, error)
 	FindJobsToExecute(orchestratorType, ownerID string) (*[]models.Job, error)
+	FindJobsByJobGroup(jobGroupID string) (*[]models.Job, error)
+	FindJobGroupRollout(jobGroupID string) (*models.JobGroup, error)
+	FindDegradedUpdates() (*[]models.Job, error)
+	HaltRollout(jobGroupID, message string) error
+	FindJobGroupTenants(jobGroupIDs []string) (map[string]string, error)
+	FindJobGroupOverlays(jobGroupIDs []string) (map[string]models.OverlayList, error)
+	FindJobGroupApprovals(jobGroupIDs []string) (map[string]models.ApprovalStatus, error)
 	JobPromote(*models.Job) (*models.Job, error)
 }
 
@@ -75,7 +82,9 @@ func (repo *jobRepository) SaveJob(job *models.Job) (*models.Job, error) {
 	return job, nil
 }
 
-// UpdateJob updates an existing job in the database
+// UpdateJob updates an existing job in the database. The resource version of the job is the one
+// the update is based on, ErrVersionConflict is returned if it is not the stored one anymore.
+// A zero resource version updates the job whatever its stored version is.
 func (repo *jobRepository) UpdateJob(job *models.Job) (*models.Job, error) {
 	tx := repo.db.Begin()
 
@@ -86,6 +95,15 @@ func (repo *jobRepository) UpdateJob(job *models.Job) (*models.Job, error) {
 		}
 	}()
 
+	version, err := nextResourceVersion(tx, &models.Job{}, job.ID, job.ResourceVersion)
+	if err != nil {
+		err = notFound(err, "job not found")
+		logs.Logger.Println("Error updating job:", err)
+		tx.Rollback()
+		return nil, err
+	}
+	job.ResourceVersion = version
+
 	if err := tx.Debug().Session(&gorm.Session{FullSaveAssociations: true}).Where("id = ?", job.ID).Updates(job).Error; err != nil {
 		logs.Logger.Println("Error updating job:", err)
 		tx.Rollback()
@@ -101,8 +119,8 @@ func (repo *jobRepository) UpdateJob(job *models.Job) (*models.Job, error) {
 	return job, nil
 }
 
-// DeleteJob deletes a job from the database
-func (repo *jobRepository) DeleteJob(id string) (int64, error) {
+// DeleteJob deletes a job from the database, a non zero version must still be the stored one
+func (repo *jobRepository) DeleteJob(id string, version int64) (int64, error) {
 	tx := repo.db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -117,16 +135,16 @@ func (repo *jobRepository) DeleteJob(id string) (int64, error) {
 	}
 
 	// Delete job
-	result := tx.Debug().Where("id = ?", id).Delete(&models.Job{})
-	if result.Error != nil {
+	rowsAffected, err := deleteVersioned(tx, &models.Job{}, id, version)
+	if err != nil {
 		tx.Rollback()
-		return 0, result.Error
+		return 0, err
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
-	return result.RowsAffected, nil
+	return rowsAffected, nil
 }
 
 // FindJobByUUID finds a job by its UUID
@@ -151,7 +169,7 @@ func (repo *jobRepository) FindJobByResourceUUID(uid string) (*models.Job, error
 		Joins("JOIN resources ON resources.job_id = jobs.id").
 		Where("resources.resource_uid = ?", uid).
 		Preload("Targets").
-		Preload("Resource").
+		Preload("Resource.Conditions").
 		First(&job).Error
 
 	if err != nil {
@@ -187,10 +205,15 @@ func (repo *jobRepository) FindJobsByState(state int) (*[]models.Job, error) {
 func (repo *jobRepository) FindJobsToExecute(orchestratorType, ownerID string) (*[]models.Job, error) {
 	var jobs []models.Job
 	err := repo.db.Debug().Preload(clause.Associations).Preload("Targets").Preload("Resource").
+		// (6) undeployments aside, the jobs of job groups pending approval or rejected are held back
+		Where("type = ? OR NOT EXISTS (SELECT 1 FROM job_groups WHERE job_groups.id = jobs.job_group_id AND job_groups.approval_state IN ?)",
+			models.DeleteDeployment, []string{models.ApprovalPending, models.ApprovalRejected}).
+		Order("priority DESC, created_at, id").
 		Find(&jobs,
 			"((type = ?) AND state = ? AND (owner_id = '' OR owner_id IS NULL) AND orchestrator = ?) OR "+
 				"((type = ?) AND (state = ? OR state = ?) AND owner_id != ? AND updated_at < ? AND orchestrator = ?) OR "+
 				"(type = ? AND state = ? AND owner_id = ? AND orchestrator = ?) OR "+
+				"(type = ? AND state = ? AND owner_id = ? AND orchestrator = ?) OR "+
 				"(type = ? AND state = ? AND owner_id = ? AND orchestrator = ?)",
 			// (1) CreateDeployment, JobCreated, owner_id = nil
 			models.CreateDeployment, int(models.JobCreated), orchestratorType,
@@ -199,14 +222,111 @@ func (repo *jobRepository) FindJobsToExecute(orchestratorType, ownerID string) (
 			// (3) UpdateDeployment, JobCreated, owner_id = ownerID
 			models.UpdateDeployment, int(models.JobCreated), ownerID, orchestratorType,
 			// (4) DeleteDeployment, JobCreated, owner_id = ownerID
-			models.DeleteDeployment, int(models.JobCreated), ownerID, orchestratorType).Error
+			models.DeleteDeployment, int(models.JobCreated), ownerID, orchestratorType,
+			// (5) ReplaceDeployment, JobCreated, owner_id = ownerID
+			models.ReplaceDeployment, int(models.JobCreated), ownerID, orchestratorType).Error
+	if err != nil {
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// FindJobsByJobGroup retrieves the jobs of a job group with the conditions of their resources
+func (repo *jobRepository) FindJobsByJobGroup(jobGroupID string) (*[]models.Job, error) {
+	var jobs []models.Job
+	err := repo.db.Debug().Where("job_group_id = ?", jobGroupID).Preload("Resource.Conditions").Find(&jobs).Error
 	if err != nil {
 		return nil, err
 	}
 	return &jobs, nil
 }
 
-// JobLocker updates the lock state of a job
+// FindJobGroupRollout retrieves a job group with the state of its rollout, its jobs and the conditions of their
+// resources, in creation order
+func (repo *jobRepository) FindJobGroupRollout(jobGroupID string) (*models.JobGroup, error) {
+	jobGroup := models.JobGroup{}
+	err := repo.db.Debug().
+		Preload("Jobs", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		Preload("Jobs.Resource.Conditions").
+		Where("id = ?", jobGroupID).
+		First(&jobGroup).Error
+	if err != nil {
+		return nil, err
+	}
+	return &jobGroup, nil
+}
+
+// FindDegradedUpdates retrieves the jobs updating or replacing a deployment of a job group, taken by an agent,
+// whose resource is degraded, with the conditions of their resources. Remediations and the jobs of job groups
+// whose rollout already failed are left out.
+func (repo *jobRepository) FindDegradedUpdates() (*[]models.Job, error) {
+	var jobs []models.Job
+	degraded := repo.db.Model(&models.Resource{}).Select("resources.job_id").
+		Joins("JOIN conditions ON conditions.resource_id = resources.id").
+		Where("conditions.type = ? AND conditions.status = ?", models.Degraded, models.ConditionTrue)
+	failed := repo.db.Model(&models.JobGroup{}).Select("id").Where("rollout_status_state = ?", models.RolloutFailed)
+	err := repo.db.Debug().Preload("Resource.Conditions").
+		Where("type IN ? AND state <> ? AND job_group_id <> '' AND (sub_type = '' OR sub_type IS NULL)",
+			[]models.JobType{models.UpdateDeployment, models.ReplaceDeployment}, int(models.JobCreated)).
+		Where("id IN (?) AND job_group_id NOT IN (?)", degraded, failed).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	return &jobs, nil
+}
+
+// HaltRollout halts the rollout of a job group, as a status it leaves its resource version unchanged
+func (repo *jobRepository) HaltRollout(jobGroupID, message string) error {
+	return repo.db.Debug().Model(&models.JobGroup{}).Where("id = ?", jobGroupID).UpdateColumns(map[string]interface{}{
+		"rollout_status_state":   models.RolloutHalted,
+		"rollout_status_message": message,
+	}).Error
+}
+
+// FindJobGroupTenants returns the tenants owning the job groups, by job group ID
+func (repo *jobRepository) FindJobGroupTenants(jobGroupIDs []string) (map[string]string, error) {
+	jobGroups := []models.JobGroup{}
+	if err := repo.db.Debug().Select("id", "tenant").Where("id IN ?", jobGroupIDs).Find(&jobGroups).Error; err != nil {
+		return nil, err
+	}
+	tenants := map[string]string{}
+	for _, jobGroup := range jobGroups {
+		tenants[jobGroup.ID] = jobGroup.Tenant
+	}
+	return tenants, nil
+}
+
+// FindJobGroupApprovals returns the approval of the job groups, by job group ID
+func (repo *jobRepository) FindJobGroupApprovals(jobGroupIDs []string) (map[string]models.ApprovalStatus, error) {
+	jobGroups := []models.JobGroup{}
+	if err := repo.db.Debug().Select("id", "approval_state", "approval_requested_by", "approval_reviewed_by", "approval_reviewed_at", "approval_reason").
+		Where("id IN ?", jobGroupIDs).Find(&jobGroups).Error; err != nil {
+		return nil, err
+	}
+	approvals := map[string]models.ApprovalStatus{}
+	for _, jobGroup := range jobGroups {
+		approvals[jobGroup.ID] = jobGroup.Approval
+	}
+	return approvals, nil
+}
+
+// FindJobGroupOverlays returns the overlays of the job groups having some, by job group ID
+func (repo *jobRepository) FindJobGroupOverlays(jobGroupIDs []string) (map[string]models.OverlayList, error) {
+	jobGroups := []models.JobGroup{}
+	if err := repo.db.Debug().Select("id", "overlays").Where("id IN ?", jobGroupIDs).Find(&jobGroups).Error; err != nil {
+		return nil, err
+	}
+	overlays := map[string]models.OverlayList{}
+	for _, jobGroup := range jobGroups {
+		if len(jobGroup.Overlays) > 0 {
+			overlays[jobGroup.ID] = jobGroup.Overlays
+		}
+	}
+	return overlays, nil
+}
+
+// JobPromote updates the owner and state of a job, with the same resource version semantics as UpdateJob
 func (repo *jobRepository) JobPromote(job *models.Job) (*models.Job, error) {
 	tx := repo.db.Begin()
 	defer func() {
@@ -215,9 +335,15 @@ func (repo *jobRepository) JobPromote(job *models.Job) (*models.Job, error) {
 		}
 	}()
 
+	version, err := nextResourceVersion(tx, &models.Job{}, job.ID, job.ResourceVersion)
+	if err != nil {
+		tx.Rollback()
+		return nil, notFound(err, "job not found")
+	}
+
 	log.Println("Setting new TTL for the Job before update: " + job.ID)
-	err := tx.Debug().Model(&models.Job{}).Where("id = ?", job.ID).Updates(
-		models.Job{OwnerID: job.OwnerID, State: job.State}).Error
+	err = tx.Debug().Model(&models.Job{}).Where("id = ?", job.ID).Updates(
+		models.Job{OwnerID: job.OwnerID, State: job.State, ResourceVersion: version}).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, err
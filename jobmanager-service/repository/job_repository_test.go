@@ -22,12 +22,14 @@ package repository
 
 import (
 	"testing"
+	"time"
 
 	"icos/server/jobmanager-service/models"
 	mocks "icos/server/jobmanager-service/repository/mocks"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
@@ -57,13 +59,44 @@ func TestUpdateJob(t *testing.T) {
 	assert.Equal(t, job, result)
 }
 
+func TestUpdateJobResourceVersion(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
+
+	job := &models.Job{State: 1}
+	repo.SaveJob(job)
+	assert.Equal(t, int64(1), job.ResourceVersion)
+
+	// an agent updates the job based on version 1
+	agentUpdate := *job
+	agentUpdate.State = 2
+	result, err := repo.UpdateJob(&agentUpdate)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.ResourceVersion)
+
+	// a user edit based on the same version is rejected
+	userUpdate := *job
+	userUpdate.State = 3
+	_, err = repo.UpdateJob(&userUpdate)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// updates without a version are applied on top of the stored one
+	userUpdate.ResourceVersion = 0
+	result, err = repo.UpdateJob(&userUpdate)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), result.ResourceVersion)
+}
+
 func TestDeleteJob(t *testing.T) {
 	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
 
 	job := &models.Job{State: 1}
 	repo.SaveJob(job)
 
-	rowsAffected, err := repo.DeleteJob(job.ID)
+	// a stale version keeps the job
+	_, err := repo.DeleteJob(job.ID, job.ResourceVersion+1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	rowsAffected, err := repo.DeleteJob(job.ID, job.ResourceVersion)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), rowsAffected)
 }
@@ -133,6 +166,219 @@ func TestFindJobsToExecute(t *testing.T) {
 	assert.Len(t, *result, 2)
 }
 
+func TestFindJobsToExecuteReplacement(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
+
+	// a replaced deployment is only offered to the agent owning it
+	owner := uuid.New().String()
+	replaced := &models.Job{Type: models.ReplaceDeployment, State: models.JobCreated, OwnerID: owner, Orchestrator: "ocm"}
+	_, err := repo.SaveJob(replaced)
+	require.NoError(t, err)
+
+	result, err := repo.FindJobsToExecute("ocm", owner)
+	assert.NoError(t, err)
+	require.Len(t, *result, 1)
+	assert.Equal(t, replaced.ID, (*result)[0].ID)
+
+	for _, ownerID := range []string{uuid.New().String(), ""} {
+		result, err = repo.FindJobsToExecute("ocm", ownerID)
+		assert.NoError(t, err)
+		assert.Empty(t, *result)
+	}
+}
+
+func TestFindJobsToExecuteByPriority(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
+
+	older := &models.Job{Type: models.CreateDeployment, State: models.JobCreated, Orchestrator: "ocm"}
+	newer := &models.Job{Type: models.CreateDeployment, State: models.JobCreated, Orchestrator: "ocm", Priority: 10}
+	repo.SaveJob(older)
+	repo.SaveJob(newer)
+
+	result, err := repo.FindJobsToExecute("ocm", "")
+	assert.NoError(t, err)
+	assert.Len(t, *result, 2)
+	assert.Equal(t, newer.ID, (*result)[0].ID)
+	assert.Equal(t, older.ID, (*result)[1].ID)
+}
+
+func TestFindJobGroupTenants(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	teamA := models.JobGroup{Tenant: "team-a"}
+	teamB := models.JobGroup{Tenant: "team-b"}
+	groups.SaveJobGroup(&teamA)
+	groups.SaveJobGroup(&teamB)
+
+	tenants, err := repo.FindJobGroupTenants([]string{teamA.ID, teamB.ID, uuid.New().String()})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{teamA.ID: "team-a", teamB.ID: "team-b"}, tenants)
+}
+
+func TestFindJobGroupOverlays(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	overlays := models.OverlayList{{Name: "edge", Targets: models.OverlayTargets{Cluster: "edge-*"}, Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}}
+	overlaid := models.JobGroup{Overlays: overlays}
+	plain := models.JobGroup{}
+	groups.SaveJobGroup(&overlaid)
+	groups.SaveJobGroup(&plain)
+
+	// only the job groups having overlays are returned
+	result, err := repo.FindJobGroupOverlays([]string{overlaid.ID, plain.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]models.OverlayList{overlaid.ID: overlays}, result)
+}
+
+func TestFindJobGroupApprovals(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	pending := models.JobGroup{Approval: models.ApprovalStatus{State: models.ApprovalPending, RequestedBy: "alice"}}
+	ungated := models.JobGroup{}
+	groups.SaveJobGroup(&pending)
+	groups.SaveJobGroup(&ungated)
+
+	result, err := repo.FindJobGroupApprovals([]string{pending.ID, ungated.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]models.ApprovalStatus{pending.ID: pending.Approval, ungated.ID: {}}, result)
+}
+
+func TestFindJobsToExecuteHoldsPendingApproval(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	pending := models.JobGroup{Approval: models.ApprovalStatus{State: models.ApprovalPending, RequestedBy: "alice"}}
+	approved := models.JobGroup{Approval: models.ApprovalStatus{State: models.ApprovalApproved, RequestedBy: "alice", ReviewedBy: "bob"}}
+	groups.SaveJobGroup(&pending)
+	groups.SaveJobGroup(&approved)
+	held := &models.Job{JobGroupID: pending.ID, Type: models.CreateDeployment, State: models.JobCreated, Orchestrator: "ocm"}
+	released := &models.Job{JobGroupID: approved.ID, Type: models.CreateDeployment, State: models.JobCreated, Orchestrator: "ocm"}
+	repo.SaveJob(held)
+	repo.SaveJob(released)
+
+	result, err := repo.FindJobsToExecute("ocm", "")
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.Equal(t, released.ID, (*result)[0].ID)
+}
+
+func TestFindJobsByJobGroup(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
+
+	groupID := uuid.New().String()
+	job1 := &models.Job{JobGroupID: groupID, DependsOn: models.StringList{"db"}, Resource: &models.Resource{
+		ResourceName: "api",
+		Conditions: []models.Condition{{
+			Type: models.Available, Status: models.ConditionTrue, LastTransitionTime: time.Now(), Reason: "Ready", Message: "ready",
+		}},
+	}}
+	job2 := &models.Job{JobGroupID: uuid.New().String()}
+	repo.SaveJob(job1)
+	repo.SaveJob(job2)
+
+	result, err := repo.FindJobsByJobGroup(groupID)
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.Equal(t, models.StringList{"db"}, (*result)[0].DependsOn)
+	assert.Len(t, (*result)[0].Resource.Conditions, 1)
+}
+
+func TestFindJobGroupRollout(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	jobGroup := models.JobGroup{Rollout: models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 1}}
+	groups.SaveJobGroup(&jobGroup)
+	job1 := &models.Job{JobGroupID: jobGroup.ID, DependsOn: models.StringList{"db"}, Resource: &models.Resource{
+		ResourceName: "api",
+		Conditions: []models.Condition{{
+			Type: models.Available, Status: models.ConditionTrue, LastTransitionTime: time.Now(), Reason: "Ready", Message: "ready",
+		}},
+	}}
+	job2 := &models.Job{JobGroupID: uuid.New().String()}
+	repo.SaveJob(job1)
+	repo.SaveJob(job2)
+
+	result, err := repo.FindJobGroupRollout(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RolloutRolling, result.Rollout.Type)
+	assert.Len(t, result.Jobs, 1)
+	assert.Equal(t, models.StringList{"db"}, result.Jobs[0].DependsOn)
+	assert.Len(t, result.Jobs[0].Resource.Conditions, 1)
+}
+
+func TestFindDegradedUpdates(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	updating := models.JobGroup{}
+	failed := models.JobGroup{RolloutStatus: models.RolloutStatus{State: models.RolloutFailed}}
+	groups.SaveJobGroup(&updating)
+	groups.SaveJobGroup(&failed)
+	resource := func(state models.ResourceState) *models.Resource {
+		return &models.Resource{Conditions: []models.Condition{{
+			Type: state, Status: models.ConditionTrue, LastTransitionTime: time.Now(), Reason: "Reported", Message: "reported",
+		}}}
+	}
+	degraded := &models.Job{JobGroupID: updating.ID, Type: models.ReplaceDeployment, State: models.JobProgressing, Resource: resource(models.Degraded)}
+	jobs := []*models.Job{
+		degraded,
+		{JobGroupID: updating.ID, Type: models.ReplaceDeployment, State: models.JobProgressing, Resource: resource(models.Available)},
+		{JobGroupID: updating.ID, Type: models.UpdateDeployment, State: models.JobCreated, Resource: resource(models.Degraded)},
+		{JobGroupID: updating.ID, Type: models.CreateDeployment, State: models.JobProgressing, Resource: resource(models.Degraded)},
+		{JobGroupID: updating.ID, Type: models.UpdateDeployment, SubType: models.ScaleUp, State: models.JobProgressing, Resource: resource(models.Degraded)},
+		{JobGroupID: failed.ID, Type: models.ReplaceDeployment, State: models.JobProgressing, Resource: resource(models.Degraded)},
+	}
+	for _, job := range jobs {
+		repo.SaveJob(job)
+	}
+
+	result, err := repo.FindDegradedUpdates()
+	assert.NoError(t, err)
+	if assert.Len(t, *result, 1) {
+		assert.Equal(t, degraded.ID, (*result)[0].ID)
+		assert.Len(t, (*result)[0].Resource.Conditions, 1)
+	}
+}
+
+func TestHaltRollout(t *testing.T) {
+	var groups JobGroupRepository
+	repo := mocks.SetupTest(t, func(db *gorm.DB) interface{} {
+		groups = NewJobGroupRepository(db)
+		return NewJobRepository(db)
+	}).(JobRepository)
+
+	jobGroup := models.JobGroup{RolloutStatus: models.RolloutStatus{State: models.RolloutProgressing}}
+	groups.SaveJobGroup(&jobGroup)
+
+	assert.NoError(t, repo.HaltRollout(jobGroup.ID, "job 1 is degraded"))
+	result, err := repo.FindJobGroupRollout(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.RolloutStatus{State: models.RolloutHalted, Message: "job 1 is degraded"}, result.RolloutStatus)
+	assert.Equal(t, jobGroup.ResourceVersion, result.ResourceVersion)
+}
+
 func TestJobPromote(t *testing.T) {
 	repo := mocks.SetupTest(t, initJobRepo).(JobRepository)
 
@@ -21,8 +21,12 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/utils/logs"
+	"slices"
+	"sort"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -32,11 +36,18 @@ import (
 type JobGroupRepository interface {
 	SaveJobGroup(*models.JobGroup) (*models.JobGroup, error)
 	UpdateJobGroup(*models.JobGroup) (*models.JobGroup, error)
-	DeleteJobGroup(string) (int64, error)
+	DeleteJobGroup(id string, version int64) (int64, error)
 	FindJobGroupByUUID(string) (*models.JobGroup, error)
 	FindAllJobGroups() (*[]models.JobGroup, error)
+	FindNamespaceTenants(namespace string) ([]string, error)
+	SaveJobGroupRevision(*models.JobGroupRevision) (*models.JobGroupRevision, error)
+	FindJobGroupRevisions(jobGroupID string) (*[]models.JobGroupRevision, error)
+	FindJobGroupRevision(jobGroupID string, revision int64) (*models.JobGroupRevision, error)
 }
 
+// ErrNamespaceConflict is returned when a job group deploys to a namespace owned by another tenant
+var ErrNamespaceConflict = errors.New("namespace is used by another tenant")
+
 // jobGroupRepository is the implementation of JobGroupRepository
 type jobGroupRepository struct {
 	db *gorm.DB
@@ -59,6 +70,10 @@ func (repo *jobGroupRepository) SaveJobGroup(jg *models.JobGroup) (*models.JobGr
 	}()
 
 	//jg.BeforeCreate(tx)
+	if err := claimNamespaces(tx, jg, nil); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	if err := tx.Debug().Create(&jg).Error; err != nil {
 		tx.Rollback()
 		return nil, err
@@ -70,20 +85,107 @@ func (repo *jobGroupRepository) SaveJobGroup(jg *models.JobGroup) (*models.JobGr
 	return jg, nil
 }
 
+// UpdateJobGroup updates a job group and its jobs. As for jobs, the resource versions of the job group
+// and of each of its jobs are the ones the update is based on, a zero version skips the check.
+// The manifests of a job are replaced by the given ones, unless they are left out.
 func (repo *jobGroupRepository) UpdateJobGroup(jg *models.JobGroup) (*models.JobGroup, error) {
 
-	tx := repo.db.Begin().Model(&jg).Where("id = ?", jg.ID)
+	tx := repo.db.Begin()
 
 	if tx.Error != nil {
 		logs.Logger.Println("Error starting transaction:", tx.Error)
 		return nil, tx.Error
 	}
 
-	if err := tx.Debug().Session(&gorm.Session{FullSaveAssociations: true}).Updates(&jg).Error; err != nil {
+	version, err := nextResourceVersion(tx, &models.JobGroup{}, jg.ID, jg.ResourceVersion)
+	if err != nil {
 		logs.Logger.Println("Error saving job group:", err)
 		tx.Rollback()
 		return nil, err
 	}
+	jg.ResourceVersion = version
+
+	deployed := []string{}
+	if err := tx.Model(&models.Job{}).Where("job_group_id = ?", jg.ID).Distinct().Pluck("namespace", &deployed).Error; err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+	if err := claimNamespaces(tx, jg, deployed); err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	for i := range jg.Jobs {
+		job := &jg.Jobs[i]
+		if job.ID == "" {
+			continue
+		}
+		version, err := nextResourceVersion(tx, &models.Job{}, job.ID, job.ResourceVersion)
+		if err != nil {
+			logs.Logger.Println("Error saving job group:", err)
+			tx.Rollback()
+			return nil, notFound(err, "job not found")
+		}
+		job.ResourceVersion = version
+	}
+
+	if err := tx.Debug().Model(&jg).Where("id = ?", jg.ID).Session(&gorm.Session{FullSaveAssociations: true}).Updates(&jg).Error; err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	// the rollout, the approval, the priority, the parameters and the overlays are written as a whole, zero values
+	// included, so that a rollback clears what its revision did not have
+	parameters, err := jg.Parameters.Sealed()
+	if err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+	overlays, err := jg.Overlays.Sealed()
+	if err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Debug().Model(&models.JobGroup{}).Where("id = ?", jg.ID).UpdateColumns(map[string]interface{}{
+		"rollout_type":            jg.Rollout.Type,
+		"rollout_max_unavailable": jg.Rollout.MaxUnavailable,
+		"rollout_canary_percent":  jg.Rollout.CanaryPercent,
+		"rollout_status_state":    jg.RolloutStatus.State,
+		"rollout_status_message":  jg.RolloutStatus.Message,
+		"rollout_status_resumed":  jg.RolloutStatus.Resumed,
+		"approval_state":          jg.Approval.State,
+		"approval_requested_by":   jg.Approval.RequestedBy,
+		"approval_reviewed_by":    jg.Approval.ReviewedBy,
+		"approval_reviewed_at":    jg.Approval.ReviewedAt,
+		"approval_reason":         jg.Approval.Reason,
+		"priority":                jg.Priority,
+		"parameters":              parameters,
+		"overlays":                overlays,
+	}).Error; err != nil {
+		logs.Logger.Println("Error saving job group:", err)
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, job := range jg.Jobs {
+		if job.ID == "" || job.Manifests == nil {
+			continue
+		}
+		ids := []uint32{}
+		for _, manifest := range job.Manifests {
+			ids = append(ids, manifest.ID)
+		}
+		if err := tx.Debug().Where("job_id = ? AND id NOT IN ?", job.ID, ids).Delete(&models.PlainManifest{}).Error; err != nil {
+			logs.Logger.Println("Error saving job group:", err)
+			tx.Rollback()
+			return nil, err
+		}
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		logs.Logger.Println("Error committing transaction:", err)
@@ -94,8 +196,8 @@ func (repo *jobGroupRepository) UpdateJobGroup(jg *models.JobGroup) (*models.Job
 	return jg, nil
 }
 
-// DeleteJobGroup deletes a job group from the database
-func (repo *jobGroupRepository) DeleteJobGroup(id string) (int64, error) {
+// DeleteJobGroup deletes a job group from the database, a non zero version must still be the stored one
+func (repo *jobGroupRepository) DeleteJobGroup(id string, version int64) (int64, error) {
 	tx := repo.db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
@@ -103,16 +205,69 @@ func (repo *jobGroupRepository) DeleteJobGroup(id string) (int64, error) {
 		}
 	}()
 
-	result := tx.Debug().Where("id = ?", id).Delete(&models.JobGroup{})
-	if result.Error != nil {
+	if err := tx.Debug().Where("job_group_id = ?", id).Delete(&models.JobGroupRevision{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Debug().Where("job_group_id = ?", id).Delete(&models.Schedule{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	namespaces := []string{}
+	if err := tx.Model(&models.Job{}).Where("job_group_id = ? AND namespace <> ''", id).Distinct().Pluck("namespace", &namespaces).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	rowsAffected, err := deleteVersioned(tx, &models.JobGroup{}, id, version)
+	if err != nil {
 		tx.Rollback()
-		return 0, result.Error
+		return 0, err
+	}
+
+	// the namespaces the other job groups no longer deploy to are released
+	if len(namespaces) > 0 {
+		if err := tx.Debug().Where("namespace IN ? AND namespace NOT IN (?)", namespaces,
+			tx.Model(&models.Job{}).Select("jobs.namespace").
+				Joins("JOIN job_groups ON job_groups.id = jobs.job_group_id").Where("jobs.job_group_id <> ?", id)).
+			Delete(&models.NamespaceOwner{}).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
-	return result.RowsAffected, nil
+	return rowsAffected, nil
+}
+
+// claimNamespaces makes the tenant of the job group the owner of the namespaces of its jobs, the first one to
+// deploy to a namespace owns it. The namespaces owned by another tenant are an ErrNamespaceConflict, except the
+// ones the job group already deploys to.
+func claimNamespaces(tx *gorm.DB, jg *models.JobGroup, deployed []string) error {
+	namespaces := []string{}
+	for _, job := range jg.Jobs {
+		if job.Namespace != "" && !slices.Contains(namespaces, job.Namespace) && !slices.Contains(deployed, job.Namespace) {
+			namespaces = append(namespaces, job.Namespace)
+		}
+	}
+	// claimed in order, so that concurrent claims do not deadlock
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		if err := tx.Debug().Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.NamespaceOwner{Namespace: namespace, Tenant: jg.Tenant}).Error; err != nil {
+			return err
+		}
+		owner := models.NamespaceOwner{}
+		if err := tx.Where("namespace = ?", namespace).First(&owner).Error; err != nil {
+			return err
+		}
+		if owner.Tenant != jg.Tenant {
+			return fmt.Errorf("%w: %s", ErrNamespaceConflict, namespace)
+		}
+	}
+	return nil
 }
 
 // FindJobGroupByUUID finds a job group by its UUID
@@ -141,3 +296,58 @@ func (repo *jobGroupRepository) FindAllJobGroups() (*[]models.JobGroup, error) {
 	}
 	return &jobGroups, err
 }
+
+// FindNamespaceTenants returns the tenants owning the job groups with jobs in the namespace
+func (repo *jobGroupRepository) FindNamespaceTenants(namespace string) ([]string, error) {
+	tenants := []string{}
+	err := repo.db.Model(&models.JobGroup{}).
+		Distinct("job_groups.tenant").
+		Joins("JOIN jobs ON jobs.job_group_id = job_groups.id").
+		Where("jobs.namespace = ?", namespace).
+		Pluck("job_groups.tenant", &tenants).Error
+	return tenants, err
+}
+
+// SaveJobGroupRevision saves a revision of a job group, numbered after the last one. The job group is locked
+// meanwhile, so that concurrent revisions are numbered one after the other.
+func (repo *jobGroupRepository) SaveJobGroupRevision(revision *models.JobGroupRevision) (*models.JobGroupRevision, error) {
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id = ?", revision.JobGroupID).First(&models.JobGroup{}).Error; err != nil {
+			return err
+		}
+		var last int64
+		if err := tx.Model(&models.JobGroupRevision{}).
+			Where("job_group_id = ?", revision.JobGroupID).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&last).Error; err != nil {
+			return err
+		}
+		revision.Revision = last + 1
+		return tx.Debug().Create(revision).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// FindJobGroupRevisions returns the revisions of a job group, the last one first
+func (repo *jobGroupRepository) FindJobGroupRevisions(jobGroupID string) (*[]models.JobGroupRevision, error) {
+	revisions := []models.JobGroupRevision{}
+	err := repo.db.Where("job_group_id = ?", jobGroupID).Order("revision DESC").Find(&revisions).Error
+	if err != nil {
+		return nil, err
+	}
+	return &revisions, nil
+}
+
+// FindJobGroupRevision finds a revision of a job group by its number
+func (repo *jobGroupRepository) FindJobGroupRevision(jobGroupID string, revision int64) (*models.JobGroupRevision, error) {
+	jobGroupRevision := models.JobGroupRevision{}
+	err := repo.db.Where("job_group_id = ? AND revision = ?", jobGroupID, revision).First(&jobGroupRevision).Error
+	if err != nil {
+		return nil, err
+	}
+	return &jobGroupRevision, nil
+}
@@ -21,10 +21,14 @@
 package repository
 
 import (
+	"bytes"
 	"icos/server/jobmanager-service/models"
 	mocks "icos/server/jobmanager-service/repository/mocks"
+	"icos/server/jobmanager-service/utils/secrets"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"gorm.io/gorm"
 )
@@ -57,13 +61,95 @@ func TestUpdateJobGroup(t *testing.T) {
 	assert.Equal(t, "Updated Description", result.AppDescription)
 }
 
+func TestUpdateJobGroupClearsSubType(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	jobGroup := models.JobGroup{Jobs: []models.Job{{Type: models.UpdateDeployment, SubType: models.ScaleUp, State: models.JobFinished}}}
+	repo.SaveJobGroup(&jobGroup)
+
+	// the job is deployed again once its remediation is over
+	jobGroup.Jobs[0].Type = models.ReplaceDeployment
+	jobGroup.Jobs[0].SubType = ""
+	jobGroup.Jobs[0].State = models.JobCreated
+	_, err := repo.UpdateJobGroup(&jobGroup)
+	assert.NoError(t, err)
+
+	result, err := repo.FindJobGroupByUUID(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReplaceDeployment, result.Jobs[0].Type)
+	assert.Empty(t, result.Jobs[0].SubType)
+}
+
+func TestUpdateJobGroupClearsOverlaysAndParameters(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	sealer, err := secrets.NewSealer(bytes.Repeat([]byte("k"), 32))
+	assert.NoError(t, err)
+	secrets.UseSealer(sealer)
+	t.Cleanup(func() { secrets.UseSealer(nil) })
+
+	jobGroup := models.JobGroup{
+		Parameters: models.StringMap{"password": "s3cr3t"},
+		Overlays:   models.OverlayList{{Name: "edge", Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}},
+	}
+	repo.SaveJobGroup(&jobGroup)
+
+	// parameters written along with the rollout are sealed as well
+	jobGroup.Parameters = models.StringMap{"password": "n3w"}
+	_, err = repo.UpdateJobGroup(&jobGroup)
+	assert.NoError(t, err)
+	var stored string
+	repo.(*jobGroupRepository).db.Table("job_groups").Select("parameters").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "n3w")
+
+	// a job group brought back to a state without overlays nor parameters has none left
+	jobGroup.Parameters, jobGroup.Overlays = nil, nil
+	_, err = repo.UpdateJobGroup(&jobGroup)
+	assert.NoError(t, err)
+
+	result, err := repo.FindJobGroupByUUID(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, result.Parameters)
+	assert.Empty(t, result.Overlays)
+}
+
+func TestUpdateJobGroupResourceVersion(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	jobGroup := models.JobGroup{Jobs: []models.Job{{State: models.JobCreated}}}
+	repo.SaveJobGroup(&jobGroup)
+
+	stale, _ := repo.FindJobGroupByUUID(jobGroup.ID)
+
+	jobGroup.AppDescription = "Updated Description"
+	result, err := repo.UpdateJobGroup(&jobGroup)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.ResourceVersion)
+	assert.Equal(t, int64(2), result.Jobs[0].ResourceVersion)
+
+	stale.AppDescription = "Stale Description"
+	_, err = repo.UpdateJobGroup(stale)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// a job updated on its own since the job group was read is a conflict as well
+	stale, _ = repo.FindJobGroupByUUID(jobGroup.ID)
+	stale.Jobs[0].ResourceVersion = 1
+	_, err = repo.UpdateJobGroup(stale)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
+
 func TestDeleteJobGroup(t *testing.T) {
 	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
 
 	jobGroup := models.JobGroup{}
 	repo.SaveJobGroup(&jobGroup)
 
-	rowsAffected, err := repo.DeleteJobGroup(jobGroup.ID)
+	// a stale version keeps the job group
+	_, err := repo.DeleteJobGroup(jobGroup.ID, jobGroup.ResourceVersion+1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	rowsAffected, err := repo.DeleteJobGroup(jobGroup.ID, jobGroup.ResourceVersion)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), rowsAffected)
 }
@@ -79,6 +165,30 @@ func TestFindJobGroupByUUID(t *testing.T) {
 	assert.Equal(t, jobGroup.ID, result.ID)
 }
 
+func TestFindJobGroupByUUIDTextColumns(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	// text columns may be read back as strings as well as bytes
+	jobGroup := models.JobGroup{
+		Labels: models.StringMap{"team": "edge"},
+		Jobs:   []models.Job{{DependsOn: models.StringList{"database"}}},
+	}
+	_, err := repo.SaveJobGroup(&jobGroup)
+	assert.NoError(t, err)
+
+	result, err := repo.FindJobGroupByUUID(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.StringMap{"team": "edge"}, result.Labels)
+	assert.Equal(t, models.StringList{"database"}, result.Jobs[0].DependsOn)
+
+	var labels models.StringMap
+	assert.NoError(t, labels.Scan(`{"team":"edge"}`))
+	assert.Equal(t, models.StringMap{"team": "edge"}, labels)
+	var dependsOn models.StringList
+	assert.NoError(t, dependsOn.Scan(`["database"]`))
+	assert.Equal(t, models.StringList{"database"}, dependsOn)
+}
+
 func TestFindAllJobGroups(t *testing.T) {
 	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
 
@@ -91,3 +201,187 @@ func TestFindAllJobGroups(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, *result, 2)
 }
+
+func TestFindNamespaceTenants(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	// job groups of several tenants shared a namespace before namespaces were claimed
+	for _, jobGroup := range []models.JobGroup{
+		{Tenant: "team-a", Jobs: []models.Job{{Namespace: "shop"}, {Namespace: "shop"}}},
+		{Tenant: "team-b", Jobs: []models.Job{{Namespace: "shop"}}},
+		{Tenant: "team-c", Jobs: []models.Job{{Namespace: "blog"}}},
+	} {
+		assert.NoError(t, repo.(*jobGroupRepository).db.Create(&jobGroup).Error)
+	}
+
+	tenants, err := repo.FindNamespaceTenants("shop")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"team-a", "team-b"}, tenants)
+
+	tenants, err = repo.FindNamespaceTenants("wiki")
+	assert.NoError(t, err)
+	assert.Empty(t, tenants)
+}
+
+func TestSaveJobGroupClaimsNamespace(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	shop := models.JobGroup{Tenant: "team-a", Jobs: []models.Job{{Namespace: "shop"}, {Namespace: "shop"}}}
+	_, err := repo.SaveJobGroup(&shop)
+	assert.NoError(t, err)
+
+	// the namespace belongs to the first tenant, job groups without tenant included
+	for _, tenant := range []string{"team-b", ""} {
+		other := models.JobGroup{Tenant: tenant, Jobs: []models.Job{{Namespace: "shop"}}}
+		_, err = repo.SaveJobGroup(&other)
+		assert.ErrorIs(t, err, ErrNamespaceConflict)
+	}
+	jobGroups, err := repo.FindAllJobGroups()
+	assert.NoError(t, err)
+	assert.Len(t, *jobGroups, 1)
+
+	cart := models.JobGroup{Tenant: "team-a", Jobs: []models.Job{{Namespace: "shop"}}}
+	_, err = repo.SaveJobGroup(&cart)
+	assert.NoError(t, err)
+
+	// an update cannot move the jobs to a namespace of another tenant
+	blog := models.JobGroup{Tenant: "team-b", Jobs: []models.Job{{Namespace: "blog"}}}
+	_, err = repo.SaveJobGroup(&blog)
+	assert.NoError(t, err)
+	cart.Jobs[0].Namespace = "blog"
+	_, err = repo.UpdateJobGroup(&cart)
+	assert.ErrorIs(t, err, ErrNamespaceConflict)
+
+	// the namespace is released with the last job group deploying to it
+	_, err = repo.DeleteJobGroup(shop.ID, 0)
+	assert.NoError(t, err)
+	other := models.JobGroup{Tenant: "team-b", Jobs: []models.Job{{Namespace: "shop"}}}
+	_, err = repo.SaveJobGroup(&other)
+	assert.ErrorIs(t, err, ErrNamespaceConflict)
+
+	_, err = repo.DeleteJobGroup(cart.ID, 0)
+	assert.NoError(t, err)
+	_, err = repo.SaveJobGroup(&other)
+	assert.NoError(t, err)
+}
+
+func TestSaveJobGroupSealsSecrets(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	sealer, err := secrets.NewSealer(bytes.Repeat([]byte("k"), 32))
+	assert.NoError(t, err)
+	secrets.UseSealer(sealer)
+	t.Cleanup(func() { secrets.UseSealer(nil) })
+
+	secret := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\nstringData:\n  password: s3cr3t\n"
+	overlay := "kind: Secret\nstringData:\n  password: s3cr3t\n"
+	jobGroup := models.JobGroup{
+		Parameters: models.StringMap{"password": "s3cr3t"},
+		Overlays:   models.OverlayList{{Name: "edge", Patches: []models.OverlayPatch{{Patch: overlay}}}},
+		Jobs:       []models.Job{{Manifests: []models.PlainManifest{{YamlString: secret}}}},
+	}
+	_, err = repo.SaveJobGroup(&jobGroup)
+	assert.NoError(t, err)
+	assert.Contains(t, jobGroup.Jobs[0].Manifests[0].YamlString, "s3cr3t")
+	assert.Equal(t, "s3cr3t", jobGroup.Parameters["password"])
+
+	// the password is only stored sealed
+	var stored string
+	repo.(*jobGroupRepository).db.Table("plain_manifests").Select("yaml_string").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+	repo.(*jobGroupRepository).db.Table("job_groups").Select("parameters").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+	repo.(*jobGroupRepository).db.Table("job_groups").Select("overlays").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+
+	result, err := repo.FindJobGroupByUUID(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, result.Jobs[0].Manifests[0].YamlString, "password: s3cr3t")
+	assert.Equal(t, models.StringMap{"password": "s3cr3t"}, result.Parameters)
+	assert.Equal(t, overlay, result.Overlays[0].Patches[0].Patch)
+
+	// overlays written along with the rollout are sealed as well
+	_, err = repo.UpdateJobGroup(result)
+	assert.NoError(t, err)
+	repo.(*jobGroupRepository).db.Table("job_groups").Select("overlays").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+
+	// so is the descriptor of its revisions, which holds the manifests
+	revision := models.JobGroupRevision{JobGroupID: jobGroup.ID, Descriptor: "name: db\nmanifests:\n- " + strings.ReplaceAll(secret, "\n", "\n  "),
+		Overlays: jobGroup.Overlays}
+	_, err = repo.SaveJobGroupRevision(&revision)
+	assert.NoError(t, err)
+	repo.(*jobGroupRepository).db.Table("job_group_revisions").Select("descriptor").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+	repo.(*jobGroupRepository).db.Table("job_group_revisions").Select("overlays").Scan(&stored)
+	assert.Contains(t, stored, "ENC[v1,")
+	assert.NotContains(t, stored, "s3cr3t")
+	found, err := repo.FindJobGroupRevision(jobGroup.ID, revision.Revision)
+	assert.NoError(t, err)
+	assert.Contains(t, found.Descriptor, "password: s3cr3t")
+	assert.Equal(t, overlay, found.Overlays[0].Patches[0].Patch)
+}
+
+func TestJobGroupRevisions(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	jobGroup := models.JobGroup{}
+	repo.SaveJobGroup(&jobGroup)
+
+	for _, author := range []string{"alice", "bob"} {
+		revision := models.JobGroupRevision{
+			JobGroupID: jobGroup.ID,
+			Author:     author,
+			Jobs:       models.RevisionJobs{{JobID: "job-1", Manifests: []string{"kind: ConfigMap\n"}}},
+		}
+		_, err := repo.SaveJobGroupRevision(&revision)
+		assert.NoError(t, err)
+	}
+
+	revisions, err := repo.FindJobGroupRevisions(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Len(t, *revisions, 2)
+	assert.Equal(t, int64(2), (*revisions)[0].Revision)
+	assert.Equal(t, "bob", (*revisions)[0].Author)
+
+	revision, err := repo.FindJobGroupRevision(jobGroup.ID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", revision.Author)
+	assert.Equal(t, []string{"kind: ConfigMap\n"}, revision.Jobs[0].Manifests)
+
+	_, err = repo.FindJobGroupRevision(jobGroup.ID, 3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// revisions are numbered under the lock of their job group
+	_, err = repo.SaveJobGroupRevision(&models.JobGroupRevision{JobGroupID: uuid.New().String()})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// revisions go away with their job group
+	repo.DeleteJobGroup(jobGroup.ID, 0)
+	revisions, err = repo.FindJobGroupRevisions(jobGroup.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, *revisions)
+}
+
+func TestUpdateJobGroupReplacesManifests(t *testing.T) {
+	repo := mocks.SetupTest(t, initJobGroupRepo).(JobGroupRepository)
+
+	jobGroup := models.JobGroup{Jobs: []models.Job{{Manifests: []models.PlainManifest{
+		{YamlString: "kind: ConfigMap\n"},
+		{YamlString: "kind: Service\n"},
+	}}}}
+	repo.SaveJobGroup(&jobGroup)
+
+	jobGroup.Jobs[0].Manifests = []models.PlainManifest{{YamlString: "kind: Deployment\n"}}
+	_, err := repo.UpdateJobGroup(&jobGroup)
+	assert.NoError(t, err)
+
+	result, _ := repo.FindJobGroupByUUID(jobGroup.ID)
+	assert.Len(t, result.Jobs[0].Manifests, 1)
+	assert.Equal(t, "kind: Deployment\n", result.Jobs[0].Manifests[0].YamlString)
+}
@@ -0,0 +1,81 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+
+	"gorm.io/gorm"
+)
+
+// MaintenanceWindowRepository interface defines the methods for the maintenance windows of clusters
+type MaintenanceWindowRepository interface {
+	SaveMaintenanceWindow(*models.MaintenanceWindow) (*models.MaintenanceWindow, error)
+	FindMaintenanceWindows(cluster string) (*[]models.MaintenanceWindow, error)
+	FindMaintenanceWindow(id string) (*models.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(id string) (int64, error)
+}
+
+// maintenanceWindowRepository is the implementation of MaintenanceWindowRepository
+type maintenanceWindowRepository struct {
+	db *gorm.DB
+}
+
+// NewMaintenanceWindowRepository returns a new instance of maintenanceWindowRepository
+func NewMaintenanceWindowRepository(db *gorm.DB) MaintenanceWindowRepository {
+	return &maintenanceWindowRepository{db: db}
+}
+
+// SaveMaintenanceWindow saves a new maintenance window
+func (repo *maintenanceWindowRepository) SaveMaintenanceWindow(window *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	if err := repo.db.Debug().Create(window).Error; err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// FindMaintenanceWindows returns the maintenance windows of a cluster, of every cluster when none is given
+func (repo *maintenanceWindowRepository) FindMaintenanceWindows(cluster string) (*[]models.MaintenanceWindow, error) {
+	windows := []models.MaintenanceWindow{}
+	query := repo.db.Order("cluster, created_at")
+	if cluster != "" {
+		query = query.Where("cluster = ?", cluster)
+	}
+	if err := query.Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	return &windows, nil
+}
+
+// FindMaintenanceWindow finds a maintenance window by its UUID
+func (repo *maintenanceWindowRepository) FindMaintenanceWindow(id string) (*models.MaintenanceWindow, error) {
+	window := models.MaintenanceWindow{}
+	if err := repo.db.Debug().Where("id = ?", id).First(&window).Error; err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window
+func (repo *maintenanceWindowRepository) DeleteMaintenanceWindow(id string) (int64, error) {
+	result := repo.db.Debug().Where("id = ?", id).Delete(&models.MaintenanceWindow{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,68 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"icos/server/jobmanager-service/models"
+	mocks "icos/server/jobmanager-service/repository/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func initMaintenanceWindowRepo(db *gorm.DB) interface{} {
+	return NewMaintenanceWindowRepository(db)
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	repo := mocks.SetupTest(t, initMaintenanceWindowRepo).(MaintenanceWindowRepository)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	end := start.Add(2 * time.Hour)
+	oneOff := &models.MaintenanceWindow{Cluster: "edge-1", Start: &start, End: &end}
+	recurring := &models.MaintenanceWindow{Cluster: "edge-2", Cron: "0 2 * * 6", Duration: "4h", Timezone: "Europe/Madrid"}
+	_, err := repo.SaveMaintenanceWindow(oneOff)
+	require.NoError(t, err)
+	_, err = repo.SaveMaintenanceWindow(recurring)
+	require.NoError(t, err)
+
+	windows, err := repo.FindMaintenanceWindows("")
+	require.NoError(t, err)
+	assert.Len(t, *windows, 2)
+	windows, err = repo.FindMaintenanceWindows("edge-2")
+	require.NoError(t, err)
+	require.Len(t, *windows, 1)
+	assert.Equal(t, recurring.ID, (*windows)[0].ID)
+
+	stored, err := repo.FindMaintenanceWindow(oneOff.ID)
+	require.NoError(t, err)
+	assert.True(t, end.Equal(*stored.End))
+
+	rows, err := repo.DeleteMaintenanceWindow(oneOff.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows)
+	_, err = repo.FindMaintenanceWindow(oneOff.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -43,13 +43,20 @@ func setupTestDB(t *testing.T) (*gorm.DB, func()) {
 
 	// Migrate the schema
 	err = db.AutoMigrate(&models.JobGroup{},
+		&models.JobGroupRevision{},
 		&models.Job{},
 		&models.PlainManifest{},
 		&models.Target{},
 		&models.Resource{},
 		&models.Condition{},
 		&models.Incompliance{},
-		&models.Subject{})
+		&models.Subject{},
+		&models.ServiceAccount{},
+		&models.Quota{},
+		&models.IdempotencyKey{},
+		&models.Schedule{},
+		&models.MaintenanceWindow{},
+		&models.NamespaceOwner{})
 
 	if err != nil {
 		assert.FailNow(t, "Error migrating the database schema")
@@ -28,6 +28,7 @@ import (
 
 type PolicyRepository interface {
 	SaveIncompliance(*models.Incompliance) (*models.Incompliance, error)
+	MarkRemediated(id string) error
 }
 
 type policyRepository struct {
@@ -45,3 +46,8 @@ func (repo *policyRepository) SaveIncompliance(incompliance *models.Incompliance
 	}
 	return incompliance, nil
 }
+
+// MarkRemediated records that the remediation of an incompliance was applied
+func (repo *policyRepository) MarkRemediated(id string) error {
+	return repo.db.Debug().Model(&models.Incompliance{}).Where("id = ?", id).Update("remediated", true).Error
+}
@@ -0,0 +1,136 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuotaRepository interface defines the methods for tenant quotas and their usage
+type QuotaRepository interface {
+	SaveQuota(*models.Quota) (*models.Quota, error)
+	FindQuotaByTenant(string) (*models.Quota, error)
+	FindAllQuotas() (*[]models.Quota, error)
+	FindTenantByJobGroupID(string) (string, error)
+	CountJobGroups(tenant string) (int64, error)
+	CountActiveJobs(tenant, excludedJobGroupID string) (int64, error)
+	SumManifestBytes(tenant, excludedJobGroupID string) (int64, error)
+	CountRemediationsSince(tenant string, since time.Time) (int64, error)
+}
+
+// quotaRepository is the implementation of QuotaRepository
+type quotaRepository struct {
+	db *gorm.DB
+}
+
+// NewQuotaRepository returns a new instance of quotaRepository
+func NewQuotaRepository(db *gorm.DB) QuotaRepository {
+	return &quotaRepository{db: db}
+}
+
+// SaveQuota creates or replaces the quota of a tenant
+func (repo *quotaRepository) SaveQuota(quota *models.Quota) (*models.Quota, error) {
+	err := repo.db.Debug().Clauses(clause.OnConflict{UpdateAll: true}).Create(quota).Error
+	if err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// FindQuotaByTenant finds the quota configured for a tenant
+func (repo *quotaRepository) FindQuotaByTenant(tenant string) (*models.Quota, error) {
+	quota := models.Quota{}
+	err := repo.db.Debug().Where("tenant = ?", tenant).Take(&quota).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("quota not found")
+		}
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// FindAllQuotas returns all the quotas configured
+func (repo *quotaRepository) FindAllQuotas() (*[]models.Quota, error) {
+	quotas := []models.Quota{}
+	if err := repo.db.Debug().Find(&quotas).Error; err != nil {
+		return nil, err
+	}
+	return &quotas, nil
+}
+
+// FindTenantByJobGroupID returns the tenant owning a job group
+func (repo *quotaRepository) FindTenantByJobGroupID(id string) (string, error) {
+	jobGroup := models.JobGroup{}
+	err := repo.db.Debug().Select("tenant").Where("id = ?", id).Take(&jobGroup).Error
+	if err != nil {
+		return "", err
+	}
+	return jobGroup.Tenant, nil
+}
+
+// CountJobGroups counts the job groups owned by a tenant
+func (repo *quotaRepository) CountJobGroups(tenant string) (int64, error) {
+	var count int64
+	err := repo.db.Debug().Model(&models.JobGroup{}).Where("tenant = ?", tenant).Count(&count).Error
+	return count, err
+}
+
+// CountActiveJobs counts the jobs of a tenant that are not undeployed, optionally ignoring a job group
+func (repo *quotaRepository) CountActiveJobs(tenant, excludedJobGroupID string) (int64, error) {
+	var count int64
+	err := repo.db.Debug().Model(&models.Job{}).
+		Joins("JOIN job_groups ON job_groups.id = jobs.job_group_id").
+		Where("job_groups.tenant = ? AND job_groups.id != ?", tenant, excludedJobGroupID).
+		Where("NOT (jobs.type = ? AND jobs.state = ?)", models.DeleteDeployment, int(models.JobFinished)).
+		Count(&count).Error
+	return count, err
+}
+
+// SumManifestBytes sums the size of all the manifests stored for a tenant, optionally ignoring a job group
+func (repo *quotaRepository) SumManifestBytes(tenant, excludedJobGroupID string) (int64, error) {
+	var total int64
+	err := repo.db.Debug().Model(&models.PlainManifest{}).
+		Select("COALESCE(SUM(LENGTH(plain_manifests.yaml_string)), 0)").
+		Joins("JOIN jobs ON jobs.id = plain_manifests.job_id").
+		Joins("JOIN job_groups ON job_groups.id = jobs.job_group_id").
+		Where("job_groups.tenant = ? AND job_groups.id != ?", tenant, excludedJobGroupID).
+		Scan(&total).Error
+	return total, err
+}
+
+// CountRemediationsSince counts the remediations applied to the jobs of a tenant since a given time
+func (repo *quotaRepository) CountRemediationsSince(tenant string, since time.Time) (int64, error) {
+	var count int64
+	err := repo.db.Debug().Model(&models.Incompliance{}).
+		Joins("JOIN subjects ON subjects.incompliance_id = incompliances.id").
+		Joins("JOIN resources ON resources.resource_uid = subjects.resource_id").
+		Joins("JOIN jobs ON jobs.id = resources.job_id").
+		Joins("JOIN job_groups ON job_groups.id = jobs.job_group_id").
+		Where("job_groups.tenant = ? AND incompliances.created_at >= ? AND incompliances.remediated = ?", tenant, since, true).
+		Count(&count).Error
+	return count, err
+}
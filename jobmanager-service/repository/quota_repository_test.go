@@ -0,0 +1,128 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	mocks "icos/server/jobmanager-service/repository/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type quotaRepos struct {
+	quotas    QuotaRepository
+	jobGroups JobGroupRepository
+}
+
+func initQuotaRepo(db *gorm.DB) interface{} {
+	return quotaRepos{quotas: NewQuotaRepository(db), jobGroups: NewJobGroupRepository(db)}
+}
+
+func TestSaveQuota(t *testing.T) {
+	repos := mocks.SetupTest(t, initQuotaRepo).(quotaRepos)
+
+	_, err := repos.quotas.SaveQuota(&models.Quota{Tenant: "team-a", MaxJobGroups: 1})
+	assert.NoError(t, err)
+
+	// saving again replaces the limits of the tenant
+	_, err = repos.quotas.SaveQuota(&models.Quota{Tenant: "team-a", MaxJobGroups: 5})
+	assert.NoError(t, err)
+
+	result, err := repos.quotas.FindQuotaByTenant("team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), result.MaxJobGroups)
+
+	all, err := repos.quotas.FindAllQuotas()
+	assert.NoError(t, err)
+	assert.Len(t, *all, 1)
+}
+
+func TestFindQuotaByTenantNotFound(t *testing.T) {
+	repos := mocks.SetupTest(t, initQuotaRepo).(quotaRepos)
+
+	_, err := repos.quotas.FindQuotaByTenant("unknown")
+	assert.Error(t, err)
+}
+
+func TestQuotaUsage(t *testing.T) {
+	repos := mocks.SetupTest(t, initQuotaRepo).(quotaRepos)
+
+	active := models.JobGroup{Tenant: "team-a", Jobs: []models.Job{
+		{Type: models.CreateDeployment, State: models.JobCreated, Manifests: []models.PlainManifest{{YamlString: "kind: Service"}}},
+		{Type: models.CreateDeployment, State: models.JobFinished, Manifests: []models.PlainManifest{{YamlString: "kind: Pod"}}},
+	}}
+	undeployed := models.JobGroup{Tenant: "team-a", Jobs: []models.Job{
+		{Type: models.DeleteDeployment, State: models.JobFinished},
+	}}
+	other := models.JobGroup{Tenant: "team-b", Jobs: []models.Job{
+		{Type: models.CreateDeployment, State: models.JobCreated},
+	}}
+	repos.jobGroups.SaveJobGroup(&active)
+	repos.jobGroups.SaveJobGroup(&undeployed)
+	repos.jobGroups.SaveJobGroup(&other)
+
+	groups, err := repos.quotas.CountJobGroups("team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), groups)
+
+	activeJobs, err := repos.quotas.CountActiveJobs("team-a", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), activeJobs)
+
+	activeJobs, err = repos.quotas.CountActiveJobs("team-a", active.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), activeJobs)
+
+	manifestBytes, err := repos.quotas.SumManifestBytes("team-a", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("kind: Service")+len("kind: Pod")), manifestBytes)
+
+	tenant, err := repos.quotas.FindTenantByJobGroupID(other.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "team-b", tenant)
+}
+
+func TestCountRemediationsSince(t *testing.T) {
+	repos := mocks.SetupTest(t, initQuotaRepo).(quotaRepos)
+	policies := NewPolicyRepository(repos.quotas.(*quotaRepository).db)
+
+	resourceUID := "8c6f1a52-61c4-4a7e-9a36-0d8e9f6b1c21"
+	jobGroup := models.JobGroup{Tenant: "team-a", Jobs: []models.Job{
+		{Type: models.CreateDeployment, State: models.JobProgressing, Resource: &models.Resource{ResourceUID: resourceUID}},
+	}}
+	repos.jobGroups.SaveJobGroup(&jobGroup)
+
+	applied := &models.Incompliance{PolicyName: "cpu", Subject: models.Subject{ResourceID: resourceUID}}
+	rejected := &models.Incompliance{PolicyName: "memory", Subject: models.Subject{ResourceID: resourceUID}}
+	_, err := policies.SaveIncompliance(applied)
+	assert.NoError(t, err)
+	_, err = policies.SaveIncompliance(rejected)
+	assert.NoError(t, err)
+	assert.NoError(t, policies.MarkRemediated(applied.ID))
+
+	// only the remediation that was applied counts
+	count, err := repos.quotas.CountRemediationsSince("team-a", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,110 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ScheduleRepository interface defines the methods for the schedules of job groups
+type ScheduleRepository interface {
+	SaveSchedule(*models.Schedule) (*models.Schedule, error)
+	FindSchedulesByJobGroup(jobGroupID string) (*[]models.Schedule, error)
+	FindSchedule(jobGroupID, id string) (*models.Schedule, error)
+	DeleteSchedule(jobGroupID, id string) (int64, error)
+	FindDueSchedules(now time.Time) (*[]models.Schedule, error)
+	ClaimSchedule(schedule *models.Schedule, nextRun *time.Time, ranAt time.Time) (bool, error)
+	SetScheduleError(id, message string) error
+}
+
+// scheduleRepository is the implementation of ScheduleRepository
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+// NewScheduleRepository returns a new instance of scheduleRepository
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepository{db: db}
+}
+
+// SaveSchedule saves a new schedule
+func (repo *scheduleRepository) SaveSchedule(schedule *models.Schedule) (*models.Schedule, error) {
+	if err := repo.db.Debug().Create(schedule).Error; err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// FindSchedulesByJobGroup returns the schedules of a job group, the next to trigger first
+func (repo *scheduleRepository) FindSchedulesByJobGroup(jobGroupID string) (*[]models.Schedule, error) {
+	schedules := []models.Schedule{}
+	err := repo.db.Debug().Where("job_group_id = ?", jobGroupID).Order("next_run IS NULL, next_run, created_at").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedules, nil
+}
+
+// FindSchedule finds a schedule of a job group
+func (repo *scheduleRepository) FindSchedule(jobGroupID, id string) (*models.Schedule, error) {
+	schedule := models.Schedule{}
+	err := repo.db.Debug().Where("job_group_id = ? AND id = ?", jobGroupID, id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule deletes a schedule of a job group
+func (repo *scheduleRepository) DeleteSchedule(jobGroupID, id string) (int64, error) {
+	result := repo.db.Debug().Where("job_group_id = ? AND id = ?", jobGroupID, id).Delete(&models.Schedule{})
+	return result.RowsAffected, result.Error
+}
+
+// FindDueSchedules returns the schedules whose next run is due, the oldest first
+func (repo *scheduleRepository) FindDueSchedules(now time.Time) (*[]models.Schedule, error) {
+	schedules := []models.Schedule{}
+	err := repo.db.Where("next_run IS NOT NULL AND next_run <= ?", now).Order("next_run").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedules, nil
+}
+
+// ClaimSchedule moves a due schedule to its next run, it returns false when another job manager instance
+// claimed the same run first
+func (repo *scheduleRepository) ClaimSchedule(schedule *models.Schedule, nextRun *time.Time, ranAt time.Time) (bool, error) {
+	result := repo.db.Debug().Model(&models.Schedule{}).
+		Where("id = ? AND next_run = ?", schedule.ID, schedule.NextRun).
+		UpdateColumns(map[string]interface{}{"next_run": nextRun, "last_run": ranAt, "last_error": ""})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetScheduleError records why the last run of a schedule failed
+func (repo *scheduleRepository) SetScheduleError(id, message string) error {
+	return repo.db.Debug().Model(&models.Schedule{}).Where("id = ?", id).UpdateColumn("last_error", message).Error
+}
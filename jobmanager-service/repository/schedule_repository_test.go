@@ -0,0 +1,82 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"icos/server/jobmanager-service/models"
+	mocks "icos/server/jobmanager-service/repository/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func initScheduleRepo(db *gorm.DB) interface{} {
+	return NewScheduleRepository(db)
+}
+
+func TestSchedules(t *testing.T) {
+	repo := mocks.SetupTest(t, initScheduleRepo).(ScheduleRepository)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	due, later := now.Add(-time.Minute), now.Add(time.Hour)
+	deploy := &models.Schedule{JobGroupID: "group-1", Action: models.ScheduleDeploy, Cron: "0 8 * * 1-5", NextRun: &due}
+	undeploy := &models.Schedule{JobGroupID: "group-1", Action: models.ScheduleUndeploy, Cron: "0 18 * * 1-5", NextRun: &later}
+	_, err := repo.SaveSchedule(deploy)
+	require.NoError(t, err)
+	_, err = repo.SaveSchedule(undeploy)
+	require.NoError(t, err)
+
+	schedules, err := repo.FindSchedulesByJobGroup("group-1")
+	require.NoError(t, err)
+	require.Len(t, *schedules, 2)
+	assert.Equal(t, deploy.ID, (*schedules)[0].ID)
+
+	dueSchedules, err := repo.FindDueSchedules(now)
+	require.NoError(t, err)
+	require.Len(t, *dueSchedules, 1)
+	claimable := (*dueSchedules)[0]
+
+	// a run is claimed once
+	next := now.Add(24 * time.Hour)
+	claimed, err := repo.ClaimSchedule(&claimable, &next, now)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = repo.ClaimSchedule(&claimable, &next, now)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	require.NoError(t, repo.SetScheduleError(deploy.ID, "job group is not stopped"))
+	stored, err := repo.FindSchedule("group-1", deploy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "job group is not stopped", stored.LastError)
+	assert.True(t, next.Equal(*stored.NextRun))
+	assert.True(t, now.Equal(*stored.LastRun))
+
+	rows, err := repo.DeleteSchedule("group-1", deploy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows)
+	_, err = repo.FindSchedule("group-1", deploy.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,111 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+
+	"gorm.io/gorm"
+)
+
+// ServiceAccountRepository interface defines the methods for service account operations
+type ServiceAccountRepository interface {
+	SaveServiceAccount(*models.ServiceAccount) (*models.ServiceAccount, error)
+	RevokeServiceAccount(string) (int64, error)
+	FindServiceAccountByUUID(string) (*models.ServiceAccount, error)
+	FindServiceAccountByAPIKeyHash(string) (*models.ServiceAccount, error)
+	FindServiceAccountByClientID(string) (*models.ServiceAccount, error)
+	FindAllServiceAccounts() (*[]models.ServiceAccount, error)
+	FindServiceAccountsByOwnerID(string) (*[]models.ServiceAccount, error)
+}
+
+// serviceAccountRepository is the implementation of ServiceAccountRepository
+type serviceAccountRepository struct {
+	db *gorm.DB
+}
+
+// NewServiceAccountRepository returns a new instance of serviceAccountRepository
+func NewServiceAccountRepository(db *gorm.DB) ServiceAccountRepository {
+	return &serviceAccountRepository{db: db}
+}
+
+// SaveServiceAccount saves a new service account to the database
+func (repo *serviceAccountRepository) SaveServiceAccount(sa *models.ServiceAccount) (*models.ServiceAccount, error) {
+	if err := repo.db.Debug().Create(sa).Error; err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+// RevokeServiceAccount marks a service account as revoked, its credentials are not accepted anymore
+func (repo *serviceAccountRepository) RevokeServiceAccount(id string) (int64, error) {
+	result := repo.db.Debug().Model(&models.ServiceAccount{}).Where("id = ?", id).Update("revoked", true)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// FindServiceAccountByUUID finds a service account by its UUID
+func (repo *serviceAccountRepository) FindServiceAccountByUUID(id string) (*models.ServiceAccount, error) {
+	return repo.findOne("id = ?", id)
+}
+
+// FindServiceAccountByAPIKeyHash finds a non revoked service account by the hash of its API key
+func (repo *serviceAccountRepository) FindServiceAccountByAPIKeyHash(hash string) (*models.ServiceAccount, error) {
+	return repo.findOne("api_key_hash = ? AND revoked = ?", hash, false)
+}
+
+// FindServiceAccountByClientID finds a non revoked service account bound to an OAuth2 client
+func (repo *serviceAccountRepository) FindServiceAccountByClientID(clientID string) (*models.ServiceAccount, error) {
+	return repo.findOne("client_id = ? AND revoked = ?", clientID, false)
+}
+
+// FindAllServiceAccounts returns all service accounts from the database
+func (repo *serviceAccountRepository) FindAllServiceAccounts() (*[]models.ServiceAccount, error) {
+	serviceAccounts := []models.ServiceAccount{}
+	if err := repo.db.Debug().Find(&serviceAccounts).Error; err != nil {
+		return nil, err
+	}
+	return &serviceAccounts, nil
+}
+
+// FindServiceAccountsByOwnerID returns the non revoked service accounts of an owner
+func (repo *serviceAccountRepository) FindServiceAccountsByOwnerID(ownerID string) (*[]models.ServiceAccount, error) {
+	serviceAccounts := []models.ServiceAccount{}
+	if err := repo.db.Debug().Where("owner_id = ? AND revoked = ?", ownerID, false).Find(&serviceAccounts).Error; err != nil {
+		return nil, err
+	}
+	return &serviceAccounts, nil
+}
+
+func (repo *serviceAccountRepository) findOne(query string, args ...interface{}) (*models.ServiceAccount, error) {
+	sa := models.ServiceAccount{}
+	err := repo.db.Debug().Where(query, args...).Take(&sa).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("service account not found")
+		}
+		return nil, err
+	}
+	return &sa, nil
+}
@@ -0,0 +1,117 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	mocks "icos/server/jobmanager-service/repository/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func initServiceAccountRepo(db *gorm.DB) interface{} {
+	return NewServiceAccountRepository(db)
+}
+
+func TestSaveServiceAccount(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	sa := &models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM}
+
+	result, err := repo.SaveServiceAccount(sa)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.ID)
+	assert.NotEmpty(t, result.OwnerID)
+}
+
+func TestSaveServiceAccountInvalidOrchestrator(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	sa := &models.ServiceAccount{Name: "unknown-agent", Orchestrator: "unknown"}
+
+	_, err := repo.SaveServiceAccount(sa)
+	assert.Error(t, err)
+}
+
+func TestFindServiceAccountByAPIKeyHash(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	sa := &models.ServiceAccount{Name: "nuvla-agent", Orchestrator: models.Nuvla, APIKeyHash: "hash"}
+	repo.SaveServiceAccount(sa)
+
+	result, err := repo.FindServiceAccountByAPIKeyHash("hash")
+	assert.NoError(t, err)
+	assert.Equal(t, sa.ID, result.ID)
+}
+
+func TestFindServiceAccountByClientID(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	sa := &models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM, ClientID: "ocm-agent-client"}
+	repo.SaveServiceAccount(sa)
+
+	result, err := repo.FindServiceAccountByClientID("ocm-agent-client")
+	assert.NoError(t, err)
+	assert.Equal(t, sa.OwnerID, result.OwnerID)
+}
+
+func TestRevokeServiceAccount(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	sa := &models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM, APIKeyHash: "hash"}
+	repo.SaveServiceAccount(sa)
+
+	rowsAffected, err := repo.RevokeServiceAccount(sa.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+
+	_, err = repo.FindServiceAccountByAPIKeyHash("hash")
+	assert.Error(t, err)
+}
+
+func TestFindAllServiceAccounts(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	repo.SaveServiceAccount(&models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM})
+	repo.SaveServiceAccount(&models.ServiceAccount{Name: "nuvla-agent", Orchestrator: models.Nuvla})
+
+	result, err := repo.FindAllServiceAccounts()
+	assert.NoError(t, err)
+	assert.Len(t, *result, 2)
+}
+
+func TestFindServiceAccountsByOwnerID(t *testing.T) {
+	repo := mocks.SetupTest(t, initServiceAccountRepo).(ServiceAccountRepository)
+
+	active := &models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM}
+	repo.SaveServiceAccount(active)
+	revoked := &models.ServiceAccount{Name: "ocm-agent", Orchestrator: models.OCM, OwnerID: active.OwnerID}
+	repo.SaveServiceAccount(revoked)
+	repo.RevokeServiceAccount(revoked.ID)
+	repo.SaveServiceAccount(&models.ServiceAccount{Name: "nuvla-agent", Orchestrator: models.Nuvla})
+
+	result, err := repo.FindServiceAccountsByOwnerID(active.OwnerID)
+	assert.NoError(t, err)
+	assert.Len(t, *result, 1)
+	assert.Equal(t, active.ID, (*result)[0].ID)
+}
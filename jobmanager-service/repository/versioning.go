@@ -0,0 +1,106 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when a row was modified after the version a write is based on was read
+var ErrVersionConflict = errors.New("resource version conflict")
+
+// bumpResourceVersion moves a row to the next resource version, as long as it is still at the expected
+// version. Running it first in a transaction serializes concurrent writers on the row.
+func bumpResourceVersion(tx *gorm.DB, model interface{}, id string, expected int64) (int64, error) {
+	result := tx.Debug().Model(model).Where("id = ? AND resource_version = ?", id, expected).
+		UpdateColumn("resource_version", expected+1)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := tx.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count == 0 {
+			return 0, gorm.ErrRecordNotFound
+		}
+		return 0, ErrVersionConflict
+	}
+	return expected + 1, nil
+}
+
+// deleteVersioned deletes a row, as long as it is still at the expected version when one is expected
+func deleteVersioned(tx *gorm.DB, model interface{}, id string, expected int64) (int64, error) {
+	query := tx.Debug().Where("id = ?", id)
+	if expected != 0 {
+		query = query.Where("resource_version = ?", expected)
+	}
+	result := query.Delete(model)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 && expected != 0 {
+		var count int64
+		if err := tx.Model(model).Where("id = ?", id).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count != 0 {
+			return 0, ErrVersionConflict
+		}
+	}
+	return result.RowsAffected, nil
+}
+
+// nextResourceVersion bumps the resource version of a row from the expected one, or from the stored
+// one when no version is expected
+func nextResourceVersion(tx *gorm.DB, model interface{}, id string, expected int64) (int64, error) {
+	if expected == 0 {
+		var err error
+		if expected, err = currentResourceVersion(tx, model, id); err != nil {
+			return 0, err
+		}
+	}
+	return bumpResourceVersion(tx, model, id, expected)
+}
+
+// currentResourceVersion reads the version of a row, used by writes without a precondition
+func currentResourceVersion(tx *gorm.DB, model interface{}, id string) (int64, error) {
+	var version int64
+	result := tx.Debug().Model(model).Select("resource_version").Where("id = ?", id).Scan(&version)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return version, nil
+}
+
+// notFound replaces a missing record error by the repository's own message
+func notFound(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New(message)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+)
+
+// Errors returned by the services that controllers map to a specific HTTP status code
+var (
+	ErrForbidden       = errors.New("forbidden")
+	ErrVersionConflict = repository.ErrVersionConflict
+	// ErrNamespaceConflict is reported as an IssueConflict of the namespace
+	ErrNamespaceConflict = repository.ErrNamespaceConflict
+
+	ErrIdempotencyKeyInProgress = errors.New("a request with the same idempotency key is still in progress")
+	ErrIdempotencyKeyReused     = errors.New("idempotency key already used for a different request")
+)
+
+// checkResourceVersion verifies the version a request is based on against the stored one, zero means any version
+func checkResourceVersion(expected, stored int64) error {
+	if expected != 0 && expected != stored {
+		return fmt.Errorf("%w: expected version %d, stored version is %d", ErrVersionConflict, expected, stored)
+	}
+	return nil
+}
+
+// Codes of the issues found in application descriptors
+const (
+	IssueRequired        = "required"
+	IssueInvalid         = "invalid"
+	IssueInvalidManifest = "invalid_manifest"
+	IssueNotFound        = "not_found"
+	IssueDuplicate       = "duplicate"
+	IssueAmbiguous       = "ambiguous"
+	IssueQuotaExceeded   = "quota_exceeded"
+	IssueGenerated       = "generated"
+	IssueNoTarget        = "no_target"
+	IssueUnreferenced    = "unreferenced"
+	IssueIgnored         = "ignored"
+	IssueConflict        = "conflict"
+)
+
+// ValidationError holds every problem found in an application descriptor
+type ValidationError struct {
+	Issues []models.ValidationIssue `json:"issues"`
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Issues) == 0 {
+		return "invalid application descriptor"
+	}
+	first := e.Issues[0]
+	if first.Path == "" {
+		return fmt.Sprintf("invalid application descriptor (%d issue(s)): %s", len(e.Issues), first.Message)
+	}
+	return fmt.Sprintf("invalid application descriptor (%d issue(s)): %s: %s", len(e.Issues), first.Path, first.Message)
+}
+
+// Names of the quotas, matching the fields of models.Quota
+const (
+	QuotaJobGroups           = "maxJobGroups"
+	QuotaActiveJobs          = "maxActiveJobs"
+	QuotaManifestBytes       = "maxManifestBytes"
+	QuotaRemediationsPerHour = "maxRemediationsPerHour"
+)
+
+// QuotaExceededError reports which quota of a tenant would be exceeded by a request
+type QuotaExceededError struct {
+	Tenant    string `json:"tenant"`
+	Quota     string `json:"quota"`
+	Limit     int64  `json:"limit"`
+	Requested int64  `json:"requested"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota %s exceeded for tenant '%s': %d requested, limit is %d", e.Quota, e.Tenant, e.Requested, e.Limit)
+}
+
+// RateLimited tells whether the quota is a rate, so retrying later may succeed
+func (e *QuotaExceededError) RateLimited() bool {
+	return e.Quota == QuotaRemediationsPerHour
+}
@@ -0,0 +1,125 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"time"
+)
+
+// defaultIdempotencyKeyTTL is the retention window of idempotency keys when IDEMPOTENCY_KEY_TTL is not set
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// defaultIdempotencyKeyLease is how long a request holds its key when IDEMPOTENCY_KEY_LEASE is not set,
+// a key left in progress by a request that never completed is freed for retries once its lease is over
+const defaultIdempotencyKeyLease = 5 * time.Minute
+
+// IdempotencyService interface defines the methods to deduplicate retried requests
+type IdempotencyService interface {
+	Reserve(tenant, key string, body []byte) (*models.IdempotencyKey, error)
+	Complete(idempotencyKey *models.IdempotencyKey, jobGroupID string, statusCode int) error
+	Release(idempotencyKey *models.IdempotencyKey) error
+}
+
+// idempotencyService struct implements the IdempotencyService interface
+type idempotencyService struct {
+	repo  repository.IdempotencyRepository
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyService returns a new instance of idempotencyService, completed keys are retained for
+// IDEMPOTENCY_KEY_TTL and keys in progress for IDEMPOTENCY_KEY_LEASE
+func NewIdempotencyService(repo repository.IdempotencyRepository) IdempotencyService {
+	return &idempotencyService{
+		repo:  repo,
+		ttl:   durationFromEnv("IDEMPOTENCY_KEY_TTL", defaultIdempotencyKeyTTL),
+		lease: durationFromEnv("IDEMPOTENCY_KEY_LEASE", defaultIdempotencyKeyLease),
+	}
+}
+
+// durationFromEnv reads a positive duration from an environment variable, or returns the default one
+func durationFromEnv(name string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		logs.Logger.Printf("Ignoring invalid value for %s: %s", name, value)
+		return defaultValue
+	}
+	return parsed
+}
+
+// Reserve takes the key for a request of the tenant. If a previous request already completed with the same
+// key and body, its record is returned and Completed tells the caller to replay its outcome.
+func (s *idempotencyService) Reserve(tenant, key string, body []byte) (*models.IdempotencyKey, error) {
+	if len(key) > 255 {
+		return nil, errors.New("idempotency key cannot be longer than 255 characters")
+	}
+
+	now := time.Now()
+	if _, err := s.repo.DeleteIdempotencyKeysBefore(now.Add(-s.ttl), now.Add(-s.lease)); err != nil {
+		logs.Logger.Println("Error deleting expired idempotency keys:", err)
+	}
+
+	hash := sha256.Sum256(body)
+	idempotencyKey := &models.IdempotencyKey{Tenant: tenant, Key: key, RequestHash: hex.EncodeToString(hash[:])}
+	reserved, err := s.repo.ReserveIdempotencyKey(idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return idempotencyKey, nil
+	}
+
+	existing, err := s.repo.FindIdempotencyKey(tenant, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != idempotencyKey.RequestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !existing.Completed() {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	logs.Logger.Printf("Replaying request with idempotency key %s of tenant %s", key, tenant)
+	return existing, nil
+}
+
+// Complete records the job group created by the request holding the key
+func (s *idempotencyService) Complete(idempotencyKey *models.IdempotencyKey, jobGroupID string, statusCode int) error {
+	idempotencyKey.JobGroupID = jobGroupID
+	idempotencyKey.StatusCode = statusCode
+	return s.repo.CompleteIdempotencyKey(idempotencyKey)
+}
+
+// Release frees the key of a failed request, so that it can be retried
+func (s *idempotencyService) Release(idempotencyKey *models.IdempotencyKey) error {
+	return s.repo.DeleteIdempotencyKey(idempotencyKey)
+}
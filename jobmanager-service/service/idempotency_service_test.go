@@ -0,0 +1,92 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyService(t *testing.T) {
+	mockIdempotencyRepo := new(repository.MockIdempotencyRepository)
+	idempotencyService := service.NewIdempotencyService(mockIdempotencyRepo)
+	mockIdempotencyRepo.On("DeleteIdempotencyKeysBefore", mock.Anything, mock.Anything).Return(int64(0), nil)
+
+	body := []byte("name: app")
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+
+	t.Run("ReserveNewKey", func(t *testing.T) {
+		mockIdempotencyRepo.On("ReserveIdempotencyKey", mock.Anything).Return(true, nil).Once()
+
+		result, err := idempotencyService.Reserve("team-a", "key-1", body)
+		require.NoError(t, err)
+		assert.False(t, result.Completed())
+		assert.Equal(t, hash, result.RequestHash)
+	})
+
+	t.Run("ReserveCompletedKey", func(t *testing.T) {
+		completed := &models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: hash, JobGroupID: "group-1", StatusCode: 201}
+		mockIdempotencyRepo.On("ReserveIdempotencyKey", mock.Anything).Return(false, nil).Once()
+		mockIdempotencyRepo.On("FindIdempotencyKey", "team-a", "key-1").Return(completed, nil).Once()
+
+		result, err := idempotencyService.Reserve("team-a", "key-1", body)
+		require.NoError(t, err)
+		assert.True(t, result.Completed())
+		assert.Equal(t, "group-1", result.JobGroupID)
+	})
+
+	t.Run("ReserveKeyInProgress", func(t *testing.T) {
+		inProgress := &models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: hash}
+		mockIdempotencyRepo.On("ReserveIdempotencyKey", mock.Anything).Return(false, nil).Once()
+		mockIdempotencyRepo.On("FindIdempotencyKey", "team-a", "key-1").Return(inProgress, nil).Once()
+
+		_, err := idempotencyService.Reserve("team-a", "key-1", body)
+		assert.ErrorIs(t, err, service.ErrIdempotencyKeyInProgress)
+	})
+
+	t.Run("ReserveKeyReused", func(t *testing.T) {
+		completed := &models.IdempotencyKey{Tenant: "team-a", Key: "key-1", RequestHash: hash, JobGroupID: "group-1", StatusCode: 201}
+		mockIdempotencyRepo.On("ReserveIdempotencyKey", mock.Anything).Return(false, nil).Once()
+		mockIdempotencyRepo.On("FindIdempotencyKey", "team-a", "key-1").Return(completed, nil).Once()
+
+		_, err := idempotencyService.Reserve("team-a", "key-1", []byte("name: other-app"))
+		assert.ErrorIs(t, err, service.ErrIdempotencyKeyReused)
+	})
+
+	t.Run("Complete", func(t *testing.T) {
+		key := &models.IdempotencyKey{Tenant: "team-a", Key: "key-2", RequestHash: hash}
+		mockIdempotencyRepo.On("CompleteIdempotencyKey", key).Return(nil).Once()
+
+		err := idempotencyService.Complete(key, "group-2", 201)
+		assert.NoError(t, err)
+		assert.True(t, key.Completed())
+		mockIdempotencyRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,157 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// validatePriority reports a priority of a job group out of range
+func validatePriority(priority int, path string) []models.ValidationIssue {
+	if priority < models.PriorityDefault || priority > models.PriorityMax {
+		return []models.ValidationIssue{{
+			Path:    path,
+			Code:    IssueInvalid,
+			Message: fmt.Sprintf("priority %d is not between %d and %d", priority, models.PriorityDefault, models.PriorityMax),
+		}}
+	}
+	return nil
+}
+
+// jobPriority is the priority a job is executed with, undeployments and remediations come first
+func jobPriority(job models.Job) int {
+	priority := job.Priority
+	switch jobKind(job) {
+	case JobKindRemediation:
+		priority = max(priority, models.PriorityRemediation)
+	case JobKindDelete:
+		priority = max(priority, models.PriorityDelete)
+	}
+	return priority
+}
+
+// maxJobsPerGroup reads MAX_EXECUTABLE_JOBS_PER_GROUP, the number of jobs of a job group an agent is offered at
+// once, 0 for no limit
+func maxJobsPerGroup() int {
+	value, ok := os.LookupEnv("MAX_EXECUTABLE_JOBS_PER_GROUP")
+	if !ok {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		logs.Logger.Printf("Ignoring invalid value for MAX_EXECUTABLE_JOBS_PER_GROUP: %s", value)
+		return 0
+	}
+	return limit
+}
+
+// fairQueue holds the jobs of a tenant, by job group in order of their oldest job
+type fairQueue struct {
+	groups [][]models.Job
+	next   int
+}
+
+// pop takes the next job of the tenant, the job groups taking turns
+func (q *fairQueue) pop() (models.Job, bool) {
+	for len(q.groups) > 0 {
+		q.next %= len(q.groups)
+		group := q.groups[q.next]
+		if len(group) == 0 {
+			q.groups = append(q.groups[:q.next], q.groups[q.next+1:]...)
+			continue
+		}
+		q.groups[q.next] = group[1:]
+		q.next++
+		return group[0], true
+	}
+	return models.Job{}, false
+}
+
+// fairOrder orders the jobs by priority then age. Jobs of the same priority take turns across tenants and, within
+// a tenant, across its job groups, so that a large application does not starve the other ones. At most perGroup
+// jobs of a job group are kept when perGroup is not 0.
+func fairOrder(jobs []models.Job, tenants map[string]string, perGroup int) []models.Job {
+	sorted := append([]models.Job{}, jobs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if pi, pj := jobPriority(sorted[i]), jobPriority(sorted[j]); pi != pj {
+			return pi > pj
+		}
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	ordered := []models.Job{}
+	kept := map[string]int{}
+	for start := 0; start < len(sorted); {
+		priority := jobPriority(sorted[start])
+		end := start
+		for end < len(sorted) && jobPriority(sorted[end]) == priority {
+			end++
+		}
+
+		// the tenants and their job groups take turns in order of their oldest job
+		queues := []*fairQueue{}
+		tenantQueues := map[string]*fairQueue{}
+		groupIndexes := map[string]int{}
+		for _, job := range sorted[start:end] {
+			group := job.JobGroupID
+			if group == "" {
+				group = job.ID
+			}
+			tenant := tenants[job.JobGroupID]
+			queue, ok := tenantQueues[tenant]
+			if !ok {
+				queue = &fairQueue{}
+				tenantQueues[tenant] = queue
+				queues = append(queues, queue)
+			}
+			index, ok := groupIndexes[group]
+			if !ok {
+				index = len(queue.groups)
+				groupIndexes[group] = index
+				queue.groups = append(queue.groups, nil)
+			}
+			queue.groups[index] = append(queue.groups[index], job)
+		}
+		for len(queues) > 0 {
+			remaining := queues[:0]
+			for _, queue := range queues {
+				job, ok := queue.pop()
+				if !ok {
+					continue
+				}
+				remaining = append(remaining, queue)
+				if job.JobGroupID != "" && perGroup > 0 && kept[job.JobGroupID] >= perGroup {
+					continue
+				}
+				kept[job.JobGroupID]++
+				ordered = append(ordered, job)
+			}
+			queues = remaining
+		}
+		start = end
+	}
+	return ordered
+}
@@ -0,0 +1,135 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scheduledJob(id, group string, priority int, age time.Duration) models.Job {
+	job := models.Job{BaseUUID: models.BaseUUID{ID: id}, JobGroupID: group, Type: models.CreateDeployment,
+		State: models.JobCreated, Priority: priority}
+	job.CreatedAt = time.Now().Add(-age)
+	return job
+}
+
+func jobIDs(jobs []models.Job) []string {
+	ids := []string{}
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func TestJobPriority(t *testing.T) {
+	job := scheduledJob("a", "g", 10, 0)
+	assert.Equal(t, 10, jobPriority(job))
+	job.Type = models.DeleteDeployment
+	assert.Equal(t, models.PriorityDelete, jobPriority(job))
+	job.Type, job.SubType = models.UpdateDeployment, models.ScaleUp
+	assert.Equal(t, models.PriorityRemediation, jobPriority(job))
+}
+
+func TestJobPriorityAfterRemediation(t *testing.T) {
+	// a job of a remediated job group is deployed again at the priority of its job group
+	job := scheduledJob("a", "g", 10, 0)
+	job.Type, job.State, job.SubType = models.UpdateDeployment, models.JobFinished, models.ScaleUp
+	replaceJob(&job)
+	assert.Empty(t, job.SubType)
+	assert.Equal(t, 10, jobPriority(job))
+}
+
+func TestFairOrder(t *testing.T) {
+	// a large application of tenant-a, a smaller one of tenant-a and one of tenant-b, all created later
+	jobs := []models.Job{
+		scheduledJob("big-1", "big", 0, 10*time.Minute),
+		scheduledJob("big-2", "big", 0, 9*time.Minute),
+		scheduledJob("big-3", "big", 0, 8*time.Minute),
+		scheduledJob("big-4", "big", 0, 7*time.Minute),
+		scheduledJob("small-1", "small", 0, 6*time.Minute),
+		scheduledJob("other-1", "other", 0, 5*time.Minute),
+		scheduledJob("other-2", "other", 0, 4*time.Minute),
+		scheduledJob("urgent-1", "urgent", 50, time.Minute),
+	}
+	tenants := map[string]string{"big": "tenant-a", "small": "tenant-a", "other": "tenant-b", "urgent": "tenant-b"}
+
+	ordered := fairOrder(jobs, tenants, 0)
+	assert.Equal(t, []string{"urgent-1", "big-1", "other-1", "small-1", "other-2", "big-2", "big-3", "big-4"}, jobIDs(ordered))
+
+	ordered = fairOrder(jobs, tenants, 1)
+	assert.Equal(t, []string{"urgent-1", "big-1", "other-1", "small-1"}, jobIDs(ordered))
+}
+
+func TestFindJobsToExecuteByPriority(t *testing.T) {
+	t.Setenv("MAX_EXECUTABLE_JOBS_PER_GROUP", "2")
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	big := []models.Job{
+		scheduledJob("big-1", "big", 0, 10*time.Minute),
+		scheduledJob("big-2", "big", 0, 9*time.Minute),
+		scheduledJob("big-3", "big", 0, 8*time.Minute),
+	}
+	other := []models.Job{scheduledJob("other-1", "other", 0, 2*time.Minute)}
+	remove := scheduledJob("delete", "other", 0, time.Minute)
+	remove.Type, remove.OwnerID = models.DeleteDeployment, "agent"
+	other = append(other, remove)
+	mockRepo.On("FindJobsToExecute", "ocm", "agent").Return(&[]models.Job{big[0], big[1], big[2], other[0], remove}, nil)
+	mockRepo.On("FindJobGroupRollout", "big").Return(&models.JobGroup{Jobs: big}, nil)
+	mockRepo.On("FindJobGroupRollout", "other").Return(&models.JobGroup{Jobs: other}, nil)
+	mockRepo.On("FindJobsByJobGroup", "other").Return(&other, nil)
+	mockRepo.On("FindJobGroupTenants", []string{"big", "other"}).Return(map[string]string{"big": "tenant-a", "other": "tenant-b"}, nil)
+	mockRepo.On("FindJobGroupOverlays", []string{"other", "big"}).Return(map[string]models.OverlayList{}, nil)
+
+	jobs, err := service.FindJobsToExecute("ocm", "agent")
+	require.NoError(t, err)
+	// the deletion comes first, then the job groups take turns with at most 2 jobs each
+	assert.Equal(t, []string{"delete", "big-1", "other-1", "big-2"}, jobIDs(*jobs))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBuildJobGroupPriority(t *testing.T) {
+	header := models.JobGroupHeader{
+		Name:     "shop",
+		Priority: 40,
+		Components: []models.Component{
+			{Name: "db", Type: models.ManifestComponent, Manifests: []models.ManifestDTO{{Name: "db"}}},
+		},
+		Manifests: []interface{}{map[string]interface{}{
+			"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "db"},
+		}},
+	}
+	validation := buildJobGroup(header, "team-a")
+	require.True(t, validation.Valid, validation.Errors)
+	assert.Equal(t, 40, validation.JobGroup.Priority)
+	assert.Equal(t, 40, validation.JobGroup.Jobs[0].Priority)
+
+	header.Priority = models.PriorityDelete
+	validation = buildJobGroup(header, "team-a")
+	assert.False(t, validation.Valid)
+	assert.Equal(t, "priority", validation.Errors[0].Path)
+}
@@ -21,98 +21,361 @@
 package service
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/repository"
 	"icos/server/jobmanager-service/utils/logs"
-	"net/http"
-
-	"github.com/gorilla/mux"
 )
 
 type JobService interface {
 	SaveJob(*models.Job) (*models.Job, error)
-	UpdateJob(*models.Job) (*models.Job, error)
-	DeleteJob(string) (int64, error)
+	UpdateJob(job *models.Job, tenant string) (*models.Job, error)
+	DeleteJob(id string, version int64) (int64, error)
 	FindJobByUUID(string) (*models.Job, error)
 	FindJobByResourceUUID(string) (*models.Job, error)
 	FindAllJobs() (*[]models.Job, error)
 	FindJobsByState(state int) (*[]models.Job, error)
 	FindJobsToExecute(orchestratorType, ownerID string) (*[]models.Job, error)
-	JobPromote(r *http.Request) (*models.Job, error)
+	JobPromote(jobID string, agent *models.ServiceAccount, version int64) (*models.Job, error)
 }
 
 type jobService struct {
-	repo repository.JobRepository
+	repo        repository.JobRepository
+	maintenance MaintenanceService
+	perGroup    int
 }
 
-func NewJobService(repo repository.JobRepository) JobService {
-	return &jobService{repo: repo}
+// NewJobService returns a new instance of jobService, the maintenance windows of the clusters defer their jobs
+// when given. The jobs of a job group offered at once are limited by MAX_EXECUTABLE_JOBS_PER_GROUP.
+func NewJobService(repo repository.JobRepository, maintenance MaintenanceService) JobService {
+	return &jobService{repo: repo, maintenance: maintenance, perGroup: maxJobsPerGroup()}
 }
 
 func (s *jobService) SaveJob(job *models.Job) (*models.Job, error) {
 	return s.repo.SaveJob(job)
 }
 
-func (s *jobService) UpdateJob(job *models.Job) (*models.Job, error) {
-	return s.repo.UpdateJob(job)
+// UpdateJob updates a job of a job group of the tenant, failing with ErrVersionConflict if its resource version
+// is not the stored one. Redacted fields of its manifests keep their stored value, and manifests sent back as
+// read keep their stored value without the overlays of the job group. The manifests of a job group gated by an
+// approval only change through an update of the job group, see JobGroupService.UpdateJobGroup.
+func (s *jobService) UpdateJob(job *models.Job, tenant string) (*models.Job, error) {
+	if job.ID != "" {
+		if stored, err := s.repo.FindJobByUUID(job.ID); err == nil && stored != nil {
+			restoreRedacted(job, stored)
+			if stored.JobGroupID != "" {
+				overlays, err := s.repo.FindJobGroupOverlays([]string{stored.JobGroupID})
+				if err != nil {
+					return nil, err
+				}
+				restoreOverlaid(job, stored, overlays[stored.JobGroupID])
+				if err := s.checkJobUpdate(job, stored, tenant); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return redacted(s.repo.UpdateJob(job))
+}
+
+// checkJobUpdate checks the tenant owns the job group of a job updated and that its manifests only change when
+// the job group is not gated by an approval
+func (s *jobService) checkJobUpdate(updated, stored *models.Job, tenant string) error {
+	if tenant != "" {
+		tenants, err := s.repo.FindJobGroupTenants([]string{stored.JobGroupID})
+		if err != nil {
+			return err
+		}
+		if err := checkTenant(&models.JobGroup{BaseUUID: models.BaseUUID{ID: stored.JobGroupID}, Tenant: tenants[stored.JobGroupID]}, tenant); err != nil {
+			return err
+		}
+	}
+	if !manifestsChanged(updated, stored) {
+		return nil
+	}
+	approvals, err := s.repo.FindJobGroupApprovals([]string{stored.JobGroupID})
+	if err != nil {
+		return err
+	}
+	if approval := approvals[stored.JobGroupID]; approval.State != "" {
+		logs.Logger.Printf("Job with ID %s belongs to a job group gated by an approval, its manifests cannot be updated", stored.ID)
+		return fmt.Errorf("%w: job group %s is gated by an approval, its manifests change through an update of the job group", ErrForbidden, stored.JobGroupID)
+	}
+	return nil
+}
+
+// manifestsChanged tells whether the manifests of a job updated differ from the stored ones
+func manifestsChanged(updated, stored *models.Job) bool {
+	if len(updated.Manifests) != len(stored.Manifests) {
+		return true
+	}
+	for i, manifest := range updated.Manifests {
+		if manifest.ID != stored.Manifests[i].ID || !sameManifest(manifest.YamlString, stored.Manifests[i].YamlString) {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *jobService) DeleteJob(id string) (int64, error) {
-	return s.repo.DeleteJob(id)
+// DeleteJob deletes a job, a non zero version must match the stored one
+func (s *jobService) DeleteJob(id string, version int64) (int64, error) {
+	return s.repo.DeleteJob(id, version)
 }
 
+// FindJobByUUID finds a job, its manifests are overlaid, stamped with the labels of the job and redacted
 func (s *jobService) FindJobByUUID(id string) (*models.Job, error) {
-	return s.repo.FindJobByUUID(id)
+	return redacted(s.rendered(s.deferred(s.repo.FindJobByUUID(id))))
 }
 
 func (s *jobService) FindJobByResourceUUID(id string) (*models.Job, error) {
-	return s.repo.FindJobByResourceUUID(id)
+	return redacted(s.rendered(s.deferred(s.repo.FindJobByResourceUUID(id))))
 }
 
 func (s *jobService) FindAllJobs() (*[]models.Job, error) {
-	return s.repo.FindAllJobs()
+	return redactedAll(s.renderedAll(s.deferredAll(s.repo.FindAllJobs())))
 }
 
 func (s *jobService) FindJobsByState(state int) (*[]models.Job, error) {
-	return s.repo.FindJobsByState(state)
+	return redactedAll(s.renderedAll(s.deferredAll(s.repo.FindJobsByState(state))))
 }
 
+// deferred sets the reason a job found is deferred, see MaintenanceService.DeferJobs
+func (s *jobService) deferred(job *models.Job, err error) (*models.Job, error) {
+	if err == nil && job != nil && s.maintenance != nil {
+		jobs := []models.Job{*job}
+		if err := s.maintenance.DeferJobs(jobs); err != nil {
+			return nil, err
+		}
+		job.Deferred = jobs[0].Deferred
+	}
+	return job, err
+}
+
+// deferredAll sets the reason the jobs found are deferred, see MaintenanceService.DeferJobs
+func (s *jobService) deferredAll(jobs *[]models.Job, err error) (*[]models.Job, error) {
+	if err == nil && jobs != nil && s.maintenance != nil {
+		if err := s.maintenance.DeferJobs(*jobs); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, err
+}
+
+// FindJobsToExecute finds the jobs an agent can execute once their dependencies are ready and no maintenance
+// window of their cluster withholds them, ordered by priority then age with the job groups taking turns. The
+// sensitive fields of the manifests are only revealed for the jobs the agent owns, the other ones are revealed
+// once promoted.
 func (s *jobService) FindJobsToExecute(orchestratorType, ownerID string) (*[]models.Job, error) {
-	return s.repo.FindJobsToExecute(orchestratorType, ownerID)
+	jobs, err := s.repo.FindJobsToExecute(orchestratorType, ownerID)
+	if err == nil && jobs != nil {
+		jobs, err = s.readyJobs(jobs)
+	}
+	if err == nil && jobs != nil && s.maintenance != nil {
+		jobs, err = s.undeferredJobs(jobs)
+	}
+	if err == nil && jobs != nil {
+		jobs, err = s.fairJobs(jobs)
+	}
+	jobs, err = s.renderedAll(jobs, err)
+	if err == nil && jobs != nil {
+		for i, job := range *jobs {
+			if ownerID == "" || job.OwnerID != ownerID {
+				redactJobs((*jobs)[i : i+1])
+			}
+		}
+	}
+	return jobs, err
 }
 
-func (s *jobService) JobPromote(r *http.Request) (*models.Job, error) {
-	vars := mux.Vars(r)
-	stringJobID := vars["job_uuid"]
-	if stringJobID == "" {
-		err := errors.New("job ID Cannot be empty")
-		logs.Logger.Println("job ID Cannot be empty")
+// readyJobs keeps the jobs whose dependencies are ready and that the rollout of their job group lets through.
+// The jobs and the rollouts of the job groups are only read when needed, a degraded job halts the rollout of
+// its job group.
+func (s *jobService) readyJobs(jobs *[]models.Job) (*[]models.Job, error) {
+	groupJobs := map[string][]models.Job{}
+	rollouts := map[string]*models.JobGroup{}
+	ready := []models.Job{}
+	for _, job := range *jobs {
+		rolledOut := job.State == models.JobCreated && job.Type != models.DeleteDeployment
+		dependent := len(job.DependsOn) > 0 || job.Type == models.DeleteDeployment
+		if job.JobGroupID == "" || (!rolledOut && !dependent) {
+			ready = append(ready, job)
+			continue
+		}
+
+		if dependent {
+			others, ok := groupJobs[job.JobGroupID]
+			if !ok {
+				found, err := s.repo.FindJobsByJobGroup(job.JobGroupID)
+				if err != nil {
+					return nil, err
+				}
+				others = *found
+				groupJobs[job.JobGroupID] = others
+			}
+			if !dependenciesReady(job, others) {
+				continue
+			}
+		}
+		if rolledOut {
+			group, ok := rollouts[job.JobGroupID]
+			if !ok {
+				found, err := s.repo.FindJobGroupRollout(job.JobGroupID)
+				if err != nil {
+					return nil, err
+				}
+				group = found
+				rollouts[job.JobGroupID] = group
+			}
+			executable, halt := rolloutReady(job, group)
+			if halt != "" && group.RolloutStatus.State != models.RolloutHalted {
+				logs.Logger.Printf("Rollout of job group %s halted: %s", group.ID, halt)
+				if err := s.repo.HaltRollout(group.ID, halt); err != nil {
+					return nil, err
+				}
+				group.RolloutStatus = models.RolloutStatus{State: models.RolloutHalted, Message: halt}
+			}
+			if !executable {
+				continue
+			}
+		}
+		ready = append(ready, job)
+	}
+	return &ready, nil
+}
+
+// undeferredJobs keeps the jobs no maintenance window of their cluster withholds
+func (s *jobService) undeferredJobs(jobs *[]models.Job) (*[]models.Job, error) {
+	if err := s.maintenance.DeferJobs(*jobs); err != nil {
 		return nil, err
 	}
+	executable := []models.Job{}
+	for _, job := range *jobs {
+		if job.Deferred != "" {
+			logs.Logger.Printf("Job %s deferred: %s", job.ID, job.Deferred)
+			continue
+		}
+		executable = append(executable, job)
+	}
+	return &executable, nil
+}
 
-	var jobOwnershipDTO models.JobOwnershipDTO
-	err := json.NewDecoder(r.Body).Decode(&jobOwnershipDTO)
-	if err != nil {
-		logs.Logger.Printf("Error decoding job patch body: %v", err)
+// fairJobs orders the executable jobs, see fairOrder. The tenants are only read when several job groups compete.
+func (s *jobService) fairJobs(jobs *[]models.Job) (*[]models.Job, error) {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, job := range *jobs {
+		if job.JobGroupID != "" && !seen[job.JobGroupID] {
+			seen[job.JobGroupID] = true
+			ids = append(ids, job.JobGroupID)
+		}
+	}
+	tenants := map[string]string{}
+	if len(ids) > 1 {
+		found, err := s.repo.FindJobGroupTenants(ids)
+		if err != nil {
+			return nil, err
+		}
+		tenants = found
+	}
+	ordered := fairOrder(*jobs, tenants, s.perGroup)
+	return &ordered, nil
+}
+
+// rendered applies the overlays of its job group to the manifests of a job found and stamps them, see
+// overlayJobs and stampJob
+func (s *jobService) rendered(job *models.Job, err error) (*models.Job, error) {
+	if err == nil && job != nil {
+		jobs := []models.Job{*job}
+		if _, err := s.renderedAll(&jobs, nil); err != nil {
+			return nil, err
+		}
+		job.Manifests = jobs[0].Manifests
+	}
+	return job, err
+}
+
+// renderedAll applies the overlays of their job groups to the manifests of the jobs found and stamps them. The
+// overlays are only read for the jobs of job groups. Undeployments leave the namespace created for their
+// application in place.
+func (s *jobService) renderedAll(jobs *[]models.Job, err error) (*[]models.Job, error) {
+	if err != nil || jobs == nil {
+		return jobs, err
+	}
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, job := range *jobs {
+		if job.JobGroupID != "" && !seen[job.JobGroupID] {
+			seen[job.JobGroupID] = true
+			ids = append(ids, job.JobGroupID)
+		}
+	}
+	if len(ids) > 0 {
+		overlays, err := s.repo.FindJobGroupOverlays(ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range *jobs {
+			overlayJobs((*jobs)[i:i+1], overlays[(*jobs)[i].JobGroupID])
+		}
+	}
+	withoutCreatedNamespace(*jobs)
+	stampJobs(*jobs)
+	return jobs, nil
+}
+
+// JobPromote hands a job over to the authenticated agent, the owner is taken from its credential.
+// A non zero version must match the stored one.
+func (s *jobService) JobPromote(jobID string, agent *models.ServiceAccount, version int64) (*models.Job, error) {
+	if jobID == "" {
+		err := errors.New("job ID Cannot be empty")
+		logs.Logger.Println("job ID Cannot be empty")
 		return nil, err
 	}
-	if jobOwnershipDTO.OwnerID == "" {
+	if agent == nil || agent.OwnerID == "" {
 		err := errors.New("owner ID Cannot be empty")
 		logs.Logger.Println("owner ID Cannot be empty")
 		return nil, err
 	}
 
-	jobGotten, err := s.FindJobByUUID(stringJobID)
+	// the job is saved again, it is only stamped once promoted
+	jobGotten, err := s.repo.FindJobByUUID(jobID)
 	if err != nil {
 		logs.Logger.Printf("Error retrieving job: %v", err)
 		return nil, err
 	}
 
+	if err := checkResourceVersion(version, jobGotten.ResourceVersion); err != nil {
+		logs.Logger.Printf("Job with ID %s cannot be promoted: %v", jobGotten.ID, err)
+		return nil, err
+	}
+
+	if jobGotten.Orchestrator != agent.Orchestrator {
+		logs.Logger.Printf("Job with ID %s belongs to orchestrator %s, agent is %s", jobGotten.ID, jobGotten.Orchestrator, agent.Orchestrator)
+		return nil, fmt.Errorf("%w: job is not executable by a %s agent", ErrForbidden, agent.Orchestrator)
+	}
+
+	if jobGotten.JobGroupID != "" && jobGotten.Type != models.DeleteDeployment {
+		// as in FindJobsToExecute, undeployments aside the jobs of job groups pending approval or rejected are held back
+		approvals, err := s.repo.FindJobGroupApprovals([]string{jobGotten.JobGroupID})
+		if err != nil {
+			logs.Logger.Printf("Error retrieving the approval of job group %s: %v", jobGotten.JobGroupID, err)
+			return nil, err
+		}
+		if state := approvals[jobGotten.JobGroupID].State; state == models.ApprovalPending || state == models.ApprovalRejected {
+			logs.Logger.Printf("Job with ID %s belongs to a job group whose approval is %s, cannot be promoted", jobGotten.ID, state)
+			return nil, fmt.Errorf("%w: job group %s is %s", ErrForbidden, jobGotten.JobGroupID, state)
+		}
+	}
+
 	switch jobGotten.State {
 	case models.JobCreated:
-		jobGotten.OwnerID = jobOwnershipDTO.OwnerID
+		// update and delete jobs can only be taken by the agent that already owns the deployment
+		if jobGotten.OwnerID != "" && jobGotten.OwnerID != agent.OwnerID {
+			logs.Logger.Printf("Job with ID %s is owned by another agent, cannot be promoted", jobGotten.ID)
+			return nil, fmt.Errorf("%w: job is owned by another agent", ErrForbidden)
+		}
+		jobGotten.OwnerID = agent.OwnerID
 		jobGotten.State = models.JobProgressing
 	case models.JobProgressing, models.JobFinished, models.JobDegraded:
 		err := errors.New("job cannot be promoted")
@@ -130,5 +393,5 @@ func (s *jobService) JobPromote(r *http.Request) (*models.Job, error) {
 		return nil, err
 	}
 
-	return updatedJob, nil
+	return s.rendered(updatedJob, nil)
 }
@@ -21,17 +21,19 @@
 package service
 
 import (
+	"strings"
 	"testing"
 
 	"icos/server/jobmanager-service/models"
 	repository "icos/server/jobmanager-service/service/mocks"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestJobService(t *testing.T) {
 	mockRepo := new(repository.MockJobRepository)
-	service := NewJobService(mockRepo)
+	service := NewJobService(mockRepo, nil)
 
 	job := &models.Job{}
 
@@ -45,15 +47,15 @@ func TestJobService(t *testing.T) {
 
 	t.Run("UpdateJob", func(t *testing.T) {
 		mockRepo.On("UpdateJob", job).Return(job, nil)
-		result, err := service.UpdateJob(job)
+		result, err := service.UpdateJob(job, "")
 		assert.NoError(t, err)
 		assert.Equal(t, job, result)
 		mockRepo.AssertExpectations(t)
 	})
 
 	t.Run("DeleteJob", func(t *testing.T) {
-		mockRepo.On("DeleteJob", "123").Return(int64(1), nil)
-		result, err := service.DeleteJob("123")
+		mockRepo.On("DeleteJob", "123", int64(0)).Return(int64(1), nil)
+		result, err := service.DeleteJob("123", 0)
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), result)
 		mockRepo.AssertExpectations(t)
@@ -102,12 +104,129 @@ func TestJobService(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 
-	// Refactor test to suit new implementation
-	// t.Run("JobPromote", func(t *testing.T) {
-	// 	mockRepo.On("JobPromote", job).Return(job, nil)
-	// 	result, err := service.JobPromote(job)
-	// 	assert.NoError(t, err)
-	// 	assert.Equal(t, job, result)
-	// 	mockRepo.AssertExpectations(t)
-	// })
+	t.Run("JobPromote", func(t *testing.T) {
+		agent := &models.ServiceAccount{OwnerID: "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c", Orchestrator: models.OCM}
+		created := &models.Job{BaseUUID: models.BaseUUID{ID: "promote-123"}, State: models.JobCreated, Orchestrator: models.OCM}
+		mockRepo.On("FindJobByUUID", "promote-123").Return(created, nil).Once()
+		mockRepo.On("JobPromote", created).Return(created, nil).Once()
+
+		result, err := service.JobPromote("promote-123", agent, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, agent.OwnerID, result.OwnerID)
+		assert.Equal(t, models.JobProgressing, result.State)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("JobPromoteOtherOrchestrator", func(t *testing.T) {
+		agent := &models.ServiceAccount{OwnerID: "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c", Orchestrator: models.Nuvla}
+		created := &models.Job{BaseUUID: models.BaseUUID{ID: "promote-456"}, State: models.JobCreated, Orchestrator: models.OCM}
+		mockRepo.On("FindJobByUUID", "promote-456").Return(created, nil).Once()
+
+		_, err := service.JobPromote("promote-456", agent, 0)
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+
+	t.Run("JobPromoteStaleVersion", func(t *testing.T) {
+		agent := &models.ServiceAccount{OwnerID: "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c", Orchestrator: models.OCM}
+		created := &models.Job{BaseUUID: models.BaseUUID{ID: "promote-789"}, State: models.JobCreated, Orchestrator: models.OCM, ResourceVersion: 3}
+		mockRepo.On("FindJobByUUID", "promote-789").Return(created, nil).Once()
+
+		_, err := service.JobPromote("promote-789", agent, 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+
+	t.Run("DeleteJobStaleVersion", func(t *testing.T) {
+		mockRepo.On("DeleteJob", "delete-123", int64(2)).Return(int64(0), ErrVersionConflict).Once()
+
+		_, err := service.DeleteJob("delete-123", 2)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+}
+
+func TestUpdateJobApprovalGate(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	base := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n"
+	storedJob := func(id, jobGroupID string) *models.Job {
+		return &models.Job{BaseUUID: models.BaseUUID{ID: id}, JobGroupID: jobGroupID, Resource: &models.Resource{ResourceName: "web"},
+			Manifests: []models.PlainManifest{{BaseUINT: models.BaseUINT{ID: 1}, YamlString: base}}}
+	}
+	mockRepo.On("FindJobGroupOverlays", mock.Anything).Return(map[string]models.OverlayList{}, nil)
+	mockRepo.On("FindJobGroupTenants", []string{"gated"}).Return(map[string]string{"gated": "team-a"}, nil)
+	mockRepo.On("FindJobGroupTenants", []string{"open"}).Return(map[string]string{"open": "team-a"}, nil)
+	mockRepo.On("FindJobGroupApprovals", []string{"gated"}).Return(map[string]models.ApprovalStatus{
+		"gated": {State: models.ApprovalApproved, RequestedBy: "alice", ReviewedBy: "bob"},
+	}, nil)
+	mockRepo.On("FindJobGroupApprovals", []string{"open"}).Return(map[string]models.ApprovalStatus{"open": {}}, nil)
+
+	t.Run("ManifestsOfGatedJobGroup", func(t *testing.T) {
+		mockRepo.On("FindJobByUUID", "job-1").Return(storedJob("job-1", "gated"), nil).Once()
+		updated := storedJob("job-1", "gated")
+		updated.Manifests[0].YamlString = strings.Replace(base, "replicas: 3", "replicas: 5", 1)
+
+		_, err := service.UpdateJob(updated, "team-a")
+		assert.ErrorIs(t, err, ErrForbidden)
+		mockRepo.AssertNotCalled(t, "UpdateJob", updated)
+	})
+
+	t.Run("StateOfGatedJobGroup", func(t *testing.T) {
+		mockRepo.On("FindJobByUUID", "job-2").Return(storedJob("job-2", "gated"), nil).Once()
+		updated := storedJob("job-2", "gated")
+		updated.State = models.JobFinished
+		mockRepo.On("UpdateJob", updated).Return(updated, nil).Once()
+
+		_, err := service.UpdateJob(updated, "team-a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("ManifestsOfUngatedJobGroup", func(t *testing.T) {
+		mockRepo.On("FindJobByUUID", "job-3").Return(storedJob("job-3", "open"), nil).Once()
+		updated := storedJob("job-3", "open")
+		updated.Manifests[0].YamlString = strings.Replace(base, "replicas: 3", "replicas: 5", 1)
+		mockRepo.On("UpdateJob", updated).Return(updated, nil).Once()
+
+		_, err := service.UpdateJob(updated, "team-a")
+		assert.NoError(t, err)
+	})
+
+	t.Run("OtherTenant", func(t *testing.T) {
+		mockRepo.On("FindJobByUUID", "job-4").Return(storedJob("job-4", "open"), nil).Once()
+		updated := storedJob("job-4", "open")
+
+		_, err := service.UpdateJob(updated, "team-b")
+		assert.ErrorIs(t, err, ErrForbidden)
+	})
+}
+
+func TestJobPromoteApprovalGate(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	agent := &models.ServiceAccount{OwnerID: "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c", Orchestrator: models.OCM}
+	mockRepo.On("FindJobGroupApprovals", []string{"pending"}).Return(map[string]models.ApprovalStatus{
+		"pending": {State: models.ApprovalPending, RequestedBy: "alice"},
+	}, nil)
+	mockRepo.On("FindJobGroupApprovals", []string{"rejected"}).Return(map[string]models.ApprovalStatus{
+		"rejected": {State: models.ApprovalRejected, RequestedBy: "alice", ReviewedBy: "bob", Reason: "too many replicas"},
+	}, nil)
+
+	for _, approval := range []string{"pending", "rejected"} {
+		job := &models.Job{BaseUUID: models.BaseUUID{ID: "job-" + approval}, JobGroupID: approval, Type: models.CreateDeployment,
+			State: models.JobCreated, Orchestrator: models.OCM}
+		mockRepo.On("FindJobByUUID", job.ID).Return(job, nil).Once()
+
+		_, err := service.JobPromote(job.ID, agent, 0)
+		assert.ErrorIs(t, err, ErrForbidden, approval)
+	}
+
+	// undeployments are not held back
+	undeploy := &models.Job{BaseUUID: models.BaseUUID{ID: "job-undeploy"}, JobGroupID: "pending", Type: models.DeleteDeployment,
+		State: models.JobCreated, Orchestrator: models.OCM, OwnerID: agent.OwnerID}
+	mockRepo.On("FindJobByUUID", undeploy.ID).Return(undeploy, nil).Once()
+	mockRepo.On("JobPromote", undeploy).Return(undeploy, nil).Once()
+	mockRepo.On("FindJobGroupOverlays", []string{"pending"}).Return(map[string]models.OverlayList{}, nil)
+
+	_, err := service.JobPromote(undeploy.ID, agent, 0)
+	assert.NoError(t, err)
 }
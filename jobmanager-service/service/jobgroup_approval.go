@@ -0,0 +1,151 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotPendingApproval is returned when reviewing a job group that does not wait for an approval
+var ErrNotPendingApproval = errors.New("JobGroup is not pending approval")
+
+// approvalGate tells which job groups wait for an approval before their jobs are executed: the ones deploying
+// into APPROVAL_NAMESPACES, comma separated glob patterns, or labelled with one of APPROVAL_LABELS, comma
+// separated key=value pairs
+type approvalGate struct {
+	namespaces []string
+	labels     map[string]string
+}
+
+// approvalGateFromEnv reads the approval gate from the environment, an empty gate approves every job group
+func approvalGateFromEnv() approvalGate {
+	gate := approvalGate{labels: map[string]string{}}
+	for _, namespace := range strings.Split(os.Getenv("APPROVAL_NAMESPACES"), ",") {
+		if namespace = strings.TrimSpace(namespace); namespace == "" {
+			continue
+		}
+		if _, err := path.Match(namespace, ""); err != nil {
+			logs.Logger.Printf("Ignoring invalid namespace pattern in APPROVAL_NAMESPACES: %s", namespace)
+			continue
+		}
+		gate.namespaces = append(gate.namespaces, namespace)
+	}
+	for _, label := range strings.Split(os.Getenv("APPROVAL_LABELS"), ",") {
+		if label = strings.TrimSpace(label); label == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			logs.Logger.Printf("Ignoring invalid label in APPROVAL_LABELS: %s", label)
+			continue
+		}
+		gate.labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return gate
+}
+
+// requires tells whether the jobs of a job group wait for an approval, the reason is returned
+func (g approvalGate) requires(jobGroup *models.JobGroup) (bool, string) {
+	for key, value := range g.labels {
+		if actual, ok := jobGroup.Labels[key]; ok && actual == value {
+			return true, fmt.Sprintf("label %s=%s", key, value)
+		}
+	}
+	for _, job := range jobGroup.Jobs {
+		for _, pattern := range g.namespaces {
+			if matched, _ := path.Match(pattern, job.Namespace); matched {
+				return true, "namespace " + job.Namespace
+			}
+		}
+	}
+	return false, ""
+}
+
+// requestApproval puts a created or updated job group on hold when the approval gate requires it, the previous
+// review is cleared otherwise
+func (s *jobGroupService) requestApproval(jobGroup *models.JobGroup, author string) {
+	jobGroup.Approval = models.ApprovalStatus{}
+	if required, reason := s.gate.requires(jobGroup); required {
+		logs.Logger.Printf("Job group %s waits for an approval, %s", jobGroup.AppName, reason)
+		jobGroup.Approval = models.ApprovalStatus{State: models.ApprovalPending, RequestedBy: author}
+	}
+}
+
+// ApproveJobGroup approves a job group of the tenant pending approval, its jobs are executed. A non zero
+// version must match the stored one.
+func (s *jobGroupService) ApproveJobGroup(id, approver, tenant string, version int64) (*models.JobGroup, error) {
+	return s.reviewJobGroup(id, approver, tenant, models.ApprovalApproved, "", version)
+}
+
+// RejectJobGroup rejects a job group of the tenant pending approval for a reason, its jobs stay held back until
+// the next update. A non zero version must match the stored one.
+func (s *jobGroupService) RejectJobGroup(id, approver, tenant, reason string, version int64) (*models.JobGroup, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, errors.New("a rejection needs a reason")
+	}
+	return s.reviewJobGroup(id, approver, tenant, models.ApprovalRejected, reason, version)
+}
+
+// reviewJobGroup records the review of a job group pending approval. Approvers review the job groups of their
+// tenant, the user who requested the approval cannot review it.
+func (s *jobGroupService) reviewJobGroup(id, approver, tenant, state, reason string, version int64) (*models.JobGroup, error) {
+	jobGroup, err := s.repo.FindJobGroupByUUID(id)
+	if err != nil {
+		logs.Logger.Println("Error finding job group by UUID:", err)
+		return nil, err
+	}
+	if err := checkTenant(jobGroup, tenant); err != nil {
+		logs.Logger.Println("Error reviewing job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroup.ResourceVersion); err != nil {
+		return nil, err
+	}
+	if jobGroup.Approval.State != models.ApprovalPending {
+		return nil, ErrNotPendingApproval
+	}
+	if approver == "" || approver == jobGroup.Approval.RequestedBy {
+		return nil, fmt.Errorf("%w: the job group must be reviewed by another user than %s", ErrForbidden, jobGroup.Approval.RequestedBy)
+	}
+
+	now := time.Now()
+	jobGroup.Approval.State = state
+	jobGroup.Approval.ReviewedBy = approver
+	jobGroup.Approval.ReviewedAt = &now
+	jobGroup.Approval.Reason = reason
+	jobGroupUpdated, err := s.repo.UpdateJobGroup(jobGroup)
+	if err != nil {
+		logs.Logger.Println("Error reviewing job group:", err)
+		return nil, err
+	}
+	logs.Logger.Printf("Job group %s %s by %s", id, strings.ToLower(state), approver)
+
+	reportRollout(jobGroupUpdated)
+	redactJobGroup(jobGroupUpdated)
+	return jobGroupUpdated, nil
+}
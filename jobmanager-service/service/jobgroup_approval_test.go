@@ -0,0 +1,91 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalGate(t *testing.T) {
+	t.Setenv("APPROVAL_NAMESPACES", "prod-*, payments")
+	t.Setenv("APPROVAL_LABELS", "env=production,invalid")
+	gate := approvalGateFromEnv()
+	assert.Equal(t, []string{"prod-*", "payments"}, gate.namespaces)
+	assert.Equal(t, map[string]string{"env": "production"}, gate.labels)
+
+	required, reason := gate.requires(&models.JobGroup{Jobs: []models.Job{{Namespace: "dev"}, {Namespace: "prod-eu"}}})
+	assert.True(t, required)
+	assert.Equal(t, "namespace prod-eu", reason)
+
+	required, reason = gate.requires(&models.JobGroup{Labels: models.StringMap{"env": "production"}, Jobs: []models.Job{{Namespace: "shop"}}})
+	assert.True(t, required)
+	assert.Equal(t, "label env=production", reason)
+
+	required, _ = gate.requires(&models.JobGroup{Labels: models.StringMap{"env": "staging"}, Jobs: []models.Job{{Namespace: "shop"}}})
+	assert.False(t, required)
+}
+
+func TestReviewJobGroup(t *testing.T) {
+	t.Setenv("APPROVAL_NAMESPACES", "prod")
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	service := NewJobGroupService(mockJobGroupRepo, nil, nil, nil).(*jobGroupService)
+
+	jobGroup := &models.JobGroup{BaseUUID: models.BaseUUID{ID: "group-1"}, Tenant: "team-a", ResourceVersion: 2, Jobs: []models.Job{{Namespace: "prod"}}}
+	service.requestApproval(jobGroup, "alice")
+	assert.Equal(t, models.ApprovalStatus{State: models.ApprovalPending, RequestedBy: "alice"}, jobGroup.Approval)
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(jobGroup, nil)
+	mockJobGroupRepo.On("FindJobGroupByUUID", "missing").Return((*models.JobGroup)(nil), errors.New("record not found"))
+	mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil)
+
+	_, err := service.ApproveJobGroup("group-1", "alice", "team-a", 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.ApproveJobGroup("group-1", "bob", "team-a", 1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	_, err = service.RejectJobGroup("group-1", "bob", "team-a", " ", 0)
+	assert.Error(t, err)
+	_, err = service.ApproveJobGroup("missing", "bob", "team-a", 0)
+	assert.Error(t, err)
+
+	// approvers only review the job groups of their tenant
+	_, err = service.ApproveJobGroup("group-1", "carol", "team-b", 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	result, err := service.RejectJobGroup("group-1", "bob", "team-a", "wrong image tag", 2)
+	require.NoError(t, err)
+	assert.Equal(t, models.ApprovalRejected, result.Approval.State)
+	assert.Equal(t, "bob", result.Approval.ReviewedBy)
+	assert.Equal(t, "wrong image tag", result.Approval.Reason)
+	assert.NotNil(t, result.Approval.ReviewedAt)
+
+	_, err = service.ApproveJobGroup("group-1", "bob", "", 0)
+	assert.ErrorIs(t, err, ErrNotPendingApproval)
+
+	// an update outside of the gated namespaces clears the review
+	jobGroup.Jobs[0].Namespace = "dev"
+	service.requestApproval(jobGroup, "alice")
+	assert.Equal(t, models.ApprovalStatus{}, jobGroup.Approval)
+}
@@ -0,0 +1,141 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"sort"
+	"strings"
+)
+
+// validateDependencies reports the dependencies on unknown components and the dependency cycles
+func validateDependencies(components []models.Component) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	indexes := map[string]int{}
+	for c, comp := range components {
+		indexes[comp.Name] = c
+	}
+	for c, comp := range components {
+		for d, dependency := range comp.DependsOn {
+			if _, ok := indexes[dependency]; !ok {
+				issues = append(issues, models.ValidationIssue{
+					Path:    fmt.Sprintf("components[%d].dependsOn[%d]", c, d),
+					Code:    IssueNotFound,
+					Message: fmt.Sprintf("component %s depends on unknown component %s", comp.Name, dependency),
+				})
+			}
+		}
+	}
+
+	// depth first search, a component met again while it is being visited closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := map[string]int{}
+	reported := map[string]bool{}
+	var visit func(name string, stack []string)
+	visit = func(name string, stack []string) {
+		states[name] = visiting
+		stack = append(stack, name)
+		for _, dependency := range components[indexes[name]].DependsOn {
+			if _, ok := indexes[dependency]; !ok {
+				continue
+			}
+			switch states[dependency] {
+			case unvisited:
+				visit(dependency, stack)
+			case visiting:
+				cycle := []string{dependency}
+				for i := len(stack) - 1; stack[i] != dependency; i-- {
+					cycle = append([]string{stack[i]}, cycle...)
+				}
+				cycle = append([]string{dependency}, cycle...)
+				members := append([]string{}, cycle[1:]...)
+				sort.Strings(members)
+				if key := strings.Join(members, ","); !reported[key] {
+					reported[key] = true
+					issues = append(issues, models.ValidationIssue{
+						Path:    fmt.Sprintf("components[%d].dependsOn", indexes[name]),
+						Code:    IssueInvalid,
+						Message: "dependency cycle: " + strings.Join(cycle, " -> "),
+					})
+				}
+			}
+		}
+		states[name] = visited
+	}
+	for _, comp := range components {
+		if states[comp.Name] == unvisited {
+			visit(comp.Name, nil)
+		}
+	}
+	return issues
+}
+
+// componentName returns the name of the component a job deploys
+func componentName(job models.Job) string {
+	if job.Resource == nil {
+		return ""
+	}
+	return job.Resource.ResourceName
+}
+
+// isAvailable tells whether the resource of a deployed job reports it available
+func isAvailable(job models.Job) bool {
+	if job.State == models.JobCreated || job.Resource == nil {
+		return false
+	}
+	for _, condition := range job.Resource.Conditions {
+		if condition.Type == models.Available && condition.Status == models.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// dependenciesReady tells whether a job can be executed given the jobs of its group: deployments wait for the
+// components they depend on to be available, undeployments wait for the components depending on them to be
+// undeployed
+func dependenciesReady(job models.Job, group []models.Job) bool {
+	if job.Type == models.DeleteDeployment {
+		name := componentName(job)
+		for _, other := range group {
+			for _, dependency := range other.DependsOn {
+				if dependency == name && other.ID != job.ID && other.State != models.JobFinished {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for _, dependency := range job.DependsOn {
+		for _, other := range group {
+			if componentName(other) == dependency && other.ID != job.ID && !isAvailable(other) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDependencies(t *testing.T) {
+	issues := validateDependencies([]models.Component{
+		{Name: "db"},
+		{Name: "api", DependsOn: []string{"db"}},
+		{Name: "web", DependsOn: []string{"api", "cache"}},
+	})
+	assert.Equal(t, []models.ValidationIssue{{
+		Path:    "components[2].dependsOn[1]",
+		Code:    IssueNotFound,
+		Message: "component web depends on unknown component cache",
+	}}, issues)
+
+	issues = validateDependencies([]models.Component{
+		{Name: "db", DependsOn: []string{"web"}},
+		{Name: "api", DependsOn: []string{"db"}},
+		{Name: "web", DependsOn: []string{"api"}},
+		{Name: "worker", DependsOn: []string{"worker"}},
+	})
+	require.Len(t, issues, 2)
+	assert.Equal(t, IssueInvalid, issues[0].Code)
+	assert.Equal(t, "components[1].dependsOn", issues[0].Path)
+	assert.Equal(t, "dependency cycle: db -> web -> api -> db", issues[0].Message)
+	assert.Equal(t, "dependency cycle: worker -> worker", issues[1].Message)
+}
+
+func TestBuildJobGroupDependencies(t *testing.T) {
+	validation := buildJobGroup(models.JobGroupHeader{
+		Name: "shop",
+		Components: []models.Component{
+			{Name: "db", Type: models.ManifestComponent, Manifests: []models.ManifestDTO{{Name: "db"}}},
+			{Name: "api", Type: models.ManifestComponent, Manifests: []models.ManifestDTO{{Name: "db"}}, DependsOn: []string{"db"}},
+		},
+		Manifests: []interface{}{map[string]interface{}{
+			"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "db"},
+		}},
+	}, "team-a")
+	require.True(t, validation.Valid, validation.Errors)
+	assert.Equal(t, models.StringList{"db"}, validation.JobGroup.Jobs[1].DependsOn)
+}
+
+func dependencyJob(id, component string, jobType models.JobType, state models.JobState, available bool, dependsOn ...string) models.Job {
+	job := models.Job{
+		BaseUUID:   models.BaseUUID{ID: id},
+		JobGroupID: "group-1",
+		Type:       jobType,
+		State:      state,
+		DependsOn:  dependsOn,
+		Resource:   &models.Resource{ResourceName: component},
+	}
+	if available {
+		job.Resource.Conditions = []models.Condition{{Type: models.Available, Status: models.ConditionTrue}}
+	}
+	return job
+}
+
+func TestFindJobsToExecuteWaitsForDependencies(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	db := dependencyJob("db", "db", models.CreateDeployment, models.JobProgressing, false)
+	api := dependencyJob("api", "api", models.CreateDeployment, models.JobCreated, false, "db")
+	mockRepo.On("FindJobsToExecute", "ocm", "agent").Return(&[]models.Job{api}, nil)
+	mockRepo.On("FindJobsByJobGroup", "group-1").Return(&[]models.Job{db, api}, nil).Once()
+	mockRepo.On("FindJobGroupOverlays", []string{"group-1"}).Return(map[string]models.OverlayList{}, nil)
+
+	jobs, err := service.FindJobsToExecute("ocm", "agent")
+	require.NoError(t, err)
+	assert.Empty(t, *jobs)
+
+	db = dependencyJob("db", "db", models.CreateDeployment, models.JobProgressing, true)
+	mockRepo.On("FindJobsByJobGroup", "group-1").Return(&[]models.Job{db, api}, nil).Once()
+	mockRepo.On("FindJobGroupRollout", "group-1").Return(&models.JobGroup{Jobs: []models.Job{db, api}}, nil).Once()
+
+	jobs, err = service.FindJobsToExecute("ocm", "agent")
+	require.NoError(t, err)
+	require.Len(t, *jobs, 1)
+	assert.Equal(t, "api", (*jobs)[0].ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDependenciesReadyOnUndeploy(t *testing.T) {
+	db := dependencyJob("db", "db", models.DeleteDeployment, models.JobCreated, true)
+	api := dependencyJob("api", "api", models.DeleteDeployment, models.JobCreated, true, "db")
+
+	// the database is undeployed once the API depending on it is
+	assert.True(t, dependenciesReady(api, []models.Job{db, api}))
+	assert.False(t, dependenciesReady(db, []models.Job{db, api}))
+
+	api.State = models.JobFinished
+	assert.True(t, dependenciesReady(db, []models.Job{db, api}))
+}
@@ -0,0 +1,518 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+)
+
+// parseApplicationDescriptor parses the YAML application descriptor sent by the user, rendered with the values
+// of its parameters. The documents following the descriptor in a multi-document body are manifests of the
+// application, they are appended to its manifests. The descriptor is returned along with its single document
+// form, which is the one sent to the matchmaker.
+func parseApplicationDescriptor(bodyBytes []byte, values map[string]string) (models.JobGroupHeader, []byte, error) {
+	bodyStringTrimmed := strings.Trim(string(bodyBytes), "\r\n")
+
+	applicationDescriptor := models.JobGroupHeader{}
+	invalid := func(path string, err error) (models.JobGroupHeader, []byte, error) {
+		return applicationDescriptor, nil, &ValidationError{Issues: []models.ValidationIssue{
+			{Path: path, Code: IssueInvalid, Message: err.Error()},
+		}}
+	}
+
+	rendered, resolved, issues := renderDescriptor(bodyStringTrimmed, values)
+	if len(issues) > 0 {
+		return applicationDescriptor, nil, &ValidationError{Issues: issues}
+	}
+
+	documents, err := splitYAMLDocuments(rendered)
+	if err != nil {
+		return invalid("", err)
+	}
+	descriptor := map[interface{}]interface{}{}
+	if len(documents) > 0 {
+		var ok bool
+		if descriptor, ok = documents[0].(map[interface{}]interface{}); !ok {
+			return invalid("", fmt.Errorf("application descriptor is not a map"))
+		}
+	}
+	if len(documents) > 1 {
+		manifests, ok := descriptor["manifests"].([]interface{})
+		if !ok && descriptor["manifests"] != nil {
+			return invalid("manifests", fmt.Errorf("manifests is not a list"))
+		}
+		descriptor["manifests"] = append(manifests, documents[1:]...)
+	}
+
+	descriptorBytes, err := yaml.Marshal(descriptor)
+	if err != nil {
+		return invalid("", err)
+	}
+	if err := yaml.Unmarshal(descriptorBytes, &applicationDescriptor); err != nil {
+		return invalid("", err)
+	}
+	applicationDescriptor.ParameterValues = resolved
+	logs.Logger.Printf("Application descriptor: %#v", applicationDescriptor)
+	return applicationDescriptor, descriptorBytes, nil
+}
+
+// splitYAMLDocuments decodes every document of a `---` separated YAML stream, empty documents are skipped
+func splitYAMLDocuments(yamlString string) ([]interface{}, error) {
+	documents := []interface{}{}
+	decoder := yaml.NewDecoder(strings.NewReader(yamlString))
+	for {
+		var document interface{}
+		if err := decoder.Decode(&document); err == io.EOF {
+			return documents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if document != nil {
+			documents = append(documents, document)
+		}
+	}
+}
+
+// manifestDocuments splits a manifest string of the descriptor into the YAML of its documents
+func manifestDocuments(manifest string) ([]string, error) {
+	documents, err := splitYAMLDocuments(manifest)
+	if err != nil {
+		return nil, err
+	}
+	documentsYAML := []string{}
+	for _, document := range documents {
+		documentYAML, err := yaml.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+		documentsYAML = append(documentsYAML, string(documentYAML))
+	}
+	return documentsYAML, nil
+}
+
+// descriptorManifest is a single manifest of the application descriptor along with its path in the descriptor
+type descriptorManifest struct {
+	path   string
+	object map[interface{}]interface{}
+}
+
+// expandManifest flattens a manifest entry of the descriptor: strings are split into their documents and the
+// items of List kinds are taken one by one, recursively. Entries that are not objects are reported as issues.
+func expandManifest(entry interface{}, path string) ([]descriptorManifest, []models.ValidationIssue) {
+	switch entry := yamlObject(entry).(type) {
+	case string:
+		documents, err := splitYAMLDocuments(entry)
+		if err != nil {
+			return nil, []models.ValidationIssue{{Path: path, Code: IssueInvalidManifest, Message: fmt.Sprintf("manifest documents cannot be parsed: %v", err)}}
+		}
+		return expandManifests(documents, path+".documents")
+	case map[interface{}]interface{}:
+		kind, _ := entry["kind"].(string)
+		if kind != "List" && !(strings.HasSuffix(kind, "List") && entry["items"] != nil) {
+			return []descriptorManifest{{path: path, object: entry}}, nil
+		}
+		items, ok := entry["items"].([]interface{})
+		if !ok && entry["items"] != nil {
+			return nil, []models.ValidationIssue{{Path: path + ".items", Code: IssueInvalidManifest, Message: kind + " items is not a list"}}
+		}
+		return expandManifests(items, path+".items")
+	default:
+		return nil, []models.ValidationIssue{{Path: path, Code: IssueInvalidManifest, Message: fmt.Sprintf("manifest is a %T, not an object", entry)}}
+	}
+}
+
+// expandManifests expands every entry of a manifest list, see expandManifest
+func expandManifests(entries []interface{}, path string) ([]descriptorManifest, []models.ValidationIssue) {
+	manifests, issues := []descriptorManifest{}, []models.ValidationIssue{}
+	for i, entry := range entries {
+		expanded, entryIssues := expandManifest(entry, fmt.Sprintf("%s[%d]", path, i))
+		manifests = append(manifests, expanded...)
+		issues = append(issues, entryIssues...)
+	}
+	return manifests, issues
+}
+
+// yamlObject converts the JSON maps of a value, as returned by the matchmaker, to the maps decoded from YAML
+func yamlObject(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		converted := map[interface{}]interface{}{}
+		for key, item := range value {
+			converted[key] = yamlObject(item)
+		}
+		return converted
+	case map[interface{}]interface{}:
+		for key, item := range value {
+			value[key] = yamlObject(item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = yamlObject(item)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// matchmake asks the matchmaker for the targets of the components, they replace the ones of the descriptor
+func (s *jobGroupService) matchmake(applicationDescriptor *models.JobGroupHeader, descriptorBytes []byte, header http.Header) error {
+	req, err := http.NewRequest("POST", models.MatchmakerBaseURL+"/matchmake", bytes.NewBuffer(descriptorBytes))
+	if err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		return err
+	}
+
+	// add content type
+	req.Header.Set("Content-Type", "application/x-yaml")
+	// forward the authorization token
+	req.Header.Add("Authorization", header.Get("Authorization"))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		logs.Logger.Printf("ERROR executing request: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	bodyMM, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logs.Logger.Printf("ERROR reading response body: %v", err)
+		return err
+	}
+
+	// Log and format MM response
+	dst := &bytes.Buffer{}
+	if err := json.Indent(dst, bodyMM, "", "  "); err != nil {
+		logs.Logger.Printf("ERROR formatting JSON response: %v", err)
+		return err
+	}
+	logs.Logger.Println("MM response is: " + dst.String())
+
+	if err := json.Unmarshal(bodyMM, applicationDescriptor); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		return err
+	}
+	logs.Logger.Printf("Matchmaking response details: %#v", *applicationDescriptor)
+	return nil
+}
+
+// buildJobGroup creates the job group of an application descriptor, with a job per component holding the
+// manifests it references. Every problem found is reported with its path in the descriptor, manifests that
+// cannot be used are left out of the jobs.
+func buildJobGroup(applicationDescriptor models.JobGroupHeader, tenant string) *models.JobGroupValidation {
+	validation := &models.JobGroupValidation{Warnings: []models.ValidationIssue{}, Errors: []models.ValidationIssue{}}
+	addError := func(path, code, format string, args ...interface{}) {
+		issue := models.ValidationIssue{Path: path, Code: code, Message: fmt.Sprintf(format, args...)}
+		logs.Logger.Printf("ERROR in application descriptor at %s: %s", issue.Path, issue.Message)
+		validation.Errors = append(validation.Errors, issue)
+	}
+	addWarning := func(path, code, format string, args ...interface{}) {
+		validation.Warnings = append(validation.Warnings, models.ValidationIssue{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	conditions := []models.Condition{
+		{
+			Type:               "Created",
+			Status:             "True",
+			ObservedGeneration: 1,
+			LastTransitionTime: time.Now(),
+			Reason:             "AwaitingForTarget",
+			Message:            "Waiting for the Target",
+		},
+		{
+			Type:               "Created",
+			Status:             "True",
+			ObservedGeneration: 1,
+			LastTransitionTime: time.Now(),
+			Reason:             "AwaitingForExecution",
+			Message:            "Waiting an Orchestrator to take the Job",
+		},
+	}
+
+	jobGroup := &validation.JobGroup
+	jobGroup.AppName = applicationDescriptor.Name
+	jobGroup.AppDescription = applicationDescriptor.Description
+	jobGroup.Tenant = tenant
+	jobGroup.Parameters = applicationDescriptor.ParameterValues
+
+	if jobGroup.AppName == "" {
+		jobGroup.AppName = uuid.New().String()
+		addWarning("name", IssueGenerated, "application has no name, generated %s", jobGroup.AppName)
+	}
+
+	namespace, namespaceIssue := jobNamespace(applicationDescriptor.Namespace, jobGroup.AppName)
+	if namespaceIssue != nil && namespaceIssue.Code == IssueGenerated {
+		validation.Warnings = append(validation.Warnings, *namespaceIssue)
+	} else if namespaceIssue != nil {
+		validation.Errors = append(validation.Errors, *namespaceIssue)
+	}
+	namespaceObjects := []models.PlainManifest{}
+	if applicationDescriptor.CreateNamespace != nil && namespace != "" {
+		var issues []models.ValidationIssue
+		namespaceObjects, issues = namespaceManifests(namespace, applicationDescriptor.CreateNamespace)
+		validation.Errors = append(validation.Errors, issues...)
+	}
+
+	// validate the manifests once, in the order of the descriptor. Manifests that are not valid are still
+	// candidates of the references, so that they are not reported as missing as well.
+	manifests := []*namedManifest{}
+	expanded, issues := expandManifests(applicationDescriptor.Manifests, "manifests")
+	validation.Errors = append(validation.Errors, issues...)
+	for _, manifest := range expanded {
+		path := manifest.path
+		manifestMap, ok := manifest.object["metadata"].(map[interface{}]interface{})
+		if !ok {
+			addError(path+".metadata", IssueRequired, "manifest metadata is missing or is not a map")
+			continue
+		}
+
+		manifestName, ok := manifestMap["name"].(string)
+		if !ok || manifestName == "" {
+			addError(path+".metadata.name", IssueRequired, "manifest name is missing or is not a string")
+			continue
+		}
+		named := &namedManifest{descriptorManifest: manifest, name: manifestName}
+		named.kind, _ = manifest.object["kind"].(string)
+		named.apiVersion, _ = manifest.object["apiVersion"].(string)
+		named.namespace, _ = manifestMap["namespace"].(string)
+		for _, other := range manifests {
+			if other.identity() == named.identity() {
+				addError(path, IssueDuplicate, "manifest %s is defined more than once, first at %s", named.identity(), other.path)
+			}
+		}
+		manifests = append(manifests, named)
+
+		manifestYAML, err := yaml.Marshal(manifest.object)
+		if err != nil {
+			addError(path, IssueInvalidManifest, "manifest %s cannot be marshalled: %v", manifestName, err)
+			continue
+		}
+
+		if _, err := decodeYAMLToObject(string(manifestYAML)); err != nil {
+			addError(path, IssueInvalidManifest, "manifest %s is not a valid Kubernetes object: %v", manifestName, err)
+			continue
+		}
+		named.yaml = string(manifestYAML)
+	}
+
+	if len(applicationDescriptor.Components) == 0 {
+		addError("components", IssueRequired, "application has no components")
+	}
+
+	validation.Errors = append(validation.Errors, validateOverlays(applicationDescriptor.Overlays, applicationDescriptor.Components)...)
+	jobGroup.Overlays = applicationDescriptor.Overlays
+	validation.Errors = append(validation.Errors, validateDependencies(applicationDescriptor.Components)...)
+	if applicationDescriptor.Rollout != nil {
+		jobGroup.Rollout = *applicationDescriptor.Rollout
+	}
+	validation.Errors = append(validation.Errors, validateRollout(&jobGroup.Rollout, "rollout")...)
+	startRollout(jobGroup)
+	if len(applicationDescriptor.Labels) > 0 {
+		jobGroup.Labels = applicationDescriptor.Labels
+	}
+	jobGroup.Priority = applicationDescriptor.Priority
+	validation.Errors = append(validation.Errors, validatePriority(jobGroup.Priority, "priority")...)
+
+	componentNames := map[string]bool{}
+	for c, comp := range applicationDescriptor.Components {
+		path := fmt.Sprintf("components[%d]", c)
+		if comp.Name == "" {
+			addError(path+".name", IssueRequired, "component name is missing")
+		} else if componentNames[comp.Name] {
+			addError(path+".name", IssueDuplicate, "component %s is defined more than once", comp.Name)
+		}
+		componentNames[comp.Name] = true
+
+		job := models.Job{
+			Type:         models.CreateDeployment,
+			State:        models.JobCreated,
+			JobGroupName: jobGroup.AppName,
+			Namespace:    namespace,
+			DependsOn:    comp.DependsOn,
+			Priority:     jobGroup.Priority,
+			Resource: &models.Resource{
+				ResourceName: comp.Name,
+				Conditions:   conditions,
+			},
+		}
+
+		// the namespace comes first on every target
+		job.Manifests = append(job.Manifests, namespaceObjects...)
+
+		targets, err := componentTargets(comp.Targets)
+		if err != nil {
+			addError(path+".targets", IssueInvalid, "%v", err)
+		} else if targets.Orchestrator == "" {
+			addWarning(path+".targets", IssueNoTarget, "component %s has no target", comp.Name)
+		}
+		job.Targets = targets
+		job.Orchestrator = targets.Orchestrator
+
+		if len(comp.Manifests) == 0 && comp.Type != models.HelmComponent {
+			addError(path+".manifests", IssueRequired, "component %s references no manifest", comp.Name)
+		}
+		selected := map[*namedManifest]bool{}
+		for m, manifestRef := range comp.Manifests {
+			refPath := fmt.Sprintf("%s.manifests[%d]", path, m)
+			matches := []*namedManifest{}
+			for _, manifest := range manifests {
+				if manifest.matches(manifestRef) {
+					matches = append(matches, manifest)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				addError(refPath+".name", IssueNotFound, "manifest %s is not defined", manifestRef.Name)
+			case 1:
+				matches[0].referenced = true
+				selected[matches[0]] = true
+			default:
+				candidates := []string{}
+				for _, manifest := range matches {
+					candidates = append(candidates, manifest.identity())
+				}
+				addError(refPath, IssueAmbiguous, "manifest %s matches %s, set its kind, apiVersion or namespace",
+					manifestRef.Name, strings.Join(candidates, ", "))
+			}
+		}
+
+		// the manifests keep the order of the descriptor
+		for _, manifest := range manifests {
+			if selected[manifest] && manifest.yaml != "" {
+				logs.Logger.Println("Manifest to be populated: " + manifest.name)
+				job.Manifests = append(job.Manifests, models.PlainManifest{
+					YamlString: manifest.yaml,
+				})
+			}
+		}
+
+		// the chart of helm components is rendered into plain manifests, orchestrators never see the chart
+		if comp.Type == models.HelmComponent {
+			rendered, err := renderHelmComponent(comp, job.Namespace)
+			if err != nil {
+				addError(path+".chart", IssueInvalid, "chart of component %s cannot be rendered: %v", comp.Name, err)
+				rendered = &renderedChart{}
+			}
+			for _, hook := range rendered.hooks {
+				addWarning(path+".chart", IssueIgnored, "hook %s of component %s is not deployed", hook, comp.Name)
+			}
+			for _, manifestYAML := range rendered.manifests {
+				if _, err := decodeYAMLToObject(manifestYAML); err != nil {
+					addError(path+".chart", IssueInvalidManifest, "chart of component %s renders an invalid manifest: %v", comp.Name, err)
+					continue
+				}
+				job.Manifests = append(job.Manifests, models.PlainManifest{
+					YamlString: manifestYAML,
+				})
+			}
+		}
+
+		// overlays are applied whenever the job is read, see overlayJobs, the patches failing on its target are
+		// reported now
+		validation.Errors = append(validation.Errors, previewOverlays(applicationDescriptor.Overlays, job)...)
+
+		jobGroup.Jobs = append(jobGroup.Jobs, job)
+		logs.Logger.Println("New Job appended to JobGroup: " + job.JobGroupID)
+	}
+
+	for _, manifest := range manifests {
+		if !manifest.referenced {
+			addWarning(manifest.path, IssueUnreferenced, "manifest %s is not referenced by any component", manifest.name)
+		}
+	}
+
+	validation.Valid = len(validation.Errors) == 0
+	return validation
+}
+
+// namedManifest is a manifest of the descriptor with the fields identifying it, its YAML is empty when the
+// manifest is not a valid Kubernetes object
+type namedManifest struct {
+	descriptorManifest
+	name       string
+	kind       string
+	apiVersion string
+	namespace  string
+	yaml       string
+	referenced bool
+}
+
+// identity describes the manifest as apiVersion, kind and namespaced name
+func (m *namedManifest) identity() string {
+	name := m.name
+	if m.namespace != "" {
+		name = m.namespace + "/" + name
+	}
+	return strings.Join(strings.Fields(m.apiVersion+" "+m.kind+" "+name), " ")
+}
+
+// matches tells whether the reference designates the manifest, the fields left empty in the reference match
+// any manifest
+func (m *namedManifest) matches(ref models.ManifestDTO) bool {
+	return ref.Name == m.name &&
+		(ref.Kind == "" || ref.Kind == m.kind) &&
+		(ref.APIVersion == "" || ref.APIVersion == m.apiVersion) &&
+		(ref.Namespace == "" || ref.Namespace == m.namespace)
+}
+
+// componentTargets parses the targets of a component. Given that it is possible that MM returns an empty
+// target, we need to handle this case: a map is a single target object, while a missing target or an
+// empty array means there are no targets yet. Any other type is an unexpected scenario.
+func componentTargets(targets interface{}) (models.Target, error) {
+	target := models.Target{}
+	switch targets := targets.(type) {
+	case nil:
+		return target, nil
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, value := range targets {
+			converted[fmt.Sprint(key)] = value
+		}
+		return componentTargets(converted)
+	case map[string]interface{}:
+		targetBytes, err := json.Marshal(targets)
+		if err != nil {
+			return target, err
+		}
+		err = json.Unmarshal(targetBytes, &target)
+		return target, err
+	case []interface{}:
+		if len(targets) == 0 {
+			return target, nil
+		}
+		return target, fmt.Errorf("unexpected non-empty array for targets")
+	default:
+		return target, fmt.Errorf("unexpected type for targets: %T", targets)
+	}
+}
@@ -0,0 +1,480 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/secrets"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Changes reported by diffs
+const (
+	ChangeAdded     = "added"
+	ChangeRemoved   = "removed"
+	ChangeModified  = "changed"
+	ChangeUnchanged = "unchanged"
+)
+
+// currentState names the jobs of a job group as they are stored
+const currentState = "current"
+
+// diffState returns a job group at a revision, its stored state for revision 0
+func (s *jobGroupService) diffState(id string, revision int64) (*models.JobGroupRevision, string, error) {
+	if revision == 0 {
+		jobGroup, err := s.repo.FindJobGroupByUUID(id)
+		if err != nil {
+			return nil, "", err
+		}
+		return newRevision(jobGroup, "", ""), currentState, nil
+	}
+	jobGroupRevision, err := s.repo.FindJobGroupRevision(id, revision)
+	if err != nil {
+		return nil, "", err
+	}
+	return jobGroupRevision, fmt.Sprintf("revision %d", revision), nil
+}
+
+// DiffJobGroup compares two revisions of a job group, revision 0 being its current state
+func (s *jobGroupService) DiffJobGroup(id string, from, to int64) (*models.JobGroupDiff, error) {
+	fromState, fromName, err := s.diffState(id, from)
+	if err != nil {
+		return nil, err
+	}
+	toState, toName, err := s.diffState(id, to)
+	if err != nil {
+		return nil, err
+	}
+	return newJobGroupDiff(fromName, toName, fromState, toState), nil
+}
+
+// DiffJobGroupDescriptor compares the current state of a job group with the one a descriptor would lead to.
+// The deployed targets are kept unless the matchmaker is asked for new ones.
+func (s *jobGroupService) DiffJobGroupDescriptor(id string, bodyBytes []byte, params map[string]string, header http.Header, tenant string, matchmaking bool) (*models.JobGroupDiff, error) {
+	current, _, err := s.diffState(id, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	applicationDescriptor, descriptorBytes, err := parseApplicationDescriptor(bodyBytes, params)
+	if err != nil {
+		return nil, err
+	}
+	if matchmaking {
+		if err := s.matchmake(&applicationDescriptor, descriptorBytes, header); err != nil {
+			return nil, err
+		}
+	} else {
+		for i := range applicationDescriptor.Components {
+			applicationDescriptor.Components[i].Targets = nil
+		}
+	}
+
+	validation := buildJobGroup(applicationDescriptor, tenant)
+	if !validation.Valid {
+		return nil, &ValidationError{Issues: validation.Errors}
+	}
+	proposed := newRevision(&validation.JobGroup, "", "")
+
+	issues := []models.ValidationIssue{}
+	if !matchmaking {
+		deployed := map[string]models.RevisionJob{}
+		for _, job := range current.Jobs {
+			deployed[job.Component] = job
+		}
+		for i := range proposed.Jobs {
+			if job, ok := deployed[proposed.Jobs[i].Component]; ok {
+				proposed.Jobs[i].Targets, proposed.Jobs[i].Orchestrator = job.Targets, job.Orchestrator
+			}
+		}
+	}
+	for _, warning := range validation.Warnings {
+		if matchmaking || warning.Code != IssueNoTarget {
+			issues = append(issues, warning)
+		}
+	}
+
+	diff := newJobGroupDiff(currentState, "descriptor", current, proposed)
+	diff.Issues = issues
+	return diff, nil
+}
+
+// newJobGroupDiff compares two states of a job group: the jobs of its components, matched by name, as they are
+// deployed with the overlays applied, the values of its parameters and its overlays
+func newJobGroupDiff(fromName, toName string, fromState, toState *models.JobGroupRevision) *models.JobGroupDiff {
+	diff := &models.JobGroupDiff{From: fromName, To: toName, Components: []models.ComponentDiff{}}
+	from := renderedJobs(fromState.Jobs, fromState.Overlays)
+	to := renderedJobs(toState.Jobs, toState.Overlays)
+
+	fromJobs := map[string]*models.RevisionJob{}
+	for i := range from {
+		fromJobs[componentKey(from[i])] = &from[i]
+	}
+	toKeys := map[string]bool{}
+	for i := range to {
+		key := componentKey(to[i])
+		toKeys[key] = true
+		diff.Components = append(diff.Components, diffComponent(key, fromJobs[key], &to[i]))
+	}
+	for i := range from {
+		if key := componentKey(from[i]); !toKeys[key] {
+			diff.Components = append(diff.Components, diffComponent(key, &from[i], nil))
+		}
+	}
+	diff.Parameters = diffParameters(fromState.Parameters, toState.Parameters)
+	diff.Overlays = diffOverlays(fromState.Overlays, toState.Overlays)
+
+	for _, component := range diff.Components {
+		if component.Change != ChangeUnchanged {
+			diff.Changed = true
+		}
+	}
+	diff.Changed = diff.Changed || len(diff.Parameters) > 0 || len(diff.Overlays) > 0
+	return diff
+}
+
+// renderedJobs returns a copy of the jobs of a state with the overlays selecting their targets applied, as
+// their agents deploy them
+func renderedJobs(revisionJobs models.RevisionJobs, overlays models.OverlayList) models.RevisionJobs {
+	if len(overlays) == 0 {
+		return revisionJobs
+	}
+	jobs := []models.Job{}
+	for _, revisionJob := range revisionJobs {
+		job := models.Job{
+			BaseUUID:     models.BaseUUID{ID: revisionJob.JobID},
+			Resource:     &models.Resource{ResourceName: revisionJob.Component},
+			Targets:      revisionJob.Targets,
+			Orchestrator: revisionJob.Orchestrator,
+			Namespace:    revisionJob.Namespace,
+			Manifests:    []models.PlainManifest{},
+		}
+		for _, manifest := range revisionJob.Manifests {
+			job.Manifests = append(job.Manifests, models.PlainManifest{YamlString: manifest})
+		}
+		jobs = append(jobs, job)
+	}
+	overlayJobs(jobs, overlays)
+
+	rendered := models.RevisionJobs{}
+	for i, revisionJob := range revisionJobs {
+		revisionJob.Manifests = []string{}
+		for _, manifest := range jobs[i].Manifests {
+			revisionJob.Manifests = append(revisionJob.Manifests, manifest.YamlString)
+		}
+		rendered = append(rendered, revisionJob)
+	}
+	return rendered
+}
+
+// diffParameters compares the values of the parameters of two states, the values are redacted
+func diffParameters(from, to models.StringMap) []models.FieldChange {
+	names := []string{}
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []models.FieldChange{}
+	for _, name := range names {
+		fromValue, inFrom := from[name]
+		toValue, inTo := to[name]
+		switch {
+		case !inFrom:
+			changes = append(changes, models.FieldChange{Path: fieldPath([]string{name}), Change: ChangeAdded, To: secrets.Redacted})
+		case !inTo:
+			changes = append(changes, models.FieldChange{Path: fieldPath([]string{name}), Change: ChangeRemoved, From: secrets.Redacted})
+		case fromValue != toValue:
+			changes = append(changes, models.FieldChange{Path: fieldPath([]string{name}), Change: ChangeModified, From: secrets.Redacted, To: secrets.Redacted})
+		}
+	}
+	return changes
+}
+
+// diffOverlays compares the overlays of two states by name, the sensitive fields their patches set are redacted
+func diffOverlays(from, to models.OverlayList) []models.FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	changes := diffFields("", nil, overlayObjects(redactOverlays(from)), overlayObjects(redactOverlays(to)))
+	if len(changes) == 0 {
+		// only redacted values changed
+		for name, overlay := range overlayObjects(from) {
+			if other, ok := overlayObjects(to)[name]; ok && !reflect.DeepEqual(overlay, other) {
+				changes = append(changes, models.FieldChange{Path: fieldPath([]string{name}), Change: ChangeModified, From: secrets.Redacted, To: secrets.Redacted})
+			}
+		}
+	}
+	return changes
+}
+
+// overlayObjects returns the overlays as objects by name, for diffFields
+func overlayObjects(overlays models.OverlayList) map[string]interface{} {
+	objects := map[string]interface{}{}
+	for _, overlay := range overlays {
+		overlayJSON, err := json.Marshal(overlay)
+		if err != nil {
+			continue
+		}
+		var object interface{}
+		if err := json.Unmarshal(overlayJSON, &object); err == nil {
+			objects[overlay.Name] = object
+		}
+	}
+	return objects
+}
+
+// componentKey identifies the job of a component, jobs without component are identified by their ID
+func componentKey(job models.RevisionJob) string {
+	if job.Component != "" {
+		return job.Component
+	}
+	return job.JobID
+}
+
+// diffComponent compares the job of a component in two states, either job is nil when missing
+func diffComponent(name string, from, to *models.RevisionJob) models.ComponentDiff {
+	component := models.ComponentDiff{Component: name, Change: ChangeUnchanged, Manifests: []models.ManifestDiff{}}
+	switch {
+	case from == nil:
+		component.Change = ChangeAdded
+		for _, manifest := range parseDiffManifests(to.Manifests) {
+			component.Manifests = append(component.Manifests, manifest.diff(ChangeAdded, nil))
+		}
+		return component
+	case to == nil:
+		component.Change = ChangeRemoved
+		for _, manifest := range parseDiffManifests(from.Manifests) {
+			component.Manifests = append(component.Manifests, manifest.diff(ChangeRemoved, nil))
+		}
+		return component
+	}
+
+	component.Fields = diffFields("", nil, map[string]interface{}{
+		"cluster_name": from.Targets.ClusterName,
+		"node_name":    from.Targets.NodeName,
+		"orchestrator": string(from.Orchestrator),
+		"namespace":    from.Namespace,
+	}, map[string]interface{}{
+		"cluster_name": to.Targets.ClusterName,
+		"node_name":    to.Targets.NodeName,
+		"orchestrator": string(to.Orchestrator),
+		"namespace":    to.Namespace,
+	})
+
+	fromManifests := map[string]diffManifest{}
+	for _, manifest := range parseDiffManifests(from.Manifests) {
+		fromManifests[manifest.key] = manifest
+	}
+	toKeys := map[string]bool{}
+	for _, manifest := range parseDiffManifests(to.Manifests) {
+		toKeys[manifest.key] = true
+		previous, ok := fromManifests[manifest.key]
+		if !ok {
+			component.Manifests = append(component.Manifests, manifest.diff(ChangeAdded, nil))
+			continue
+		}
+		fields := diffFields(manifest.head.Kind, nil, previous.object, manifest.object)
+		change := ChangeUnchanged
+		if len(fields) > 0 {
+			change = ChangeModified
+		}
+		component.Manifests = append(component.Manifests, manifest.diff(change, fields))
+	}
+	for _, manifest := range parseDiffManifests(from.Manifests) {
+		if !toKeys[manifest.key] {
+			component.Manifests = append(component.Manifests, manifest.diff(ChangeRemoved, nil))
+		}
+	}
+
+	if len(component.Fields) > 0 {
+		component.Change = ChangeModified
+	}
+	for _, manifest := range component.Manifests {
+		if manifest.Change != ChangeUnchanged {
+			component.Change = ChangeModified
+		}
+	}
+	return component
+}
+
+// diffManifest is a manifest parsed for a diff, identified by its apiVersion, kind, namespace and name
+type diffManifest struct {
+	key    string
+	head   manifestHead
+	object interface{}
+}
+
+// parseDiffManifests parses the manifests of a job, the ones that cannot be parsed are identified by position
+func parseDiffManifests(manifests []string) []diffManifest {
+	parsed := []diffManifest{}
+	for i, manifest := range manifests {
+		diff := diffManifest{}
+		if err := yaml.Unmarshal([]byte(manifest), &diff.head); err != nil {
+			diff.key = fmt.Sprintf("#%d", i)
+			diff.head.Metadata.Name = diff.key
+			diff.object = manifest
+		} else {
+			diff.key = strings.Join([]string{diff.head.APIVersion, diff.head.Kind, diff.head.Metadata.Namespace, diff.head.Metadata.Name}, "/")
+			yaml.Unmarshal([]byte(manifest), &diff.object)
+		}
+		parsed = append(parsed, diff)
+	}
+	return parsed
+}
+
+// diff reports a change of the manifest
+func (m diffManifest) diff(change string, fields []models.FieldChange) models.ManifestDiff {
+	return models.ManifestDiff{
+		APIVersion: m.head.APIVersion,
+		Kind:       m.head.Kind,
+		Namespace:  m.head.Metadata.Namespace,
+		Name:       m.head.Metadata.Name,
+		Change:     change,
+		Fields:     fields,
+	}
+}
+
+// diffFields compares two values of a manifest of the kind, maps are compared key by key and lists item by item.
+// The values of sensitive fields are redacted.
+func diffFields(kind string, path []string, from, to interface{}) []models.FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		keys := []string{}
+		for key := range fromMap {
+			keys = append(keys, key)
+		}
+		for key := range toMap {
+			if _, ok := fromMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		changes := []models.FieldChange{}
+		for _, key := range keys {
+			fromValue, inFrom := fromMap[key]
+			toValue, inTo := toMap[key]
+			keyPath := append(append([]string{}, path...), key)
+			switch {
+			case !inFrom:
+				changes = append(changes, fieldChange(kind, keyPath, ChangeAdded, nil, toValue))
+			case !inTo:
+				changes = append(changes, fieldChange(kind, keyPath, ChangeRemoved, fromValue, nil))
+			default:
+				changes = append(changes, diffFields(kind, keyPath, fromValue, toValue)...)
+			}
+		}
+		return changes
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		changes := []models.FieldChange{}
+		for i := 0; i < len(fromList) || i < len(toList); i++ {
+			itemPath := append(append([]string{}, path...), strconv.Itoa(i))
+			switch {
+			case i >= len(fromList):
+				changes = append(changes, fieldChange(kind, itemPath, ChangeAdded, nil, toList[i]))
+			case i >= len(toList):
+				changes = append(changes, fieldChange(kind, itemPath, ChangeRemoved, fromList[i], nil))
+			default:
+				changes = append(changes, diffFields(kind, itemPath, fromList[i], toList[i])...)
+			}
+		}
+		return changes
+	}
+
+	return []models.FieldChange{fieldChange(kind, path, ChangeModified, from, to)}
+}
+
+// fieldChange reports the change of a field, redacting the sensitive values it holds
+func fieldChange(kind string, path []string, change string, from, to interface{}) models.FieldChange {
+	return models.FieldChange{
+		Path:   fieldPath(path),
+		Change: change,
+		From:   redactValue(kind, path, from),
+		To:     redactValue(kind, path, to),
+	}
+}
+
+// redactValue replaces the sensitive fields of a value at the path of a manifest of the kind
+func redactValue(kind string, path []string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if secrets.IsSensitive(kind, path) {
+		return secrets.Redacted
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := map[string]interface{}{}
+		for key, child := range value {
+			redacted[key] = redactValue(kind, append(append([]string{}, path...), key), child)
+		}
+		return redacted
+	case []interface{}:
+		redacted := []interface{}{}
+		for i, child := range value {
+			redacted = append(redacted, redactValue(kind, append(append([]string{}, path...), strconv.Itoa(i)), child))
+		}
+		return redacted
+	}
+	return value
+}
+
+// fieldPath formats the path of a field, e.g. spec.containers[0].image or metadata.labels["app.kubernetes.io/name"]
+func fieldPath(path []string) string {
+	var builder strings.Builder
+	for _, segment := range path {
+		if _, err := strconv.Atoi(segment); err == nil {
+			builder.WriteString("[" + segment + "]")
+			continue
+		}
+		if strings.ContainsAny(segment, "./") {
+			builder.WriteString("[" + strconv.Quote(segment) + "]")
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString(".")
+		}
+		builder.WriteString(segment)
+	}
+	return builder.String()
+}
@@ -0,0 +1,220 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"icos/server/jobmanager-service/utils/secrets"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  replicas: %s
+  template:
+    spec:
+      containers:
+      - image: %s
+        name: web
+`
+
+const diffSecret = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: shop
+stringData:
+  password: %s
+`
+
+func diffJobGroupService() (service.JobGroupService, *repository.MockJobGroupRepository) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	return newJobGroupService(mockJobGroupRepo, new(MockHTTPClient)), mockJobGroupRepo
+}
+
+func TestDiffJobGroup(t *testing.T) {
+	jobGroupService, mockJobGroupRepo := diffJobGroupService()
+
+	target := models.Target{ClusterName: "edge-1", Orchestrator: models.OCM}
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(1)).Return(&models.JobGroupRevision{Revision: 1, Jobs: models.RevisionJobs{
+		{JobID: "job-1", Component: "web", Targets: target, Orchestrator: models.OCM, Manifests: []string{
+			fmt.Sprintf(diffDeployment, "1", "shop:1"), fmt.Sprintf(diffSecret, "old"),
+		}},
+		{JobID: "job-2", Component: "worker", Targets: target, Orchestrator: models.OCM, Manifests: []string{}},
+	}}, nil)
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(&models.JobGroup{BaseUUID: models.BaseUUID{ID: "group-1"}, Jobs: []models.Job{{
+		BaseUUID:     models.BaseUUID{ID: "job-1"},
+		Resource:     &models.Resource{ResourceName: "web"},
+		Targets:      models.Target{ClusterName: "edge-2", Orchestrator: models.OCM},
+		Orchestrator: models.OCM,
+		Manifests: []models.PlainManifest{
+			{YamlString: fmt.Sprintf(diffDeployment, "3", "shop:2")},
+			{YamlString: fmt.Sprintf(diffSecret, "new")},
+			{YamlString: "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: shop\n"},
+		},
+	}}}, nil)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(5)).Return((*models.JobGroupRevision)(nil), errors.New("record not found"))
+
+	diff, err := jobGroupService.DiffJobGroup("group-1", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "revision 1", diff.From)
+	assert.Equal(t, "current", diff.To)
+	assert.True(t, diff.Changed)
+	require.Len(t, diff.Components, 2)
+
+	web := diff.Components[0]
+	assert.Equal(t, "web", web.Component)
+	assert.Equal(t, service.ChangeModified, web.Change)
+	assert.Equal(t, []models.FieldChange{{Path: "cluster_name", Change: service.ChangeModified, From: "edge-1", To: "edge-2"}}, web.Fields)
+	require.Len(t, web.Manifests, 3)
+	assert.Equal(t, "Deployment", web.Manifests[0].Kind)
+	assert.Equal(t, service.ChangeModified, web.Manifests[0].Change)
+	assert.Equal(t, []models.FieldChange{
+		{Path: "spec.replicas", Change: service.ChangeModified, From: float64(1), To: float64(3)},
+		{Path: "spec.template.spec.containers[0].image", Change: service.ChangeModified, From: "shop:1", To: "shop:2"},
+	}, web.Manifests[0].Fields)
+	// secret values never show up in a diff
+	assert.Equal(t, []models.FieldChange{
+		{Path: "stringData.password", Change: service.ChangeModified, From: secrets.Redacted, To: secrets.Redacted},
+	}, web.Manifests[1].Fields)
+	assert.Equal(t, "Service", web.Manifests[2].Kind)
+	assert.Equal(t, service.ChangeAdded, web.Manifests[2].Change)
+
+	assert.Equal(t, "worker", diff.Components[1].Component)
+	assert.Equal(t, service.ChangeRemoved, diff.Components[1].Change)
+
+	diff, err = jobGroupService.DiffJobGroup("group-1", 1, 1)
+	require.NoError(t, err)
+	assert.False(t, diff.Changed)
+
+	_, err = jobGroupService.DiffJobGroup("group-1", 5, 0)
+	assert.Error(t, err)
+}
+
+func TestDiffJobGroupDescriptor(t *testing.T) {
+	jobGroupService, mockJobGroupRepo := diffJobGroupService()
+
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(&models.JobGroup{BaseUUID: models.BaseUUID{ID: "group-1"}, Jobs: []models.Job{{
+		BaseUUID:     models.BaseUUID{ID: "job-1"},
+		Namespace:    "shop",
+		Resource:     &models.Resource{ResourceName: "web"},
+		Targets:      models.Target{ClusterName: "edge-1", Orchestrator: models.OCM},
+		Orchestrator: models.OCM,
+		Manifests:    []models.PlainManifest{{YamlString: fmt.Sprintf(diffDeployment, "1", "shop:1")}},
+	}}}, nil)
+
+	descriptor := []byte(`name: shop
+namespace: shop
+components:
+- name: web
+  type: manifest
+  manifests:
+  - name: web
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+    namespace: shop
+  spec:
+    replicas: 2
+    selector:
+      matchLabels:
+        app: web
+    template:
+      metadata:
+        labels:
+          app: web
+      spec:
+        containers:
+        - image: shop:1
+          name: web
+`)
+
+	diff, err := jobGroupService.DiffJobGroupDescriptor("group-1", descriptor, nil, http.Header{}, "team-a", false)
+	require.NoError(t, err)
+	assert.Equal(t, "descriptor", diff.To)
+	require.Len(t, diff.Components, 1)
+	web := diff.Components[0]
+	// the deployed targets are kept without matchmaking
+	assert.Empty(t, web.Fields)
+	require.Len(t, web.Manifests, 1)
+	assert.Contains(t, web.Manifests[0].Fields, models.FieldChange{Path: "spec.replicas", Change: service.ChangeModified, From: float64(1), To: float64(2)})
+	assert.Contains(t, web.Manifests[0].Fields, models.FieldChange{Path: "spec.selector", Change: service.ChangeAdded, To: map[string]interface{}{
+		"matchLabels": map[string]interface{}{"app": "web"},
+	}})
+	for _, issue := range diff.Issues {
+		assert.NotEqual(t, service.IssueNoTarget, issue.Code)
+	}
+
+	_, err = jobGroupService.DiffJobGroupDescriptor("group-1", []byte("name: shop\ncomponents: []\n"), nil, http.Header{}, "team-a", false)
+	var validationErr *service.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestDiffJobGroupOverlaysAndParameters(t *testing.T) {
+	jobGroupService, mockJobGroupRepo := diffJobGroupService()
+
+	jobs := models.RevisionJobs{{JobID: "job-1", Component: "web", Targets: models.Target{ClusterName: "edge-1", Orchestrator: models.OCM},
+		Orchestrator: models.OCM, Manifests: []string{fmt.Sprintf(diffDeployment, "3", "shop:1")}}}
+	edge := models.OverlayList{{Name: "edge", Targets: models.OverlayTargets{Cluster: "edge-*"}, Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}}
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(1)).Return(&models.JobGroupRevision{Revision: 1, Jobs: jobs,
+		Parameters: models.StringMap{"token": "old"}}, nil)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(2)).Return(&models.JobGroupRevision{Revision: 2, Jobs: jobs,
+		Parameters: models.StringMap{"token": "old"}, Overlays: edge}, nil)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(3)).Return(&models.JobGroupRevision{Revision: 3, Jobs: jobs,
+		Parameters: models.StringMap{"token": "new"}, Overlays: edge}, nil)
+
+	// the stored manifests are the same, the overlay changes what the agent deploys
+	diff, err := jobGroupService.DiffJobGroup("group-1", 1, 2)
+	require.NoError(t, err)
+	assert.True(t, diff.Changed)
+	require.Len(t, diff.Components, 1)
+	require.Len(t, diff.Components[0].Manifests, 1)
+	assert.Equal(t, []models.FieldChange{
+		{Path: "spec.replicas", Change: service.ChangeModified, From: float64(3), To: float64(1)},
+	}, diff.Components[0].Manifests[0].Fields)
+	require.Len(t, diff.Overlays, 1)
+	assert.Equal(t, "edge", diff.Overlays[0].Path)
+	assert.Equal(t, service.ChangeAdded, diff.Overlays[0].Change)
+	assert.Empty(t, diff.Parameters)
+
+	// parameter values never show up in a diff
+	diff, err = jobGroupService.DiffJobGroup("group-1", 2, 3)
+	require.NoError(t, err)
+	assert.True(t, diff.Changed)
+	assert.Equal(t, []models.FieldChange{
+		{Path: "token", Change: service.ChangeModified, From: secrets.Redacted, To: secrets.Redacted},
+	}, diff.Parameters)
+	assert.Empty(t, diff.Overlays)
+}
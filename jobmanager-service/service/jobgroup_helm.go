@@ -0,0 +1,192 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// maxHelmChartSize caps the size of chart archives, packaged and decompressed, so that a chart cannot exhaust
+// the memory of the job manager
+const maxHelmChartSize = 10 << 20
+
+// renderedChart holds the manifests rendered from the chart of a helm component, in install order. The hooks
+// of the chart are not part of the release installed by the orchestrators, they are only reported.
+type renderedChart struct {
+	manifests []string
+	hooks     []string
+}
+
+// renderHelmComponent renders the chart of a helm component into plain manifests, as `helm template` would for
+// a release installed in the namespace. The release is named after the component unless the chart sets it.
+func renderHelmComponent(component models.Component, namespace string) (*renderedChart, error) {
+	if component.Chart == nil {
+		return nil, fmt.Errorf("helm component %s has no chart", component.Name)
+	}
+
+	chrt, err := loadHelmChart(component.Chart)
+	if err != nil {
+		return nil, err
+	}
+	if chrt.Metadata.Type == "library" {
+		return nil, fmt.Errorf("chart %s is a library chart, it cannot be installed", chrt.Name())
+	}
+
+	// values decoded from YAML have untyped map keys, helm expects string keys
+	valuesYAML, err := yaml.Marshal(component.Chart.Values)
+	if err != nil {
+		return nil, err
+	}
+	values, err := chartutil.ReadValues(valuesYAML)
+	if err != nil {
+		return nil, fmt.Errorf("chart values are not valid: %w", err)
+	}
+	if err := chartutil.ProcessDependencies(chrt, values); err != nil {
+		return nil, err
+	}
+
+	releaseName := component.Chart.ReleaseName
+	if releaseName == "" {
+		releaseName = component.Name
+	}
+	options := chartutil.ReleaseOptions{Name: releaseName, Namespace: namespace, Revision: 1, IsInstall: true}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, options, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, err
+	}
+	for name := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(files, name)
+		}
+	}
+
+	hooks, manifests, err := releaseutil.SortManifests(files, chartutil.DefaultCapabilities.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &renderedChart{}
+	for _, crd := range chrt.CRDObjects() {
+		rendered.manifests = append(rendered.manifests, string(crd.File.Data))
+	}
+	for _, manifest := range manifests {
+		rendered.manifests = append(rendered.manifests, manifest.Content)
+	}
+	for _, hook := range hooks {
+		rendered.hooks = append(rendered.hooks, hook.Path)
+	}
+	return rendered, nil
+}
+
+// loadHelmChart loads the chart of a helm component, embedded charts are packaged charts encoded in base64 while
+// paths designate a packaged chart or a chart directory of the local chart repository (HELM_CHART_REPOSITORY)
+func loadHelmChart(helmChart *models.HelmChart) (*chart.Chart, error) {
+	switch {
+	case helmChart.Archive != "" && helmChart.Path != "":
+		return nil, fmt.Errorf("chart sets both an archive and a path")
+	case helmChart.Archive != "":
+		if base64.StdEncoding.DecodedLen(len(helmChart.Archive)) > maxHelmChartSize {
+			return nil, fmt.Errorf("chart archive is larger than %d bytes", maxHelmChartSize)
+		}
+		archive, err := base64.StdEncoding.DecodeString(helmChart.Archive)
+		if err != nil {
+			return nil, fmt.Errorf("chart archive is not base64 encoded: %w", err)
+		}
+		return loadHelmArchive(archive)
+	case helmChart.Path != "":
+		repository := os.Getenv("HELM_CHART_REPOSITORY")
+		if repository == "" {
+			return nil, fmt.Errorf("no local chart repository is configured, the chart must be embedded")
+		}
+		if !filepath.IsLocal(helmChart.Path) {
+			return nil, fmt.Errorf("chart path %s is outside the local chart repository", helmChart.Path)
+		}
+
+		// symbolic links are resolved, the chart they lead to must still be in the repository
+		root, err := filepath.EvalSymlinks(repository)
+		if err != nil {
+			return nil, fmt.Errorf("local chart repository is not readable: %w", err)
+		}
+		chartPath, err := filepath.EvalSymlinks(filepath.Join(root, helmChart.Path))
+		if err != nil {
+			return nil, fmt.Errorf("chart %s is not in the local chart repository", helmChart.Path)
+		}
+		if relative, err := filepath.Rel(root, chartPath); err != nil || !filepath.IsLocal(relative) {
+			return nil, fmt.Errorf("chart path %s is outside the local chart repository", helmChart.Path)
+		}
+
+		info, err := os.Stat(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return loader.LoadDir(chartPath)
+		}
+		if info.Size() > maxHelmChartSize {
+			return nil, fmt.Errorf("chart archive is larger than %d bytes", maxHelmChartSize)
+		}
+		archive, err := os.ReadFile(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		return loadHelmArchive(archive)
+	default:
+		return nil, fmt.Errorf("chart sets neither an archive nor a path")
+	}
+}
+
+// loadHelmArchive loads a packaged chart once its decompressed size is known to be under maxHelmChartSize
+func loadHelmArchive(archive []byte) (*chart.Chart, error) {
+	if len(archive) > maxHelmChartSize {
+		return nil, fmt.Errorf("chart archive is larger than %d bytes", maxHelmChartSize)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("chart archive is not gzipped: %w", err)
+	}
+	size, err := io.Copy(io.Discard, io.LimitReader(reader, maxHelmChartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("chart archive is not valid: %w", err)
+	}
+	if size > maxHelmChartSize {
+		return nil, fmt.Errorf("chart archive is larger than %d bytes once decompressed", maxHelmChartSize)
+	}
+	return loader.LoadArchive(bytes.NewReader(archive))
+}
@@ -0,0 +1,199 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// writeChart writes a chart directory named web with a deployment, a service, a hook and notes
+func writeChart(t *testing.T, dir string) string {
+	files := map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"values.yaml": "replicas: 1\nimage: nginx\n",
+		"templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  replicas: {{ .Values.replicas }}
+  selector:
+    matchLabels:
+      app: {{ .Release.Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Release.Name }}
+    spec:
+      containers:
+      - name: web
+        image: {{ .Values.image }}
+`,
+		"templates/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Release.Name }}
+spec:
+  selector:
+    app: {{ .Release.Name }}
+`,
+		"templates/test.yaml": `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`,
+		"templates/NOTES.txt": "Installed {{ .Release.Name }}\n",
+	}
+	chartDir := filepath.Join(dir, "web")
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(chartDir, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0o600))
+	}
+	return chartDir
+}
+
+func TestValidateJobGroupHelmComponent(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	repositoryDir := t.TempDir()
+	chartDir := writeChart(t, repositoryDir)
+	t.Setenv("HELM_CHART_REPOSITORY", repositoryDir)
+
+	t.Run("LocalRepository", func(t *testing.T) {
+		bodyBytes := []byte(`name: shop
+components:
+- name: frontend
+  type: helm
+  chart:
+    path: web
+    values:
+      replicas: 3
+`)
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		require.Len(t, result.JobGroup.Jobs, 1)
+		manifests := result.JobGroup.Jobs[0].Manifests
+		require.Len(t, manifests, 2)
+		// services are installed before deployments
+		assert.Contains(t, manifests[0].YamlString, "kind: Service")
+		assert.Contains(t, manifests[1].YamlString, "name: frontend\n  namespace: shop")
+		assert.Contains(t, manifests[1].YamlString, "replicas: 3")
+		assert.Contains(t, result.Warnings, models.ValidationIssue{
+			Path:    "components[0].chart",
+			Code:    service.IssueIgnored,
+			Message: "hook web/templates/test.yaml of component frontend is not deployed",
+		})
+	})
+
+	t.Run("EmbeddedArchive", func(t *testing.T) {
+		chrt, err := loader.Load(chartDir)
+		require.NoError(t, err)
+		archivePath, err := chartutil.Save(chrt, t.TempDir())
+		require.NoError(t, err)
+		archive, err := os.ReadFile(archivePath)
+		require.NoError(t, err)
+
+		bodyBytes := []byte(`name: shop
+components:
+- name: frontend
+  type: helm
+  chart:
+    archive: ` + base64.StdEncoding.EncodeToString(archive) + `
+    releaseName: web
+`)
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		require.Len(t, result.JobGroup.Jobs[0].Manifests, 2)
+		assert.Contains(t, result.JobGroup.Jobs[0].Manifests[1].YamlString, "name: web")
+	})
+
+	t.Run("InvalidChart", func(t *testing.T) {
+		bodyBytes := []byte(`name: shop
+components:
+- name: frontend
+  type: helm
+  chart:
+    path: ../web
+`)
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "components[0].chart", result.Errors[0].Path)
+		assert.Empty(t, result.JobGroup.Jobs[0].Manifests)
+	})
+
+	t.Run("SymlinkOutsideRepository", func(t *testing.T) {
+		outsideDir := writeChart(t, t.TempDir())
+		require.NoError(t, os.Symlink(outsideDir, filepath.Join(repositoryDir, "escape")))
+
+		bodyBytes := []byte(`name: shop
+components:
+- name: frontend
+  type: helm
+  chart:
+    path: escape
+`)
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0].Message, "outside the local chart repository")
+	})
+
+	t.Run("OversizedArchive", func(t *testing.T) {
+		// a small archive decompressing to more than the size cap is refused
+		var archive bytes.Buffer
+		writer := gzip.NewWriter(&archive)
+		_, err := writer.Write(make([]byte, 11<<20))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		bodyBytes := []byte(`name: shop
+components:
+- name: frontend
+  type: helm
+  chart:
+    archive: ` + base64.StdEncoding.EncodeToString(archive.Bytes()) + `
+`)
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0].Message, "once decompressed")
+	})
+}
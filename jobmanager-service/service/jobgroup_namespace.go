@@ -0,0 +1,196 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// invalidNamespaceCharacters matches the characters a namespace cannot hold
+var invalidNamespaceCharacters = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// jobNamespace returns the namespace of the jobs, a DNS-1123 label derived from the application name unless
+// the descriptor sets it. Derived namespaces that differ from the name are reported with an IssueGenerated
+// warning, other issues are errors.
+func jobNamespace(namespace, appName string) (string, *models.ValidationIssue) {
+	if namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return namespace, &models.ValidationIssue{Path: "namespace", Code: IssueInvalid,
+				Message: fmt.Sprintf("namespace %s is not a valid DNS-1123 label: %s", namespace, strings.Join(errs, ", "))}
+		}
+		return namespace, nil
+	}
+
+	namespace = namespaceFromName(appName)
+	switch namespace {
+	case "":
+		return "", &models.ValidationIssue{Path: "namespace", Code: IssueRequired,
+			Message: fmt.Sprintf("application name %s is not a valid namespace, a namespace must be set", appName)}
+	case appName:
+		return namespace, nil
+	default:
+		return namespace, &models.ValidationIssue{Path: "namespace", Code: IssueGenerated,
+			Message: fmt.Sprintf("application name %s is not a valid namespace, using %s", appName, namespace)}
+	}
+}
+
+// namespaceFromName derives a DNS-1123 label from an application name, it is empty when none can be derived
+func namespaceFromName(name string) string {
+	namespace := invalidNamespaceCharacters.ReplaceAllString(strings.ToLower(name), "-")
+	if len(namespace) > validation.DNS1123LabelMaxLength {
+		namespace = namespace[:validation.DNS1123LabelMaxLength]
+	}
+	return strings.Trim(namespace, "-")
+}
+
+// namespaceManifests returns the Namespace manifest of the template, followed by its ResourceQuota when it sets
+// a quota. Both are marked with AnnotationCreatedNamespace. Invalid labels, annotations and quantities are
+// reported as issues.
+func namespaceManifests(namespace string, template *models.NamespaceTemplate) ([]models.PlainManifest, []models.ValidationIssue) {
+	issues := []models.ValidationIssue{}
+	addIssue := func(path, format string, args ...interface{}) {
+		issues = append(issues, models.ValidationIssue{Path: path, Code: IssueInvalid, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for key, value := range template.Labels {
+		if errs := append(validation.IsQualifiedName(key), validation.IsValidLabelValue(value)...); len(errs) > 0 {
+			addIssue("createNamespace.labels."+key, "label %s=%s is not valid: %s", key, value, strings.Join(errs, ", "))
+		}
+	}
+	for key := range template.Annotations {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			addIssue("createNamespace.annotations."+key, "annotation %s is not valid: %s", key, strings.Join(errs, ", "))
+		}
+	}
+	resources := []string{}
+	for name := range template.Quota {
+		resources = append(resources, name)
+	}
+	sort.Strings(resources)
+	for _, name := range resources {
+		if _, err := resource.ParseQuantity(template.Quota[name]); err != nil {
+			addIssue("createNamespace.quota."+name, "quota of %s is not a valid quantity: %v", name, err)
+		}
+	}
+	if len(issues) > 0 {
+		return nil, issues
+	}
+
+	annotations := map[string]string{AnnotationCreatedNamespace: "true"}
+	for key, value := range template.Annotations {
+		annotations[key] = value
+	}
+	objects := []map[string]interface{}{{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name":        namespace,
+			"labels":      template.Labels,
+			"annotations": annotations,
+		},
+	}}
+	if len(template.Quota) > 0 {
+		objects = append(objects, map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ResourceQuota",
+			"metadata": map[string]interface{}{
+				"name":        namespace,
+				"namespace":   namespace,
+				"annotations": map[string]string{AnnotationCreatedNamespace: "true"},
+			},
+			"spec": map[string]interface{}{"hard": template.Quota},
+		})
+	}
+
+	manifests := []models.PlainManifest{}
+	for _, object := range objects {
+		manifestYAML, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, []models.ValidationIssue{{Path: "createNamespace", Code: IssueInvalid, Message: err.Error()}}
+		}
+		manifests = append(manifests, models.PlainManifest{YamlString: string(manifestYAML)})
+	}
+	return manifests, nil
+}
+
+// withoutCreatedNamespace leaves out of the manifests of the undeployments the namespace created for the
+// application, along with its quota. Every job of the application carries it, and the other job groups of
+// the tenant may deploy to it: removing a component must not remove the namespace and everything in it.
+func withoutCreatedNamespace(jobs []models.Job) {
+	for i := range jobs {
+		if jobs[i].Type != models.DeleteDeployment {
+			continue
+		}
+		manifests := []models.PlainManifest{}
+		for _, manifest := range jobs[i].Manifests {
+			if !isCreatedNamespace(manifest) {
+				manifests = append(manifests, manifest)
+			}
+		}
+		jobs[i].Manifests = manifests
+	}
+}
+
+// isCreatedNamespace tells whether a manifest is marked with AnnotationCreatedNamespace
+func isCreatedNamespace(manifest models.PlainManifest) bool {
+	object := struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := yaml.Unmarshal([]byte(manifest.YamlString), &object); err != nil {
+		return false
+	}
+	return object.Metadata.Annotations[AnnotationCreatedNamespace] == "true"
+}
+
+// checkNamespace reports the namespace of the job group as a conflict when job groups of other tenants deploy
+// to it, job groups without tenant included. The namespace is only claimed when the job group is saved, see
+// ErrNamespaceConflict.
+func (s *jobGroupService) checkNamespace(tenant string, jobGroup *models.JobGroup) (*models.ValidationIssue, error) {
+	if len(jobGroup.Jobs) == 0 || jobGroup.Jobs[0].Namespace == "" {
+		return nil, nil
+	}
+	namespace := jobGroup.Jobs[0].Namespace
+	tenants, err := s.repo.FindNamespaceTenants(namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, owner := range tenants {
+		if owner != tenant {
+			return namespaceConflict(namespace), nil
+		}
+	}
+	return nil, nil
+}
+
+// namespaceConflict is the issue of a namespace used by another tenant
+func namespaceConflict(namespace string) *models.ValidationIssue {
+	return &models.ValidationIssue{Path: "namespace", Code: IssueConflict, Message: fmt.Sprintf("namespace %s is used by another tenant", namespace)}
+}
@@ -0,0 +1,152 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJobGroupNamespace(t *testing.T) {
+	descriptor := func(header string) []byte {
+		return []byte(header + `
+components:
+- name: web
+  manifests:
+  - name: web
+manifests:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: web
+`)
+	}
+
+	t.Run("CreateNamespace", func(t *testing.T) {
+		jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+		result, err := jobGroupService.ValidateJobGroup(descriptor(`name: shop
+namespace: shop-prod
+createNamespace:
+  labels:
+    team: shop
+  quota:
+    pods: "10"
+    limits.memory: 2Gi`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		job := result.JobGroup.Jobs[0]
+		assert.Equal(t, "shop-prod", job.Namespace)
+		require.Len(t, job.Manifests, 3)
+		assert.Contains(t, job.Manifests[0].YamlString, "kind: Namespace")
+		assert.Contains(t, job.Manifests[0].YamlString, "team: shop")
+		assert.Contains(t, job.Manifests[0].YamlString, service.AnnotationCreatedNamespace+`: "true"`)
+		assert.Contains(t, job.Manifests[1].YamlString, "kind: ResourceQuota")
+		assert.Contains(t, job.Manifests[1].YamlString, "limits.memory: 2Gi")
+		assert.Contains(t, job.Manifests[2].YamlString, "kind: ConfigMap")
+
+		// the namespace is created with the application, it is not removed with it
+		deploy := models.Job{BaseUUID: models.BaseUUID{ID: "deploy"}, Type: models.CreateDeployment, Manifests: job.Manifests}
+		undeploy := models.Job{BaseUUID: models.BaseUUID{ID: "undeploy"}, Type: models.DeleteDeployment, Manifests: job.Manifests}
+		mockJobRepo := new(repository.MockJobRepository)
+		mockJobRepo.On("FindJobsToExecute", "ocm", "agent").Return(&[]models.Job{deploy, undeploy}, nil)
+		jobs, err := service.NewJobService(mockJobRepo, nil).FindJobsToExecute("ocm", "agent")
+		require.NoError(t, err)
+		manifests := map[string][]models.PlainManifest{}
+		for _, job := range *jobs {
+			manifests[job.ID] = job.Manifests
+		}
+		assert.Len(t, manifests["deploy"], 3)
+		require.Len(t, manifests["undeploy"], 1)
+		assert.Contains(t, manifests["undeploy"][0].YamlString, "kind: ConfigMap")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+		result, err := jobGroupService.ValidateJobGroup(descriptor(`name: shop
+namespace: Shop_Prod
+createNamespace:
+  quota:
+    pods: ten`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		paths := []string{}
+		for _, issue := range result.Errors {
+			paths = append(paths, issue.Path)
+		}
+		assert.Equal(t, []string{"namespace", "createNamespace.quota.pods"}, paths)
+	})
+
+	t.Run("DerivedFromName", func(t *testing.T) {
+		jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+		result, err := jobGroupService.ValidateJobGroup(descriptor(`name: My Shop!`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Equal(t, "my-shop", result.JobGroup.Jobs[0].Namespace)
+		assert.Contains(t, result.Warnings, models.ValidationIssue{Path: "namespace", Code: service.IssueGenerated, Message: "application name My Shop! is not a valid namespace, using my-shop"})
+	})
+
+	t.Run("UsedByAnotherTenant", func(t *testing.T) {
+		mockJobGroupRepo := new(repository.MockJobGroupRepository)
+		mockJobGroupRepo.On("FindNamespaceTenants", "shop").Return([]string{"", "team-b"}, nil)
+		jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+		result, err := jobGroupService.ValidateJobGroup(descriptor(`name: shop`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		assert.Equal(t, []models.ValidationIssue{{Path: "namespace", Code: service.IssueConflict, Message: "namespace shop is used by another tenant"}}, result.Errors)
+		mockJobGroupRepo.AssertNotCalled(t, "SaveJobGroup", mock.Anything)
+	})
+
+	t.Run("UsedByGroupWithoutTenant", func(t *testing.T) {
+		mockJobGroupRepo := new(repository.MockJobGroupRepository)
+		mockJobGroupRepo.On("FindNamespaceTenants", "shop").Return([]string{""}, nil)
+		jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+		result, err := jobGroupService.ValidateJobGroup(descriptor(`name: shop`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Equal(t, []models.ValidationIssue{{Path: "namespace", Code: service.IssueConflict, Message: "namespace shop is used by another tenant"}}, result.Errors)
+	})
+
+	t.Run("ClaimedConcurrently", func(t *testing.T) {
+		// another tenant saved a job group in the namespace after it was checked
+		mockJobGroupRepo := newMockJobGroupRepository()
+		mockJobGroupRepo.On("SaveJobGroup", mock.Anything).Return((*models.JobGroup)(nil), fmt.Errorf("%w: shop", service.ErrNamespaceConflict))
+		mockHTTPClient := new(MockHTTPClient)
+		mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"components": [{"name": "web", "manifests": [{"name": "web"}], "targets": {"cluster_name": "c1", "orchestrator": "ocm"}}]}`)),
+		}, nil).Once()
+		jobGroupService := newJobGroupService(mockJobGroupRepo, mockHTTPClient)
+
+		_, err := jobGroupService.CreateJobGroup(descriptor(`name: shop`), nil, http.Header{}, "team-a", "alice")
+		var validationErr *service.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []models.ValidationIssue{{Path: "namespace", Code: service.IssueConflict, Message: "namespace shop is used by another tenant"}}, validationErr.Issues)
+	})
+}
@@ -0,0 +1,234 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+	"path"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestHead holds the fields identifying a manifest
+type manifestHead struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+}
+
+// validateOverlays reports the overlays that can never apply, before they are applied to the jobs
+func validateOverlays(overlays []models.Overlay, components []models.Component) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	componentNames := map[string]bool{}
+	for _, component := range components {
+		componentNames[component.Name] = true
+	}
+	for o, overlay := range overlays {
+		overlayPath := fmt.Sprintf("overlays[%d]", o)
+		if _, err := path.Match(overlay.Targets.Cluster, ""); err != nil {
+			issues = append(issues, models.ValidationIssue{Path: overlayPath + ".targets.cluster", Code: IssueInvalid, Message: fmt.Sprintf("cluster pattern %s is not valid: %v", overlay.Targets.Cluster, err)})
+		}
+		for c, component := range overlay.Components {
+			if !componentNames[component] {
+				issues = append(issues, models.ValidationIssue{Path: fmt.Sprintf("%s.components[%d]", overlayPath, c), Code: IssueNotFound, Message: fmt.Sprintf("component %s is not defined", component)})
+			}
+		}
+		if len(overlay.Patches) == 0 {
+			issues = append(issues, models.ValidationIssue{Path: overlayPath + ".patches", Code: IssueRequired, Message: fmt.Sprintf("overlay %s has no patches", overlay.Name)})
+		}
+		for p, patch := range overlay.Patches {
+			if _, err := yaml.YAMLToJSON([]byte(patch.Patch)); err != nil || patch.Patch == "" {
+				issues = append(issues, models.ValidationIssue{Path: fmt.Sprintf("%s.patches[%d].patch", overlayPath, p), Code: IssueInvalid, Message: "patch is missing or is not valid YAML"})
+			}
+		}
+	}
+	return issues
+}
+
+// applyOverlays patches the manifests of a job with the overlays selecting its component and target, in the
+// order of the descriptor. Patches that fail leave the manifest unchanged and are reported.
+func applyOverlays(overlays []models.Overlay, job *models.Job) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	component := ""
+	if job.Resource != nil {
+		component = job.Resource.ResourceName
+	}
+	for o, overlay := range overlays {
+		if !overlaySelects(overlay, job) {
+			continue
+		}
+		for p, patch := range overlay.Patches {
+			patchPath := fmt.Sprintf("overlays[%d].patches[%d]", o, p)
+			for i, manifest := range job.Manifests {
+				head := manifestHead{}
+				if err := yaml.Unmarshal([]byte(manifest.YamlString), &head); err != nil || !patchSelects(patch.Target, head) {
+					continue
+				}
+				patched, err := patchManifest(manifest.YamlString, patch.Patch, head)
+				if err == nil {
+					_, err = decodeYAMLToObject(patched)
+				}
+				if err != nil {
+					issues = append(issues, models.ValidationIssue{Path: patchPath, Code: IssueInvalid, Message: fmt.Sprintf("patch of overlay %s cannot be applied to %s %s of job %s: %v",
+						overlay.Name, head.Kind, head.Metadata.Name, component, err)})
+					continue
+				}
+				job.Manifests[i].YamlString = patched
+			}
+		}
+	}
+	return issues
+}
+
+// previewOverlays applies the overlays to a copy of the manifests of a job, reporting the patches that fail
+func previewOverlays(overlays []models.Overlay, job models.Job) []models.ValidationIssue {
+	job.Manifests = append([]models.PlainManifest{}, job.Manifests...)
+	return applyOverlays(overlays, &job)
+}
+
+// overlayJobs applies the overlays selecting the current target of the jobs to their manifests. The manifests
+// are stored without overlays, which are applied whenever the jobs are read, so that the overlays follow the
+// targets when the jobs are reallocated, updated, rolled back or redeployed. The patches failing were reported
+// when the descriptor was validated, they are only logged.
+func overlayJobs(jobs []models.Job, overlays []models.Overlay) {
+	if len(overlays) == 0 {
+		return
+	}
+	for i := range jobs {
+		for _, issue := range applyOverlays(overlays, &jobs[i]) {
+			logs.Logger.Println("Overlay not applied: " + issue.Message)
+		}
+	}
+}
+
+// restoreOverlaid gives back their stored value to the manifests of an updated job that are sent back as they
+// were read, with the overlays applied and stamped, so that the overlays are not stored in them. Manifests are
+// matched by ID.
+func restoreOverlaid(updated *models.Job, stored *models.Job, overlays []models.Overlay) {
+	rendered := []models.Job{*stored}
+	rendered[0].Manifests = append([]models.PlainManifest{}, stored.Manifests...)
+	overlayJobs(rendered, overlays)
+	stampJob(&rendered[0])
+
+	storedManifests := map[uint32]int{}
+	for i, manifest := range stored.Manifests {
+		storedManifests[manifest.ID] = i
+	}
+	for i, manifest := range updated.Manifests {
+		if s, ok := storedManifests[manifest.ID]; ok && manifest.ID != 0 && sameManifest(manifest.YamlString, rendered[0].Manifests[s].YamlString) {
+			updated.Manifests[i].YamlString = stored.Manifests[s].YamlString
+		}
+	}
+}
+
+// sameManifest tells whether two manifests hold the same object, whatever their formatting
+func sameManifest(a, b string) bool {
+	var objectA, objectB interface{}
+	if yaml.Unmarshal([]byte(a), &objectA) != nil || yaml.Unmarshal([]byte(b), &objectB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(objectA, objectB)
+}
+
+// overlaySelects tells whether the overlay applies to the component and the target of the job
+func overlaySelects(overlay models.Overlay, job *models.Job) bool {
+	if len(overlay.Components) > 0 {
+		selected := false
+		for _, component := range overlay.Components {
+			selected = selected || (job.Resource != nil && job.Resource.ResourceName == component)
+		}
+		if !selected {
+			return false
+		}
+	}
+
+	selector := overlay.Targets
+	if selector.Cluster != "" {
+		if matched, _ := path.Match(selector.Cluster, job.Targets.ClusterName); !matched {
+			return false
+		}
+	}
+	if selector.Orchestrator != "" && selector.Orchestrator != string(job.Targets.Orchestrator) {
+		return false
+	}
+	for key, value := range selector.Labels {
+		if label, ok := job.Targets.Labels[key]; !ok || label != value {
+			return false
+		}
+	}
+	return true
+}
+
+// patchSelects tells whether the patch applies to the manifest
+func patchSelects(target *models.PatchTarget, head manifestHead) bool {
+	return target == nil ||
+		(target.Kind == "" || target.Kind == head.Kind) &&
+			(target.Name == "" || target.Name == head.Metadata.Name) &&
+			(target.Namespace == "" || target.Namespace == head.Metadata.Namespace)
+}
+
+// patchManifest applies a patch to a manifest, strategic merge patches of kinds without a typed object are
+// applied as JSON merge patches
+func patchManifest(manifestYAML, patchYAML string, head manifestHead) (string, error) {
+	manifestJSON, err := yaml.YAMLToJSON([]byte(manifestYAML))
+	if err != nil {
+		return "", err
+	}
+	patchJSON, err := yaml.YAMLToJSON([]byte(patchYAML))
+	if err != nil {
+		return "", err
+	}
+
+	var patchedJSON []byte
+	if bytes.HasPrefix(bytes.TrimSpace(patchJSON), []byte("[")) {
+		operations, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return "", err
+		}
+		patchedJSON, err = operations.Apply(manifestJSON)
+		if err != nil {
+			return "", err
+		}
+	} else if object, err := defaultManifestValidator().scheme.New(schema.FromAPIVersionAndKind(head.APIVersion, head.Kind)); err == nil {
+		patchedJSON, err = strategicpatch.StrategicMergePatch(manifestJSON, patchJSON, object)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		patchedJSON, err = jsonpatch.MergePatch(manifestJSON, patchJSON)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	patched, err := yaml.JSONToYAML(patchedJSON)
+	return string(patched), err
+}
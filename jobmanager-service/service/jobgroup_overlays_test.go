@@ -0,0 +1,236 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"encoding/json"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJobGroupOverlays(t *testing.T) {
+	mockHTTPClient := new(MockHTTPClient)
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), mockHTTPClient)
+
+	bodyBytes := []byte(`name: shop
+components:
+- name: edge
+  manifests:
+  - name: edge
+- name: core
+  manifests:
+  - name: core
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: edge
+  spec:
+    template:
+      spec:
+        containers:
+        - name: app
+          image: nginx
+        - name: sidecar
+          image: envoy
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: core
+  spec:
+    template:
+      spec:
+        containers:
+        - name: app
+          image: nginx
+overlays:
+- name: mirror
+  targets:
+    cluster: edge-*
+  patches:
+  - target:
+      kind: Deployment
+    patch: |
+      spec:
+        template:
+          spec:
+            containers:
+            - name: app
+              image: mirror.local/nginx
+- name: eu
+  targets:
+    labels:
+      region: eu
+  patches:
+  - patch: |
+      - op: add
+        path: /spec/template/spec/nodeSelector
+        value:
+          zone: eu-west
+- name: broken
+  components: [core]
+  patches:
+  - patch: |
+      - op: remove
+        path: /spec/replicas
+`)
+	mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body: io.NopCloser(strings.NewReader(`{"components": [
+			{"name": "edge", "manifests": [{"name": "edge"}], "targets": {"cluster_name": "edge-1", "orchestrator": "ocm", "labels": {"region": "eu"}}},
+			{"name": "core", "manifests": [{"name": "core"}], "targets": {"cluster_name": "core-1", "orchestrator": "ocm"}}
+		]}`)),
+	}, nil).Once()
+
+	result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", true)
+	require.NoError(t, err)
+	require.Len(t, result.JobGroup.Jobs, 2)
+
+	edge := result.JobGroup.Jobs[0].Manifests[0].YamlString
+	// the strategic merge patch only replaces the image of the app container
+	assert.Contains(t, edge, "image: mirror.local/nginx")
+	assert.Contains(t, edge, "image: envoy")
+	assert.Contains(t, edge, "zone: eu-west")
+
+	core := result.JobGroup.Jobs[1].Manifests[0].YamlString
+	assert.Contains(t, core, "image: nginx")
+	assert.NotContains(t, core, "zone: eu-west")
+
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, "overlays[2].patches[0]", result.Errors[0].Path)
+	assert.Equal(t, service.IssueInvalid, result.Errors[0].Code)
+}
+
+func TestValidateJobGroupInvalidOverlays(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	bodyBytes := []byte(`name: shop
+components:
+- name: edge
+  manifests:
+  - name: edge
+manifests:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: edge
+overlays:
+- name: typo
+  targets:
+    cluster: "edge-["
+  components: [egde]
+  patches:
+  - patch: ""
+`)
+
+	result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+	require.NoError(t, err)
+	paths := []string{}
+	for _, issue := range result.Errors {
+		paths = append(paths, issue.Path)
+	}
+	assert.Equal(t, []string{"overlays[0].targets.cluster", "overlays[0].components[0]", "overlays[0].patches[0].patch"}, paths)
+}
+
+func TestOverlaysFollowTargets(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroupID := "3f0c8a1e-5b7d-4c2e-9a1f-6d8e2b4c7a90"
+	base := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 3\n"
+	storedJobGroup := func(cluster string) *models.JobGroup {
+		return &models.JobGroup{
+			BaseUUID: models.BaseUUID{ID: jobGroupID},
+			Tenant:   "team-a",
+			Overlays: models.OverlayList{{Name: "edge", Targets: models.OverlayTargets{Cluster: "edge-*"}, Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}},
+			Jobs: []models.Job{{
+				BaseUUID:   models.BaseUUID{ID: "8a2d4f6b-1c3e-4a5b-9d7f-0e2c4a6b8d1f"},
+				JobGroupID: jobGroupID,
+				Targets:    models.Target{ClusterName: cluster, Orchestrator: models.OCM},
+				Resource:   &models.Resource{ResourceName: "web"},
+				Manifests:  []models.PlainManifest{{BaseUINT: models.BaseUINT{ID: 1}, YamlString: base}},
+			}},
+		}
+	}
+
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(storedJobGroup("core-1"), nil).Once()
+	result, err := jobGroupService.FindJobGroupByUUID(jobGroupID)
+	require.NoError(t, err)
+	assert.Contains(t, result.Jobs[0].Manifests[0].YamlString, "replicas: 3")
+
+	// once the job moved to an edge cluster, the overlay selecting it applies
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(storedJobGroup("edge-1"), nil).Once()
+	result, err = jobGroupService.FindJobGroupByUUID(jobGroupID)
+	require.NoError(t, err)
+	assert.Contains(t, result.Jobs[0].Manifests[0].YamlString, "replicas: 1")
+
+	// the job group sent back as read keeps the manifests without the overlay
+	bodyJob, err := json.Marshal(result)
+	require.NoError(t, err)
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(storedJobGroup("edge-1"), nil).Once()
+	mockJobGroupRepo.On("UpdateJobGroup", mock.MatchedBy(func(jobGroup *models.JobGroup) bool {
+		return jobGroup.Jobs[0].Manifests[0].YamlString == base
+	})).Return(storedJobGroup("edge-1"), nil).Once()
+	_, err = jobGroupService.UpdateJobGroup(bodyJob, "team-a", "alice", 0)
+	require.NoError(t, err)
+	mockJobGroupRepo.AssertExpectations(t)
+}
+
+func TestValidateJobGroupOverlaysWithoutMatchmaking(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	bodyBytes := []byte(`name: shop
+components:
+- name: edge
+  manifests:
+  - name: edge
+manifests:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: edge
+overlays:
+- name: mirror
+  targets:
+    cluster: edge-*
+  patches:
+  - patch: |
+      data:
+        registry: mirror.local
+`)
+
+	result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Contains(t, result.Warnings, models.ValidationIssue{
+		Path:    "overlays[0].targets",
+		Code:    service.IssueNoTarget,
+		Message: "overlay mirror selects targets, it is only applied once the matchmaker assigned them",
+	})
+}
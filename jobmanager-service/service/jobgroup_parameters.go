@@ -0,0 +1,231 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Types of the descriptor parameters
+const (
+	ParameterString  = "string"
+	ParameterInteger = "integer"
+	ParameterNumber  = "number"
+	ParameterBoolean = "boolean"
+)
+
+// templateAction matches the actions of a template, they are left out to read the parameter declarations
+var templateAction = regexp.MustCompile(`{{.*?}}`)
+
+// parameterReference matches the references to a parameter, {{ .params.<name> }}
+var parameterReference = regexp.MustCompile(`{{\s*\.params\.([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// renderDescriptor replaces the references to the parameters of the descriptor, {{ .params.<name> }}, with
+// their values. Any other text between braces, such as the templates of the manifests, is left as is, and
+// descriptors that declare no parameters are returned as is. The values resolved for the parameters,
+// defaults included, are returned with the rendered descriptor.
+func renderDescriptor(descriptor string, values map[string]string) (string, map[string]string, []models.ValidationIssue) {
+	parameters, err := readParameters(templateAction.ReplaceAllString(descriptor, ""))
+	if err != nil {
+		return "", nil, []models.ValidationIssue{{Path: "parameters", Code: IssueInvalid, Message: fmt.Sprintf("parameters cannot be read: %v", err)}}
+	}
+	if len(parameters) == 0 && len(values) == 0 {
+		return descriptor, nil, nil
+	}
+
+	params, resolved, issues := resolveParameters(parameters, values)
+	if len(issues) > 0 {
+		return "", nil, issues
+	}
+
+	undeclared := map[string]bool{}
+	rendered := parameterReference.ReplaceAllStringFunc(descriptor, func(reference string) string {
+		name := parameterReference.FindStringSubmatch(reference)[1]
+		value, ok := params[name]
+		if !ok {
+			if !undeclared[name] {
+				undeclared[name] = true
+				issues = append(issues, models.ValidationIssue{Path: "params." + name, Code: IssueNotFound, Message: fmt.Sprintf("parameter %s is referenced but not declared", name)})
+			}
+			return reference
+		}
+		return fmt.Sprint(value)
+	})
+	if len(issues) > 0 {
+		return "", nil, issues
+	}
+	return rendered, resolved, nil
+}
+
+// readParameters reads the parameter declarations of the first document of the descriptor
+func readParameters(descriptor string) ([]models.Parameter, error) {
+	documents, err := splitYAMLDocuments(descriptor)
+	if err != nil || len(documents) == 0 {
+		return nil, err
+	}
+	header, ok := documents[0].(map[interface{}]interface{})
+	if !ok || header["parameters"] == nil {
+		return nil, nil
+	}
+	parametersYAML, err := yaml.Marshal(header["parameters"])
+	if err != nil {
+		return nil, err
+	}
+	parameters := []models.Parameter{}
+	err = yaml.Unmarshal(parametersYAML, &parameters)
+	return parameters, err
+}
+
+// resolveParameters types the value of every parameter, taking its default when no value is given, and
+// checks it against the declaration. Values of undeclared parameters are reported.
+func resolveParameters(parameters []models.Parameter, values map[string]string) (map[string]interface{}, map[string]string, []models.ValidationIssue) {
+	params, resolved := map[string]interface{}{}, map[string]string{}
+	issues := []models.ValidationIssue{}
+	addIssue := func(path, code, format string, args ...interface{}) {
+		issues = append(issues, models.ValidationIssue{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for i, parameter := range parameters {
+		path := fmt.Sprintf("parameters[%d]", i)
+		if parameter.Name == "" {
+			addIssue(path+".name", IssueRequired, "parameter name is missing")
+			continue
+		}
+		if _, ok := params[parameter.Name]; ok {
+			addIssue(path+".name", IssueDuplicate, "parameter %s is declared more than once", parameter.Name)
+			continue
+		}
+		if parameter.Type == "" {
+			parameter.Type = ParameterString
+		}
+
+		value, ok := values[parameter.Name]
+		if ok {
+			path = "params." + parameter.Name
+		} else if parameter.Default != nil {
+			value, path = fmt.Sprint(parameter.Default), path+".default"
+		} else if parameter.Required {
+			addIssue(path, IssueRequired, "parameter %s is required", parameter.Name)
+			continue
+		}
+
+		typed, err := parameterValue(parameter, value, ok || parameter.Default != nil)
+		if err != nil {
+			addIssue(path, IssueInvalid, "parameter %s: %v", parameter.Name, err)
+			continue
+		}
+		params[parameter.Name] = typed
+		resolved[parameter.Name] = fmt.Sprint(typed)
+	}
+
+	undeclared := []string{}
+	for name := range values {
+		if !declared(parameters, name) {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	for _, name := range undeclared {
+		addIssue("params."+name, IssueNotFound, "parameter %s is not declared", name)
+	}
+	return params, resolved, issues
+}
+
+// parameterValue converts the value of a parameter to its type and checks its constraints, parameters
+// without value are the zero value of their type
+func parameterValue(parameter models.Parameter, value string, set bool) (interface{}, error) {
+	var typed interface{}
+	var number float64
+	var err error
+	switch parameter.Type {
+	case ParameterString:
+		typed = value
+		if parameter.Pattern != "" && set {
+			matched, err := regexp.MatchString(parameter.Pattern, value)
+			if err != nil {
+				return nil, fmt.Errorf("pattern %s is not valid: %w", parameter.Pattern, err)
+			}
+			if !matched {
+				return nil, fmt.Errorf("value %q does not match %s", value, parameter.Pattern)
+			}
+		}
+	case ParameterInteger:
+		var integer int64
+		if set {
+			integer, err = strconv.ParseInt(value, 10, 64)
+		}
+		typed, number = integer, float64(integer)
+	case ParameterNumber:
+		if set {
+			number, err = strconv.ParseFloat(value, 64)
+		}
+		typed = number
+	case ParameterBoolean:
+		boolean := false
+		if set {
+			boolean, err = strconv.ParseBool(value)
+		}
+		typed = boolean
+	default:
+		return nil, fmt.Errorf("unknown type %s", parameter.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("value %q is not a valid %s", value, parameter.Type)
+	}
+	if !set {
+		return typed, nil
+	}
+
+	if parameter.Minimum != nil && (parameter.Type == ParameterInteger || parameter.Type == ParameterNumber) && number < *parameter.Minimum {
+		return nil, fmt.Errorf("value %s is lower than %v", value, *parameter.Minimum)
+	}
+	if parameter.Maximum != nil && (parameter.Type == ParameterInteger || parameter.Type == ParameterNumber) && number > *parameter.Maximum {
+		return nil, fmt.Errorf("value %s is greater than %v", value, *parameter.Maximum)
+	}
+	if len(parameter.Enum) > 0 {
+		allowed := []string{}
+		for _, option := range parameter.Enum {
+			if fmt.Sprint(option) == fmt.Sprint(typed) {
+				return typed, nil
+			}
+			allowed = append(allowed, fmt.Sprint(option))
+		}
+		return nil, fmt.Errorf("value %s is not one of %s", value, strings.Join(allowed, ", "))
+	}
+	return typed, nil
+}
+
+// declared tells whether the parameter is declared
+func declared(parameters []models.Parameter, name string) bool {
+	for _, parameter := range parameters {
+		if parameter.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	"icos/server/jobmanager-service/utils/secrets"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const parameterizedDescriptor = `name: shop
+parameters:
+- name: replicas
+  type: integer
+  default: 1
+  minimum: 1
+  maximum: 5
+- name: image
+  required: true
+  pattern: ^[a-z0-9./:-]+$
+- name: tier
+  enum: [free, paid]
+  default: free
+components:
+- name: web
+  manifests:
+  - name: web
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+    labels:
+      tier: {{ .params.tier }}
+  spec:
+    replicas: {{ .params.replicas }}
+    template:
+      spec:
+        containers:
+        - name: web
+          image: {{ .params.image }}
+`
+
+func TestValidateJobGroupParameters(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	t.Run("Rendered", func(t *testing.T) {
+		result, err := jobGroupService.ValidateJobGroup([]byte(parameterizedDescriptor), map[string]string{"replicas": "3", "image": "nginx:1.27"}, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		// the values of the parameters are not returned, they may hold credentials
+		assert.Equal(t, models.StringMap{"replicas": secrets.Redacted, "image": secrets.Redacted, "tier": secrets.Redacted}, result.JobGroup.Parameters)
+		manifest := result.JobGroup.Jobs[0].Manifests[0].YamlString
+		assert.Contains(t, manifest, "replicas: 3")
+		assert.Contains(t, manifest, "image: nginx:1.27")
+		assert.Contains(t, manifest, "tier: free")
+	})
+
+	t.Run("InvalidValues", func(t *testing.T) {
+		_, err := jobGroupService.ValidateJobGroup([]byte(parameterizedDescriptor), map[string]string{"replicas": "9", "tier": "gold", "region": "eu"}, http.Header{}, "team-a", false)
+
+		var validationErr *service.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []models.ValidationIssue{
+			{Path: "params.replicas", Code: service.IssueInvalid, Message: "parameter replicas: value 9 is greater than 5"},
+			{Path: "parameters[1]", Code: service.IssueRequired, Message: "parameter image is required"},
+			{Path: "params.tier", Code: service.IssueInvalid, Message: "parameter tier: value gold is not one of free, paid"},
+			{Path: "params.region", Code: service.IssueNotFound, Message: "parameter region is not declared"},
+		}, validationErr.Issues)
+	})
+
+	t.Run("UndeclaredReference", func(t *testing.T) {
+		descriptor := parameterizedDescriptor + "    namespace: {{ .params.namespace }}\n"
+		_, err := jobGroupService.ValidateJobGroup([]byte(descriptor), map[string]string{"image": "nginx"}, http.Header{}, "team-a", false)
+
+		var validationErr *service.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []models.ValidationIssue{
+			{Path: "params.namespace", Code: service.IssueNotFound, Message: "parameter namespace is referenced but not declared"},
+		}, validationErr.Issues)
+	})
+
+	t.Run("LiteralBraces", func(t *testing.T) {
+		// only the references to the parameters are rendered, the templates of the manifests are kept
+		descriptor := strings.Replace(parameterizedDescriptor, "  - name: web\n", "  - name: web\n  - name: alerts\n", 1) + `- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: alerts
+  data:
+    summary: "{{ $labels.instance }} is down"
+    values: "{{ .Values.image }} {{ .params.image }}"
+`
+		result, err := jobGroupService.ValidateJobGroup([]byte(descriptor), map[string]string{"image": "nginx:1.27"}, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		manifest := result.JobGroup.Jobs[0].Manifests[1].YamlString
+		assert.Contains(t, manifest, "{{ $labels.instance }} is down")
+		assert.Contains(t, manifest, "{{ .Values.image }} nginx:1.27")
+	})
+
+	t.Run("NotATemplate", func(t *testing.T) {
+		// descriptors without parameters are taken literally
+		result, err := jobGroupService.ValidateJobGroup([]byte(`name: monitoring
+components:
+- name: alerts
+  manifests:
+  - name: alerts
+manifests:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: alerts
+  data:
+    summary: "{{ $labels.instance }} is down"
+`), nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.Empty(t, result.Errors)
+		assert.Contains(t, result.JobGroup.Jobs[0].Manifests[0].YamlString, "{{ $labels.instance }} is down")
+		assert.Empty(t, result.JobGroup.Parameters)
+	})
+}
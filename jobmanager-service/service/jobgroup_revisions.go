@@ -0,0 +1,243 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+	"icos/server/jobmanager-service/utils/secrets"
+)
+
+// autoRollbackAuthor is the author of the revisions recorded by automatic rollbacks
+const autoRollbackAuthor = "job-manager"
+
+// newRevision snapshots the jobs of a job group
+func newRevision(jobGroup *models.JobGroup, descriptor, author string) *models.JobGroupRevision {
+	revision := &models.JobGroupRevision{
+		JobGroupID: jobGroup.ID,
+		Descriptor: descriptor,
+		Parameters: jobGroup.Parameters,
+		Overlays:   jobGroup.Overlays,
+		Priority:   jobGroup.Priority,
+		Jobs:       models.RevisionJobs{},
+		Author:     author,
+	}
+	for _, job := range jobGroup.Jobs {
+		revisionJob := models.RevisionJob{
+			JobID:        job.ID,
+			Manifests:    []string{},
+			Targets:      job.Targets,
+			Orchestrator: job.Orchestrator,
+			Namespace:    job.Namespace,
+		}
+		if job.Resource != nil {
+			revisionJob.Component = job.Resource.ResourceName
+		}
+		for _, manifest := range job.Manifests {
+			revisionJob.Manifests = append(revisionJob.Manifests, manifest.YamlString)
+		}
+		revision.Jobs = append(revision.Jobs, revisionJob)
+	}
+	return revision
+}
+
+// recordRevision saves a revision of a job group once a change was accepted. The change is already saved when
+// the revision cannot be, the error tells so.
+func (s *jobGroupService) recordRevision(jobGroup *models.JobGroup, descriptor, author string, rollbackOf int64) error {
+	revision := newRevision(jobGroup, descriptor, author)
+	revision.RollbackOf = rollbackOf
+	if _, err := s.repo.SaveJobGroupRevision(revision); err != nil {
+		logs.Logger.Printf("ERROR saving a revision of job group %s: %v", jobGroup.ID, err)
+		return fmt.Errorf("job group %s was saved but its revision was not: %w", jobGroup.ID, err)
+	}
+	logs.Logger.Printf("Job group %s is at revision %d", jobGroup.ID, revision.Revision)
+	return nil
+}
+
+// redactRevision replaces the sensitive fields of the manifests of a revision, those of its descriptor and its
+// overlays included
+func redactRevision(revision *models.JobGroupRevision) {
+	revision.Descriptor = redactDescriptor(revision.Descriptor)
+	revision.Parameters = redactParameters(revision.Parameters)
+	revision.Overlays = redactOverlays(revision.Overlays)
+	for i := range revision.Jobs {
+		for m, manifest := range revision.Jobs[i].Manifests {
+			revision.Jobs[i].Manifests[m] = secrets.RedactManifest(manifest)
+		}
+	}
+}
+
+// FindJobGroupRevisions returns the revisions of a job group, the last one first
+func (s *jobGroupService) FindJobGroupRevisions(id string) (*[]models.JobGroupRevision, error) {
+	revisions, err := s.repo.FindJobGroupRevisions(id)
+	if err == nil && revisions != nil {
+		for i := range *revisions {
+			redactRevision(&(*revisions)[i])
+		}
+	}
+	return revisions, err
+}
+
+// FindJobGroupRevision finds a revision of a job group
+func (s *jobGroupService) FindJobGroupRevision(id string, revision int64) (*models.JobGroupRevision, error) {
+	jobGroupRevision, err := s.repo.FindJobGroupRevision(id, revision)
+	if err == nil && jobGroupRevision != nil {
+		redactRevision(jobGroupRevision)
+	}
+	return jobGroupRevision, err
+}
+
+// RollbackJobGroup brings the jobs of a job group of the tenant back to a revision, they are deployed again and
+// the rollback is recorded as a new revision. A non zero version must match the stored one.
+func (s *jobGroupService) RollbackJobGroup(id string, revision int64, tenant, author string, version int64) (*models.JobGroup, error) {
+	jobGroup, err := s.repo.FindJobGroupByUUID(id)
+	if err != nil {
+		logs.Logger.Println("Error finding job group by UUID:", err)
+		return nil, err
+	}
+	if err := checkTenant(jobGroup, tenant); err != nil {
+		logs.Logger.Println("Error rolling back job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroup.ResourceVersion); err != nil {
+		logs.Logger.Println("Error rolling back job group:", err)
+		return nil, err
+	}
+
+	target, err := s.repo.FindJobGroupRevision(id, revision)
+	if err != nil {
+		logs.Logger.Printf("Error finding revision %d of job group %s: %v", revision, id, err)
+		return nil, err
+	}
+
+	restoreRevision(jobGroup, target)
+
+	if err := s.quotas.CheckJobGroup(jobGroup.Tenant, jobGroup, false); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		return nil, err
+	}
+
+	s.requestApproval(jobGroup, author)
+
+	jobGroupUpdated, err := s.repo.UpdateJobGroup(jobGroup)
+	if err != nil {
+		logs.Logger.Println("Error rolling back job group:", err)
+		return nil, err
+	}
+	if err := s.recordRevision(jobGroupUpdated, target.Descriptor, author, revision); err != nil {
+		return nil, err
+	}
+
+	redactJobGroup(jobGroupUpdated)
+	return jobGroupUpdated, nil
+}
+
+// restoreRevision brings the jobs of a job group back to the manifests, targets, parameters, overlays and
+// priority of a revision, they are deployed again
+func restoreRevision(jobGroup *models.JobGroup, target *models.JobGroupRevision) {
+	revisionJobs := map[string]models.RevisionJob{}
+	for _, revisionJob := range target.Jobs {
+		revisionJobs[revisionJob.JobID] = revisionJob
+	}
+	for i := range jobGroup.Jobs {
+		job := &jobGroup.Jobs[i]
+		revisionJob, ok := revisionJobs[job.ID]
+		if !ok {
+			continue
+		}
+		job.Manifests = []models.PlainManifest{}
+		for _, manifest := range revisionJob.Manifests {
+			job.Manifests = append(job.Manifests, models.PlainManifest{JobID: job.ID, YamlString: manifest})
+		}
+		targets := revisionJob.Targets
+		targets.ID, targets.JobID = job.Targets.ID, job.Targets.JobID
+		job.Targets = targets
+		job.Orchestrator = revisionJob.Orchestrator
+		job.Namespace = revisionJob.Namespace
+		replaceJob(job)
+		job.Priority = target.Priority
+	}
+	jobGroup.Priority = target.Priority
+	jobGroup.Parameters = target.Parameters
+	jobGroup.Overlays = target.Overlays
+	startRollout(jobGroup)
+}
+
+// AutoRollbackJobGroup rolls a job group whose update failed back to its previous revision, the failure and its
+// reason are kept in the rollout status. A job group whose last update already failed is left as is, so that
+// a degraded previous revision does not bring the failed update back. The rollback is not held back for an
+// approval on purpose: it restores a revision that was deployed before, and waiting for an approver would keep
+// the failed update running.
+func (s *jobGroupService) AutoRollbackJobGroup(id, reason string) (*models.JobGroup, error) {
+	jobGroup, err := s.repo.FindJobGroupByUUID(id)
+	if err != nil {
+		logs.Logger.Println("Error finding job group by UUID:", err)
+		return nil, err
+	}
+	if jobGroup.RolloutStatus.State == models.RolloutFailed {
+		return jobGroup, nil
+	}
+
+	revisions, err := s.repo.FindJobGroupRevisions(id)
+	if err != nil {
+		logs.Logger.Printf("Error finding the revisions of job group %s: %v", id, err)
+		return nil, err
+	}
+	var rollbackOf int64
+	if len(*revisions) > 1 {
+		// the last revision is the failed update itself
+		target := &(*revisions)[1]
+		restoreRevision(jobGroup, target)
+		rollbackOf = target.Revision
+		reason += fmt.Sprintf(", rolled back to revision %d", rollbackOf)
+	} else {
+		reason += ", no previous revision to roll back to"
+	}
+	jobGroup.RolloutStatus = models.RolloutStatus{State: models.RolloutFailed, Message: reason}
+
+	jobGroupUpdated, err := s.repo.UpdateJobGroup(jobGroup)
+	if err != nil {
+		logs.Logger.Println("Error rolling back job group:", err)
+		return nil, err
+	}
+	logs.Logger.Printf("Update of job group %s failed: %s", id, reason)
+	if rollbackOf != 0 {
+		if err := s.recordRevision(jobGroupUpdated, (*revisions)[1].Descriptor, autoRollbackAuthor, rollbackOf); err != nil {
+			return nil, err
+		}
+	}
+
+	redactJobGroup(jobGroupUpdated)
+	return jobGroupUpdated, nil
+}
+
+// replaceJob makes a job executable again, the agent owning its deployment replaces it. A remediation it was
+// left on is over.
+func replaceJob(job *models.Job) {
+	job.State = models.JobCreated
+	job.SubType = ""
+	if job.OwnerID != "" {
+		job.Type = models.ReplaceDeployment
+	} else {
+		job.Type = models.CreateDeployment
+	}
+}
@@ -0,0 +1,211 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackJobGroup(t *testing.T) {
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroup := &models.JobGroup{
+		BaseUUID:        models.BaseUUID{ID: "group-1"},
+		Tenant:          "team-a",
+		ResourceVersion: 3,
+		Priority:        40,
+		Jobs: []models.Job{{
+			BaseUUID:     models.BaseUUID{ID: "job-1"},
+			OwnerID:      "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c",
+			Type:         models.CreateDeployment,
+			State:        models.JobFinished,
+			Priority:     40,
+			Manifests:    []models.PlainManifest{{BaseUINT: models.BaseUINT{ID: 7}, YamlString: "image: shop:2\n"}},
+			Targets:      models.Target{BaseUINT: models.BaseUINT{ID: 4}, ClusterName: "edge-2", Orchestrator: models.OCM},
+			Orchestrator: models.OCM,
+		}},
+	}
+	revision := &models.JobGroupRevision{
+		JobGroupID: "group-1",
+		Revision:   1,
+		Descriptor: "name: shop\n",
+		Priority:   10,
+		Jobs: models.RevisionJobs{{
+			JobID:        "job-1",
+			Manifests:    []string{"image: shop:1\n"},
+			Targets:      models.Target{ClusterName: "edge-1", Orchestrator: models.OCM},
+			Orchestrator: models.OCM,
+		}},
+	}
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(jobGroup, nil)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(1)).Return(revision, nil)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(9)).Return((*models.JobGroupRevision)(nil), errors.New("record not found"))
+	mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil)
+	mockJobGroupRepo.On("SaveJobGroupRevision", mock.MatchedBy(func(saved *models.JobGroupRevision) bool {
+		return saved.RollbackOf == 1 && saved.Author == "alice" && saved.Descriptor == "name: shop\n" &&
+			saved.Priority == 10 && saved.Jobs[0].Manifests[0] == "image: shop:1\n"
+	})).Return(&models.JobGroupRevision{Revision: 4}, nil).Once()
+
+	_, err := jobGroupService.RollbackJobGroup("group-1", 1, "team-a", "alice", 2)
+	assert.ErrorIs(t, err, service.ErrVersionConflict)
+
+	// job groups of other tenants are not rolled back
+	_, err = jobGroupService.RollbackJobGroup("group-1", 1, "team-b", "alice", 3)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	_, err = jobGroupService.RollbackJobGroup("group-1", 9, "team-a", "alice", 3)
+	assert.Error(t, err)
+
+	result, err := jobGroupService.RollbackJobGroup("group-1", 1, "team-a", "alice", 3)
+	require.NoError(t, err)
+	job := result.Jobs[0]
+	assert.Equal(t, models.ReplaceDeployment, job.Type)
+	assert.Equal(t, models.JobCreated, job.State)
+	require.Len(t, job.Manifests, 1)
+	assert.Equal(t, "image: shop:1\n", job.Manifests[0].YamlString)
+	assert.Equal(t, "edge-1", job.Targets.ClusterName)
+	assert.Equal(t, uint32(4), job.Targets.ID)
+	// the priority of the revision is restored along with its jobs
+	assert.Equal(t, 10, result.Priority)
+	assert.Equal(t, 10, job.Priority)
+	mockJobGroupRepo.AssertExpectations(t)
+
+	t.Run("WithoutTenant", func(t *testing.T) {
+		// job groups created before tenants existed are only rolled back by administrators
+		mockJobGroupRepo := new(repository.MockJobGroupRepository)
+		legacy := &models.JobGroup{BaseUUID: models.BaseUUID{ID: "group-2"}}
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-2").Return(legacy, nil)
+
+		_, err := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient)).RollbackJobGroup("group-2", 1, "team-a", "alice", 0)
+		assert.ErrorIs(t, err, service.ErrForbidden)
+		assert.Empty(t, legacy.Tenant)
+		mockJobGroupRepo.AssertNotCalled(t, "UpdateJobGroup", mock.Anything)
+	})
+
+	t.Run("RevisionWithoutOverlays", func(t *testing.T) {
+		// a revision taken before the overlays and parameters were added clears them
+		mockJobGroupRepo := newMockJobGroupRepository()
+		overlaid := &models.JobGroup{
+			BaseUUID:   models.BaseUUID{ID: "group-3"},
+			Parameters: models.StringMap{"replicas": "3"},
+			Overlays:   models.OverlayList{{Name: "edge", Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}},
+			Jobs:       []models.Job{{BaseUUID: models.BaseUUID{ID: "job-3"}, Type: models.CreateDeployment, State: models.JobFinished}},
+		}
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-3").Return(overlaid, nil)
+		mockJobGroupRepo.On("FindJobGroupRevision", "group-3", int64(1)).Return(&models.JobGroupRevision{
+			JobGroupID: "group-3",
+			Revision:   1,
+			Jobs:       models.RevisionJobs{{JobID: "job-3", Manifests: []string{"image: shop:1\n"}}},
+		}, nil)
+		mockJobGroupRepo.On("UpdateJobGroup", mock.MatchedBy(func(jobGroup *models.JobGroup) bool {
+			return jobGroup.Overlays == nil && jobGroup.Parameters == nil
+		})).Return(overlaid, nil).Once()
+
+		result, err := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient)).RollbackJobGroup("group-3", 1, "", "alice", 0)
+		require.NoError(t, err)
+		assert.Empty(t, result.Overlays)
+		assert.Empty(t, result.Parameters)
+		mockJobGroupRepo.AssertExpectations(t)
+	})
+
+	t.Run("RevisionNotSaved", func(t *testing.T) {
+		mockJobGroupRepo := new(repository.MockJobGroupRepository)
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(jobGroup, nil)
+		mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(1)).Return(revision, nil)
+		mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil)
+		mockJobGroupRepo.On("SaveJobGroupRevision", mock.Anything).Return((*models.JobGroupRevision)(nil), errors.New("UNIQUE constraint failed"))
+
+		_, err := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient)).RollbackJobGroup("group-1", 1, "", "alice", 0)
+		assert.ErrorContains(t, err, "UNIQUE constraint failed")
+	})
+}
+
+func TestFindJobGroupRevisionRedactsDescriptor(t *testing.T) {
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	mockJobGroupRepo.On("FindJobGroupRevision", "group-1", int64(1)).Return(&models.JobGroupRevision{
+		JobGroupID: "group-1",
+		Revision:   1,
+		Descriptor: "name: db\nmanifests:\n- apiVersion: v1\n  kind: Secret\n  metadata:\n    name: db\n  stringData:\n    password: s3cr3t\n",
+	}, nil)
+
+	revision, err := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient)).FindJobGroupRevision("group-1", 1)
+	require.NoError(t, err)
+	assert.NotContains(t, revision.Descriptor, "s3cr3t")
+	assert.Contains(t, revision.Descriptor, "password: '**REDACTED**'")
+	assert.Contains(t, revision.Descriptor, "name: db")
+}
+
+func TestAutoRollbackJobGroup(t *testing.T) {
+	t.Setenv("APPROVAL_LABELS", "env=production")
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroup := &models.JobGroup{
+		BaseUUID:      models.BaseUUID{ID: "group-1"},
+		Labels:        models.StringMap{"env": "production"},
+		Approval:      models.ApprovalStatus{State: models.ApprovalApproved, RequestedBy: "alice", ReviewedBy: "bob"},
+		RolloutStatus: models.RolloutStatus{State: models.RolloutProgressing},
+		Jobs: []models.Job{{
+			BaseUUID:  models.BaseUUID{ID: "job-1"},
+			OwnerID:   "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c",
+			Type:      models.UpdateDeployment,
+			SubType:   models.ScaleUp,
+			State:     models.JobDegraded,
+			Manifests: []models.PlainManifest{{YamlString: "image: shop:2\n"}},
+		}},
+	}
+	revisions := &[]models.JobGroupRevision{
+		{JobGroupID: "group-1", Revision: 2, Jobs: models.RevisionJobs{{JobID: "job-1", Manifests: []string{"image: shop:2\n"}}}},
+		{JobGroupID: "group-1", Revision: 1, Descriptor: "name: shop\n", Jobs: models.RevisionJobs{{JobID: "job-1", Manifests: []string{"image: shop:1\n"}}}},
+	}
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(jobGroup, nil)
+	mockJobGroupRepo.On("FindJobGroupRevisions", "group-1").Return(revisions, nil).Once()
+	mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil).Once()
+	mockJobGroupRepo.On("SaveJobGroupRevision", mock.MatchedBy(func(saved *models.JobGroupRevision) bool {
+		return saved.RollbackOf == 1 && saved.Author == "job-manager" && saved.Jobs[0].Manifests[0] == "image: shop:1\n"
+	})).Return(&models.JobGroupRevision{Revision: 3}, nil).Once()
+
+	result, err := jobGroupService.AutoRollbackJobGroup("group-1", "job job-1 degraded")
+	require.NoError(t, err)
+	assert.Equal(t, models.RolloutStatus{State: models.RolloutFailed, Message: "job job-1 degraded, rolled back to revision 1"}, result.RolloutStatus)
+	job := result.Jobs[0]
+	assert.Equal(t, models.ReplaceDeployment, job.Type)
+	// the remediation the job was left on is over
+	assert.Empty(t, job.SubType)
+	assert.Equal(t, models.JobCreated, job.State)
+	assert.Equal(t, "image: shop:1\n", job.Manifests[0].YamlString)
+	// the previous revision is deployed again without waiting for an approval
+	assert.Equal(t, models.ApprovalApproved, result.Approval.State)
+
+	// a failed update is rolled back once
+	_, err = jobGroupService.AutoRollbackJobGroup("group-1", "job job-1 degraded")
+	require.NoError(t, err)
+	mockJobGroupRepo.AssertExpectations(t)
+}
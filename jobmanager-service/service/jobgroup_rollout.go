@@ -0,0 +1,229 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/logs"
+)
+
+// ErrRolloutDegraded is returned when resuming a halted rollout while one of its jobs is still degraded
+var ErrRolloutDegraded = errors.New("JobGroup rollout cannot be resumed")
+
+// defaultMaxUnavailable is the number of jobs a rolling rollout deploys at a time when none is given
+const defaultMaxUnavailable = 1
+
+// validateRollout reports the problems of a rollout strategy, the defaults are filled in
+func validateRollout(strategy *models.RolloutStrategy, path string) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	switch strategy.Type {
+	case "", models.RolloutAllAtOnce:
+		strategy.Type = models.RolloutAllAtOnce
+	case models.RolloutRolling:
+		if strategy.MaxUnavailable < 0 {
+			issues = append(issues, models.ValidationIssue{Path: path + ".maxUnavailable", Code: IssueInvalid,
+				Message: fmt.Sprintf("maxUnavailable must be positive, got %d", strategy.MaxUnavailable)})
+		} else if strategy.MaxUnavailable == 0 {
+			strategy.MaxUnavailable = defaultMaxUnavailable
+		}
+	case models.RolloutCanary:
+		if strategy.CanaryPercent == 0 {
+			issues = append(issues, models.ValidationIssue{Path: path + ".canaryPercent", Code: IssueRequired,
+				Message: "a canary rollout needs the percentage of jobs deployed first"})
+		} else if strategy.CanaryPercent < 0 || strategy.CanaryPercent > 100 {
+			issues = append(issues, models.ValidationIssue{Path: path + ".canaryPercent", Code: IssueInvalid,
+				Message: fmt.Sprintf("canaryPercent must be between 1 and 100, got %d", strategy.CanaryPercent)})
+		}
+	default:
+		issues = append(issues, models.ValidationIssue{Path: path + ".type", Code: IssueInvalid,
+			Message: fmt.Sprintf("unknown rollout strategy %s, expected %s, %s or %s", strategy.Type,
+				models.RolloutAllAtOnce, models.RolloutRolling, models.RolloutCanary)})
+	}
+	return issues
+}
+
+// startRollout starts the rollout of the jobs of a job group to deploy
+func startRollout(jobGroup *models.JobGroup) {
+	jobGroup.RolloutStatus = models.RolloutStatus{State: models.RolloutProgressing}
+}
+
+// rolloutJobs returns the jobs of a job group taking part in its rollout, undeployments are not rolled out
+func rolloutJobs(jobGroup *models.JobGroup) []models.Job {
+	jobs := []models.Job{}
+	for _, job := range jobGroup.Jobs {
+		if job.Type != models.DeleteDeployment {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// isDegraded tells whether a job taken by an agent is reported degraded
+func isDegraded(job models.Job) bool {
+	if job.State == models.JobCreated {
+		return false
+	}
+	if job.State == models.JobDegraded {
+		return true
+	}
+	if job.Resource != nil {
+		for _, condition := range job.Resource.Conditions {
+			if condition.Type == models.Degraded && condition.Status == models.ConditionTrue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// canaryCount is the number of jobs a canary rollout deploys first, at least one
+func canaryCount(strategy models.RolloutStrategy, jobs int) int {
+	count := (strategy.CanaryPercent*jobs + 99) / 100
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// rolloutReady tells whether the rollout of its job group lets a job to deploy through. Jobs are rolled out in
+// the order of the job group. A rolling rollout keeps at most maxUnavailable jobs deploying, taken among the ones
+// whose dependencies are ready so that a job does not hold the slot of the ones it waits for. A canary rollout
+// deploys its canary jobs first, the other ones once the rollout is resumed. A degraded job halts the rollout,
+// the reason is returned. A failed update rolled back is not rolled out, its previous revision replaces it at once.
+func rolloutReady(job models.Job, jobGroup *models.JobGroup) (bool, string) {
+	strategy := jobGroup.Rollout
+	if strategy.Type == "" || strategy.Type == models.RolloutAllAtOnce {
+		return true, ""
+	}
+	if jobGroup.RolloutStatus.State == models.RolloutFailed {
+		return true, ""
+	}
+	if jobGroup.RolloutStatus.State == models.RolloutHalted {
+		return false, ""
+	}
+
+	jobs := rolloutJobs(jobGroup)
+	for _, other := range jobs {
+		if isDegraded(other) {
+			return false, fmt.Sprintf("job %s of component %s is degraded", other.ID, componentName(other))
+		}
+	}
+
+	switch strategy.Type {
+	case models.RolloutRolling:
+		maxUnavailable := strategy.MaxUnavailable
+		if maxUnavailable <= 0 {
+			maxUnavailable = defaultMaxUnavailable
+		}
+		pending := []string{}
+		for _, other := range jobs {
+			if other.State == models.JobCreated {
+				if dependenciesReady(other, jobGroup.Jobs) {
+					pending = append(pending, other.ID)
+				}
+			} else if other.State != models.JobFinished && !isAvailable(other) {
+				maxUnavailable--
+			}
+		}
+		for i := 0; i < maxUnavailable && i < len(pending); i++ {
+			if pending[i] == job.ID {
+				return true, ""
+			}
+		}
+		return false, ""
+	case models.RolloutCanary:
+		canaries := canaryCount(strategy, len(jobs))
+		for i, other := range jobs {
+			if other.ID == job.ID && i < canaries {
+				return true, ""
+			}
+		}
+		return jobGroup.RolloutStatus.Resumed, ""
+	}
+	return true, ""
+}
+
+// reportRollout fills in the state of the rollout of a job group: halted or failed as stored, paused once the
+// canary jobs are available, completed once every job is, progressing otherwise
+func reportRollout(jobGroup *models.JobGroup) {
+	status := &jobGroup.RolloutStatus
+	if status.State == "" || status.State == models.RolloutHalted || status.State == models.RolloutFailed {
+		return
+	}
+
+	jobs := rolloutJobs(jobGroup)
+	available := 0
+	for _, job := range jobs {
+		if isAvailable(job) {
+			available++
+		}
+	}
+	switch {
+	case available == len(jobs):
+		status.State = models.RolloutCompleted
+	case jobGroup.Rollout.Type == models.RolloutCanary && !status.Resumed && available >= canaryCount(jobGroup.Rollout, len(jobs)):
+		status.State = models.RolloutPaused
+	default:
+		status.State = models.RolloutProgressing
+	}
+}
+
+// ResumeRollout resumes the rollout of a job group of the tenant, a paused canary rollout deploys its other jobs
+// and a halted rollout goes on once none of its jobs is degraded anymore. A non zero version must match the
+// stored one.
+func (s *jobGroupService) ResumeRollout(id, tenant string, version int64) (*models.JobGroup, error) {
+	jobGroup, err := s.repo.FindJobGroupByUUID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTenant(jobGroup, tenant); err != nil {
+		logs.Logger.Println("Error resuming the rollout of job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroup.ResourceVersion); err != nil {
+		return nil, err
+	}
+	if jobGroup.RolloutStatus.State == "" {
+		return nil, errors.New("JobGroup has no rollout to resume")
+	}
+	if jobGroup.RolloutStatus.State == models.RolloutHalted {
+		// the rollout would halt again on the next poll of the agents
+		for _, job := range rolloutJobs(jobGroup) {
+			if isDegraded(job) {
+				return nil, fmt.Errorf("%w: job %s of component %s is still degraded", ErrRolloutDegraded, job.ID, componentName(job))
+			}
+		}
+	}
+
+	jobGroup.RolloutStatus = models.RolloutStatus{State: models.RolloutProgressing, Resumed: true}
+	jobGroupUpdated, err := s.repo.UpdateJobGroup(jobGroup)
+	if err != nil {
+		logs.Logger.Println("Error resuming the rollout of job group:", err)
+		return nil, err
+	}
+	logs.Logger.Printf("Rollout of job group %s resumed", id)
+
+	reportRollout(jobGroupUpdated)
+	redactJobGroup(jobGroupUpdated)
+	return jobGroupUpdated, nil
+}
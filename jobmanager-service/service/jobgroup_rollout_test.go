@@ -0,0 +1,250 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRollout(t *testing.T) {
+	strategy := models.RolloutStrategy{}
+	assert.Empty(t, validateRollout(&strategy, "rollout"))
+	assert.Equal(t, models.RolloutAllAtOnce, strategy.Type)
+
+	strategy = models.RolloutStrategy{Type: models.RolloutRolling}
+	assert.Empty(t, validateRollout(&strategy, "rollout"))
+	assert.Equal(t, 1, strategy.MaxUnavailable)
+
+	strategy = models.RolloutStrategy{Type: models.RolloutCanary}
+	issues := validateRollout(&strategy, "rollout")
+	require.Len(t, issues, 1)
+	assert.Equal(t, models.ValidationIssue{Path: "rollout.canaryPercent", Code: IssueRequired,
+		Message: "a canary rollout needs the percentage of jobs deployed first"}, issues[0])
+
+	strategy = models.RolloutStrategy{Type: models.RolloutCanary, CanaryPercent: 150}
+	issues = validateRollout(&strategy, "rollout")
+	require.Len(t, issues, 1)
+	assert.Equal(t, IssueInvalid, issues[0].Code)
+
+	strategy = models.RolloutStrategy{Type: "blue-green"}
+	issues = validateRollout(&strategy, "rollout")
+	require.Len(t, issues, 1)
+	assert.Equal(t, "rollout.type", issues[0].Path)
+}
+
+func rolloutGroup(strategy models.RolloutStrategy, jobs ...models.Job) *models.JobGroup {
+	return &models.JobGroup{
+		BaseUUID:      models.BaseUUID{ID: "group-1"},
+		Rollout:       strategy,
+		RolloutStatus: models.RolloutStatus{State: models.RolloutProgressing},
+		Jobs:          jobs,
+	}
+}
+
+func TestRollingRollout(t *testing.T) {
+	strategy := models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 2}
+	a := dependencyJob("a", "a", models.CreateDeployment, models.JobCreated, false)
+	b := dependencyJob("b", "b", models.CreateDeployment, models.JobCreated, false)
+	c := dependencyJob("c", "c", models.CreateDeployment, models.JobCreated, false)
+
+	group := rolloutGroup(strategy, a, b, c)
+	executable, _ := rolloutReady(a, group)
+	assert.True(t, executable)
+	executable, _ = rolloutReady(b, group)
+	assert.True(t, executable)
+	executable, _ = rolloutReady(c, group)
+	assert.False(t, executable)
+
+	// one slot is taken by a job still deploying
+	a.State = models.JobProgressing
+	group = rolloutGroup(strategy, a, b, c)
+	executable, _ = rolloutReady(b, group)
+	assert.True(t, executable)
+	executable, _ = rolloutReady(c, group)
+	assert.False(t, executable)
+
+	a = dependencyJob("a", "a", models.CreateDeployment, models.JobProgressing, true)
+	group = rolloutGroup(strategy, a, b, c)
+	executable, _ = rolloutReady(c, group)
+	assert.True(t, executable)
+}
+
+func TestRollingRolloutWithDependencies(t *testing.T) {
+	strategy := models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 1}
+	api := dependencyJob("api", "api", models.CreateDeployment, models.JobCreated, false, "db")
+	db := dependencyJob("db", "db", models.CreateDeployment, models.JobCreated, false)
+
+	// the job coming first waits for the one it depends on, which takes the slot
+	group := rolloutGroup(strategy, api, db)
+	executable, _ := rolloutReady(db, group)
+	assert.True(t, executable)
+	executable, _ = rolloutReady(api, group)
+	assert.False(t, executable)
+
+	db = dependencyJob("db", "db", models.CreateDeployment, models.JobProgressing, true)
+	group = rolloutGroup(strategy, api, db)
+	executable, _ = rolloutReady(api, group)
+	assert.True(t, executable)
+	assert.True(t, dependenciesReady(api, group.Jobs))
+}
+
+func TestCanaryRollout(t *testing.T) {
+	strategy := models.RolloutStrategy{Type: models.RolloutCanary, CanaryPercent: 25}
+	jobs := []models.Job{}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		jobs = append(jobs, dependencyJob(id, id, models.CreateDeployment, models.JobCreated, false))
+	}
+
+	// 25% of five jobs rounds up to two canaries
+	group := rolloutGroup(strategy, jobs...)
+	for i, job := range jobs {
+		executable, _ := rolloutReady(job, group)
+		assert.Equal(t, i < 2, executable, job.ID)
+	}
+
+	jobs[0] = dependencyJob("a", "a", models.CreateDeployment, models.JobProgressing, true)
+	jobs[1] = dependencyJob("b", "b", models.CreateDeployment, models.JobProgressing, true)
+	group = rolloutGroup(strategy, jobs...)
+	reportRollout(group)
+	assert.Equal(t, models.RolloutPaused, group.RolloutStatus.State)
+	executable, _ := rolloutReady(jobs[2], group)
+	assert.False(t, executable)
+
+	group.RolloutStatus = models.RolloutStatus{State: models.RolloutProgressing, Resumed: true}
+	executable, _ = rolloutReady(jobs[2], group)
+	assert.True(t, executable)
+	reportRollout(group)
+	assert.Equal(t, models.RolloutProgressing, group.RolloutStatus.State)
+}
+
+func TestCanaryAutoRollback(t *testing.T) {
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	jobGroupService := NewJobGroupService(mockJobGroupRepo, nil, nil, nil)
+
+	jobs := []models.Job{dependencyJob("a", "a", models.UpdateDeployment, models.JobDegraded, false)}
+	for _, id := range []string{"b", "c", "d"} {
+		jobs = append(jobs, dependencyJob(id, id, models.UpdateDeployment, models.JobFinished, true))
+	}
+	revisionJobs := models.RevisionJobs{}
+	for i := range jobs {
+		jobs[i].OwnerID = "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c"
+		revisionJobs = append(revisionJobs, models.RevisionJob{JobID: jobs[i].ID, Manifests: []string{"image: shop:1\n"}})
+	}
+	group := rolloutGroup(models.RolloutStrategy{Type: models.RolloutCanary, CanaryPercent: 25}, jobs...)
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(group, nil).Once()
+	mockJobGroupRepo.On("FindJobGroupRevisions", "group-1").Return(&[]models.JobGroupRevision{
+		{JobGroupID: "group-1", Revision: 2, Jobs: revisionJobs},
+		{JobGroupID: "group-1", Revision: 1, Jobs: revisionJobs},
+	}, nil).Once()
+	mockJobGroupRepo.On("UpdateJobGroup", group).Return(group, nil).Once()
+	mockJobGroupRepo.On("SaveJobGroupRevision", mock.Anything).Return(&models.JobGroupRevision{Revision: 3}, nil).Once()
+
+	result, err := jobGroupService.AutoRollbackJobGroup("group-1", "job a degraded")
+	require.NoError(t, err)
+	assert.Equal(t, models.RolloutFailed, result.RolloutStatus.State)
+
+	// the previous revision replaces the failed update at once, the canary does not hold it back
+	for _, job := range result.Jobs {
+		executable, halt := rolloutReady(job, result)
+		assert.True(t, executable, job.ID)
+		assert.Empty(t, halt)
+	}
+	mockJobGroupRepo.AssertExpectations(t)
+}
+
+func TestFindJobsToExecuteHaltsDegradedRollout(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	a := dependencyJob("a", "a", models.CreateDeployment, models.JobDegraded, false)
+	b := dependencyJob("b", "b", models.CreateDeployment, models.JobCreated, false)
+	group := rolloutGroup(models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 2}, a, b)
+	mockRepo.On("FindJobsToExecute", "ocm", "agent").Return(&[]models.Job{b}, nil)
+	mockRepo.On("FindJobGroupRollout", "group-1").Return(group, nil).Once()
+	mockRepo.On("HaltRollout", "group-1", "job a of component a is degraded").Return(nil).Once()
+
+	jobs, err := service.FindJobsToExecute("ocm", "agent")
+	require.NoError(t, err)
+	assert.Empty(t, *jobs)
+
+	// a halted rollout stays halted until it is resumed
+	mockRepo.On("FindJobGroupRollout", "group-1").Return(group, nil).Once()
+	jobs, err = service.FindJobsToExecute("ocm", "agent")
+	require.NoError(t, err)
+	assert.Empty(t, *jobs)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResumeHaltedRollout(t *testing.T) {
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	jobGroupService := NewJobGroupService(mockJobGroupRepo, nil, nil, nil)
+
+	a := dependencyJob("a", "a", models.CreateDeployment, models.JobDegraded, false)
+	b := dependencyJob("b", "b", models.CreateDeployment, models.JobCreated, false)
+	group := rolloutGroup(models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 1}, a, b)
+	group.RolloutStatus = models.RolloutStatus{State: models.RolloutHalted, Message: "job a of component a is degraded"}
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(group, nil).Once()
+
+	// the rollout would halt again on the degraded job
+	_, err := jobGroupService.ResumeRollout("group-1", "", 0)
+	assert.ErrorIs(t, err, ErrRolloutDegraded)
+	mockJobGroupRepo.AssertNotCalled(t, "UpdateJobGroup", group)
+
+	a = dependencyJob("a", "a", models.CreateDeployment, models.JobProgressing, true)
+	group = rolloutGroup(models.RolloutStrategy{Type: models.RolloutRolling, MaxUnavailable: 1}, a, b)
+	group.RolloutStatus = models.RolloutStatus{State: models.RolloutHalted, Message: "job a of component a is degraded"}
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(group, nil).Once()
+	mockJobGroupRepo.On("UpdateJobGroup", group).Return(group, nil).Once()
+
+	result, err := jobGroupService.ResumeRollout("group-1", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.RolloutProgressing, result.RolloutStatus.State)
+	mockJobGroupRepo.AssertExpectations(t)
+}
+
+func TestResumeRolloutTenant(t *testing.T) {
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	jobGroupService := NewJobGroupService(mockJobGroupRepo, nil, nil, nil)
+
+	group := rolloutGroup(models.RolloutStrategy{Type: models.RolloutCanary, CanaryPercent: 50},
+		dependencyJob("a", "a", models.CreateDeployment, models.JobProgressing, true),
+		dependencyJob("b", "b", models.CreateDeployment, models.JobCreated, false))
+	group.Tenant = "team-a"
+	group.RolloutStatus = models.RolloutStatus{State: models.RolloutPaused}
+	mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(group, nil)
+	mockJobGroupRepo.On("UpdateJobGroup", group).Return(group, nil).Once()
+
+	// the paused canary of another tenant is not resumed
+	_, err := jobGroupService.ResumeRollout("group-1", "team-b", 0)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.False(t, group.RolloutStatus.Resumed)
+
+	result, err := jobGroupService.ResumeRollout("group-1", "team-a", 0)
+	require.NoError(t, err)
+	assert.True(t, result.RolloutStatus.Resumed)
+	mockJobGroupRepo.AssertExpectations(t)
+}
@@ -21,240 +21,149 @@
 package service
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/repository"
 	"icos/server/jobmanager-service/utils/logs"
-	"io"
 	"net/http"
-	"strings"
-	"time"
-
-	"github.com/google/uuid"
-	"gopkg.in/yaml.v2"
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
 )
 
 // JobGroupService interface defines the methods for job group operations
 type JobGroupService interface {
-	CreateJobGroup(bodyBytes []byte, header http.Header) (*models.JobGroup, error)
-	UpdateJobGroup(bodyJob []byte) (*models.JobGroup, error)
+	CreateJobGroup(bodyBytes []byte, params map[string]string, header http.Header, tenant, author string) (*models.JobGroup, error)
+	ValidateJobGroup(bodyBytes []byte, params map[string]string, header http.Header, tenant string, matchmaking bool) (*models.JobGroupValidation, error)
+	UpdateJobGroup(bodyJob []byte, tenant, author string, version int64) (*models.JobGroup, error)
 	FindJobGroupByUUID(string) (*models.JobGroup, error)
 	FindAllJobGroups() (*[]models.JobGroup, error)
-	DeleteJobGroupByID(id string) (*models.JobGroup, error)
-	StopJobGroupByID(stringID string) (*models.JobGroup, error)
+	DeleteJobGroupByID(id, tenant string, version int64) (*models.JobGroup, error)
+	StopJobGroupByID(stringID, tenant string, version int64) (*models.JobGroup, error)
+	RedeployJobGroupByID(id string, matchmaking bool, header http.Header, tenant, author string, version int64) (*models.JobGroup, error)
+	FindJobGroupRevisions(id string) (*[]models.JobGroupRevision, error)
+	FindJobGroupRevision(id string, revision int64) (*models.JobGroupRevision, error)
+	RollbackJobGroup(id string, revision int64, tenant, author string, version int64) (*models.JobGroup, error)
+	ApproveJobGroup(id, approver, tenant string, version int64) (*models.JobGroup, error)
+	RejectJobGroup(id, approver, tenant, reason string, version int64) (*models.JobGroup, error)
+	AutoRollbackJobGroup(id, reason string) (*models.JobGroup, error)
+	DiffJobGroup(id string, from, to int64) (*models.JobGroupDiff, error)
+	DiffJobGroupDescriptor(id string, bodyBytes []byte, params map[string]string, header http.Header, tenant string, matchmaking bool) (*models.JobGroupDiff, error)
+	ResumeRollout(id, tenant string, version int64) (*models.JobGroup, error)
 }
 
 // jobGroupService struct implements the JobGroupService interface
 type jobGroupService struct {
-	repo repository.JobGroupRepository
+	repo        repository.JobGroupRepository
+	quotas      QuotaService
+	httpClient  HTTPClient
+	maintenance MaintenanceService
+	gate        approvalGate
 }
 
-// NewJobGroupService returns a new instance of jobGroupService
-func NewJobGroupService(repo repository.JobGroupRepository) JobGroupService {
-	return &jobGroupService{repo: repo}
+// NewJobGroupService returns a new instance of jobGroupService, the HTTP client is used to call the matchmaker.
+// The jobs of the job groups found tell why they are deferred when a maintenance window withholds them.
+func NewJobGroupService(repo repository.JobGroupRepository, quotas QuotaService, httpClient HTTPClient, maintenance MaintenanceService) JobGroupService {
+	return &jobGroupService{repo: repo, quotas: quotas, httpClient: httpClient, maintenance: maintenance, gate: approvalGateFromEnv()}
 }
 
-// SaveJobGroup saves a new job group
-func (s *jobGroupService) CreateJobGroup(bodyBytes []byte, header http.Header) (*models.JobGroup, error) {
-	bodyString := string(bodyBytes)
-	bodyStringTrimmed := strings.Trim(bodyString, "\r\n")
-	logs.Logger.Println("Trimmed body: " + bodyStringTrimmed)
-
-	applicationDescriptor := models.JobGroupHeader{}
-	err := yaml.Unmarshal([]byte(bodyStringTrimmed), &applicationDescriptor)
-	if err != nil {
-		return nil, err
-	}
-
-	logs.Logger.Printf("Application descriptor: %#v", applicationDescriptor)
-	// // Prepare request for MM
-	req, err := http.NewRequest("POST", models.MatchmakerBaseURL+"/matchmake", bytes.NewBuffer(bodyBytes))
+// SaveJobGroup saves a new job group owned by the tenant, the descriptor is rendered with the parameter values.
+// The job group starts at the first revision, authored by the caller.
+func (s *jobGroupService) CreateJobGroup(bodyBytes []byte, params map[string]string, header http.Header, tenant, author string) (*models.JobGroup, error) {
+	applicationDescriptor, descriptorBytes, err := parseApplicationDescriptor(bodyBytes, params)
 	if err != nil {
-		logs.Logger.Println("ERROR " + err.Error())
 		return nil, err
 	}
 
-	// add content type
-	req.Header.Set("Content-Type", "application/x-yaml")
-	// forward the authorization token
-	req.Header.Add("Authorization", header.Get("Authorization"))
-
-	// do request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		logs.Logger.Printf("ERROR executing request: %v", err)
+	if err := s.matchmake(&applicationDescriptor, descriptorBytes, header); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	//Mocking MM response for development
 	// mMResponseJson := MockMatchmakerResponse()
-	// bodyMM := []byte(mMResponseJson)
+	// json.Unmarshal([]byte(mMResponseJson), &applicationDescriptor)
 	//end of mock
 
-	bodyMM, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logs.Logger.Printf("ERROR reading response body: %v", err)
+	validation := buildJobGroup(applicationDescriptor, tenant)
+	if !validation.Valid {
+		return nil, &ValidationError{Issues: validation.Errors}
+	}
+	jobGroup := validation.JobGroup
+
+	if issue, err := s.checkNamespace(tenant, &jobGroup); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
 		return nil, err
+	} else if issue != nil {
+		return nil, &ValidationError{Issues: []models.ValidationIssue{*issue}}
 	}
 
-	// Log and format MM response
-	dst := &bytes.Buffer{}
-	if err := json.Indent(dst, bodyMM, "", "  "); err != nil {
-		logs.Logger.Printf("ERROR formatting JSON response: %v", err)
+	if err := s.quotas.CheckJobGroup(tenant, &jobGroup, true); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
 		return nil, err
 	}
-	logs.Logger.Println("MM response is: " + dst.String())
+	s.requestApproval(&jobGroup, author)
 
-	_ = json.Indent(dst, bodyMM, "", "  ")
-	logs.Logger.Println("MM response is: " + dst.String())
-	err = json.Unmarshal(bodyMM, &applicationDescriptor)
+	_, err = s.repo.SaveJobGroup(&jobGroup)
+	if errors.Is(err, ErrNamespaceConflict) {
+		// another tenant claimed the namespace since it was checked
+		return nil, &ValidationError{Issues: []models.ValidationIssue{*namespaceConflict(jobGroup.Jobs[0].Namespace)}}
+	}
 	if err != nil {
 		logs.Logger.Println("ERROR " + err.Error())
 		return nil, err
 	}
-	logs.Logger.Printf("Matchmaking response details: %#v", applicationDescriptor)
-
-	conditions := []models.Condition{
-		{
-			Type:               "Created",
-			Status:             "True",
-			ObservedGeneration: 1,
-			LastTransitionTime: time.Now(),
-			Reason:             "AwaitingForTarget",
-			Message:            "Waiting for the Target",
-		},
-		{
-			Type:               "Created",
-			Status:             "True",
-			ObservedGeneration: 1,
-			LastTransitionTime: time.Now(),
-			Reason:             "AwaitingForExecution",
-			Message:            "Waiting an Orchestrator to take the Job",
-		},
+	if err := s.recordRevision(&jobGroup, string(descriptorBytes), author, 0); err != nil {
+		return nil, err
 	}
 
-	jobGroup := models.JobGroup{
-		AppName:        applicationDescriptor.Name,
-		AppDescription: applicationDescriptor.Description,
-	}
+	redactJobGroup(&jobGroup)
+	return &jobGroup, nil
+}
 
-	if jobGroup.AppName == "" {
-		jobGroup.AppName = uuid.New().String()
+// ValidateJobGroup reports the job group an application descriptor would create, without persisting it.
+// The matchmaker is only asked for targets on demand, otherwise the jobs are left without target and the overlays
+// selecting targets are reported as not applied.
+func (s *jobGroupService) ValidateJobGroup(bodyBytes []byte, params map[string]string, header http.Header, tenant string, matchmaking bool) (*models.JobGroupValidation, error) {
+	applicationDescriptor, descriptorBytes, err := parseApplicationDescriptor(bodyBytes, params)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, comp := range applicationDescriptor.Components {
-		job := models.Job{
-			Type:  models.CreateDeployment,
-			State: models.JobCreated,
-			//Targets:      comp.Targets,
-			JobGroupName: jobGroup.AppName,
-			//Orchestrator: comp.Targets.Orchestrator,
-			Namespace: applicationDescriptor.Name,
-			Resource: &models.Resource{
-				ResourceName: comp.Name,
-				Conditions:   conditions,
-			},
+	if matchmaking {
+		if err := s.matchmake(&applicationDescriptor, descriptorBytes, header); err != nil {
+			return nil, err
 		}
-
-		/* Given that it is possible that MM returns an empty target, we need to handle this case.
-		The switch statement below checks the type of `targets` and handles each case appropriately:
-		- If `targets` is a `map[string]interface{}`, it represents a single target object.
-		  We marshal this map to JSON and then unmarshal it into a `Target` struct.
-		- If `targets` is an empty array (`[]interface{}` with length 0), we assign an empty
-		  `Target` struct to indicate that there are no targets.
-		- If `targets` is any other type, it's an unexpected scenario and we log an error.
-		*/
-
-		switch targets := comp.Targets.(type) {
-		case map[string]interface{}:
-			targetBytes, err := json.Marshal(targets)
-			if err != nil {
-				logs.Logger.Println("ERROR " + err.Error())
-				return nil, err
-			}
-
-			var targetStruct models.Target
-			err = json.Unmarshal(targetBytes, &targetStruct)
-			if err != nil {
-				logs.Logger.Println("ERROR " + err.Error())
-				return nil, err
-			}
-
-			job.Targets = targetStruct
-			job.Orchestrator = targetStruct.Orchestrator
-
-		case []interface{}:
-			if len(targets) == 0 {
-				job.Targets = models.Target{} // Empty Target
-			} else {
-				logs.Logger.Fatalf("Unexpected non-empty array for targets")
-			}
-
-		default:
-			logs.Logger.Fatalf("Unexpected type for targets: %T", comp.Targets)
+	} else {
+		// targets are assigned by the matchmaker, the ones of the descriptor are only hints
+		for i := range applicationDescriptor.Components {
+			applicationDescriptor.Components[i].Targets = nil
 		}
+	}
 
-		for _, manifest := range applicationDescriptor.Manifests {
-			manifestMap, ok := manifest["metadata"].(map[interface{}]interface{})
-			if !ok {
-				logs.Logger.Println("ERROR: Invalid manifest structure")
-				continue
-			}
-
-			manifestName, ok := manifestMap["name"].(string)
-			if !ok {
-				logs.Logger.Println("ERROR: Invalid manifest name")
-				continue
-			}
-
-			for _, manifestRef := range comp.Manifests {
-				logs.Logger.Printf("ManifestRef name: %s", manifestRef.Name)
-				logs.Logger.Print("Manifest name: " + manifestName)
-				if manifestRef.Name == manifestName {
-					logs.Logger.Println("Marshalling manifest to YAML for manifestRef: " + manifestRef.Name)
-					manifestYAML, err := yaml.Marshal(manifest)
-					if err != nil {
-						logs.Logger.Println("ERROR during k8s manifest marshalling: " + err.Error())
-						continue
-					}
-
-					logs.Logger.Println("YAML Marshalled: " + string(manifestYAML))
-					_, err = decodeYAMLToObject(string(manifestYAML))
-					if err != nil {
-						logs.Logger.Println("ERROR during k8s manifest validation: " + err.Error())
-						continue
-					}
-
-					logs.Logger.Println("Successfully decoded YAML to k8s object for manifestRef: " + manifestRef.Name)
-					logs.Logger.Println("Manifest to be populated: " + manifestRef.Name)
-					job.Manifests = append(job.Manifests, models.PlainManifest{
-						YamlString: string(manifestYAML),
-					})
-				}
+	validation := buildJobGroup(applicationDescriptor, tenant)
+	if !matchmaking {
+		for o, overlay := range applicationDescriptor.Overlays {
+			if overlay.Targets.Cluster != "" || overlay.Targets.Orchestrator != "" || len(overlay.Targets.Labels) > 0 {
+				validation.Warnings = append(validation.Warnings, models.ValidationIssue{Path: fmt.Sprintf("overlays[%d].targets", o), Code: IssueNoTarget,
+					Message: fmt.Sprintf("overlay %s selects targets, it is only applied once the matchmaker assigned them", overlay.Name)})
 			}
-
 		}
-
-		jobGroup.Jobs = append(jobGroup.Jobs, job)
-		logs.Logger.Println("New Job appended to JobGroup: " + job.JobGroupID)
 	}
 
-	_, err = s.repo.SaveJobGroup(&jobGroup)
-	if err != nil {
-		logs.Logger.Println("ERROR " + err.Error())
+	if issue, err := s.checkNamespace(tenant, &validation.JobGroup); err != nil {
 		return nil, err
+	} else if issue != nil {
+		validation.Errors = append(validation.Errors, *issue)
+		validation.Valid = false
 	}
 
-	return &jobGroup, nil
+	if err := s.quotas.CheckJobGroup(tenant, &validation.JobGroup, true); err != nil {
+		validation.Errors = append(validation.Errors, models.ValidationIssue{Code: IssueQuotaExceeded, Message: err.Error()})
+		validation.Valid = false
+	}
+
+	redactJobGroup(&validation.JobGroup)
+
+	return validation, nil
 }
 
 func MockMatchmakerResponse() string {
@@ -317,43 +226,58 @@ func MockMatchmakerResponse() string {
 	return mMResponseJson
 }
 
-func decodeYAMLToObject(yamlString string) (runtime.Object, error) {
-	scheme := runtime.NewScheme()
-	if err := appsv1.AddToScheme(scheme); err != nil {
-		return nil, fmt.Errorf("failed to add apps/v1 to scheme: %w", err)
-	}
-	if err := corev1.AddToScheme(scheme); err != nil {
-		return nil, fmt.Errorf("failed to add core/v1 to scheme: %w", err)
-	}
-
-	codecFactory := serializer.NewCodecFactory(scheme)
-	decoder := codecFactory.UniversalDeserializer()
-
-	obj, _, err := decoder.Decode([]byte(yamlString), nil, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	return obj, nil
+// jobGroupUpdateFields holds the fields of a job group update that are kept as they are when left out
+type jobGroupUpdateFields struct {
+	Priority *int `json:"priority"`
 }
 
-// UpdateJobGroup updates an existing job group
-func (s *jobGroupService) UpdateJobGroup(bodyJob []byte) (*models.JobGroup, error) {
+// UpdateJobGroup updates an existing job group of the tenant, see checkTenant. The update is based on the given
+// version, or on the resource version of the body when none is given. Every update is recorded as a new revision.
+func (s *jobGroupService) UpdateJobGroup(bodyJob []byte, tenant, author string, version int64) (*models.JobGroup, error) {
 	var jobGroupUpdate models.JobGroup
 	if err := json.Unmarshal(bodyJob, &jobGroupUpdate); err != nil {
 		logs.Logger.Println("Error unmarshaling request body:", err)
 		return nil, err
 	}
+	var updateFields jobGroupUpdateFields
+	if err := json.Unmarshal(bodyJob, &updateFields); err != nil {
+		logs.Logger.Println("Error unmarshaling request body:", err)
+		return nil, err
+	}
 
 	logs.Logger.Println("Updating job group with ID:", jobGroupUpdate.ID)
-	existingJobGroup, err := s.FindJobGroupByUUID(jobGroupUpdate.ID)
+	existingJobGroup, err := s.repo.FindJobGroupByUUID(jobGroupUpdate.ID)
 	if err != nil {
 		logs.Logger.Println("Error finding job group by UUID:", err)
 		return nil, err
 	}
+	if err := checkTenant(existingJobGroup, tenant); err != nil {
+		logs.Logger.Println("Error updating job group:", err)
+		return nil, err
+	}
+
+	if version == 0 {
+		version = jobGroupUpdate.ResourceVersion
+	}
+	if err := checkResourceVersion(version, existingJobGroup.ResourceVersion); err != nil {
+		logs.Logger.Println("Error updating job group:", err)
+		return nil, err
+	}
 
 	existingJobGroup.AppName = jobGroupUpdate.AppName
 	existingJobGroup.AppDescription = jobGroupUpdate.AppDescription
+	if jobGroupUpdate.Rollout.Type != "" {
+		if issues := validateRollout(&jobGroupUpdate.Rollout, "rollout"); len(issues) > 0 {
+			return nil, &ValidationError{Issues: issues}
+		}
+		existingJobGroup.Rollout = jobGroupUpdate.Rollout
+	}
+	if updateFields.Priority != nil {
+		if issues := validatePriority(*updateFields.Priority, "priority"); len(issues) > 0 {
+			return nil, &ValidationError{Issues: issues}
+		}
+		existingJobGroup.Priority = *updateFields.Priority
+	}
 
 	if len(jobGroupUpdate.Jobs) > 0 {
 		jobMap := make(map[string]models.Job)
@@ -363,32 +287,59 @@ func (s *jobGroupService) UpdateJobGroup(bodyJob []byte) (*models.JobGroup, erro
 
 		for i := range existingJobGroup.Jobs {
 			if updatedJob, ok := jobMap[existingJobGroup.Jobs[i].ID]; ok {
+				if err := checkResourceVersion(updatedJob.ResourceVersion, existingJobGroup.Jobs[i].ResourceVersion); err != nil {
+					logs.Logger.Println("Error updating job "+updatedJob.ID+":", err)
+					return nil, err
+				}
+				updatedJob.ResourceVersion = existingJobGroup.Jobs[i].ResourceVersion
+				restoreRedacted(&updatedJob, &existingJobGroup.Jobs[i])
+				restoreOverlaid(&updatedJob, &existingJobGroup.Jobs[i], existingJobGroup.Overlays)
 				existingJobGroup.Jobs[i] = updatedJob
 			}
 		}
 	}
 
 	for i := range existingJobGroup.Jobs {
-		job := &existingJobGroup.Jobs[i]
-		job.State = models.JobCreated
-		if job.OwnerID != "" {
-			job.Type = models.ReplaceDeployment
-		} else {
-			job.Type = models.CreateDeployment
-		}
+		replaceJob(&existingJobGroup.Jobs[i])
+		existingJobGroup.Jobs[i].Priority = existingJobGroup.Priority
+	}
+	startRollout(existingJobGroup)
+
+	if err := s.quotas.CheckJobGroup(existingJobGroup.Tenant, existingJobGroup, false); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		return nil, err
 	}
 
+	s.requestApproval(existingJobGroup, author)
+
 	jobGroupUpdated, err := s.repo.UpdateJobGroup(existingJobGroup)
+	if errors.Is(err, ErrNamespaceConflict) {
+		return nil, &ValidationError{Issues: []models.ValidationIssue{{Path: "jobs", Code: IssueConflict, Message: err.Error()}}}
+	}
 	if err != nil {
 		logs.Logger.Println("Error updating job group:", err)
 		return nil, err
 	}
+	if err := s.recordRevision(jobGroupUpdated, "", author, 0); err != nil {
+		return nil, err
+	}
 
+	redactJobGroup(jobGroupUpdated)
 	return jobGroupUpdated, nil
 }
 
-// DeleteJobGroup deletes a job group
-func (s *jobGroupService) DeleteJobGroupByID(id string) (*models.JobGroup, error) {
+// checkTenant verifies that a job group is owned by the tenant, an empty tenant stands for an administrator
+// managing every job group. Job groups created before tenants existed are only managed by administrators.
+func checkTenant(jobGroup *models.JobGroup, tenant string) error {
+	if tenant != "" && jobGroup.Tenant != tenant {
+		return fmt.Errorf("%w: job group %s is not owned by tenant %s", ErrForbidden, jobGroup.ID, tenant)
+	}
+	return nil
+}
+
+// DeleteJobGroup deletes a job group of the tenant, a non zero version must match the stored one. The deletion is
+// conditioned on the version the checks below were made on, so a job group modified meanwhile is kept.
+func (s *jobGroupService) DeleteJobGroupByID(id, tenant string, version int64) (*models.JobGroup, error) {
 	if id == "" {
 		err := errors.New("ID Cannot be empty")
 		logs.Logger.Println("JobGroup's ID is empty!")
@@ -400,6 +351,13 @@ func (s *jobGroupService) DeleteJobGroupByID(id string) (*models.JobGroup, error
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTenant(jobGroupGotten, tenant); err != nil {
+		logs.Logger.Println("Error deleting job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroupGotten.ResourceVersion); err != nil {
+		return nil, err
+	}
 
 	for _, job := range jobGroupGotten.Jobs {
 		logs.Logger.Printf("Checking job with ID: %s, Type: %d, State: %d\n", job.ID, job.Type, job.State)
@@ -419,7 +377,7 @@ func (s *jobGroupService) DeleteJobGroupByID(id string) (*models.JobGroup, error
 	}
 
 	// Delete the job group
-	_, err = s.repo.DeleteJobGroup(id)
+	_, err = s.repo.DeleteJobGroup(id, jobGroupGotten.ResourceVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -427,44 +385,228 @@ func (s *jobGroupService) DeleteJobGroupByID(id string) (*models.JobGroup, error
 	return jobGroupGotten, nil
 }
 
-func (s *jobGroupService) StopJobGroupByID(stringID string) (*models.JobGroup, error) {
+// StopJobGroupByID undeploys the jobs of a job group of the tenant, a non zero version must match the stored one
+func (s *jobGroupService) StopJobGroupByID(stringID, tenant string, version int64) (*models.JobGroup, error) {
 	if stringID == "" {
 		return nil, errors.New("ID Cannot be empty")
 	}
 
-	jobGroupGotten, err := s.FindJobGroupByUUID(stringID)
+	jobGroupGotten, err := s.repo.FindJobGroupByUUID(stringID)
 	if err != nil {
 		return nil, errors.New("JobGroup not found")
 	}
+	if err := checkTenant(jobGroupGotten, tenant); err != nil {
+		logs.Logger.Println("Error stopping job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroupGotten.ResourceVersion); err != nil {
+		return nil, err
+	}
 
 	for i := range jobGroupGotten.Jobs {
 		// TODO: Add comment explaining new executable jobs
 		job := &jobGroupGotten.Jobs[i]
-		switch job.State {
-		case models.JobCreated:
+		// an undeployment is no remediation, whatever job it replaces
+		job.SubType = ""
+		if job.Type == models.CreateDeployment && job.State == models.JobCreated && job.OwnerID == "" {
+			// a first deployment no agent took yet, nothing to undeploy
 			job.State = models.JobFinished
-			job.OwnerID = ""
-		default:
-			job.State = models.JobCreated
 			job.Type = models.DeleteDeployment
+			continue
 		}
+		// the agent owning the job undeploys what it runs, pending updates included
+		job.State = models.JobCreated
+		job.Type = models.DeleteDeployment
 	}
 
 	updatedJobGroup, err := s.repo.UpdateJobGroup(jobGroupGotten)
 	if err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
 		return nil, errors.New("error updating JobGroup")
 	}
 
+	redactJobGroup(updatedJobGroup)
 	return updatedJobGroup, nil
 }
 
-// FindJobGroupByUUID finds a job group by its UUID
+// RedeployJobGroupByID deploys the jobs of a stopped job group of the tenant again, keeping their IDs. The matchmaker can be
+// asked for new targets with the last descriptor of the job group, the change of targets is then recorded as a
+// new revision. A non zero version must match the stored one.
+func (s *jobGroupService) RedeployJobGroupByID(id string, matchmaking bool, header http.Header, tenant, author string, version int64) (*models.JobGroup, error) {
+	if id == "" {
+		return nil, errors.New("ID Cannot be empty")
+	}
+
+	jobGroupGotten, err := s.repo.FindJobGroupByUUID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTenant(jobGroupGotten, tenant); err != nil {
+		logs.Logger.Println("Error redeploying job group:", err)
+		return nil, err
+	}
+	if err := checkResourceVersion(version, jobGroupGotten.ResourceVersion); err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobGroupGotten.Jobs {
+		// stopped jobs are finished undeployments, finished deployments are still running
+		if job.Type != models.DeleteDeployment || job.State != models.JobFinished {
+			logs.Logger.Printf("Job with ID %s is of type %d in state %d, job group %s cannot be redeployed", job.ID, job.Type, job.State, id)
+			return nil, errors.New("JobGroup cannot be redeployed, one or more jobs are not stopped")
+		}
+	}
+
+	descriptor := ""
+	if matchmaking {
+		if descriptor, err = s.rematchmake(jobGroupGotten, header); err != nil {
+			logs.Logger.Println("ERROR " + err.Error())
+			return nil, err
+		}
+	}
+
+	for i := range jobGroupGotten.Jobs {
+		job := &jobGroupGotten.Jobs[i]
+		job.Type = models.CreateDeployment
+		job.SubType = ""
+		job.State = models.JobCreated
+		job.OwnerID = ""
+	}
+	startRollout(jobGroupGotten)
+
+	if err := s.quotas.CheckJobGroup(jobGroupGotten.Tenant, jobGroupGotten, false); err != nil {
+		logs.Logger.Println("ERROR " + err.Error())
+		return nil, err
+	}
+
+	s.requestApproval(jobGroupGotten, author)
+
+	updatedJobGroup, err := s.repo.UpdateJobGroup(jobGroupGotten)
+	if err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+		return nil, errors.New("error updating JobGroup")
+	}
+	if matchmaking {
+		if err := s.recordRevision(updatedJobGroup, descriptor, author, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	redactJobGroup(updatedJobGroup)
+	return updatedJobGroup, nil
+}
+
+// rematchmake asks the matchmaker for the targets of the components of a job group, with its last descriptor
+// rendered with its parameter values. It returns the descriptor used.
+func (s *jobGroupService) rematchmake(jobGroup *models.JobGroup, header http.Header) (string, error) {
+	revisions, err := s.repo.FindJobGroupRevisions(jobGroup.ID)
+	if err != nil {
+		return "", err
+	}
+	descriptor := ""
+	for _, revision := range *revisions {
+		if revision.Descriptor != "" {
+			descriptor = revision.Descriptor
+			break
+		}
+	}
+	if descriptor == "" {
+		return "", errors.New("JobGroup has no descriptor to ask the matchmaker for targets")
+	}
+
+	applicationDescriptor, descriptorBytes, err := parseApplicationDescriptor([]byte(descriptor), jobGroup.Parameters)
+	if err != nil {
+		return "", err
+	}
+	if err := s.matchmake(&applicationDescriptor, descriptorBytes, header); err != nil {
+		return "", err
+	}
+
+	for _, comp := range applicationDescriptor.Components {
+		targets, err := componentTargets(comp.Targets)
+		if err != nil {
+			return "", fmt.Errorf("targets of component %s: %w", comp.Name, err)
+		}
+		if targets.Orchestrator == "" {
+			// components the matchmaker found no target for stay where they were
+			continue
+		}
+		for i := range jobGroup.Jobs {
+			job := &jobGroup.Jobs[i]
+			if job.Resource == nil || job.Resource.ResourceName != comp.Name {
+				continue
+			}
+			targets.ID, targets.JobID = job.Targets.ID, job.Targets.JobID
+			job.Targets = targets
+			job.Orchestrator = targets.Orchestrator
+		}
+	}
+	return descriptor, nil
+}
+
+// FindJobGroupByUUID finds a job group by its UUID, the manifests of its jobs are overlaid, stamped with their
+// labels and redacted, and the deferred jobs tell why
 func (s *jobGroupService) FindJobGroupByUUID(id string) (*models.JobGroup, error) {
-	return s.repo.FindJobGroupByUUID(id)
+	jobGroup, err := s.repo.FindJobGroupByUUID(id)
+	if err == nil && jobGroup != nil {
+		if err := s.deferJobGroups(jobGroup); err != nil {
+			return nil, err
+		}
+		reportRollout(jobGroup)
+		overlayJobs(jobGroup.Jobs, jobGroup.Overlays)
+		stampJobs(jobGroup.Jobs)
+		redactJobs(jobGroup.Jobs)
+		jobGroup.Parameters = redactParameters(jobGroup.Parameters)
+		jobGroup.Overlays = redactOverlays(jobGroup.Overlays)
+	}
+	return jobGroup, err
 }
 
-// FindAllJobGroups finds all job groups
+// FindAllJobGroups finds all job groups, see FindJobGroupByUUID
 func (s *jobGroupService) FindAllJobGroups() (*[]models.JobGroup, error) {
-	// Implementation for finding all job groups
-	return s.repo.FindAllJobGroups()
+	jobGroups, err := s.repo.FindAllJobGroups()
+	if err == nil && jobGroups != nil {
+		found := []*models.JobGroup{}
+		for i := range *jobGroups {
+			found = append(found, &(*jobGroups)[i])
+		}
+		if err := s.deferJobGroups(found...); err != nil {
+			return nil, err
+		}
+		for i := range *jobGroups {
+			reportRollout(&(*jobGroups)[i])
+			overlayJobs((*jobGroups)[i].Jobs, (*jobGroups)[i].Overlays)
+			stampJobs((*jobGroups)[i].Jobs)
+			redactJobs((*jobGroups)[i].Jobs)
+			(*jobGroups)[i].Parameters = redactParameters((*jobGroups)[i].Parameters)
+			(*jobGroups)[i].Overlays = redactOverlays((*jobGroups)[i].Overlays)
+		}
+	}
+	return jobGroups, err
+}
+
+// deferJobGroups sets the reason the jobs of the job groups waiting for an agent are deferred, see
+// MaintenanceService.DeferJobs. The maintenance windows are read once for all of them.
+func (s *jobGroupService) deferJobGroups(jobGroups ...*models.JobGroup) error {
+	if s.maintenance == nil {
+		return nil
+	}
+	jobs := []models.Job{}
+	for _, jobGroup := range jobGroups {
+		jobs = append(jobs, jobGroup.Jobs...)
+	}
+	if err := s.maintenance.DeferJobs(jobs); err != nil {
+		return err
+	}
+	for _, jobGroup := range jobGroups {
+		for i := range jobGroup.Jobs {
+			jobGroup.Jobs[i].Deferred = jobs[0].Deferred
+			jobs = jobs[1:]
+		}
+	}
+	return nil
 }
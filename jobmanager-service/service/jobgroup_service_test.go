@@ -21,10 +21,14 @@
 package service_test
 
 import (
+	"errors"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/service"
 	repository "icos/server/jobmanager-service/service/mocks"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -34,9 +38,41 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestJobGroupService(t *testing.T) {
+const matchmakerResponse = `{
+	"components": [
+		{
+			"name": "consumer",
+			"type": "kubernetes",
+			"manifests": [{"name": "mjpeg"}, {"name": "mjpeg-service"}],
+			"targets": {
+				"cluster_name": "nuvlabox/55c7953e-2aa0-4d18-834c-b4d76d824bb9",
+				"node_name": "john-rasbpi-5-1",
+				"orchestrator": "nuvla"
+			}
+		}
+	]
+}`
+
+// newMockJobGroupRepository returns a job group repository mock where no other tenant uses a namespace
+func newMockJobGroupRepository() *repository.MockJobGroupRepository {
 	mockJobGroupRepo := new(repository.MockJobGroupRepository)
-	jobGroupService := service.NewJobGroupService(mockJobGroupRepo)
+	mockJobGroupRepo.On("FindNamespaceTenants", mock.Anything).Return([]string{}, nil).Maybe()
+	mockJobGroupRepo.On("SaveJobGroupRevision", mock.Anything).Return(&models.JobGroupRevision{}, nil).Maybe()
+	return mockJobGroupRepo
+}
+
+// newJobGroupService returns a job group service on the repository and the matchmaker client, the tenants have
+// no quota
+func newJobGroupService(mockJobGroupRepo *repository.MockJobGroupRepository, httpClient service.HTTPClient) service.JobGroupService {
+	mockQuotaRepo := new(repository.MockQuotaRepository)
+	mockQuotaRepo.On("FindQuotaByTenant", mock.Anything).Return((*models.Quota)(nil), errors.New("quota not found")).Maybe()
+	return service.NewJobGroupService(mockJobGroupRepo, service.NewQuotaService(mockQuotaRepo), httpClient, nil)
+}
+
+func TestJobGroupService(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	mockHTTPClient := new(MockHTTPClient)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, mockHTTPClient)
 
 	t.Run("CreateJobGroup", func(t *testing.T) {
 		// Given
@@ -60,7 +96,16 @@ components:
   targets:
   - cluster_name: cluster1
     node_name: cluster1-control-plane
-    orchestrator: ocm`)
+    orchestrator: ocm
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: mjpeg
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: mjpeg-service`)
 
 		header := http.Header{}
 		header.Set("Authorization", "Bearer test-token")
@@ -105,9 +150,13 @@ components:
 		}
 
 		mockJobGroupRepo.On("SaveJobGroup", mock.AnythingOfType("*models.JobGroup")).Return(jobGroup, nil)
+		mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(matchmakerResponse)),
+		}, nil).Once()
 
 		// When
-		result, err := jobGroupService.CreateJobGroup(bodyBytes, header)
+		result, err := jobGroupService.CreateJobGroup(bodyBytes, nil, header, "team-a", "alice")
 
 		// Then
 		require.NoError(t, err)
@@ -136,6 +185,7 @@ components:
 			BaseUUID:       models.BaseUUID{ID: "27a69131-f34d-44b3-9063-81501a1c0fc8"},
 			AppName:        "existing-jobgroup",
 			AppDescription: "existing-description",
+			Tenant:         "team-a",
 			Jobs: []models.Job{
 				{
 					BaseUUID: models.BaseUUID{ID: "6616b77c-dbb0-47aa-bc9b-ff45548db029"},
@@ -163,10 +213,26 @@ components:
 		mockJobGroupRepo.On("FindJobGroupByUUID", "27a69131-f34d-44b3-9063-81501a1c0fc8").Return(existingJobGroup, nil)
 		mockJobGroupRepo.On("UpdateJobGroup", mock.Anything).Return(updatedJobGroup, nil)
 
-		result, err := jobGroupService.UpdateJobGroup(bodyJob)
+		result, err := jobGroupService.UpdateJobGroup(bodyJob, "team-a", "alice", 0)
 		assert.NoError(t, err)
 		assert.Equal(t, updatedJobGroup, result)
 		mockJobGroupRepo.AssertExpectations(t)
+
+		// another tenant cannot update the job group, an administrator can
+		_, err = jobGroupService.UpdateJobGroup(bodyJob, "team-b", "bob", 0)
+		assert.ErrorIs(t, err, service.ErrForbidden)
+		_, err = jobGroupService.UpdateJobGroup(bodyJob, "", "admin", 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("UpdateLegacyJobGroup", func(t *testing.T) {
+		legacyJobGroup := &models.JobGroup{BaseUUID: models.BaseUUID{ID: "0b5e0a4c-2f0e-4d8b-9a57-3c3f0a1b2c3d"}}
+		mockJobGroupRepo.On("FindJobGroupByUUID", legacyJobGroup.ID).Return(legacyJobGroup, nil)
+
+		// job groups without tenant are not adopted
+		_, err := jobGroupService.UpdateJobGroup([]byte(`{"ID": "`+legacyJobGroup.ID+`"}`), "team-a", "alice", 0)
+		assert.ErrorIs(t, err, service.ErrForbidden)
+		assert.Empty(t, legacyJobGroup.Tenant)
 	})
 
 	t.Run("DeleteJobGroupByID", func(t *testing.T) {
@@ -186,9 +252,9 @@ components:
 		}
 
 		mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(existingJobGroup, nil)
-		mockJobGroupRepo.On("DeleteJobGroup", jobGroupID).Return(int64(1), nil)
+		mockJobGroupRepo.On("DeleteJobGroup", jobGroupID, int64(0)).Return(int64(1), nil)
 
-		result, err := jobGroupService.DeleteJobGroupByID(jobGroupID)
+		result, err := jobGroupService.DeleteJobGroupByID(jobGroupID, "", 0)
 		assert.NoError(t, err)
 		assert.Equal(t, existingJobGroup, result)
 		mockJobGroupRepo.AssertExpectations(t)
@@ -225,9 +291,499 @@ components:
 		mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(existingJobGroup, nil)
 		mockJobGroupRepo.On("UpdateJobGroup", mock.Anything).Return(stoppedJobGroup, nil)
 
-		result, err := jobGroupService.StopJobGroupByID(jobGroupID)
+		result, err := jobGroupService.StopJobGroupByID(jobGroupID, "", 0)
 		assert.NoError(t, err)
 		assert.NotEqual(t, stoppedJobGroup, result)
 		mockJobGroupRepo.AssertExpectations(t)
 	})
+
+	t.Run("StopJobGroupByIDNotTaken", func(t *testing.T) {
+		// first deployments no agent took are finished undeployments, so that the job group can be redeployed
+		jobGroupID := "5d1f3b7a-9c2e-4f6a-8b0d-2e4c6a8b0d1f"
+		mockJobGroupRepo := newMockJobGroupRepository()
+		jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+		mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(&models.JobGroup{
+			BaseUUID: models.BaseUUID{ID: jobGroupID},
+			Jobs: []models.Job{{
+				BaseUUID: models.BaseUUID{ID: "7e3a5c9b-1d4f-4a6e-8c2b-4f6a8c0e2d3b"},
+				Type:     models.CreateDeployment,
+				State:    models.JobCreated,
+			}},
+		}, nil).Once()
+		mockJobGroupRepo.On("UpdateJobGroup", mock.MatchedBy(func(jobGroup *models.JobGroup) bool {
+			job := jobGroup.Jobs[0]
+			return job.ID == "7e3a5c9b-1d4f-4a6e-8c2b-4f6a8c0e2d3b" && job.Type == models.DeleteDeployment && job.State == models.JobFinished
+		})).Return(&models.JobGroup{BaseUUID: models.BaseUUID{ID: jobGroupID}}, nil).Once()
+
+		_, err := jobGroupService.StopJobGroupByID(jobGroupID, "", 0)
+		assert.NoError(t, err)
+		mockJobGroupRepo.AssertExpectations(t)
+	})
+
+	t.Run("StopJobGroupByIDAfterUpdate", func(t *testing.T) {
+		// pending updates and remediations of running jobs are undeployed by the agent owning them
+		jobGroupID := "8a2c4e6f-0b1d-4e3f-9a5b-7c9d1e3f5a7b"
+		ownerID := "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c"
+		mockJobGroupRepo := newMockJobGroupRepository()
+		jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+		mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(&models.JobGroup{
+			BaseUUID: models.BaseUUID{ID: jobGroupID},
+			Jobs: []models.Job{
+				{BaseUUID: models.BaseUUID{ID: "replaced"}, Type: models.ReplaceDeployment, State: models.JobCreated, OwnerID: ownerID},
+				{BaseUUID: models.BaseUUID{ID: "remediated"}, Type: models.UpdateDeployment, SubType: models.ScaleOut, State: models.JobCreated, OwnerID: ownerID},
+			},
+		}, nil).Once()
+		mockJobGroupRepo.On("UpdateJobGroup", mock.MatchedBy(func(jobGroup *models.JobGroup) bool {
+			for _, job := range jobGroup.Jobs {
+				if job.Type != models.DeleteDeployment || job.SubType != "" || job.State != models.JobCreated || job.OwnerID != ownerID {
+					return false
+				}
+			}
+			return true
+		})).Return(&models.JobGroup{BaseUUID: models.BaseUUID{ID: jobGroupID}}, nil).Once()
+
+		_, err := jobGroupService.StopJobGroupByID(jobGroupID, "", 0)
+		assert.NoError(t, err)
+		mockJobGroupRepo.AssertExpectations(t)
+	})
+}
+
+func TestUpdateJobGroupPriority(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroupID := "27a69131-f34d-44b3-9063-81501a1c0fc8"
+	storedJobGroup := &models.JobGroup{
+		BaseUUID: models.BaseUUID{ID: jobGroupID},
+		Tenant:   "team-a",
+		Priority: 40,
+		Jobs: []models.Job{{
+			BaseUUID: models.BaseUUID{ID: "6616b77c-dbb0-47aa-bc9b-ff45548db029"},
+			Type:     models.UpdateDeployment,
+			SubType:  models.ScaleUp,
+			State:    models.JobFinished,
+			Priority: 40,
+		}},
+	}
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(storedJobGroup, nil)
+	mockJobGroupRepo.On("UpdateJobGroup", storedJobGroup).Return(storedJobGroup, nil)
+
+	// an update without priority keeps the one of the job group, the remediation is not carried over
+	result, err := jobGroupService.UpdateJobGroup([]byte(`{"ID": "`+jobGroupID+`", "AppName": "shop"}`), "team-a", "alice", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 40, result.Priority)
+	assert.Equal(t, 40, result.Jobs[0].Priority)
+	assert.Empty(t, result.Jobs[0].SubType)
+
+	result, err = jobGroupService.UpdateJobGroup([]byte(`{"ID": "`+jobGroupID+`", "priority": 0}`), "team-a", "alice", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Priority)
+	assert.Equal(t, 0, result.Jobs[0].Priority)
+
+	_, err = jobGroupService.UpdateJobGroup([]byte(`{"ID": "`+jobGroupID+`", "priority": `+strconv.Itoa(models.PriorityDelete)+`}`), "team-a", "alice", 0)
+	var validationErr *service.ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+}
+
+func TestJobGroupServiceResourceVersion(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroupID := "27a69131-f34d-44b3-9063-81501a1c0fc8"
+	storedJobGroup := &models.JobGroup{
+		BaseUUID:        models.BaseUUID{ID: jobGroupID},
+		Tenant:          "team-a",
+		ResourceVersion: 3,
+		Jobs: []models.Job{
+			{BaseUUID: models.BaseUUID{ID: "6616b77c-dbb0-47aa-bc9b-ff45548db029"}, ResourceVersion: 2},
+		},
+	}
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(storedJobGroup, nil)
+
+	t.Run("UpdateJobGroupStaleIfMatch", func(t *testing.T) {
+		bodyJob := []byte(`{"ID": "` + jobGroupID + `", "resource_version": 3}`)
+		_, err := jobGroupService.UpdateJobGroup(bodyJob, "team-a", "alice", 2)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	t.Run("UpdateJobGroupStaleBody", func(t *testing.T) {
+		bodyJob := []byte(`{"ID": "` + jobGroupID + `", "resource_version": 2}`)
+		_, err := jobGroupService.UpdateJobGroup(bodyJob, "team-a", "alice", 0)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	t.Run("UpdateJobGroupStaleJob", func(t *testing.T) {
+		bodyJob := []byte(`{"ID": "` + jobGroupID + `", "jobs": [{"ID": "6616b77c-dbb0-47aa-bc9b-ff45548db029", "resource_version": 1}]}`)
+		_, err := jobGroupService.UpdateJobGroup(bodyJob, "team-a", "alice", 0)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	t.Run("DeleteJobGroupStaleIfMatch", func(t *testing.T) {
+		_, err := jobGroupService.DeleteJobGroupByID(jobGroupID, "", 1)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	t.Run("StopJobGroupStaleIfMatch", func(t *testing.T) {
+		_, err := jobGroupService.StopJobGroupByID(jobGroupID, "", 1)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	mockJobGroupRepo.AssertNotCalled(t, "UpdateJobGroup", mock.Anything)
+	mockJobGroupRepo.AssertNotCalled(t, "DeleteJobGroup", mock.Anything, mock.Anything)
+}
+
+func TestJobGroupServiceTenant(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	jobGroupService := newJobGroupService(mockJobGroupRepo, new(MockHTTPClient))
+
+	jobGroupID := "27a69131-f34d-44b3-9063-81501a1c0fc8"
+	mockJobGroupRepo.On("FindJobGroupByUUID", jobGroupID).Return(&models.JobGroup{
+		BaseUUID: models.BaseUUID{ID: jobGroupID},
+		Tenant:   "team-a",
+		Jobs: []models.Job{
+			{BaseUUID: models.BaseUUID{ID: "6616b77c-dbb0-47aa-bc9b-ff45548db029"}, Type: models.DeleteDeployment, State: models.JobFinished},
+		},
+	}, nil)
+
+	// job groups of other tenants are neither deleted, stopped nor redeployed
+	_, err := jobGroupService.DeleteJobGroupByID(jobGroupID, "team-b", 0)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = jobGroupService.StopJobGroupByID(jobGroupID, "team-b", 0)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = jobGroupService.RedeployJobGroupByID(jobGroupID, false, http.Header{}, "team-b", "bob", 0)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	mockJobGroupRepo.AssertNotCalled(t, "UpdateJobGroup", mock.Anything)
+	mockJobGroupRepo.AssertNotCalled(t, "DeleteJobGroup", mock.Anything, mock.Anything)
+}
+
+func TestValidateJobGroup(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	mockHTTPClient := new(MockHTTPClient)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, mockHTTPClient)
+
+	bodyBytes := []byte(`name: test-job-group
+components:
+- name: consumer
+  type: kubernetes
+  manifests:
+  - name: mjpeg
+  - name: mjpeg-service
+  - name: missing
+  targets:
+  - cluster_name: cluster1
+    orchestrator: ocm
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: mjpeg
+  spec:
+    replicas: one
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: mjpeg-service
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: unused`)
+
+	t.Run("WithoutMatchmaking", func(t *testing.T) {
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.Len(t, result.JobGroup.Jobs, 1)
+		// the invalid deployment is left out of the job
+		assert.Len(t, result.JobGroup.Jobs[0].Manifests, 1)
+		assert.Equal(t, []models.ValidationIssue{
+			{Path: "manifests[0]", Code: service.IssueInvalidManifest, Message: result.Errors[0].Message},
+			{Path: "components[0].manifests[2].name", Code: service.IssueNotFound, Message: "manifest missing is not defined"},
+		}, result.Errors)
+		assert.Contains(t, result.Warnings, models.ValidationIssue{Path: "components[0].targets", Code: service.IssueNoTarget, Message: "component consumer has no target"})
+		assert.Contains(t, result.Warnings, models.ValidationIssue{Path: "manifests[2]", Code: service.IssueUnreferenced, Message: "manifest unused is not referenced by any component"})
+		mockHTTPClient.AssertNotCalled(t, "Do", mock.Anything)
+	})
+
+	t.Run("WithMatchmaking", func(t *testing.T) {
+		mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(matchmakerResponse)),
+		}, nil).Once()
+
+		result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", true)
+		require.NoError(t, err)
+		require.Len(t, result.JobGroup.Jobs, 1)
+		assert.Equal(t, models.Nuvla, result.JobGroup.Jobs[0].Orchestrator)
+		mockJobGroupRepo.AssertNotCalled(t, "SaveJobGroup", mock.Anything)
+	})
+}
+
+func TestCreateJobGroupInvalidDescriptor(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	mockHTTPClient := new(MockHTTPClient)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, mockHTTPClient)
+
+	bodyBytes := []byte(`name: test-job-group
+components:
+- name: consumer
+  manifests:
+  - name: mjpeg
+manifests:
+- apiVersion: v1
+  kind: Service
+  metadata: mjpeg`)
+	// the matchmaker answers with a target shape that used to terminate the server
+	mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"components": [{"name": "consumer", "manifests": [{"name": "mjpeg"}], "targets": [{"orchestrator": "ocm"}]}]}`)),
+	}, nil).Once()
+
+	_, err := jobGroupService.CreateJobGroup(bodyBytes, nil, http.Header{}, "team-a", "alice")
+
+	var validationErr *service.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	paths := []string{}
+	for _, issue := range validationErr.Issues {
+		paths = append(paths, issue.Path)
+	}
+	assert.Equal(t, []string{"manifests[0].metadata", "components[0].targets", "components[0].manifests[0].name"}, paths)
+	mockJobGroupRepo.AssertNotCalled(t, "SaveJobGroup", mock.Anything)
+}
+
+func TestValidateJobGroupUnparsable(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	_, err := jobGroupService.ValidateJobGroup([]byte("name: [unterminated"), nil, http.Header{}, "team-a", false)
+
+	var validationErr *service.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, service.IssueInvalid, validationErr.Issues[0].Code)
+}
+
+func TestValidateJobGroupMultiDocument(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	bodyBytes := []byte(`name: test-job-group
+components:
+- name: consumer
+  manifests:
+  - name: mjpeg
+  - name: mjpeg-service
+  - name: mjpeg-config
+  - name: mjpeg-account
+manifests:
+- |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: mjpeg-config
+  ---
+  apiVersion: v1
+  kind: ServiceAccount
+  metadata:
+    name: mjpeg-account
+- 42
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: mjpeg
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: mjpeg-service
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: unused
+`)
+
+	result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+	require.NoError(t, err)
+	require.Len(t, result.JobGroup.Jobs, 1)
+	assert.Len(t, result.JobGroup.Jobs[0].Manifests, 4)
+	assert.Equal(t, []models.ValidationIssue{
+		{Path: "manifests[1]", Code: service.IssueInvalidManifest, Message: "manifest is a int, not an object"},
+	}, result.Errors)
+	assert.Contains(t, result.Warnings, models.ValidationIssue{Path: "manifests[3]", Code: service.IssueUnreferenced, Message: "manifest unused is not referenced by any component"})
+}
+
+func TestValidateJobGroupManifestReferences(t *testing.T) {
+	jobGroupService := newJobGroupService(newMockJobGroupRepository(), new(MockHTTPClient))
+
+	bodyBytes := []byte(`name: test-job-group
+components:
+- name: producer
+  manifests:
+  - name: producer
+    kind: Deployment
+- name: exposer
+  manifests:
+  - name: producer
+    apiVersion: v1
+    namespace: edge
+- name: consumer
+  manifests:
+  - name: producer
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: producer
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: producer
+    namespace: edge
+`)
+
+	result, err := jobGroupService.ValidateJobGroup(bodyBytes, nil, http.Header{}, "team-a", false)
+	require.NoError(t, err)
+	require.Len(t, result.JobGroup.Jobs, 3)
+	require.Len(t, result.JobGroup.Jobs[0].Manifests, 1)
+	assert.Contains(t, result.JobGroup.Jobs[0].Manifests[0].YamlString, "kind: Deployment")
+	require.Len(t, result.JobGroup.Jobs[1].Manifests, 1)
+	assert.Contains(t, result.JobGroup.Jobs[1].Manifests[0].YamlString, "kind: Service")
+	assert.Empty(t, result.JobGroup.Jobs[2].Manifests)
+	assert.Equal(t, []models.ValidationIssue{{
+		Path:    "components[2].manifests[0]",
+		Code:    service.IssueAmbiguous,
+		Message: "manifest producer matches apps/v1 Deployment producer, v1 Service edge/producer, set its kind, apiVersion or namespace",
+	}}, result.Errors)
+}
+
+func TestRedeployJobGroupByID(t *testing.T) {
+	mockJobGroupRepo := newMockJobGroupRepository()
+	mockHTTPClient := new(MockHTTPClient)
+	jobGroupService := newJobGroupService(mockJobGroupRepo, mockHTTPClient)
+
+	stopped := func(id string) *models.JobGroup {
+		return &models.JobGroup{
+			BaseUUID:        models.BaseUUID{ID: id},
+			ResourceVersion: 4,
+			Jobs: []models.Job{{
+				BaseUUID:     models.BaseUUID{ID: "6616b77c-dbb0-47aa-bc9b-ff45548db029"},
+				OwnerID:      "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c",
+				Type:         models.DeleteDeployment,
+				State:        models.JobFinished,
+				Resource:     &models.Resource{ResourceName: "consumer"},
+				Targets:      models.Target{BaseUINT: models.BaseUINT{ID: 3}, ClusterName: "cluster1", Orchestrator: models.OCM},
+				Orchestrator: models.OCM,
+			}},
+		}
+	}
+
+	t.Run("Redeploy", func(t *testing.T) {
+		jobGroup := stopped("group-1")
+		// stopped while a remediation was applied
+		jobGroup.Jobs[0].SubType = models.ScaleUp
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-1").Return(jobGroup, nil).Once()
+		mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil).Once()
+
+		result, err := jobGroupService.RedeployJobGroupByID("group-1", false, http.Header{}, "", "alice", 4)
+		require.NoError(t, err)
+		assert.Equal(t, "6616b77c-dbb0-47aa-bc9b-ff45548db029", result.Jobs[0].ID)
+		assert.Equal(t, models.CreateDeployment, result.Jobs[0].Type)
+		assert.Empty(t, result.Jobs[0].SubType)
+		assert.Equal(t, models.JobCreated, result.Jobs[0].State)
+		assert.Empty(t, result.Jobs[0].OwnerID)
+		assert.Equal(t, "cluster1", result.Jobs[0].Targets.ClusterName)
+	})
+
+	t.Run("RedeployWithMatchmaking", func(t *testing.T) {
+		jobGroup := stopped("group-2")
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-2").Return(jobGroup, nil).Once()
+		mockJobGroupRepo.On("FindJobGroupRevisions", "group-2").Return(&[]models.JobGroupRevision{
+			{Revision: 2},
+			{Revision: 1, Descriptor: `name: test-job-group
+components:
+- name: consumer
+  type: kubernetes
+  manifests:
+  - name: mjpeg
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: mjpeg
+`},
+		}, nil).Once()
+		mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(matchmakerResponse)),
+		}, nil).Once()
+		mockJobGroupRepo.On("UpdateJobGroup", jobGroup).Return(jobGroup, nil).Once()
+
+		result, err := jobGroupService.RedeployJobGroupByID("group-2", true, http.Header{}, "", "alice", 0)
+		require.NoError(t, err)
+		assert.Equal(t, "nuvlabox/55c7953e-2aa0-4d18-834c-b4d76d824bb9", result.Jobs[0].Targets.ClusterName)
+		assert.Equal(t, uint32(3), result.Jobs[0].Targets.ID)
+		assert.Equal(t, models.Nuvla, result.Jobs[0].Orchestrator)
+	})
+
+	t.Run("RedeployWithMatchmakingKeepsOverlays", func(t *testing.T) {
+		base := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: mjpeg\nspec:\n  replicas: 3\n"
+		jobGroup := stopped("group-5")
+		jobGroup.Overlays = models.OverlayList{{Name: "edge", Targets: models.OverlayTargets{Cluster: "nuvlabox/*"}, Patches: []models.OverlayPatch{{Patch: "spec:\n  replicas: 1\n"}}}}
+		jobGroup.Jobs[0].Manifests = []models.PlainManifest{{BaseUINT: models.BaseUINT{ID: 1}, YamlString: base}}
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-5").Return(jobGroup, nil).Once()
+		mockJobGroupRepo.On("FindJobGroupRevisions", "group-5").Return(&[]models.JobGroupRevision{{Revision: 1, Descriptor: `name: test-job-group
+components:
+- name: consumer
+  type: kubernetes
+  manifests:
+  - name: mjpeg
+manifests:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: mjpeg
+`}}, nil).Once()
+		mockHTTPClient.On("Do", mock.Anything).Return(&http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(matchmakerResponse)),
+		}, nil).Once()
+		// the overlays stay on the job group and the manifests are stored without them
+		mockJobGroupRepo.On("UpdateJobGroup", mock.MatchedBy(func(jobGroup *models.JobGroup) bool {
+			return len(jobGroup.Overlays) == 1 && jobGroup.Jobs[0].Manifests[0].YamlString == base
+		})).Return(jobGroup, nil).Once()
+
+		result, err := jobGroupService.RedeployJobGroupByID("group-5", true, http.Header{}, "", "alice", 0)
+		require.NoError(t, err)
+		// they are applied to the manifests emitted for the new targets
+		assert.Contains(t, result.Jobs[0].Manifests[0].YamlString, "replicas: 1")
+	})
+
+	t.Run("RedeployRunningJobGroup", func(t *testing.T) {
+		jobGroup := stopped("group-3")
+		jobGroup.Jobs[0].State = models.JobProgressing
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-3").Return(jobGroup, nil).Once()
+
+		_, err := jobGroupService.RedeployJobGroupByID("group-3", false, http.Header{}, "", "alice", 0)
+		assert.Error(t, err)
+	})
+
+	t.Run("RedeployDeployedJobGroup", func(t *testing.T) {
+		// a finished deployment is running
+		jobGroup := stopped("group-6")
+		jobGroup.Jobs[0].Type = models.CreateDeployment
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-6").Return(jobGroup, nil).Once()
+
+		_, err := jobGroupService.RedeployJobGroupByID("group-6", false, http.Header{}, "", "alice", 0)
+		assert.EqualError(t, err, "JobGroup cannot be redeployed, one or more jobs are not stopped")
+	})
+
+	t.Run("RedeployVersionConflict", func(t *testing.T) {
+		mockJobGroupRepo.On("FindJobGroupByUUID", "group-4").Return(stopped("group-4"), nil).Once()
+
+		_, err := jobGroupService.RedeployJobGroupByID("group-4", false, http.Header{}, "", "alice", 2)
+		assert.ErrorIs(t, err, service.ErrVersionConflict)
+	})
+
+	mockJobGroupRepo.AssertExpectations(t)
 }
@@ -0,0 +1,277 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+	"icos/server/jobmanager-service/utils/cron"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"strings"
+	"time"
+)
+
+// Kinds of jobs the maintenance windows tell apart, MAINTENANCE_URGENT_JOBS lists the ones passing through
+const (
+	JobKindCreate      = "create"
+	JobKindUpdate      = "update"
+	JobKindReplace     = "replace"
+	JobKindDelete      = "delete"
+	JobKindRemediation = "remediation"
+)
+
+// defaultUrgentJobs are the kinds of jobs executed during maintenance windows when MAINTENANCE_URGENT_JOBS is not set
+const defaultUrgentJobs = JobKindDelete + "," + JobKindRemediation
+
+// MaintenanceService interface defines the methods for the maintenance windows of clusters
+type MaintenanceService interface {
+	CreateMaintenanceWindow(body []byte, author string) (*models.MaintenanceWindow, error)
+	FindMaintenanceWindows(cluster string, activeOnly bool) (*[]models.MaintenanceWindow, error)
+	FindMaintenanceWindow(id string) (*models.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(id string) error
+	DeferJobs(jobs []models.Job) error
+}
+
+// maintenanceService struct implements the MaintenanceService interface
+type maintenanceService struct {
+	repo   repository.MaintenanceWindowRepository
+	urgent map[string]bool
+}
+
+// NewMaintenanceService returns a new instance of maintenanceService, the kinds of jobs passing through the
+// maintenance windows are read from MAINTENANCE_URGENT_JOBS
+func NewMaintenanceService(repo repository.MaintenanceWindowRepository) MaintenanceService {
+	value, ok := os.LookupEnv("MAINTENANCE_URGENT_JOBS")
+	if !ok {
+		value = defaultUrgentJobs
+	}
+	urgent := map[string]bool{}
+	for _, kind := range strings.Split(value, ",") {
+		switch kind = strings.TrimSpace(kind); kind {
+		case "":
+		case JobKindCreate, JobKindUpdate, JobKindReplace, JobKindDelete, JobKindRemediation:
+			urgent[kind] = true
+		default:
+			logs.Logger.Printf("Ignoring invalid job kind in MAINTENANCE_URGENT_JOBS: %s", kind)
+		}
+	}
+	return &maintenanceService{repo: repo, urgent: urgent}
+}
+
+// jobKind tells the kind of a job, remediations are updates with a sub type
+func jobKind(job models.Job) string {
+	if job.SubType != "" {
+		return JobKindRemediation
+	}
+	switch job.Type {
+	case models.UpdateDeployment:
+		return JobKindUpdate
+	case models.ReplaceDeployment:
+		return JobKindReplace
+	case models.DeleteDeployment:
+		return JobKindDelete
+	}
+	return JobKindCreate
+}
+
+// CreateMaintenanceWindow defines a maintenance window of a cluster, once from a start to an end or for a
+// duration from every trigger of a cron expression
+func (s *maintenanceService) CreateMaintenanceWindow(body []byte, author string) (*models.MaintenanceWindow, error) {
+	request := models.MaintenanceWindow{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	window := &models.MaintenanceWindow{
+		Cluster:     request.Cluster,
+		Description: request.Description,
+		Start:       request.Start,
+		End:         request.End,
+		Cron:        request.Cron,
+		Duration:    request.Duration,
+		Timezone:    request.Timezone,
+		Author:      author,
+	}
+	if issues := validateMaintenanceWindow(window); len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
+	}
+
+	saved, err := s.repo.SaveMaintenanceWindow(window)
+	if err != nil {
+		logs.Logger.Println("Error saving maintenance window:", err)
+		return nil, err
+	}
+	logs.Logger.Printf("Maintenance window %s defined for cluster %s", saved.ID, saved.Cluster)
+	fillWindowState(saved, time.Now())
+	return saved, nil
+}
+
+// validateMaintenanceWindow reports the problems of a new maintenance window
+func validateMaintenanceWindow(window *models.MaintenanceWindow) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	addIssue := func(path, code, format string, args ...interface{}) {
+		issues = append(issues, models.ValidationIssue{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if window.Cluster == "" {
+		addIssue("cluster", IssueRequired, "the cluster of the maintenance window is missing")
+	}
+
+	oneOff := window.Start != nil || window.End != nil
+	switch {
+	case oneOff && window.Cron != "":
+		addIssue("cron", IssueConflict, "the maintenance window has both a start and a cron expression")
+	case oneOff:
+		if window.Start == nil {
+			addIssue("start", IssueRequired, "the maintenance window has an end but no start")
+		} else if window.End == nil {
+			addIssue("end", IssueRequired, "the maintenance window has a start but no end")
+		} else if !window.End.After(*window.Start) {
+			addIssue("end", IssueInvalid, "the maintenance window ends before it starts")
+		}
+	case window.Cron == "":
+		addIssue("start", IssueRequired, "the maintenance window needs a start and an end, or a cron expression and a duration")
+	default:
+		if _, err := time.LoadLocation(window.Timezone); err != nil {
+			addIssue("timezone", IssueInvalid, "unknown time zone %s", window.Timezone)
+		}
+		if expression, err := cron.Parse(window.Cron); err != nil {
+			addIssue("cron", IssueInvalid, "%s", err.Error())
+		} else if expression.Next(time.Now()).IsZero() {
+			addIssue("cron", IssueInvalid, "cron expression %s never triggers", window.Cron)
+		}
+		if window.Duration == "" {
+			addIssue("duration", IssueRequired, "a recurring maintenance window needs a duration")
+		} else if duration, err := time.ParseDuration(window.Duration); err != nil || duration <= 0 {
+			addIssue("duration", IssueInvalid, "invalid duration %s", window.Duration)
+		}
+	}
+	return issues
+}
+
+// windowState tells whether a maintenance window is open at a time, and until when
+func windowState(window models.MaintenanceWindow, now time.Time) (bool, time.Time) {
+	if window.Cron == "" {
+		if window.Start != nil && window.End != nil && !now.Before(*window.Start) && now.Before(*window.End) {
+			return true, *window.End
+		}
+		return false, time.Time{}
+	}
+
+	location, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		return false, time.Time{}
+	}
+	expression, err := cron.Parse(window.Cron)
+	if err != nil {
+		return false, time.Time{}
+	}
+	duration, err := time.ParseDuration(window.Duration)
+	if err != nil || duration <= 0 {
+		return false, time.Time{}
+	}
+	// the window is open when it was triggered less than its duration ago
+	opened := expression.Next(now.Add(-duration).In(location))
+	if opened.IsZero() || opened.After(now) {
+		return false, time.Time{}
+	}
+	return true, opened.Add(duration)
+}
+
+// fillWindowState fills in whether a maintenance window is open at a time
+func fillWindowState(window *models.MaintenanceWindow, now time.Time) {
+	active, until := windowState(*window, now)
+	window.Active, window.ActiveUntil = active, nil
+	if active {
+		window.ActiveUntil = &until
+	}
+}
+
+// FindMaintenanceWindows returns the maintenance windows of a cluster, of every cluster when none is given,
+// only the open ones when asked
+func (s *maintenanceService) FindMaintenanceWindows(cluster string, activeOnly bool) (*[]models.MaintenanceWindow, error) {
+	windows, err := s.repo.FindMaintenanceWindows(cluster)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	found := []models.MaintenanceWindow{}
+	for _, window := range *windows {
+		fillWindowState(&window, now)
+		if window.Active || !activeOnly {
+			found = append(found, window)
+		}
+	}
+	return &found, nil
+}
+
+// FindMaintenanceWindow finds a maintenance window by its UUID
+func (s *maintenanceService) FindMaintenanceWindow(id string) (*models.MaintenanceWindow, error) {
+	window, err := s.repo.FindMaintenanceWindow(id)
+	if err != nil {
+		return nil, err
+	}
+	fillWindowState(window, time.Now())
+	return window, nil
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window, the jobs it withholds are executed again
+func (s *maintenanceService) DeleteMaintenanceWindow(id string) error {
+	if _, err := s.repo.FindMaintenanceWindow(id); err != nil {
+		return err
+	}
+	_, err := s.repo.DeleteMaintenanceWindow(id)
+	return err
+}
+
+// DeferJobs sets the reason the jobs waiting for an agent are deferred, when an open maintenance window of
+// their cluster withholds their kind
+func (s *maintenanceService) DeferJobs(jobs []models.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	windows, err := s.repo.FindMaintenanceWindows("")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	open := map[string]string{}
+	for _, window := range *windows {
+		if active, until := windowState(window, now); active {
+			if _, ok := open[window.Cluster]; !ok {
+				open[window.Cluster] = fmt.Sprintf("maintenance window %s of cluster %s until %s", window.ID, window.Cluster,
+					until.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	for i, job := range jobs {
+		if job.State != models.JobCreated || s.urgent[jobKind(job)] {
+			continue
+		}
+		if reason, ok := open[job.Targets.ClusterName]; ok {
+			jobs[i].Deferred = reason
+		}
+	}
+	return nil
+}
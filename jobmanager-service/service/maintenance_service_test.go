@@ -0,0 +1,144 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowState(t *testing.T) {
+	now := time.Date(2024, 6, 1, 3, 0, 0, 0, time.UTC) // a Saturday
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+
+	active, until := windowState(models.MaintenanceWindow{Start: &start, End: &end}, now)
+	assert.True(t, active)
+	assert.Equal(t, end, until)
+	active, _ = windowState(models.MaintenanceWindow{Start: &start, End: &end}, end)
+	assert.False(t, active)
+
+	// every Saturday from 02:00 to 06:00
+	recurring := models.MaintenanceWindow{Cron: "0 2 * * 6", Duration: "4h"}
+	active, until = windowState(recurring, now)
+	assert.True(t, active)
+	assert.Equal(t, time.Date(2024, 6, 1, 6, 0, 0, 0, time.UTC), until)
+	active, _ = windowState(recurring, now.Add(3*time.Hour))
+	assert.False(t, active)
+
+	// the cron expression is evaluated in the time zone of the window, 02:00 in Madrid is 00:00 UTC in summer
+	recurring.Timezone = "Europe/Madrid"
+	active, until = windowState(recurring, now)
+	assert.True(t, active)
+	assert.True(t, time.Date(2024, 6, 1, 4, 0, 0, 0, time.UTC).Equal(until))
+}
+
+func TestValidateMaintenanceWindow(t *testing.T) {
+	start := time.Now()
+	end := start.Add(-time.Hour)
+	issues := validateMaintenanceWindow(&models.MaintenanceWindow{Start: &start, End: &end})
+	require.Len(t, issues, 2)
+	assert.Equal(t, models.ValidationIssue{Path: "cluster", Code: IssueRequired, Message: "the cluster of the maintenance window is missing"}, issues[0])
+	assert.Equal(t, "end", issues[1].Path)
+	assert.Equal(t, IssueInvalid, issues[1].Code)
+
+	issues = validateMaintenanceWindow(&models.MaintenanceWindow{Cluster: "edge-1", Start: &start, Cron: "@daily"})
+	require.Len(t, issues, 1)
+	assert.Equal(t, IssueConflict, issues[0].Code)
+
+	issues = validateMaintenanceWindow(&models.MaintenanceWindow{Cluster: "edge-1", Cron: "0 2 30 2 *", Duration: "-1h", Timezone: "Mars/Olympus"})
+	require.Len(t, issues, 3)
+	assert.Equal(t, "timezone", issues[0].Path)
+	assert.Equal(t, "cron", issues[1].Path)
+	assert.Equal(t, "duration", issues[2].Path)
+
+	assert.Empty(t, validateMaintenanceWindow(&models.MaintenanceWindow{Cluster: "edge-1", Cron: "0 2 * * 6", Duration: "4h"}))
+}
+
+func TestFindJobsToExecuteDefersMaintenance(t *testing.T) {
+	t.Setenv("MAINTENANCE_URGENT_JOBS", "delete")
+	mockWindows := new(repository.MockMaintenanceWindowRepository)
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, NewMaintenanceService(mockWindows))
+
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	mockWindows.On("FindMaintenanceWindows", "").Return(&[]models.MaintenanceWindow{
+		{BaseUUID: models.BaseUUID{ID: "window-1"}, Cluster: "edge-1", Start: &start, End: &end},
+	}, nil)
+	create := models.Job{BaseUUID: models.BaseUUID{ID: "create"}, Type: models.CreateDeployment, State: models.JobCreated,
+		Targets: models.Target{ClusterName: "edge-1"}}
+	remediation := models.Job{BaseUUID: models.BaseUUID{ID: "remediation"}, Type: models.UpdateDeployment, SubType: "scale-up",
+		State: models.JobCreated, Targets: models.Target{ClusterName: "edge-1"}}
+	remove := models.Job{BaseUUID: models.BaseUUID{ID: "delete"}, Type: models.DeleteDeployment, State: models.JobCreated,
+		Targets: models.Target{ClusterName: "edge-1"}}
+	other := models.Job{BaseUUID: models.BaseUUID{ID: "other"}, Type: models.CreateDeployment, State: models.JobCreated,
+		Targets: models.Target{ClusterName: "edge-2"}}
+	mockRepo.On("FindJobsToExecute", "ocm", "").Return(&[]models.Job{create, remediation, remove, other}, nil)
+	mockRepo.On("FindJobByUUID", "create").Return(&create, nil)
+
+	// only deletions pass through, remediations are urgent by default but not configured so here
+	jobs, err := service.FindJobsToExecute("ocm", "")
+	require.NoError(t, err)
+	require.Len(t, *jobs, 2)
+	assert.Equal(t, "delete", (*jobs)[0].ID)
+	assert.Equal(t, "other", (*jobs)[1].ID)
+
+	job, err := service.FindJobByUUID("create")
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance window window-1 of cluster edge-1 until "+end.UTC().Format(time.RFC3339), job.Deferred)
+}
+
+func TestFindJobGroupsDefersMaintenance(t *testing.T) {
+	mockWindows := new(repository.MockMaintenanceWindowRepository)
+	mockJobGroupRepo := new(repository.MockJobGroupRepository)
+	service := NewJobGroupService(mockJobGroupRepo, nil, nil, NewMaintenanceService(mockWindows))
+
+	start, end := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	mockWindows.On("FindMaintenanceWindows", "").Return(&[]models.MaintenanceWindow{
+		{BaseUUID: models.BaseUUID{ID: "window-1"}, Cluster: "edge-1", Start: &start, End: &end},
+	}, nil).Twice()
+	jobGroup := func(id, cluster string) models.JobGroup {
+		return models.JobGroup{BaseUUID: models.BaseUUID{ID: id}, Jobs: []models.Job{
+			{BaseUUID: models.BaseUUID{ID: id + "-job"}, Type: models.CreateDeployment, State: models.JobCreated,
+				Targets: models.Target{ClusterName: cluster}},
+		}}
+	}
+	shop, blog := jobGroup("shop", "edge-1"), jobGroup("blog", "edge-2")
+	mockJobGroupRepo.On("FindJobGroupByUUID", "shop").Return(&shop, nil).Once()
+	mockJobGroupRepo.On("FindAllJobGroups").Return(&[]models.JobGroup{jobGroup("shop", "edge-1"), blog}, nil).Once()
+
+	reason := "maintenance window window-1 of cluster edge-1 until " + end.UTC().Format(time.RFC3339)
+	found, err := service.FindJobGroupByUUID("shop")
+	require.NoError(t, err)
+	assert.Equal(t, reason, found.Jobs[0].Deferred)
+
+	// the windows are read once for every job group
+	all, err := service.FindAllJobGroups()
+	require.NoError(t, err)
+	assert.Equal(t, reason, (*all)[0].Jobs[0].Deferred)
+	assert.Empty(t, (*all)[1].Jobs[0].Deferred)
+	mockWindows.AssertExpectations(t)
+	mockJobGroupRepo.AssertExpectations(t)
+}
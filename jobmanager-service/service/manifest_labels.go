@@ -0,0 +1,147 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/secrets"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// Labels and annotations the job manager sets on the manifests it emits, they let orchestrators and the policy
+// stack match a Kubernetes object with its job
+const (
+	LabelManagedBy       = "app.kubernetes.io/managed-by"
+	LabelJobGroupID      = "jobmanager.icos.eu/job-group-id"
+	LabelJobID           = "jobmanager.icos.eu/job-id"
+	LabelComponent       = "jobmanager.icos.eu/component"
+	LabelResourceUID     = "jobmanager.icos.eu/resource-uid"
+	AnnotationComponent  = "jobmanager.icos.eu/component"
+	AnnotationGeneration = "jobmanager.icos.eu/generation"
+	// AnnotationCreatedNamespace marks the namespace, and its quota, created for the application
+	AnnotationCreatedNamespace = "jobmanager.icos.eu/created-namespace"
+	managedByJobManager        = "job-manager"
+)
+
+// clusterScopedKinds are the built-in kinds without namespace, the namespace of the job is not set on them. The
+// scope of custom resources is read from their CRD, see manifestValidator.clusterScoped.
+var clusterScopedKinds = map[schema.GroupKind]bool{
+	{Kind: "Namespace"}:        true,
+	{Kind: "Node"}:             true,
+	{Kind: "PersistentVolume"}: true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       true,
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                true,
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}:               true,
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 true,
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             true,
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                              true,
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                    true,
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   true,
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: true,
+	{Group: "apiregistration.k8s.io", Kind: "APIService"}:                           true,
+}
+
+// manifestsGeneration hashes the namespace and the manifests of a job, it only changes with their content.
+// Sensitive fields are hashed redacted, their values cannot be guessed from the hash.
+func manifestsGeneration(job *models.Job) string {
+	hash := sha256.New()
+	hash.Write([]byte(job.Namespace))
+	for _, manifest := range job.Manifests {
+		hash.Write([]byte("\n---\n" + secrets.RedactManifest(manifest.YamlString)))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// stampJob sets the labels and annotations of the job on its manifests, and the namespace of the job on the
+// namespaced ones. The generation is a hash of the manifests, see manifestsGeneration. Manifests that cannot
+// be parsed are left as they are, they were validated at ingestion.
+func stampJob(job *models.Job) {
+	labels := map[string]string{
+		LabelManagedBy:  managedByJobManager,
+		LabelJobGroupID: job.JobGroupID,
+		LabelJobID:      job.ID,
+	}
+	annotations := map[string]string{
+		AnnotationGeneration: manifestsGeneration(job),
+	}
+	if job.Resource != nil {
+		// component names are not always valid label values, the annotation always holds them
+		annotations[AnnotationComponent] = job.Resource.ResourceName
+		if len(validation.IsValidLabelValue(job.Resource.ResourceName)) == 0 {
+			labels[LabelComponent] = job.Resource.ResourceName
+		}
+		if job.Resource.ResourceUID != "" {
+			labels[LabelResourceUID] = job.Resource.ResourceUID
+		} else {
+			labels[LabelResourceUID] = job.Resource.ID
+		}
+	}
+
+	for i, manifest := range job.Manifests {
+		object := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(manifest.YamlString), &object); err != nil || object == nil {
+			continue
+		}
+		metadata, ok := object["metadata"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		metadata["labels"] = mergeStringMap(metadata["labels"], labels)
+		metadata["annotations"] = mergeStringMap(metadata["annotations"], annotations)
+		apiVersion, _ := object["apiVersion"].(string)
+		kind, _ := object["kind"].(string)
+		if job.Namespace != "" && !defaultManifestValidator().clusterScoped(apiVersion, kind) {
+			metadata["namespace"] = job.Namespace
+		}
+
+		stamped, err := yaml.Marshal(object)
+		if err != nil {
+			continue
+		}
+		job.Manifests[i].YamlString = string(stamped)
+	}
+}
+
+// stampJobs stamps the manifests of every job, see stampJob
+func stampJobs(jobs []models.Job) {
+	for i := range jobs {
+		stampJob(&jobs[i])
+	}
+}
+
+// mergeStringMap sets the values on a map of the manifest, empty values are not set
+func mergeStringMap(existing interface{}, values map[string]string) map[string]interface{} {
+	merged, ok := existing.(map[string]interface{})
+	if !ok {
+		merged = map[string]interface{}{}
+	}
+	for key, value := range values {
+		if value != "" {
+			merged[key] = value
+		}
+	}
+	return merged
+}
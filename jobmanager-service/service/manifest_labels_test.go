@@ -0,0 +1,106 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"testing"
+
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+)
+
+func TestStampJob(t *testing.T) {
+	newJob := func() models.Job {
+		return models.Job{
+			BaseUUID:        models.BaseUUID{ID: "0f1d6fb3-3a3c-4c4e-9d4c-2b1f3bb7f1a1"},
+			JobGroupID:      "7d9e5c1e-7d8a-4a5e-8f3b-8a1e2f3c4d5e",
+			Namespace:       "shop",
+			ResourceVersion: 3,
+			Resource:        &models.Resource{BaseUUID: models.BaseUUID{ID: "5b7c2a9e-1c2d-4e3f-8a9b-0c1d2e3f4a5b"}, ResourceName: "web"},
+			Manifests: []models.PlainManifest{
+				{YamlString: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n  namespace: other\n  labels:\n    app: web\n"},
+				{YamlString: "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: reader\n"},
+			},
+		}
+	}
+	metadata := func(t *testing.T, manifest models.PlainManifest) map[string]interface{} {
+		object := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal([]byte(manifest.YamlString), &object))
+		return object["metadata"].(map[string]interface{})
+	}
+
+	mockRepo := new(repository.MockJobRepository)
+	mockRepo.On("FindJobsToExecute", "ocm", "").Return(&[]models.Job{newJob()}, nil)
+	// the overlays of the job group are applied before the manifests are stamped
+	mockRepo.On("FindJobGroupOverlays", []string{"7d9e5c1e-7d8a-4a5e-8f3b-8a1e2f3c4d5e"}).Return(map[string]models.OverlayList{
+		"7d9e5c1e-7d8a-4a5e-8f3b-8a1e2f3c4d5e": {{Name: "single", Patches: []models.OverlayPatch{{Target: &models.PatchTarget{Kind: "Deployment"}, Patch: "spec:\n  replicas: 1\n"}}}},
+	}, nil)
+	jobs, err := NewJobService(mockRepo, nil).FindJobsToExecute("ocm", "")
+	require.NoError(t, err)
+	assert.Contains(t, (*jobs)[0].Manifests[0].YamlString, "replicas: 1")
+
+	deployment := metadata(t, (*jobs)[0].Manifests[0])
+	assert.Equal(t, "shop", deployment["namespace"])
+	assert.Equal(t, map[string]interface{}{
+		"app":            "web",
+		LabelManagedBy:   "job-manager",
+		LabelJobGroupID:  "7d9e5c1e-7d8a-4a5e-8f3b-8a1e2f3c4d5e",
+		LabelJobID:       "0f1d6fb3-3a3c-4c4e-9d4c-2b1f3bb7f1a1",
+		LabelComponent:   "web",
+		LabelResourceUID: "5b7c2a9e-1c2d-4e3f-8a9b-0c1d2e3f4a5b",
+	}, deployment["labels"])
+	annotations := deployment["annotations"].(map[string]interface{})
+	assert.Equal(t, "web", annotations[AnnotationComponent])
+	assert.Len(t, annotations[AnnotationGeneration], 16)
+
+	// cluster scoped kinds have no namespace
+	clusterRole := metadata(t, (*jobs)[0].Manifests[1])
+	assert.NotContains(t, clusterRole, "namespace")
+	assert.Contains(t, clusterRole["labels"], LabelJobID)
+
+	t.Run("Generation", func(t *testing.T) {
+		// the generation follows the manifests, not the writes to the job
+		generation := func(job models.Job) interface{} {
+			stampJob(&job)
+			return metadata(t, job.Manifests[0])["annotations"].(map[string]interface{})[AnnotationGeneration]
+		}
+		job := newJob()
+		updated := newJob()
+		updated.ResourceVersion = 7
+		assert.Equal(t, generation(job), generation(updated))
+
+		updated.Manifests[0].YamlString += "spec:\n  replicas: 2\n"
+		assert.NotEqual(t, generation(job), generation(updated))
+	})
+
+	t.Run("InvalidLabelValue", func(t *testing.T) {
+		job := newJob()
+		job.Resource.ResourceName = "Web Frontend"
+		stampJob(&job)
+		labels := metadata(t, job.Manifests[0])["labels"].(map[string]interface{})
+		assert.NotContains(t, labels, LabelComponent)
+		assert.Equal(t, "Web Frontend", metadata(t, job.Manifests[0])["annotations"].(map[string]interface{})[AnnotationComponent])
+	})
+}
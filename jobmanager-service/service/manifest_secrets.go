@@ -0,0 +1,234 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/utils/secrets"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// redactJobs replaces the sensitive fields of the manifests of the jobs, only the agent owning a job sees them
+func redactJobs(jobs []models.Job) {
+	for i := range jobs {
+		for m := range jobs[i].Manifests {
+			jobs[i].Manifests[m].YamlString = secrets.RedactManifest(jobs[i].Manifests[m].YamlString)
+		}
+	}
+}
+
+// redactParameters replaces the values of parameters with Redacted, they may hold credentials
+func redactParameters(parameters models.StringMap) models.StringMap {
+	if parameters == nil {
+		return nil
+	}
+	redacted := models.StringMap{}
+	for name := range parameters {
+		redacted[name] = secrets.Redacted
+	}
+	return redacted
+}
+
+// redactOverlays replaces the sensitive fields the patches of overlays set with Redacted, the overlays are copied
+func redactOverlays(overlays models.OverlayList) models.OverlayList {
+	redacted := overlays.Copy()
+	for i := range redacted {
+		for p, patch := range redacted[i].Patches {
+			kind := ""
+			if patch.Target != nil {
+				kind = patch.Target.Kind
+			}
+			redacted[i].Patches[p].Patch = secrets.RedactPatch(patch.Patch, kind)
+		}
+	}
+	return redacted
+}
+
+// redactJobGroup applies the overlays of a job group to the manifests of its jobs and redacts them along with
+// the values of its parameters and its overlays, as they are returned to users
+func redactJobGroup(jobGroup *models.JobGroup) {
+	overlayJobs(jobGroup.Jobs, jobGroup.Overlays)
+	redactJobs(jobGroup.Jobs)
+	jobGroup.Parameters = redactParameters(jobGroup.Parameters)
+	jobGroup.Overlays = redactOverlays(jobGroup.Overlays)
+}
+
+// redactDescriptor redacts a rendered application descriptor: its manifests, the documents of manifest strings
+// and the items of List kinds included, the values of its helm charts and the patches of its overlays. The
+// descriptor is left out when it cannot be read.
+func redactDescriptor(descriptor string) string {
+	if descriptor == "" {
+		return ""
+	}
+	header := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(descriptor), &header); err != nil {
+		return secrets.Redacted
+	}
+	manifests, _ := header["manifests"].([]interface{})
+	for i, manifest := range manifests {
+		redacted, err := redactDescriptorManifest(manifest)
+		if err != nil {
+			return secrets.Redacted
+		}
+		manifests[i] = redacted
+	}
+	components, _ := header["components"].([]interface{})
+	for _, component := range components {
+		component, _ := component.(map[string]interface{})
+		chart, _ := component["chart"].(map[string]interface{})
+		if values, ok := chart["values"]; ok {
+			chart["values"] = redactValues(values)
+		}
+	}
+	overlays, _ := header["overlays"].([]interface{})
+	for _, overlay := range overlays {
+		overlay, _ := overlay.(map[string]interface{})
+		patches, _ := overlay["patches"].([]interface{})
+		for _, patch := range patches {
+			patch, _ := patch.(map[string]interface{})
+			patchYAML, ok := patch["patch"].(string)
+			if !ok {
+				continue
+			}
+			target, _ := patch["target"].(map[string]interface{})
+			kind, _ := target["kind"].(string)
+			patch["patch"] = secrets.RedactPatch(patchYAML, kind)
+		}
+	}
+	redacted, err := yaml.Marshal(header)
+	if err != nil {
+		return secrets.Redacted
+	}
+	return string(redacted)
+}
+
+// redactDescriptorManifest redacts a manifest entry of a descriptor, the documents of strings and the items of
+// List kinds one by one, recursively, see expandManifest
+func redactDescriptorManifest(entry interface{}) (interface{}, error) {
+	switch entry := entry.(type) {
+	case string:
+		documents, err := manifestDocuments(entry)
+		if err != nil {
+			return nil, err
+		}
+		for i, document := range documents {
+			var object interface{}
+			if err := yaml.Unmarshal([]byte(document), &object); err != nil {
+				return nil, err
+			}
+			redacted, err := redactDescriptorManifest(object)
+			if err != nil {
+				return nil, err
+			}
+			redactedYAML, err := yaml.Marshal(redacted)
+			if err != nil {
+				return nil, err
+			}
+			documents[i] = string(redactedYAML)
+		}
+		return strings.Join(documents, "---\n"), nil
+	case map[string]interface{}:
+		kind, _ := entry["kind"].(string)
+		if items, ok := entry["items"].([]interface{}); ok && strings.HasSuffix(kind, "List") {
+			for i, item := range items {
+				redacted, err := redactDescriptorManifest(item)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = redacted
+			}
+			return entry, nil
+		}
+		manifestYAML, err := yaml.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		redacted := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(secrets.RedactManifest(string(manifestYAML))), &redacted); err != nil {
+			return nil, err
+		}
+		return redacted, nil
+	default:
+		return entry, nil
+	}
+}
+
+// redactValues replaces the values of helm chart values with Redacted, they may hold credentials as parameters do
+func redactValues(values interface{}) interface{} {
+	switch values := values.(type) {
+	case map[string]interface{}:
+		for key, value := range values {
+			values[key] = redactValues(value)
+		}
+		return values
+	case []interface{}:
+		for i, value := range values {
+			values[i] = redactValues(value)
+		}
+		return values
+	case nil:
+		return nil
+	default:
+		return secrets.Redacted
+	}
+}
+
+// redacted redacts the job found, see redactJobs
+func redacted(job *models.Job, err error) (*models.Job, error) {
+	if err == nil && job != nil {
+		redactJobs([]models.Job{*job})
+	}
+	return job, err
+}
+
+// redactedAll redacts the jobs found, see redactJobs
+func redactedAll(jobs *[]models.Job, err error) (*[]models.Job, error) {
+	if err == nil && jobs != nil {
+		redactJobs(*jobs)
+	}
+	return jobs, err
+}
+
+// restoreRedacted gives back their stored value to the redacted fields of the manifests of an updated job, so
+// that a job read from the API can be sent back. Manifests are matched by ID.
+func restoreRedacted(updated *models.Job, stored *models.Job) {
+	storedManifests := map[uint32]string{}
+	for _, manifest := range stored.Manifests {
+		storedManifests[manifest.ID] = manifest.YamlString
+	}
+	for i, manifest := range updated.Manifests {
+		if storedManifest, ok := storedManifests[manifest.ID]; ok && manifest.ID != 0 {
+			updated.Manifests[i].YamlString = secrets.RestoreRedacted(manifest.YamlString, storedManifest)
+		}
+	}
+}
+
+// hasRedacted tells whether a manifest of the job holds a redacted field
+func hasRedacted(job *models.Job) bool {
+	for _, manifest := range job.Manifests {
+		if strings.Contains(manifest.YamlString, secrets.Redacted) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"icos/server/jobmanager-service/utils/secrets"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const secretManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: s3cr3t
+`
+
+func secretJob(id, ownerID string) models.Job {
+	return models.Job{
+		BaseUUID:  models.BaseUUID{ID: id},
+		OwnerID:   ownerID,
+		Manifests: []models.PlainManifest{{BaseUINT: models.BaseUINT{ID: 1}, YamlString: secretManifest}},
+	}
+}
+
+func TestFindJobRedactsSecrets(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	job := secretJob("job-1", "")
+	mockRepo.On("FindJobByUUID", "job-1").Return(&job, nil)
+
+	result, err := service.FindJobByUUID("job-1")
+	assert.NoError(t, err)
+	assert.Contains(t, result.Manifests[0].YamlString, secrets.Redacted)
+	assert.NotContains(t, result.Manifests[0].YamlString, "s3cr3t")
+}
+
+func TestFindJobsToExecuteRevealsOwnedSecrets(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	jobs := &[]models.Job{secretJob("owned", "agent-1"), secretJob("new", "")}
+	mockRepo.On("FindJobsToExecute", "kubernetes", "agent-1").Return(jobs, nil)
+
+	result, err := service.FindJobsToExecute("kubernetes", "agent-1")
+	assert.NoError(t, err)
+	assert.Contains(t, (*result)[0].Manifests[0].YamlString, "s3cr3t")
+	assert.NotContains(t, (*result)[1].Manifests[0].YamlString, "s3cr3t")
+}
+
+func TestUpdateJobRestoresRedactedSecrets(t *testing.T) {
+	mockRepo := new(repository.MockJobRepository)
+	service := NewJobService(mockRepo, nil)
+
+	stored := secretJob("job-1", "")
+	mockRepo.On("FindJobByUUID", "job-1").Return(&stored, nil)
+
+	// the job read from the API is sent back with its redacted secret
+	updated := secretJob("job-1", "")
+	updated.Manifests[0].YamlString = secrets.RedactManifest(secretManifest)
+	saved := ""
+	mockRepo.On("UpdateJob", &updated).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*models.Job).Manifests[0].YamlString
+	}).Return(&updated, nil)
+
+	result, err := service.UpdateJob(&updated, "")
+	assert.NoError(t, err)
+	assert.Contains(t, saved, "password: s3cr3t")
+	assert.NotContains(t, result.Manifests[0].YamlString, "s3cr3t")
+}
+
+func TestRedactJobGroupOverlays(t *testing.T) {
+	overlays := models.OverlayList{{Name: "edge", Patches: []models.OverlayPatch{
+		{Patch: "kind: Secret\nstringData:\n  password: hunter2\n"},
+		{Target: &models.PatchTarget{Kind: "Secret"}, Patch: "stringData:\n  token: t0k3n\n"},
+		{Target: &models.PatchTarget{Kind: "Secret"}, Patch: "- op: replace\n  path: /stringData/password\n  value: p4ss\n"},
+		{Patch: "- op: add\n  path: /data\n  value:\n    key: a2V5\n"},
+		{Target: &models.PatchTarget{Kind: "Deployment"}, Patch: "spec:\n  replicas: 1\n"},
+	}}}
+	jobGroup := &models.JobGroup{Overlays: overlays}
+
+	redactJobGroup(jobGroup)
+	patches := jobGroup.Overlays[0].Patches
+	for _, secret := range []string{"hunter2", "t0k3n", "p4ss", "a2V5"} {
+		for _, patch := range patches {
+			assert.NotContains(t, patch.Patch, secret)
+		}
+	}
+	assert.Contains(t, patches[0].Patch, "password: '"+secrets.Redacted+"'")
+	assert.Contains(t, patches[2].Patch, "path: /stringData/password")
+	assert.Contains(t, patches[3].Patch, "key: '"+secrets.Redacted+"'")
+	assert.Equal(t, "spec:\n  replicas: 1\n", patches[4].Patch)
+	// the stored overlays are left as they are
+	assert.Contains(t, overlays[0].Patches[0].Patch, "hunter2")
+}
+
+func TestRedactDescriptor(t *testing.T) {
+	descriptor := `name: shop
+components:
+- name: db
+  type: helm
+  chart:
+    path: postgres
+    values:
+      auth:
+        password: v4lues
+manifests:
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: db
+    stringData:
+      password: hunter2
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: shop
+    data:
+      mode: edge
+- |
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cache
+  ---
+  apiVersion: v1
+  kind: Secret
+  metadata:
+    name: cache
+  data:
+    token: ZG9jdW1lbnQ=
+overlays:
+- name: edge
+  patches:
+  - target:
+      kind: Secret
+    patch: |
+      stringData:
+        password: 0verlay
+`
+	redacted := redactDescriptor(descriptor)
+	for _, secret := range []string{"hunter2", "ZG9jdW1lbnQ=", "v4lues", "0verlay"} {
+		assert.NotContains(t, redacted, secret)
+	}
+	assert.Contains(t, redacted, "mode: edge")
+	assert.Contains(t, redacted, "name: cache")
+	assert.Contains(t, redacted, "path: postgres")
+}
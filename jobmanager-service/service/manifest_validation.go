@@ -0,0 +1,261 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/utils/logs"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestSchemeGroups registers the API groups whose kinds are decoded to typed objects, the groups used
+// can be restricted with MANIFEST_SCHEME_GROUPS. Other kinds are decoded to unstructured objects.
+var manifestSchemeGroups = map[string]func(*runtime.Scheme) error{
+	"core":       corev1.AddToScheme,
+	"apps":       appsv1.AddToScheme,
+	"batch":      batchv1.AddToScheme,
+	"networking": networkingv1.AddToScheme,
+	"rbac":       rbacv1.AddToScheme,
+	"autoscaling": func(scheme *runtime.Scheme) error {
+		if err := autoscalingv1.AddToScheme(scheme); err != nil {
+			return err
+		}
+		return autoscalingv2.AddToScheme(scheme)
+	},
+	"policy": policyv1.AddToScheme,
+}
+
+// RegisterManifestSchemeGroup makes the kinds of an API group decodable to typed objects, it must be called
+// before the first manifest is validated
+func RegisterManifestSchemeGroup(name string, addToScheme func(*runtime.Scheme) error) {
+	manifestSchemeGroups[name] = addToScheme
+}
+
+// manifestValidator decodes manifests with the typed scheme, falling back to unstructured objects for the
+// other kinds. Unstructured objects are checked against the OpenAPI schema of their CRD when one is loaded.
+type manifestValidator struct {
+	scheme  *runtime.Scheme
+	decoder runtime.Decoder
+	schemas map[schema.GroupVersionKind]*spec.Schema
+	// scopes of the kinds of the CRDs loaded, Cluster or Namespaced
+	scopes map[schema.GroupKind]string
+}
+
+// defaultManifestValidator is configured from the MANIFEST_SCHEME_GROUPS and MANIFEST_SCHEMAS_DIR variables
+var defaultManifestValidator = sync.OnceValue(func() *manifestValidator {
+	validator, err := newManifestValidator(os.Getenv("MANIFEST_SCHEME_GROUPS"), os.Getenv("MANIFEST_SCHEMAS_DIR"))
+	if err != nil {
+		logs.Logger.Println("ERROR configuring manifest validation, using the default scheme: " + err.Error())
+		validator, _ = newManifestValidator("", "")
+	}
+	return validator
+})
+
+// newManifestValidator registers the comma separated API groups, all of them when empty, and loads the CRDs
+// found in the schemas directory, if any
+func newManifestValidator(groups, schemasDir string) (*manifestValidator, error) {
+	scheme := runtime.NewScheme()
+	names := []string{}
+	for name := range manifestSchemeGroups {
+		names = append(names, name)
+	}
+	if groups != "" {
+		// core and apps are the kinds of most applications, they are always decoded to typed objects
+		names = []string{"core", "apps"}
+		for _, name := range strings.Split(groups, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		addToScheme, ok := manifestSchemeGroups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown API group %s in MANIFEST_SCHEME_GROUPS", name)
+		}
+		if err := addToScheme(scheme); err != nil {
+			return nil, fmt.Errorf("failed to add %s to scheme: %w", name, err)
+		}
+	}
+
+	validator := &manifestValidator{
+		scheme:  scheme,
+		decoder: serializer.NewCodecFactory(scheme).UniversalDeserializer(),
+		schemas: map[schema.GroupVersionKind]*spec.Schema{},
+		scopes:  map[schema.GroupKind]string{},
+	}
+	if schemasDir != "" {
+		if err := validator.loadSchemas(schemasDir); err != nil {
+			return nil, err
+		}
+	}
+	return validator, nil
+}
+
+// customResourceDefinition holds the fields of an apiextensions.k8s.io/v1 CustomResourceDefinition used
+// to validate its custom resources and to tell whether they are namespaced
+type customResourceDefinition struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Group string `json:"group"`
+		Scope string `json:"scope"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Schema *struct {
+				OpenAPIV3Schema *spec.Schema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// loadSchemas reads the OpenAPI schemas of the CRDs defined in the YAML or JSON files of a directory
+func (v *manifestValidator) loadSchemas(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		extension := filepath.Ext(file.Name())
+		if file.IsDir() || (extension != ".yaml" && extension != ".yml" && extension != ".json") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+		for {
+			crd := customResourceDefinition{}
+			if err := decoder.Decode(&crd); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return fmt.Errorf("%s: %w", file.Name(), err)
+			}
+			if crd.Kind != "CustomResourceDefinition" {
+				continue
+			}
+			v.scopes[schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}] = crd.Spec.Scope
+			for _, version := range crd.Spec.Versions {
+				if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+					continue
+				}
+				gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+				v.schemas[gvk] = version.Schema.OpenAPIV3Schema
+				logs.Logger.Println("Loaded OpenAPI schema for " + gvk.String())
+			}
+		}
+	}
+	return nil
+}
+
+// decode validates a manifest, returning the typed object for registered kinds and the unstructured one for the
+// kinds of groups that are not registered. A registered group with an unknown version or kind is a mistake.
+func (v *manifestValidator) decode(yamlString string) (runtime.Object, error) {
+	obj, _, err := v.decoder.Decode([]byte(yamlString), nil, nil)
+	if err == nil || !runtime.IsNotRegisteredError(err) {
+		return obj, err
+	}
+
+	jsonBytes, err := yaml.YAMLToJSON([]byte(yamlString))
+	if err != nil {
+		return nil, err
+	}
+	unstructuredObj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(jsonBytes, &unstructuredObj.Object); err != nil {
+		return nil, err
+	}
+	gvk := unstructuredObj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return nil, errors.New("apiVersion and kind are required")
+	}
+	if v.scheme.IsGroupRegistered(gvk.Group) {
+		return nil, fmt.Errorf("%s is not a kind of the registered API group %s, registered versions are %v",
+			gvk.GroupVersion().WithKind(gvk.Kind), groupName(gvk.Group), v.scheme.PrioritizedVersionsForGroup(gvk.Group))
+	}
+	if unstructuredObj.GetName() == "" {
+		return nil, errors.New("metadata.name is required")
+	}
+
+	if openAPISchema, ok := v.schemas[gvk]; ok {
+		result := validate.NewSchemaValidator(openAPISchema, nil, "", strfmt.Default).Validate(unstructuredObj.Object)
+		if !result.IsValid() {
+			messages := []string{}
+			for _, err := range result.Errors {
+				messages = append(messages, err.Error())
+			}
+			return nil, fmt.Errorf("%s does not match its schema: %s", gvk.Kind, strings.Join(messages, "; "))
+		}
+	}
+	return unstructuredObj, nil
+}
+
+// clusterScoped tells whether the objects of a kind have no namespace, from the scope of its CRD when one is
+// loaded. Kinds that are not known to be cluster scoped are namespaced.
+func (v *manifestValidator) clusterScoped(apiVersion, kind string) bool {
+	groupKind := schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind()
+	if scope, ok := v.scopes[groupKind]; ok {
+		return scope == "Cluster"
+	}
+	return clusterScopedKinds[groupKind]
+}
+
+// groupName names an API group in error messages
+func groupName(group string) string {
+	if group == "" {
+		return "core"
+	}
+	return group
+}
+
+// decodeYAMLToObject validates a manifest with the default validator
+func decodeYAMLToObject(yamlString string) (runtime.Object, error) {
+	return defaultManifestValidator().decode(yamlString)
+}
@@ -0,0 +1,144 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const widgetCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  scope: Namespaced
+  names:
+    kind: Widget
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [size]
+            properties:
+              size:
+                type: integer
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: gateways.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Gateway
+  versions:
+  - name: v1
+`
+
+func TestManifestValidator(t *testing.T) {
+	schemasDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(schemasDir, "widgets.yaml"), []byte(widgetCRD), 0o600))
+
+	validator, err := newManifestValidator("", schemasDir)
+	require.NoError(t, err)
+
+	t.Run("TypedKinds", func(t *testing.T) {
+		obj, err := validator.decode("apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\n")
+		require.NoError(t, err)
+		assert.IsType(t, &networkingv1.Ingress{}, obj)
+
+		obj, err = validator.decode("apiVersion: batch/v1\nkind: CronJob\nmetadata:\n  name: backup\n")
+		require.NoError(t, err)
+		assert.IsType(t, &batchv1.CronJob{}, obj)
+	})
+
+	t.Run("UnstructuredFallback", func(t *testing.T) {
+		obj, err := validator.decode("apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: web\n")
+		require.NoError(t, err)
+		assert.IsType(t, &unstructured.Unstructured{}, obj)
+
+		_, err = validator.decode("apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata: {}\n")
+		assert.Error(t, err)
+	})
+
+	t.Run("CRDSchema", func(t *testing.T) {
+		_, err := validator.decode("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: 3\n")
+		assert.NoError(t, err)
+
+		_, err = validator.decode("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: w\nspec:\n  size: large\n")
+		assert.ErrorContains(t, err, "does not match its schema")
+	})
+
+	t.Run("InvalidTypedObject", func(t *testing.T) {
+		_, err := validator.decode("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: one\n")
+		assert.Error(t, err)
+	})
+
+	t.Run("Scope", func(t *testing.T) {
+		// custom resources take the scope of their CRD, the other ones are namespaced
+		assert.True(t, validator.clusterScoped("example.com/v1", "Gateway"))
+		assert.False(t, validator.clusterScoped("example.com/v1", "Widget"))
+		assert.False(t, validator.clusterScoped("monitoring.coreos.com/v1", "ServiceMonitor"))
+		assert.True(t, validator.clusterScoped("rbac.authorization.k8s.io/v1", "ClusterRole"))
+		assert.True(t, validator.clusterScoped("v1", "Namespace"))
+		assert.False(t, validator.clusterScoped("apps/v1", "Deployment"))
+	})
+
+	t.Run("UnknownVersionOfRegisteredGroup", func(t *testing.T) {
+		// registered groups are not decoded to unstructured objects, their mistyped kinds are reported
+		_, err := validator.decode("apiVersion: apps/v2\nkind: Deployment\nmetadata:\n  name: web\n")
+		assert.ErrorContains(t, err, "registered API group apps")
+
+		_, err = validator.decode("apiVersion: v2\nkind: Service\nmetadata:\n  name: web\n")
+		assert.ErrorContains(t, err, "registered API group core")
+
+		_, err = validator.decode("apiVersion: apps/v1\nkind: Deploymnet\nmetadata:\n  name: web\n")
+		assert.Error(t, err)
+	})
+}
+
+func TestManifestValidatorGroups(t *testing.T) {
+	validator, err := newManifestValidator("batch", "")
+	require.NoError(t, err)
+
+	// kinds of groups that are not registered are still accepted as unstructured objects
+	obj, err := validator.decode("apiVersion: networking.k8s.io/v1\nkind: Ingress\nmetadata:\n  name: web\n")
+	require.NoError(t, err)
+	assert.IsType(t, &unstructured.Unstructured{}, obj)
+
+	_, err = newManifestValidator("unknown", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) ReserveIdempotencyKey(k *models.IdempotencyKey) (bool, error) {
+	args := m.Called(k)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) CompleteIdempotencyKey(k *models.IdempotencyKey) error {
+	args := m.Called(k)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteIdempotencyKey(k *models.IdempotencyKey) error {
+	args := m.Called(k)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) FindIdempotencyKey(tenant, key string) (*models.IdempotencyKey, error) {
+	args := m.Called(tenant, key)
+	return args.Get(0).(*models.IdempotencyKey), args.Error(1)
+}
+
+func (m *MockIdempotencyRepository) DeleteIdempotencyKeysBefore(completedBefore, inProgressBefore time.Time) (int64, error) {
+	args := m.Called(completedBefore, inProgressBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -41,8 +41,8 @@ func (m *MockJobRepository) UpdateJob(job *models.Job) (*models.Job, error) {
 	return args.Get(0).(*models.Job), args.Error(1)
 }
 
-func (m *MockJobRepository) DeleteJob(id string) (int64, error) {
-	args := m.Called(id)
+func (m *MockJobRepository) DeleteJob(id string, version int64) (int64, error) {
+	args := m.Called(id, version)
 	return args.Get(0).(int64), args.Error(1)
 }
 
@@ -75,3 +75,38 @@ func (m *MockJobRepository) JobPromote(job *models.Job) (*models.Job, error) {
 	args := m.Called(job)
 	return args.Get(0).(*models.Job), args.Error(1)
 }
+
+func (m *MockJobRepository) FindJobsByJobGroup(jobGroupID string) (*[]models.Job, error) {
+	args := m.Called(jobGroupID)
+	return args.Get(0).(*[]models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) FindJobGroupRollout(jobGroupID string) (*models.JobGroup, error) {
+	args := m.Called(jobGroupID)
+	return args.Get(0).(*models.JobGroup), args.Error(1)
+}
+
+func (m *MockJobRepository) FindDegradedUpdates() (*[]models.Job, error) {
+	args := m.Called()
+	return args.Get(0).(*[]models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) HaltRollout(jobGroupID, message string) error {
+	args := m.Called(jobGroupID, message)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) FindJobGroupTenants(jobGroupIDs []string) (map[string]string, error) {
+	args := m.Called(jobGroupIDs)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *MockJobRepository) FindJobGroupOverlays(jobGroupIDs []string) (map[string]models.OverlayList, error) {
+	args := m.Called(jobGroupIDs)
+	return args.Get(0).(map[string]models.OverlayList), args.Error(1)
+}
+
+func (m *MockJobRepository) FindJobGroupApprovals(jobGroupIDs []string) (map[string]models.ApprovalStatus, error) {
+	args := m.Called(jobGroupIDs)
+	return args.Get(0).(map[string]models.ApprovalStatus), args.Error(1)
+}
@@ -40,8 +40,8 @@ func (m *MockJobGroupRepository) UpdateJobGroup(jg *models.JobGroup) (*models.Jo
 	return args.Get(0).(*models.JobGroup), args.Error(1)
 }
 
-func (m *MockJobGroupRepository) DeleteJobGroup(id string) (int64, error) {
-	args := m.Called(id)
+func (m *MockJobGroupRepository) DeleteJobGroup(id string, version int64) (int64, error) {
+	args := m.Called(id, version)
 	return args.Get(0).(int64), args.Error(1)
 }
 
@@ -54,3 +54,23 @@ func (m *MockJobGroupRepository) FindAllJobGroups() (*[]models.JobGroup, error)
 	args := m.Called()
 	return args.Get(0).(*[]models.JobGroup), args.Error(1)
 }
+
+func (m *MockJobGroupRepository) FindNamespaceTenants(namespace string) ([]string, error) {
+	args := m.Called(namespace)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockJobGroupRepository) SaveJobGroupRevision(revision *models.JobGroupRevision) (*models.JobGroupRevision, error) {
+	args := m.Called(revision)
+	return args.Get(0).(*models.JobGroupRevision), args.Error(1)
+}
+
+func (m *MockJobGroupRepository) FindJobGroupRevisions(jobGroupID string) (*[]models.JobGroupRevision, error) {
+	args := m.Called(jobGroupID)
+	return args.Get(0).(*[]models.JobGroupRevision), args.Error(1)
+}
+
+func (m *MockJobGroupRepository) FindJobGroupRevision(jobGroupID string, revision int64) (*models.JobGroupRevision, error) {
+	args := m.Called(jobGroupID, revision)
+	return args.Get(0).(*models.JobGroupRevision), args.Error(1)
+}
@@ -0,0 +1,51 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMaintenanceWindowRepository struct {
+	mock.Mock
+}
+
+func (m *MockMaintenanceWindowRepository) SaveMaintenanceWindow(w *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	args := m.Called(w)
+	return args.Get(0).(*models.MaintenanceWindow), args.Error(1)
+}
+
+func (m *MockMaintenanceWindowRepository) FindMaintenanceWindows(cluster string) (*[]models.MaintenanceWindow, error) {
+	args := m.Called(cluster)
+	return args.Get(0).(*[]models.MaintenanceWindow), args.Error(1)
+}
+
+func (m *MockMaintenanceWindowRepository) FindMaintenanceWindow(id string) (*models.MaintenanceWindow, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.MaintenanceWindow), args.Error(1)
+}
+
+func (m *MockMaintenanceWindowRepository) DeleteMaintenanceWindow(id string) (int64, error) {
+	args := m.Called(id)
+	return args.Get(0).(int64), args.Error(1)
+}
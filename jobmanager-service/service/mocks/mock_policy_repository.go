@@ -34,3 +34,8 @@ func (m *MockPolicyRepository) SaveIncompliance(incompliance *models.Incomplianc
 	args := m.Called(incompliance)
 	return args.Get(0).(*models.Incompliance), args.Error(1)
 }
+
+func (m *MockPolicyRepository) MarkRemediated(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
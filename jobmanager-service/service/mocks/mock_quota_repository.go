@@ -0,0 +1,72 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockQuotaRepository struct {
+	mock.Mock
+}
+
+func (m *MockQuotaRepository) SaveQuota(q *models.Quota) (*models.Quota, error) {
+	args := m.Called(q)
+	return args.Get(0).(*models.Quota), args.Error(1)
+}
+
+func (m *MockQuotaRepository) FindQuotaByTenant(tenant string) (*models.Quota, error) {
+	args := m.Called(tenant)
+	return args.Get(0).(*models.Quota), args.Error(1)
+}
+
+func (m *MockQuotaRepository) FindAllQuotas() (*[]models.Quota, error) {
+	args := m.Called()
+	return args.Get(0).(*[]models.Quota), args.Error(1)
+}
+
+func (m *MockQuotaRepository) FindTenantByJobGroupID(id string) (string, error) {
+	args := m.Called(id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQuotaRepository) CountJobGroups(tenant string) (int64, error) {
+	args := m.Called(tenant)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuotaRepository) CountActiveJobs(tenant, excludedJobGroupID string) (int64, error) {
+	args := m.Called(tenant, excludedJobGroupID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuotaRepository) SumManifestBytes(tenant, excludedJobGroupID string) (int64, error) {
+	args := m.Called(tenant, excludedJobGroupID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockQuotaRepository) CountRemediationsSince(tenant string, since time.Time) (int64, error) {
+	args := m.Called(tenant, since)
+	return args.Get(0).(int64), args.Error(1)
+}
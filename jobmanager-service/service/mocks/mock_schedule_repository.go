@@ -0,0 +1,67 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) SaveSchedule(s *models.Schedule) (*models.Schedule, error) {
+	args := m.Called(s)
+	return args.Get(0).(*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) FindSchedulesByJobGroup(jobGroupID string) (*[]models.Schedule, error) {
+	args := m.Called(jobGroupID)
+	return args.Get(0).(*[]models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) FindSchedule(jobGroupID, id string) (*models.Schedule, error) {
+	args := m.Called(jobGroupID, id)
+	return args.Get(0).(*models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) DeleteSchedule(jobGroupID, id string) (int64, error) {
+	args := m.Called(jobGroupID, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockScheduleRepository) FindDueSchedules(now time.Time) (*[]models.Schedule, error) {
+	args := m.Called(now)
+	return args.Get(0).(*[]models.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ClaimSchedule(s *models.Schedule, nextRun *time.Time, ranAt time.Time) (bool, error) {
+	args := m.Called(s, nextRun, ranAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockScheduleRepository) SetScheduleError(id, message string) error {
+	args := m.Called(id, message)
+	return args.Error(0)
+}
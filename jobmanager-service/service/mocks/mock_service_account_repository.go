@@ -0,0 +1,66 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package repository
+
+import (
+	"icos/server/jobmanager-service/models"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockServiceAccountRepository struct {
+	mock.Mock
+}
+
+func (m *MockServiceAccountRepository) SaveServiceAccount(sa *models.ServiceAccount) (*models.ServiceAccount, error) {
+	args := m.Called(sa)
+	return args.Get(0).(*models.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) RevokeServiceAccount(id string) (int64, error) {
+	args := m.Called(id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindServiceAccountByUUID(id string) (*models.ServiceAccount, error) {
+	args := m.Called(id)
+	return args.Get(0).(*models.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindServiceAccountByAPIKeyHash(hash string) (*models.ServiceAccount, error) {
+	args := m.Called(hash)
+	return args.Get(0).(*models.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindServiceAccountByClientID(clientID string) (*models.ServiceAccount, error) {
+	args := m.Called(clientID)
+	return args.Get(0).(*models.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindAllServiceAccounts() (*[]models.ServiceAccount, error) {
+	args := m.Called()
+	return args.Get(0).(*[]models.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) FindServiceAccountsByOwnerID(ownerID string) (*[]models.ServiceAccount, error) {
+	args := m.Called(ownerID)
+	return args.Get(0).(*[]models.ServiceAccount), args.Error(1)
+}
@@ -46,11 +46,12 @@ type policyService struct {
 	policyRepository repository.PolicyRepository
 	jobRepository    repository.JobRepository
 	httpClient       HTTPClient
+	quotas           QuotaService
 }
 
 // NewPolicyService returns a new instance of policyService
-func NewPolicyService(policyRepository repository.PolicyRepository, jobRepository repository.JobRepository, httpClient HTTPClient) PolicyService {
-	return &policyService{policyRepository: policyRepository, jobRepository: jobRepository, httpClient: httpClient}
+func NewPolicyService(policyRepository repository.PolicyRepository, jobRepository repository.JobRepository, httpClient HTTPClient, quotas QuotaService) PolicyService {
+	return &policyService{policyRepository: policyRepository, jobRepository: jobRepository, httpClient: httpClient, quotas: quotas}
 }
 
 // HandlePolicyIncompliance processes incompliance and applies remediation
@@ -81,6 +82,9 @@ func (s *policyService) HandlePolicyIncompliance(incomplianceBody []byte) (*mode
 	if jobGotten.State != models.JobFinished {
 		return nil, errors.New("job cannot be remediated")
 	}
+	if err := s.quotas.CheckRemediation(jobGotten.JobGroupID); err != nil {
+		return nil, err
+	}
 
 	jobGotten.State = models.JobCreated
 	jobGotten.Type = models.UpdateDeployment
@@ -105,6 +109,12 @@ func (s *policyService) HandlePolicyIncompliance(incomplianceBody []byte) (*mode
 		return nil, err
 	}
 
+	// the remediation now counts against the quota of the tenant
+	if err := s.policyRepository.MarkRemediated(incompliance.ID); err != nil {
+		return nil, err
+	}
+	incompliance.Remediated = true
+
 	return &incompliance, nil
 }
 
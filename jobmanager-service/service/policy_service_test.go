@@ -47,7 +47,8 @@ func TestPolicyService(t *testing.T) {
 	mockPolicyRepo := new(repository.MockPolicyRepository)
 	mockJobRepo := new(repository.MockJobRepository)
 	mockHTTPClient := new(MockHTTPClient)
-	policyService := service.NewPolicyService(mockPolicyRepo, mockJobRepo, mockHTTPClient)
+	mockQuotaRepo := new(repository.MockQuotaRepository)
+	policyService := service.NewPolicyService(mockPolicyRepo, mockJobRepo, mockHTTPClient, service.NewQuotaService(mockQuotaRepo))
 
 	t.Run("HandlePolicyIncompliance", func(t *testing.T) {
 		incomplianceBody := []byte(`{
@@ -105,13 +106,16 @@ func TestPolicyService(t *testing.T) {
 
 		mockPolicyRepo.On("SaveIncompliance", &incompliance).Return(&incompliance, nil)
 		mockJobRepo.On("FindJobByResourceUUID", incompliance.Subject.ResourceID).Return(job, nil)
+		mockQuotaRepo.On("FindTenantByJobGroupID", job.JobGroupID).Return("team-a", nil)
+		mockQuotaRepo.On("FindQuotaByTenant", "team-a").Return(&models.Quota{Tenant: "team-a"}, nil)
 		mockJobRepo.On("UpdateJob", mock.MatchedBy(func(j *models.Job) bool {
 			return j.State == expectedUpdatedJob.State && j.SubType == expectedUpdatedJob.SubType && j.Type == expectedUpdatedJob.Type
 		})).Return(expectedUpdatedJob, nil)
+		mockPolicyRepo.On("MarkRemediated", incompliance.ID).Return(nil)
 
 		result, err := policyService.HandlePolicyIncompliance(incomplianceBody)
 		assert.NoError(t, err)
-		assert.Equal(t, &incompliance, result)
+		assert.True(t, result.Remediated)
 		mockPolicyRepo.AssertExpectations(t)
 		mockJobRepo.AssertExpectations(t)
 	})
@@ -0,0 +1,204 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"encoding/json"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"strconv"
+	"time"
+)
+
+// QuotaService interface defines the methods to configure and enforce tenant quotas
+type QuotaService interface {
+	SaveQuota(body []byte) (*models.Quota, error)
+	FindAllQuotas() (*[]models.Quota, error)
+	FindQuotaByTenant(tenant string) (*models.Quota, error)
+	GetUsage(tenant string) (*models.QuotaUsage, error)
+	CheckJobGroup(tenant string, jobGroup *models.JobGroup, isNew bool) error
+	CheckRemediation(jobGroupID string) error
+}
+
+// quotaService struct implements the QuotaService interface
+type quotaService struct {
+	repo         repository.QuotaRepository
+	defaultQuota models.Quota
+}
+
+// NewQuotaService returns a new instance of quotaService, tenants without a quota of their
+// own get the limits configured through the QUOTA_* environment variables
+func NewQuotaService(repo repository.QuotaRepository) QuotaService {
+	return &quotaService{
+		repo: repo,
+		defaultQuota: models.Quota{
+			MaxJobGroups:           quotaFromEnv("QUOTA_MAX_JOB_GROUPS"),
+			MaxActiveJobs:          quotaFromEnv("QUOTA_MAX_ACTIVE_JOBS"),
+			MaxManifestBytes:       quotaFromEnv("QUOTA_MAX_MANIFEST_BYTES"),
+			MaxRemediationsPerHour: quotaFromEnv("QUOTA_MAX_REMEDIATIONS_PER_HOUR"),
+		},
+	}
+}
+
+// SaveQuota creates or replaces the quota of a tenant
+func (s *quotaService) SaveQuota(body []byte) (*models.Quota, error) {
+	quota := models.Quota{}
+	if err := json.Unmarshal(body, &quota); err != nil {
+		return nil, err
+	}
+	if err := quota.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.SaveQuota(&quota)
+}
+
+// FindAllQuotas finds all the quotas configured for specific tenants
+func (s *quotaService) FindAllQuotas() (*[]models.Quota, error) {
+	return s.repo.FindAllQuotas()
+}
+
+// FindQuotaByTenant returns the limits applying to a tenant
+func (s *quotaService) FindQuotaByTenant(tenant string) (*models.Quota, error) {
+	quota, err := s.repo.FindQuotaByTenant(tenant)
+	if err != nil {
+		quota := s.defaultQuota
+		quota.Tenant = tenant
+		return &quota, nil
+	}
+	return quota, nil
+}
+
+// GetUsage reports the consumption of a tenant against its quota
+func (s *quotaService) GetUsage(tenant string) (*models.QuotaUsage, error) {
+	quota, err := s.FindQuotaByTenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := models.QuotaUsage{Tenant: tenant, Limits: *quota}
+	if usage.JobGroups, err = s.repo.CountJobGroups(tenant); err != nil {
+		return nil, err
+	}
+	if usage.ActiveJobs, err = s.repo.CountActiveJobs(tenant, ""); err != nil {
+		return nil, err
+	}
+	if usage.ManifestBytes, err = s.repo.SumManifestBytes(tenant, ""); err != nil {
+		return nil, err
+	}
+	if usage.RemediationsLastHour, err = s.repo.CountRemediationsSince(tenant, time.Now().Add(-time.Hour)); err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// CheckJobGroup verifies that storing the job group keeps the tenant within its quota. An
+// updated job group replaces its previous version, so its current usage is not accounted.
+func (s *quotaService) CheckJobGroup(tenant string, jobGroup *models.JobGroup, isNew bool) error {
+	quota, err := s.FindQuotaByTenant(tenant)
+	if err != nil {
+		return err
+	}
+
+	if isNew && quota.MaxJobGroups > 0 {
+		count, err := s.repo.CountJobGroups(tenant)
+		if err != nil {
+			return err
+		}
+		if count+1 > quota.MaxJobGroups {
+			return &QuotaExceededError{Tenant: tenant, Quota: QuotaJobGroups, Limit: quota.MaxJobGroups, Requested: count + 1}
+		}
+	}
+
+	if quota.MaxActiveJobs > 0 {
+		count, err := s.repo.CountActiveJobs(tenant, jobGroup.ID)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobGroup.Jobs {
+			if !(job.Type == models.DeleteDeployment && job.State == models.JobFinished) {
+				count++
+			}
+		}
+		if count > quota.MaxActiveJobs {
+			return &QuotaExceededError{Tenant: tenant, Quota: QuotaActiveJobs, Limit: quota.MaxActiveJobs, Requested: count}
+		}
+	}
+
+	if quota.MaxManifestBytes > 0 {
+		total, err := s.repo.SumManifestBytes(tenant, jobGroup.ID)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobGroup.Jobs {
+			for _, manifest := range job.Manifests {
+				total += int64(len(manifest.YamlString))
+			}
+		}
+		if total > quota.MaxManifestBytes {
+			return &QuotaExceededError{Tenant: tenant, Quota: QuotaManifestBytes, Limit: quota.MaxManifestBytes, Requested: total}
+		}
+	}
+
+	return nil
+}
+
+// CheckRemediation verifies that the tenant owning the job group has not exhausted its
+// remediations for the last hour. The remediation being handled is not applied yet.
+func (s *quotaService) CheckRemediation(jobGroupID string) error {
+	tenant, err := s.repo.FindTenantByJobGroupID(jobGroupID)
+	if err != nil {
+		return err
+	}
+
+	quota, err := s.FindQuotaByTenant(tenant)
+	if err != nil {
+		return err
+	}
+	if quota.MaxRemediationsPerHour <= 0 {
+		return nil
+	}
+
+	count, err := s.repo.CountRemediationsSince(tenant, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if count+1 > quota.MaxRemediationsPerHour {
+		return &QuotaExceededError{Tenant: tenant, Quota: QuotaRemediationsPerHour, Limit: quota.MaxRemediationsPerHour, Requested: count + 1}
+	}
+
+	return nil
+}
+
+func quotaFromEnv(name string) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		logs.Logger.Printf("Ignoring invalid value for %s: %s", name, value)
+		return 0
+	}
+	return limit
+}
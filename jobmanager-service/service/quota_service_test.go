@@ -0,0 +1,114 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaService(t *testing.T) {
+	mockQuotaRepo := new(repository.MockQuotaRepository)
+	quotaService := service.NewQuotaService(mockQuotaRepo)
+
+	jobGroup := &models.JobGroup{
+		Jobs: []models.Job{
+			{Type: models.CreateDeployment, State: models.JobCreated, Manifests: []models.PlainManifest{{YamlString: "kind: Deployment"}}},
+			{Type: models.CreateDeployment, State: models.JobCreated, Manifests: []models.PlainManifest{{YamlString: "kind: Service"}}},
+		},
+	}
+
+	t.Run("CheckJobGroupWithinQuota", func(t *testing.T) {
+		mockQuotaRepo.On("FindQuotaByTenant", "team-a").Return(&models.Quota{Tenant: "team-a", MaxJobGroups: 2, MaxActiveJobs: 3, MaxManifestBytes: 1024}, nil).Once()
+		mockQuotaRepo.On("CountJobGroups", "team-a").Return(int64(1), nil).Once()
+		mockQuotaRepo.On("CountActiveJobs", "team-a", "").Return(int64(1), nil).Once()
+		mockQuotaRepo.On("SumManifestBytes", "team-a", "").Return(int64(100), nil).Once()
+
+		err := quotaService.CheckJobGroup("team-a", jobGroup, true)
+		assert.NoError(t, err)
+		mockQuotaRepo.AssertExpectations(t)
+	})
+
+	t.Run("CheckJobGroupTooManyGroups", func(t *testing.T) {
+		mockQuotaRepo.On("FindQuotaByTenant", "team-b").Return(&models.Quota{Tenant: "team-b", MaxJobGroups: 1}, nil).Once()
+		mockQuotaRepo.On("CountJobGroups", "team-b").Return(int64(1), nil).Once()
+
+		err := quotaService.CheckJobGroup("team-b", jobGroup, true)
+		var quotaErr *service.QuotaExceededError
+		require.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, service.QuotaJobGroups, quotaErr.Quota)
+		assert.False(t, quotaErr.RateLimited())
+	})
+
+	t.Run("CheckJobGroupUpdateTooManyActiveJobs", func(t *testing.T) {
+		jobGroup := &models.JobGroup{BaseUUID: models.BaseUUID{ID: "group-1"}, Jobs: jobGroup.Jobs}
+		mockQuotaRepo.On("FindQuotaByTenant", "team-c").Return(&models.Quota{Tenant: "team-c", MaxJobGroups: 1, MaxActiveJobs: 2}, nil).Once()
+		mockQuotaRepo.On("CountActiveJobs", "team-c", "group-1").Return(int64(1), nil).Once()
+
+		// updates do not account a new job group
+		err := quotaService.CheckJobGroup("team-c", jobGroup, false)
+		var quotaErr *service.QuotaExceededError
+		require.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, service.QuotaActiveJobs, quotaErr.Quota)
+		assert.Equal(t, int64(3), quotaErr.Requested)
+	})
+
+	t.Run("CheckJobGroupDefaultQuota", func(t *testing.T) {
+		mockQuotaRepo.On("FindQuotaByTenant", "team-d").Return((*models.Quota)(nil), errors.New("quota not found")).Once()
+
+		err := quotaService.CheckJobGroup("team-d", jobGroup, true)
+		assert.NoError(t, err)
+	})
+
+	t.Run("CheckRemediationRateLimited", func(t *testing.T) {
+		mockQuotaRepo.On("FindTenantByJobGroupID", "group-2").Return("team-e", nil).Once()
+		mockQuotaRepo.On("FindQuotaByTenant", "team-e").Return(&models.Quota{Tenant: "team-e", MaxRemediationsPerHour: 2}, nil).Once()
+		mockQuotaRepo.On("CountRemediationsSince", "team-e", mock.Anything).Return(int64(2), nil).Once()
+
+		// the remediation being handled would be the third one
+		err := quotaService.CheckRemediation("group-2")
+		var quotaErr *service.QuotaExceededError
+		require.True(t, errors.As(err, &quotaErr))
+		assert.True(t, quotaErr.RateLimited())
+		assert.Equal(t, int64(3), quotaErr.Requested)
+	})
+
+	t.Run("GetUsage", func(t *testing.T) {
+		mockQuotaRepo.On("FindQuotaByTenant", "team-f").Return(&models.Quota{Tenant: "team-f", MaxJobGroups: 10}, nil).Once()
+		mockQuotaRepo.On("CountJobGroups", "team-f").Return(int64(2), nil).Once()
+		mockQuotaRepo.On("CountActiveJobs", "team-f", "").Return(int64(4), nil).Once()
+		mockQuotaRepo.On("SumManifestBytes", "team-f", "").Return(int64(2048), nil).Once()
+		mockQuotaRepo.On("CountRemediationsSince", "team-f", mock.Anything).Return(int64(1), nil).Once()
+
+		usage, err := quotaService.GetUsage("team-f")
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), usage.Limits.MaxJobGroups)
+		assert.Equal(t, int64(4), usage.ActiveJobs)
+		assert.Equal(t, int64(2048), usage.ManifestBytes)
+	})
+}
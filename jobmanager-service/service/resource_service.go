@@ -22,30 +22,49 @@ package service
 
 import (
 	"encoding/json"
+	"fmt"
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/repository"
 	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"time"
 )
 
+// defaultRollbackWindow is how long an updated resource can stay degraded before its job group is rolled back
+const defaultRollbackWindow = 5 * time.Minute
+
 type ResourceService interface {
 	SaveResource(*models.Resource) (*models.Resource, error)
 	UpdateAResource(*models.Resource) (*models.Resource, error)
 	AddCondition(*models.Resource, *models.Condition) (*models.Resource, error)
 	RemoveConditions(*models.Resource) (*models.Resource, error)
 	FindResourceByJobUUID(string) (*models.Resource, error)
-	UpdateResourceState([]byte) (*models.Resource, error)
+	UpdateResourceState([]byte, *models.ServiceAccount) (*models.Resource, error)
+	RollbackDegradedUpdates()
 }
 
 // ResourceService struct implements the ResourceService interface
 type resourceService struct {
 	resourceRepository repository.ResourceRepository
 	jobRepository      repository.JobRepository
+	jobGroups          JobGroupService
+	rollbackWindow     time.Duration
 }
 
-// NewResourceService returns a new instance of resourceService
-func NewResourceService(resourceRepository repository.ResourceRepository, jobRepository repository.JobRepository) ResourceService {
+// NewResourceService returns a new instance of resourceService. Job groups whose updated resources stay degraded
+// for longer than AUTO_ROLLBACK_WINDOW are rolled back through the job group service, zero disables it.
+func NewResourceService(resourceRepository repository.ResourceRepository, jobRepository repository.JobRepository, jobGroups JobGroupService) ResourceService {
+	rollbackWindow := defaultRollbackWindow
+	if value := os.Getenv("AUTO_ROLLBACK_WINDOW"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			logs.Logger.Printf("Ignoring invalid value for AUTO_ROLLBACK_WINDOW: %s", value)
+		} else {
+			rollbackWindow = parsed
+		}
+	}
 	return &resourceService{resourceRepository: resourceRepository,
-		jobRepository: jobRepository}
+		jobRepository: jobRepository, jobGroups: jobGroups, rollbackWindow: rollbackWindow}
 }
 
 // SaveResource saves a new resource
@@ -73,8 +92,8 @@ func (s *resourceService) FindResourceByJobUUID(jobId string) (*models.Resource,
 	return s.resourceRepository.FindResourceByJobUUID(jobId)
 }
 
-// UpdateResourceState updates the state of a resource
-func (s *resourceService) UpdateResourceState(resourceBody []byte) (*models.Resource, error) {
+// UpdateResourceState updates the state of a resource, only the agent owning its job can report it
+func (s *resourceService) UpdateResourceState(resourceBody []byte, agent *models.ServiceAccount) (*models.Resource, error) {
 	resource := models.Resource{}
 
 	// Parse to application objects
@@ -89,6 +108,11 @@ func (s *resourceService) UpdateResourceState(resourceBody []byte) (*models.Reso
 		return nil, err
 	}
 
+	if agent == nil || jobGotten.OwnerID != agent.OwnerID {
+		logs.Logger.Println("Resource status rejected, job " + jobGotten.ID + " is not owned by the agent")
+		return nil, fmt.Errorf("%w: job is not owned by the agent", ErrForbidden)
+	}
+
 	j, err := json.Marshal(jobGotten)
 	if err != nil {
 		logs.Logger.Println("ERROR during debug" + err.Error())
@@ -113,5 +137,69 @@ func (s *resourceService) UpdateResourceState(resourceBody []byte) (*models.Reso
 			return nil, err
 		}
 	}
+	s.rollbackDegraded(jobGotten, &resource)
 	return &resource, nil
 }
+
+// RollbackDegradedUpdates rolls back the job groups of the updates whose resources stayed degraded for longer
+// than the rollback window, their agents may have stopped reporting them
+func (s *resourceService) RollbackDegradedUpdates() {
+	if s.jobGroups == nil || s.rollbackWindow <= 0 {
+		return
+	}
+	jobs, err := s.jobRepository.FindDegradedUpdates()
+	if err != nil {
+		logs.Logger.Println("Error finding the degraded updates:", err)
+		return
+	}
+	rolledBack := map[string]bool{}
+	for i := range *jobs {
+		job := &(*jobs)[i]
+		if !rolledBack[job.JobGroupID] && s.rollbackDegraded(job, job.Resource) {
+			rolledBack[job.JobGroupID] = true
+		}
+	}
+}
+
+// rollbackDegraded rolls the job group of an update back to its previous revision once the updated resource
+// has been degraded for longer than the rollback window, it tells whether it did. Remediations are not updates
+// of the job group and are left to the policies.
+func (s *resourceService) rollbackDegraded(job *models.Job, resource *models.Resource) bool {
+	if s.jobGroups == nil || s.rollbackWindow <= 0 || job.JobGroupID == "" || job.State == models.JobCreated {
+		return false
+	}
+	if job.SubType != "" || (job.Type != models.ReplaceDeployment && job.Type != models.UpdateDeployment) {
+		return false
+	}
+	since, degraded := degradedSince(job, resource)
+	if !degraded || time.Since(since) < s.rollbackWindow {
+		return false
+	}
+
+	reason := fmt.Sprintf("update of component %s failed, job %s degraded since %s", componentName(*job), job.ID,
+		since.UTC().Format(time.RFC3339))
+	if _, err := s.jobGroups.AutoRollbackJobGroup(job.JobGroupID, reason); err != nil {
+		logs.Logger.Printf("Error rolling back job group %s: %v", job.JobGroupID, err)
+		return false
+	}
+	return true
+}
+
+// degradedSince tells whether a reported resource is degraded and since when. The degradation is measured from
+// the later of its transition and the last write of the job, when it was updated or taken by an agent: the
+// conditions may have been reported for the deployment before the update, and an update healthy for a while
+// only degrades from its transition.
+func degradedSince(job *models.Job, reported *models.Resource) (time.Time, bool) {
+	if reported == nil {
+		return time.Time{}, false
+	}
+	for _, condition := range reported.Conditions {
+		if condition.Type == models.Degraded && condition.Status == models.ConditionTrue {
+			if condition.LastTransitionTime.After(job.UpdatedAt) {
+				return condition.LastTransitionTime, true
+			}
+			return job.UpdatedAt, true
+		}
+	}
+	return time.Time{}, false
+}
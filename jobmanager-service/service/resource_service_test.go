@@ -22,7 +22,9 @@ package service_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"icos/server/jobmanager-service/models"
 	"icos/server/jobmanager-service/service"
@@ -35,7 +37,7 @@ import (
 func TestResourceService(t *testing.T) {
 	mockResourceRepo := new(repository.MockResourceRepository)
 	mockJobRepo := new(repository.MockJobRepository)
-	resourceService := service.NewResourceService(mockResourceRepo, mockJobRepo)
+	resourceService := service.NewResourceService(mockResourceRepo, mockJobRepo, nil)
 
 	resource := &models.Resource{}
 	condition := &models.Condition{Type: "Ready"}
@@ -43,12 +45,14 @@ func TestResourceService(t *testing.T) {
 		BaseUUID: models.BaseUUID{
 			ID: "54b68f2f-72c4-4df8-8b9d-f9ebc31bdf7f",
 		},
+		OwnerID: "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c",
 		Resource: &models.Resource{
 			BaseUUID: models.BaseUUID{
 				ID: "91114c14-3ae0-442b-835b-a4f5e24c99c9",
 			},
 		},
 	}
+	agent := &models.ServiceAccount{OwnerID: job.OwnerID, Orchestrator: models.OCM}
 
 	t.Run("SaveResource", func(t *testing.T) {
 		mockResourceRepo.On("SaveResource", resource).Return(resource, nil)
@@ -109,7 +113,7 @@ func TestResourceService(t *testing.T) {
 		mockResourceRepo.On("RemoveConditions", mock.Anything).Return(&resource, nil)
 		mockResourceRepo.On("AddCondition", mock.Anything, mock.Anything).Return(&resource, nil)
 
-		result, err := resourceService.UpdateResourceState(resourceBody)
+		result, err := resourceService.UpdateResourceState(resourceBody, agent)
 		assert.NoError(t, err)
 		assert.Equal(t, job.ID, result.ResourceUID)
 		assert.Equal(t, job.Resource.ID, result.ID)
@@ -117,4 +121,116 @@ func TestResourceService(t *testing.T) {
 		mockJobRepo.AssertExpectations(t)
 		mockResourceRepo.AssertExpectations(t)
 	})
+
+	t.Run("UpdateResourceStateNotOwner", func(t *testing.T) {
+		resourceBody := []byte(`{"ResourceUID": "91114c14-3ae0-442b-835b-a4f5e24c99c9"}`)
+		otherAgent := &models.ServiceAccount{OwnerID: "5f1d7c2a-9a8e-4b7b-8c1d-2e3f4a5b6c7d", Orchestrator: models.OCM}
+
+		_, err := resourceService.UpdateResourceState(resourceBody, otherAgent)
+		assert.ErrorIs(t, err, service.ErrForbidden)
+	})
+}
+
+// rollbackRecorder records the automatic rollbacks asked to the job group service
+type rollbackRecorder struct {
+	service.JobGroupService
+	reasons []string
+}
+
+func (r *rollbackRecorder) AutoRollbackJobGroup(id, reason string) (*models.JobGroup, error) {
+	r.reasons = append(r.reasons, reason)
+	return &models.JobGroup{}, nil
+}
+
+func TestUpdateResourceStateRollsBackDegradedUpdate(t *testing.T) {
+	t.Setenv("AUTO_ROLLBACK_WINDOW", "1m")
+	mockResourceRepo := new(repository.MockResourceRepository)
+	mockJobRepo := new(repository.MockJobRepository)
+	rollbacks := &rollbackRecorder{}
+	resourceService := service.NewResourceService(mockResourceRepo, mockJobRepo, rollbacks)
+
+	job := &models.Job{
+		BaseUUID:   models.BaseUUID{ID: "54b68f2f-72c4-4df8-8b9d-f9ebc31bdf7f"},
+		JobGroupID: "group-1",
+		OwnerID:    "0b8d3a5e-5c2d-4d0f-9f3c-3f4b6a1e2d7c",
+		Type:       models.ReplaceDeployment,
+		State:      models.JobProgressing,
+		Resource:   &models.Resource{BaseUUID: models.BaseUUID{ID: "91114c14-3ae0-442b-835b-a4f5e24c99c9"}, ResourceName: "shop"},
+	}
+	agent := &models.ServiceAccount{OwnerID: job.OwnerID, Orchestrator: models.OCM}
+	mockJobRepo.On("FindJobByResourceUUID", "shop-uid").Return(job, nil)
+	mockResourceRepo.On("UpdateAResource", mock.Anything).Return(&models.Resource{}, nil)
+	mockResourceRepo.On("RemoveConditions", mock.Anything).Return(&models.Resource{}, nil)
+	mockResourceRepo.On("AddCondition", mock.Anything, mock.Anything).Return(&models.Resource{}, nil)
+	ago := func(since time.Duration) string {
+		return time.Now().Add(-since).UTC().Format(time.RFC3339)
+	}
+	degraded := func(transition string) []byte {
+		return []byte(fmt.Sprintf(`{"resource_uuid": "shop-uid", "conditions": [{"type": "Degraded", "status": "True", "lastTransitionTime": %q}]}`,
+			transition))
+	}
+
+	// the degradation is measured from the promotion of the update, not from conditions reported before it
+	job.UpdatedAt = time.Now().Add(-10 * time.Second)
+	_, err := resourceService.UpdateResourceState(degraded(ago(2*time.Minute)), agent)
+	assert.NoError(t, err)
+	assert.Empty(t, rollbacks.reasons)
+
+	// an update healthy for a while is measured from its degradation
+	job.UpdatedAt = time.Now().Add(-5 * time.Minute)
+	_, err = resourceService.UpdateResourceState(degraded(ago(10*time.Second)), agent)
+	assert.NoError(t, err)
+	assert.Empty(t, rollbacks.reasons)
+
+	job.UpdatedAt = time.Now().Add(-2 * time.Minute)
+	degradedAt := ago(90 * time.Second)
+	_, err = resourceService.UpdateResourceState(degraded(degradedAt), agent)
+	assert.NoError(t, err)
+	if assert.Len(t, rollbacks.reasons, 1) {
+		assert.Contains(t, rollbacks.reasons[0], "update of component shop failed, job "+job.ID+" degraded since "+degradedAt)
+	}
+
+	// remediations are left to the policies
+	job.SubType = models.ScaleUp
+	_, err = resourceService.UpdateResourceState(degraded(ago(2*time.Minute)), agent)
+	assert.NoError(t, err)
+	assert.Len(t, rollbacks.reasons, 1)
+	job.SubType = ""
+
+	// only updates are rolled back
+	job.Type = models.CreateDeployment
+	_, err = resourceService.UpdateResourceState(degraded(ago(2*time.Minute)), agent)
+	assert.NoError(t, err)
+	assert.Len(t, rollbacks.reasons, 1)
+}
+
+func TestRollbackDegradedUpdates(t *testing.T) {
+	t.Setenv("AUTO_ROLLBACK_WINDOW", "1m")
+	mockJobRepo := new(repository.MockJobRepository)
+	rollbacks := &rollbackRecorder{}
+	resourceService := service.NewResourceService(new(repository.MockResourceRepository), mockJobRepo, rollbacks)
+
+	degradedJob := func(id, jobGroupID string, updated time.Duration) models.Job {
+		return models.Job{
+			BaseUUID:   models.BaseUUID{ID: id, Metadata: models.Metadata{UpdatedAt: time.Now().Add(-updated)}},
+			JobGroupID: jobGroupID,
+			Type:       models.UpdateDeployment,
+			State:      models.JobProgressing,
+			Resource: &models.Resource{ResourceName: id, Conditions: []models.Condition{
+				{Type: models.Degraded, Status: models.ConditionTrue, LastTransitionTime: time.Now().Add(-updated)},
+			}},
+		}
+	}
+	// the agents stopped reporting, the job groups are rolled back once each
+	mockJobRepo.On("FindDegradedUpdates").Return(&[]models.Job{
+		degradedJob("web", "group-1", 5*time.Minute),
+		degradedJob("api", "group-1", 5*time.Minute),
+		degradedJob("db", "group-2", 10*time.Second),
+	}, nil).Once()
+
+	resourceService.RollbackDegradedUpdates()
+	if assert.Len(t, rollbacks.reasons, 1) {
+		assert.Contains(t, rollbacks.reasons[0], "update of component web failed, job web degraded since")
+	}
+	mockJobRepo.AssertExpectations(t)
 }
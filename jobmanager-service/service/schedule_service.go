@@ -0,0 +1,321 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+	"icos/server/jobmanager-service/utils/cron"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"time"
+
+	// the time zones of the schedules do not depend on the image the job manager runs in
+	_ "time/tzdata"
+)
+
+// defaultSchedulerInterval is how often the due schedules are run when SCHEDULER_INTERVAL is not set
+const defaultSchedulerInterval = 30 * time.Second
+
+// ScheduleService interface defines the methods for the schedules of job groups
+type ScheduleService interface {
+	CreateSchedule(jobGroupID string, body []byte, author, tenant string) (*models.Schedule, error)
+	FindSchedules(jobGroupID, tenant string) (*[]models.Schedule, error)
+	DeleteSchedule(jobGroupID, id, tenant string) error
+	RunDueSchedules(now time.Time)
+	Run(ctx context.Context)
+}
+
+// scheduleService struct implements the ScheduleService interface
+type scheduleService struct {
+	repo      repository.ScheduleRepository
+	jobGroups JobGroupService
+	resources ResourceService
+	interval  time.Duration
+}
+
+// NewScheduleService returns a new instance of scheduleService, the schedules deploy and undeploy their job
+// groups through the job group service. Due schedules are run every SCHEDULER_INTERVAL, the degraded updates
+// are rolled back through the resource service at the same pace.
+func NewScheduleService(repo repository.ScheduleRepository, jobGroups JobGroupService, resources ResourceService) ScheduleService {
+	interval := defaultSchedulerInterval
+	if value := os.Getenv("SCHEDULER_INTERVAL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			logs.Logger.Printf("Ignoring invalid value for SCHEDULER_INTERVAL: %s", value)
+		} else {
+			interval = parsed
+		}
+	}
+	return &scheduleService{repo: repo, jobGroups: jobGroups, resources: resources, interval: interval}
+}
+
+// CreateSchedule schedules the deployment or undeployment of a job group of the tenant, once at a time or on a
+// cron expression
+func (s *scheduleService) CreateSchedule(jobGroupID string, body []byte, author, tenant string) (*models.Schedule, error) {
+	if err := s.checkJobGroup(jobGroupID, tenant); err != nil {
+		return nil, err
+	}
+
+	request := models.Schedule{}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+	schedule := &models.Schedule{
+		JobGroupID:     jobGroupID,
+		Action:         request.Action,
+		At:             request.At,
+		Cron:           request.Cron,
+		Timezone:       request.Timezone,
+		MissedTriggers: request.MissedTriggers,
+		Author:         author,
+	}
+	if issues := validateSchedule(schedule, time.Now()); len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
+	}
+
+	saved, err := s.repo.SaveSchedule(schedule)
+	if err != nil {
+		logs.Logger.Println("Error saving schedule:", err)
+		return nil, err
+	}
+	logs.Logger.Printf("Job group %s scheduled to %s, next run at %s", jobGroupID, schedule.Action, schedule.NextRun)
+	return saved, nil
+}
+
+// validateSchedule reports the problems of a new schedule, its defaults and first run are filled in
+func validateSchedule(schedule *models.Schedule, now time.Time) []models.ValidationIssue {
+	issues := []models.ValidationIssue{}
+	addIssue := func(path, code, format string, args ...interface{}) {
+		issues = append(issues, models.ValidationIssue{Path: path, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch schedule.Action {
+	case models.ScheduleDeploy, models.ScheduleUndeploy:
+	case "":
+		addIssue("action", IssueRequired, "the action of the schedule is missing, expected %s or %s", models.ScheduleDeploy, models.ScheduleUndeploy)
+	default:
+		addIssue("action", IssueInvalid, "unknown action %s, expected %s or %s", schedule.Action, models.ScheduleDeploy, models.ScheduleUndeploy)
+	}
+
+	switch schedule.MissedTriggers {
+	case "":
+		schedule.MissedTriggers = models.MissedTriggersRun
+	case models.MissedTriggersRun, models.MissedTriggersSkip:
+	default:
+		addIssue("missedTriggers", IssueInvalid, "unknown missed triggers policy %s, expected %s or %s",
+			schedule.MissedTriggers, models.MissedTriggersRun, models.MissedTriggersSkip)
+	}
+
+	switch {
+	case schedule.At == nil && schedule.Cron == "":
+		addIssue("at", IssueRequired, "the schedule needs a time or a cron expression")
+	case schedule.At != nil && schedule.Cron != "":
+		addIssue("cron", IssueConflict, "the schedule has both a time and a cron expression")
+	case schedule.At != nil:
+		if !schedule.At.After(now) {
+			addIssue("at", IssueInvalid, "the time of the schedule %s is in the past", schedule.At.Format(time.RFC3339))
+		}
+		schedule.NextRun = schedule.At
+	default:
+		location, err := time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			addIssue("timezone", IssueInvalid, "unknown time zone %s", schedule.Timezone)
+			break
+		}
+		expression, err := cron.Parse(schedule.Cron)
+		if err != nil {
+			addIssue("cron", IssueInvalid, "%s", err.Error())
+			break
+		}
+		next := expression.Next(now.In(location))
+		if next.IsZero() {
+			addIssue("cron", IssueInvalid, "cron expression %s never triggers", schedule.Cron)
+			break
+		}
+		schedule.NextRun = &next
+	}
+	return issues
+}
+
+// FindSchedules returns the schedules of a job group of the tenant, the next to trigger first
+func (s *scheduleService) FindSchedules(jobGroupID, tenant string) (*[]models.Schedule, error) {
+	if err := s.checkJobGroup(jobGroupID, tenant); err != nil {
+		return nil, err
+	}
+	return s.repo.FindSchedulesByJobGroup(jobGroupID)
+}
+
+// DeleteSchedule deletes a schedule of a job group of the tenant
+func (s *scheduleService) DeleteSchedule(jobGroupID, id, tenant string) error {
+	if err := s.checkJobGroup(jobGroupID, tenant); err != nil {
+		return err
+	}
+	if _, err := s.repo.FindSchedule(jobGroupID, id); err != nil {
+		return err
+	}
+	_, err := s.repo.DeleteSchedule(jobGroupID, id)
+	return err
+}
+
+// checkJobGroup verifies that a job group exists and is owned by the tenant, see checkTenant
+func (s *scheduleService) checkJobGroup(jobGroupID, tenant string) error {
+	jobGroup, err := s.jobGroups.FindJobGroupByUUID(jobGroupID)
+	if err != nil {
+		return err
+	}
+	if err := checkTenant(jobGroup, tenant); err != nil {
+		logs.Logger.Println("Error scheduling job group:", err)
+		return err
+	}
+	return nil
+}
+
+// nextRun returns the run following a due one, nil for schedules at a time. A trigger is missed when it is
+// overdue by more than two intervals, as after a restart of the job manager.
+func (s *scheduleService) nextRun(schedule models.Schedule, now time.Time) (*time.Time, bool) {
+	missed := now.Sub(*schedule.NextRun) > 2*s.interval
+	if schedule.Cron == "" {
+		return nil, missed
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	expression, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return nil, missed
+	}
+	// the triggers missed in between are collapsed into this run
+	next := expression.Next(now.In(location))
+	if next.IsZero() {
+		return nil, missed
+	}
+	return &next, missed
+}
+
+// RunDueSchedules runs the schedules due at the given time. Each run is claimed first, so that a single job
+// manager instance runs it, and missed triggers are run once or skipped as the schedule asks.
+func (s *scheduleService) RunDueSchedules(now time.Time) {
+	due, err := s.repo.FindDueSchedules(now)
+	if err != nil {
+		logs.Logger.Println("Error finding due schedules:", err)
+		return
+	}
+
+	for _, schedule := range *due {
+		next, missed := s.nextRun(schedule, now)
+		claimed, err := s.repo.ClaimSchedule(&schedule, next, now)
+		if err != nil {
+			logs.Logger.Printf("Error claiming schedule %s: %v", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if missed && schedule.MissedTriggers == models.MissedTriggersSkip {
+			message := fmt.Sprintf("trigger at %s missed and skipped", schedule.NextRun.Format(time.RFC3339))
+			logs.Logger.Printf("Schedule %s of job group %s: %s", schedule.ID, schedule.JobGroupID, message)
+			s.setError(schedule, message)
+			continue
+		}
+
+		logs.Logger.Printf("Schedule %s triggers the %s of job group %s", schedule.ID, schedule.Action, schedule.JobGroupID)
+		skipped, err := s.trigger(schedule)
+		if err != nil {
+			logs.Logger.Printf("Error running schedule %s of job group %s: %v", schedule.ID, schedule.JobGroupID, err)
+			s.setError(schedule, err.Error())
+		} else if skipped != "" {
+			logs.Logger.Printf("Schedule %s of job group %s: %s", schedule.ID, schedule.JobGroupID, skipped)
+			s.setError(schedule, skipped)
+		}
+	}
+}
+
+// trigger deploys or undeploys the job group of a schedule, the reason is returned when the job group already
+// is in the state the schedule brings it to
+func (s *scheduleService) trigger(schedule models.Schedule) (string, error) {
+	jobGroup, err := s.jobGroups.FindJobGroupByUUID(schedule.JobGroupID)
+	if err != nil {
+		return "", err
+	}
+	if scheduledState(jobGroup, schedule.Action) {
+		return fmt.Sprintf("job group already %sed, trigger skipped", schedule.Action), nil
+	}
+
+	// the tenant of the job group was checked when the schedule was created
+	switch schedule.Action {
+	case models.ScheduleDeploy:
+		_, err = s.jobGroups.RedeployJobGroupByID(schedule.JobGroupID, false, nil, "", schedule.Author, 0)
+	case models.ScheduleUndeploy:
+		_, err = s.jobGroups.StopJobGroupByID(schedule.JobGroupID, "", 0)
+	default:
+		err = fmt.Errorf("unknown action %s", schedule.Action)
+	}
+	return "", err
+}
+
+// scheduledState tells whether a job group is in the state a schedule action brings it to: deployed when none of
+// its jobs is undeployed or being undeployed, undeployed when all of them are
+func scheduledState(jobGroup *models.JobGroup, action string) bool {
+	undeployed := 0
+	for _, job := range jobGroup.Jobs {
+		if job.Type == models.DeleteDeployment {
+			undeployed++
+		}
+	}
+	switch action {
+	case models.ScheduleDeploy:
+		return undeployed == 0
+	case models.ScheduleUndeploy:
+		return undeployed == len(jobGroup.Jobs)
+	}
+	return false
+}
+
+func (s *scheduleService) setError(schedule models.Schedule, message string) {
+	if err := s.repo.SetScheduleError(schedule.ID, message); err != nil {
+		logs.Logger.Printf("Error recording the outcome of schedule %s: %v", schedule.ID, err)
+	}
+}
+
+// Run runs the due schedules and rolls back the degraded updates until the context is done, starting with the
+// schedules missed while the job manager was down
+func (s *scheduleService) Run(ctx context.Context) {
+	logs.Logger.Printf("Scheduler running every %s", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.RunDueSchedules(time.Now())
+		if s.resources != nil {
+			s.resources.RollbackDegradedUpdates()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
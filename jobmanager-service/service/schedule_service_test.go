@@ -0,0 +1,192 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"errors"
+	"icos/server/jobmanager-service/models"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"icos/server/jobmanager-service/utils/cron"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronNext(t *testing.T) {
+	friday := time.Date(2026, time.October, 16, 18, 0, 0, 0, time.UTC)
+	for expression, expected := range map[string]time.Time{
+		"0 8 * * 1-5":      time.Date(2026, time.October, 19, 8, 0, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2026, time.October, 16, 18, 15, 0, 0, time.UTC),
+		"30 9 1 * *":       time.Date(2026, time.November, 1, 9, 30, 0, 0, time.UTC),
+		"0 0 13 * fri":     time.Date(2026, time.October, 23, 0, 0, 0, 0, time.UTC),
+		"0 12 * dec sun,7": time.Date(2026, time.December, 6, 12, 0, 0, 0, time.UTC),
+		"@daily":           time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := cron.Parse(expression)
+		require.NoError(t, err, expression)
+		assert.Equal(t, expected, schedule.Next(friday), expression)
+	}
+
+	schedule, err := cron.Parse("0 0 30 2 *")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(friday).IsZero())
+
+	for _, expression := range []string{"* * * *", "60 * * * *", "0 5-2 * * *", "*/0 * * * *", "0 0 * foo *"} {
+		_, err := cron.Parse(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	now := time.Date(2026, time.October, 16, 18, 0, 0, 0, time.UTC)
+
+	schedule := &models.Schedule{Action: models.ScheduleDeploy, Cron: "0 8 * * 1-5", Timezone: "Europe/Paris"}
+	assert.Empty(t, validateSchedule(schedule, now))
+	assert.Equal(t, models.MissedTriggersRun, schedule.MissedTriggers)
+	assert.Equal(t, time.Date(2026, time.October, 19, 6, 0, 0, 0, time.UTC), schedule.NextRun.UTC())
+
+	at := now.Add(time.Hour)
+	schedule = &models.Schedule{Action: models.ScheduleUndeploy, At: &at, MissedTriggers: models.MissedTriggersSkip}
+	assert.Empty(t, validateSchedule(schedule, now))
+	assert.Equal(t, &at, schedule.NextRun)
+
+	past := now.Add(-time.Hour)
+	issues := validateSchedule(&models.Schedule{Action: "scale", At: &past, MissedTriggers: "later"}, now)
+	require.Len(t, issues, 3)
+	assert.Equal(t, []string{"action", "missedTriggers", "at"}, []string{issues[0].Path, issues[1].Path, issues[2].Path})
+
+	issues = validateSchedule(&models.Schedule{Action: models.ScheduleDeploy, At: &at, Cron: "@daily"}, now)
+	require.Len(t, issues, 1)
+	assert.Equal(t, IssueConflict, issues[0].Code)
+
+	issues = validateSchedule(&models.Schedule{Action: models.ScheduleDeploy, Cron: "@daily", Timezone: "Mars/Olympus"}, now)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "timezone", issues[0].Path)
+}
+
+// scheduledJobGroups records the deployments and undeployments triggered by the schedules
+type scheduledJobGroups struct {
+	JobGroupService
+	jobGroups map[string]*models.JobGroup
+	triggered []string
+}
+
+func (s *scheduledJobGroups) FindJobGroupByUUID(id string) (*models.JobGroup, error) {
+	jobGroup, ok := s.jobGroups[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return jobGroup, nil
+}
+
+// scheduledJobGroup returns a job group of the tenant with jobs of the given type
+func scheduledJobGroup(tenant string, jobType models.JobType) *models.JobGroup {
+	return &models.JobGroup{Tenant: tenant, Jobs: []models.Job{{Type: jobType, State: models.JobFinished}}}
+}
+
+func (s *scheduledJobGroups) RedeployJobGroupByID(id string, matchmaking bool, header http.Header, tenant, author string, version int64) (*models.JobGroup, error) {
+	s.triggered = append(s.triggered, "deploy "+id+" by "+author)
+	return nil, errors.New("JobGroup is not stopped")
+}
+
+func (s *scheduledJobGroups) StopJobGroupByID(id, tenant string, version int64) (*models.JobGroup, error) {
+	s.triggered = append(s.triggered, "undeploy "+id)
+	return &models.JobGroup{}, nil
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	mockRepo := new(repository.MockScheduleRepository)
+	jobGroups := &scheduledJobGroups{jobGroups: map[string]*models.JobGroup{
+		"group-1": scheduledJobGroup("", models.DeleteDeployment),
+		"group-3": scheduledJobGroup("", models.CreateDeployment),
+		"group-5": scheduledJobGroup("", models.UpdateDeployment),
+	}}
+	service := NewScheduleService(mockRepo, jobGroups, nil).(*scheduleService)
+
+	now := time.Date(2026, time.October, 19, 8, 0, 20, 0, time.UTC)
+	due := time.Date(2026, time.October, 19, 8, 0, 0, 0, time.UTC)
+	missed := due.Add(-3 * time.Hour)
+	schedules := &[]models.Schedule{
+		{BaseUUID: models.BaseUUID{ID: "deploy"}, JobGroupID: "group-1", Action: models.ScheduleDeploy, Cron: "0 8 * * 1-5",
+			MissedTriggers: models.MissedTriggersRun, NextRun: &due, Author: "alice"},
+		{BaseUUID: models.BaseUUID{ID: "skipped"}, JobGroupID: "group-2", Action: models.ScheduleUndeploy, At: &missed,
+			MissedTriggers: models.MissedTriggersSkip, NextRun: &missed},
+		{BaseUUID: models.BaseUUID{ID: "caught-up"}, JobGroupID: "group-3", Action: models.ScheduleUndeploy, At: &missed,
+			MissedTriggers: models.MissedTriggersRun, NextRun: &missed},
+		{BaseUUID: models.BaseUUID{ID: "elsewhere"}, JobGroupID: "group-4", Action: models.ScheduleUndeploy, At: &due, NextRun: &due},
+		{BaseUUID: models.BaseUUID{ID: "deployed"}, JobGroupID: "group-5", Action: models.ScheduleDeploy, At: &due, NextRun: &due},
+	}
+	claimedBy := func(id string) interface{} {
+		return mock.MatchedBy(func(schedule *models.Schedule) bool { return schedule.ID == id })
+	}
+	tuesday := time.Date(2026, time.October, 20, 8, 0, 0, 0, time.UTC)
+	mockRepo.On("FindDueSchedules", now).Return(schedules, nil)
+	mockRepo.On("ClaimSchedule", claimedBy("deploy"), &tuesday, now).Return(true, nil)
+	mockRepo.On("ClaimSchedule", claimedBy("skipped"), (*time.Time)(nil), now).Return(true, nil)
+	mockRepo.On("ClaimSchedule", claimedBy("caught-up"), (*time.Time)(nil), now).Return(true, nil)
+	mockRepo.On("ClaimSchedule", claimedBy("elsewhere"), (*time.Time)(nil), now).Return(false, nil)
+	mockRepo.On("ClaimSchedule", claimedBy("deployed"), (*time.Time)(nil), now).Return(true, nil)
+	mockRepo.On("SetScheduleError", "deploy", "JobGroup is not stopped").Return(nil).Once()
+	mockRepo.On("SetScheduleError", "skipped", "trigger at 2026-10-19T05:00:00Z missed and skipped").Return(nil).Once()
+	// a job group already in the state the schedule brings it to is left as is
+	mockRepo.On("SetScheduleError", "deployed", "job group already deployed, trigger skipped").Return(nil).Once()
+
+	service.RunDueSchedules(now)
+	assert.Equal(t, []string{"deploy group-1 by alice", "undeploy group-3"}, jobGroups.triggered)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestScheduleTenant(t *testing.T) {
+	mockRepo := new(repository.MockScheduleRepository)
+	jobGroups := &scheduledJobGroups{jobGroups: map[string]*models.JobGroup{"group-1": scheduledJobGroup("team-a", models.CreateDeployment)}}
+	service := NewScheduleService(mockRepo, jobGroups, nil)
+	body := []byte(`{"action": "undeploy", "cron": "0 20 * * *"}`)
+
+	_, err := service.CreateSchedule("group-1", body, "carol", "team-b")
+	assert.ErrorIs(t, err, ErrForbidden)
+	err = service.DeleteSchedule("group-1", "schedule-1", "team-b")
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.FindSchedules("group-1", "team-b")
+	assert.ErrorIs(t, err, ErrForbidden)
+	mockRepo.AssertNotCalled(t, "FindSchedulesByJobGroup", mock.Anything)
+	mockRepo.AssertNotCalled(t, "SaveSchedule", mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteSchedule", mock.Anything, mock.Anything)
+
+	mockRepo.On("SaveSchedule", mock.MatchedBy(func(schedule *models.Schedule) bool {
+		return schedule.JobGroupID == "group-1" && schedule.Author == "alice"
+	})).Return(&models.Schedule{BaseUUID: models.BaseUUID{ID: "schedule-1"}}, nil).Once()
+	_, err = service.CreateSchedule("group-1", body, "alice", "team-a")
+	require.NoError(t, err)
+	mockRepo.On("FindSchedulesByJobGroup", "group-1").Return(&[]models.Schedule{{BaseUUID: models.BaseUUID{ID: "schedule-1"}}}, nil).Once()
+	schedules, err := service.FindSchedules("group-1", "team-a")
+	require.NoError(t, err)
+	assert.Len(t, *schedules, 1)
+
+	// administrators manage the schedules of every job group
+	mockRepo.On("FindSchedule", "group-1", "schedule-1").Return(&models.Schedule{}, nil).Once()
+	mockRepo.On("DeleteSchedule", "group-1", "schedule-1").Return(int64(1), nil).Once()
+	require.NoError(t, service.DeleteSchedule("group-1", "schedule-1", ""))
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,179 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/repository"
+	"icos/server/jobmanager-service/utils/logs"
+
+	"github.com/google/uuid"
+)
+
+// apiKeyPrefix makes job-manager issued keys easy to recognise in configuration files and logs
+const apiKeyPrefix = "jm_"
+
+// ServiceAccountService interface defines the methods for orchestrator agent credentials
+type ServiceAccountService interface {
+	CreateServiceAccount(body []byte) (*models.ServiceAccountCredentials, error)
+	RevokeServiceAccount(id string) (*models.ServiceAccount, error)
+	FindServiceAccountByUUID(id string) (*models.ServiceAccount, error)
+	FindAllServiceAccounts() (*[]models.ServiceAccount, error)
+	FindServiceAccountByAPIKey(apiKey string) (*models.ServiceAccount, error)
+	FindServiceAccountByClientID(clientID string) (*models.ServiceAccount, error)
+}
+
+// serviceAccountService struct implements the ServiceAccountService interface
+type serviceAccountService struct {
+	repo repository.ServiceAccountRepository
+}
+
+// NewServiceAccountService returns a new instance of serviceAccountService
+func NewServiceAccountService(repo repository.ServiceAccountRepository) ServiceAccountService {
+	return &serviceAccountService{repo: repo}
+}
+
+// CreateServiceAccount registers a new orchestrator agent. Agents bound to an OAuth2 client
+// authenticate with client-credentials tokens, otherwise an API key is issued and returned once.
+// An owner given must not be bound to an agent of another orchestrator.
+func (s *serviceAccountService) CreateServiceAccount(body []byte) (*models.ServiceAccountCredentials, error) {
+	dto := models.ServiceAccountDTO{}
+	if err := json.Unmarshal(body, &dto); err != nil {
+		return nil, err
+	}
+
+	sa := models.ServiceAccount{
+		Name:         dto.Name,
+		Orchestrator: models.OrchestratorTypeMapper(string(dto.Orchestrator)),
+		OwnerID:      dto.OwnerID,
+		ClientID:     dto.ClientID,
+	}
+	if sa.Orchestrator == models.None {
+		return nil, errors.New("no valid orchestrator type provided")
+	}
+	if err := s.verifyOwner(sa); err != nil {
+		logs.Logger.Println("ERROR verifying the owner of service account " + sa.Name + ": " + err.Error())
+		return nil, err
+	}
+
+	apiKey := ""
+	if sa.ClientID == "" {
+		key, err := generateAPIKey()
+		if err != nil {
+			logs.Logger.Println("ERROR generating API key: " + err.Error())
+			return nil, err
+		}
+		apiKey = key
+		sa.APIKeyHash = hashAPIKey(apiKey)
+	}
+
+	if _, err := s.repo.SaveServiceAccount(&sa); err != nil {
+		return nil, err
+	}
+
+	return &models.ServiceAccountCredentials{ServiceAccount: sa, APIKey: apiKey}, nil
+}
+
+// verifyOwner checks the owner of a new service account, an existing owner keeps the orchestrator of its agents
+func (s *serviceAccountService) verifyOwner(sa models.ServiceAccount) error {
+	if sa.OwnerID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(sa.OwnerID); err != nil {
+		return fmt.Errorf("owner %s is not a UUID", sa.OwnerID)
+	}
+	accounts, err := s.repo.FindServiceAccountsByOwnerID(sa.OwnerID)
+	if err != nil {
+		return err
+	}
+	for _, account := range *accounts {
+		if account.Orchestrator != sa.Orchestrator {
+			return fmt.Errorf("owner %s is bound to the %s agent %s", sa.OwnerID, account.Orchestrator, account.Name)
+		}
+	}
+	return nil
+}
+
+// RevokeServiceAccount revokes the credentials of a service account
+func (s *serviceAccountService) RevokeServiceAccount(id string) (*models.ServiceAccount, error) {
+	if id == "" {
+		return nil, errors.New("ID Cannot be empty")
+	}
+
+	sa, err := s.repo.FindServiceAccountByUUID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.RevokeServiceAccount(id); err != nil {
+		return nil, err
+	}
+	sa.Revoked = true
+
+	return sa, nil
+}
+
+// FindServiceAccountByUUID finds a service account by its UUID
+func (s *serviceAccountService) FindServiceAccountByUUID(id string) (*models.ServiceAccount, error) {
+	return s.repo.FindServiceAccountByUUID(id)
+}
+
+// FindAllServiceAccounts finds all service accounts
+func (s *serviceAccountService) FindAllServiceAccounts() (*[]models.ServiceAccount, error) {
+	return s.repo.FindAllServiceAccounts()
+}
+
+// FindServiceAccountByAPIKey resolves the service account an API key was issued for
+func (s *serviceAccountService) FindServiceAccountByAPIKey(apiKey string) (*models.ServiceAccount, error) {
+	if apiKey == "" {
+		return nil, errors.New("API key cannot be empty")
+	}
+	return s.repo.FindServiceAccountByAPIKeyHash(hashAPIKey(apiKey))
+}
+
+// FindServiceAccountByClientID resolves the service account bound to an OAuth2 client
+func (s *serviceAccountService) FindServiceAccountByClientID(clientID string) (*models.ServiceAccount, error) {
+	if clientID == "" {
+		return nil, errors.New("client ID cannot be empty")
+	}
+	return s.repo.FindServiceAccountByClientID(clientID)
+}
+
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// API keys are only stored hashed, they are high entropy so a plain SHA-256 is enough
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
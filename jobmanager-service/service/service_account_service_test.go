@@ -0,0 +1,110 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package service_test
+
+import (
+	"icos/server/jobmanager-service/models"
+	"icos/server/jobmanager-service/service"
+	repository "icos/server/jobmanager-service/service/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccountService(t *testing.T) {
+	mockRepo := new(repository.MockServiceAccountRepository)
+	serviceAccountService := service.NewServiceAccountService(mockRepo)
+
+	t.Run("CreateServiceAccountWithAPIKey", func(t *testing.T) {
+		var saved *models.ServiceAccount
+		mockRepo.On("SaveServiceAccount", mock.MatchedBy(func(sa *models.ServiceAccount) bool {
+			return sa.Name == "nuvla-agent"
+		})).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*models.ServiceAccount)
+		}).Return(&models.ServiceAccount{}, nil).Once()
+
+		result, err := serviceAccountService.CreateServiceAccount([]byte(`{"name": "nuvla-agent", "orchestrator": "nuvla"}`))
+		require.NoError(t, err)
+		assert.Equal(t, models.Nuvla, result.Orchestrator)
+		assert.NotEmpty(t, result.APIKey)
+		assert.NotEmpty(t, saved.APIKeyHash)
+		assert.NotContains(t, saved.APIKeyHash, result.APIKey)
+
+		// the issued key resolves to the service account through its hash
+		mockRepo.On("FindServiceAccountByAPIKeyHash", saved.APIKeyHash).Return(saved, nil).Once()
+		agent, err := serviceAccountService.FindServiceAccountByAPIKey(result.APIKey)
+		require.NoError(t, err)
+		assert.Equal(t, saved, agent)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateServiceAccountWithClientID", func(t *testing.T) {
+		mockRepo.On("SaveServiceAccount", mock.MatchedBy(func(sa *models.ServiceAccount) bool {
+			return sa.ClientID == "ocm-agent" && sa.APIKeyHash == ""
+		})).Return(&models.ServiceAccount{}, nil).Once()
+
+		result, err := serviceAccountService.CreateServiceAccount([]byte(`{"name": "ocm-agent", "orchestrator": "ocm", "client_id": "ocm-agent"}`))
+		require.NoError(t, err)
+		assert.Empty(t, result.APIKey)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateServiceAccountInvalidOrchestrator", func(t *testing.T) {
+		_, err := serviceAccountService.CreateServiceAccount([]byte(`{"name": "agent", "orchestrator": "swarm"}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("CreateServiceAccountOwnerOfAnotherOrchestrator", func(t *testing.T) {
+		owner := "6c7194be-2bd6-42b9-ae61-718e446d018b"
+		mockRepo.On("FindServiceAccountsByOwnerID", owner).Return(&[]models.ServiceAccount{
+			{Name: "ocm-agent", Orchestrator: models.OCM, OwnerID: owner},
+		}, nil).Twice()
+
+		_, err := serviceAccountService.CreateServiceAccount([]byte(`{"name": "nuvla-agent", "orchestrator": "nuvla", "owner_id": "` + owner + `"}`))
+		assert.EqualError(t, err, "owner "+owner+" is bound to the ocm agent ocm-agent")
+
+		// a new credential of the same agent is issued
+		mockRepo.On("SaveServiceAccount", mock.MatchedBy(func(sa *models.ServiceAccount) bool {
+			return sa.OwnerID == owner
+		})).Return(&models.ServiceAccount{}, nil).Once()
+		_, err = serviceAccountService.CreateServiceAccount([]byte(`{"name": "ocm-agent", "orchestrator": "ocm", "owner_id": "` + owner + `"}`))
+		require.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("CreateServiceAccountInvalidOwner", func(t *testing.T) {
+		_, err := serviceAccountService.CreateServiceAccount([]byte(`{"name": "agent", "orchestrator": "ocm", "owner_id": "agent-1"}`))
+		assert.EqualError(t, err, "owner agent-1 is not a UUID")
+	})
+
+	t.Run("RevokeServiceAccount", func(t *testing.T) {
+		sa := &models.ServiceAccount{BaseUUID: models.BaseUUID{ID: "3b0f7a2e-1d4c-4e5f-8a9b-0c1d2e3f4a5b"}}
+		mockRepo.On("FindServiceAccountByUUID", sa.ID).Return(sa, nil).Once()
+		mockRepo.On("RevokeServiceAccount", sa.ID).Return(int64(1), nil).Once()
+
+		result, err := serviceAccountService.RevokeServiceAccount(sa.ID)
+		require.NoError(t, err)
+		assert.True(t, result.Revoked)
+		mockRepo.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,306 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+package secrets
+
+import (
+	"fmt"
+	"icos/server/jobmanager-service/utils/logs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Redacted replaces the sensitive fields of the manifests in user facing responses
+const Redacted = "**REDACTED**"
+
+// sensitiveField is a field of the manifests of a kind, its path is made of keys separated by dots where *
+// matches every key of a map or item of a list
+type sensitiveField struct {
+	kind string
+	path []string
+}
+
+// sensitiveFields are the fields of Secrets and the ones of MANIFEST_SENSITIVE_FIELDS, a comma separated list
+// of kind:path, e.g. ConfigMap:data.password
+var sensitiveFields = sync.OnceValue(func() []sensitiveField {
+	fields := []sensitiveField{
+		{kind: "Secret", path: []string{"data", "*"}},
+		{kind: "Secret", path: []string{"stringData", "*"}},
+	}
+	for _, field := range strings.Split(os.Getenv("MANIFEST_SENSITIVE_FIELDS"), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		kind, path, ok := strings.Cut(field, ":")
+		if !ok || kind == "" || path == "" {
+			logs.Logger.Printf("ERROR ignoring sensitive field %s, expected kind:path", field)
+			continue
+		}
+		fields = append(fields, sensitiveField{kind: kind, path: strings.Split(path, ".")})
+	}
+	return fields
+})
+
+// IsSensitive tells whether the field at the path of a manifest of the kind is sensitive
+func IsSensitive(kind string, path []string) bool {
+	for _, field := range sensitiveFields() {
+		if field.kind != kind || len(field.path) != len(path) {
+			continue
+		}
+		matches := true
+		for i, segment := range field.path {
+			if segment != "*" && segment != path[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// SealManifest seals the sensitive fields of a manifest that are not sealed yet, manifests are left as they
+// are when no encryption key is configured
+func SealManifest(manifest string) (string, error) {
+	sealer := defaultSealer()
+	if sealer == nil {
+		return manifest, nil
+	}
+	return rewriteManifest(manifest, func(_ string, value string) (string, error) {
+		if IsSealed(value) || value == Redacted {
+			return value, nil
+		}
+		return sealer.Seal(value)
+	})
+}
+
+// OpenManifest opens the sealed fields of a manifest
+func OpenManifest(manifest string) (string, error) {
+	return rewriteManifest(manifest, func(_ string, value string) (string, error) {
+		if !IsSealed(value) {
+			return value, nil
+		}
+		sealer := defaultSealer()
+		if sealer == nil {
+			return "", ErrNoKey
+		}
+		return sealer.Open(value)
+	})
+}
+
+// SealValue seals a value as a whole, values are left as they are when no encryption key is configured
+func SealValue(value string) (string, error) {
+	sealer := defaultSealer()
+	if sealer == nil || value == "" || IsSealed(value) || value == Redacted {
+		return value, nil
+	}
+	return sealer.Seal(value)
+}
+
+// OpenValue opens a value sealed by SealValue
+func OpenValue(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	sealer := defaultSealer()
+	if sealer == nil {
+		return "", ErrNoKey
+	}
+	return sealer.Open(value)
+}
+
+// RedactManifest replaces the values of the sensitive fields of a manifest with Redacted
+func RedactManifest(manifest string) string {
+	redacted, err := rewriteManifest(manifest, func(string, string) (string, error) {
+		return Redacted, nil
+	})
+	if err != nil {
+		return manifest
+	}
+	return redacted
+}
+
+// RedactPatch replaces with Redacted the values a patch of the manifests of the kind sets on their sensitive
+// fields, the patch being a strategic merge patch or a JSON 6902 one. The kind of a strategic merge patch is
+// its own when it has one, the sensitive fields of every kind are redacted when the kind is not known.
+func RedactPatch(patch, kind string) string {
+	var node interface{}
+	if err := yaml.Unmarshal([]byte(patch), &node); err != nil {
+		return patch
+	}
+	changed := false
+	redact := func(string, string) string {
+		changed = true
+		return Redacted
+	}
+	switch node := node.(type) {
+	case map[string]interface{}:
+		if patchKind, ok := node["kind"].(string); ok {
+			kind = patchKind
+		}
+		visitSensitive(node, kind, redact)
+	case []interface{}:
+		for _, item := range node {
+			operation, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			value, ok := operation["value"]
+			path, _ := operation["path"].(string)
+			if !ok || path == "" {
+				continue
+			}
+			// the value is set at its path, so that the sensitive fields under it are found
+			segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+			object := map[string]interface{}{}
+			parent := object
+			for i, segment := range segments {
+				segment = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+				segments[i] = segment
+				if i == len(segments)-1 {
+					parent[segment] = value
+				} else {
+					child := map[string]interface{}{}
+					parent[segment] = child
+					parent = child
+				}
+			}
+			visitSensitive(object, kind, redact)
+			for _, segment := range segments[:len(segments)-1] {
+				object = object[segment].(map[string]interface{})
+			}
+			operation["value"] = object[segments[len(segments)-1]]
+		}
+	default:
+		return patch
+	}
+	if !changed {
+		return patch
+	}
+	redacted, err := yaml.Marshal(node)
+	if err != nil {
+		return Redacted
+	}
+	return string(redacted)
+}
+
+// visitSensitive calls the function on the values of the sensitive fields of an object of the kind, the ones of
+// every kind when it is empty
+func visitSensitive(object map[string]interface{}, kind string, fn func(path string, value string) string) {
+	for _, field := range sensitiveFields() {
+		if kind == "" || field.kind == kind {
+			visit(object, field.path, "", fn)
+		}
+	}
+}
+
+// RestoreRedacted gives back to the redacted fields of an updated manifest their stored value, so that the
+// manifests of a response can be sent back as they are
+func RestoreRedacted(updated, stored string) string {
+	values := map[string]string{}
+	if _, err := rewriteManifest(stored, func(path, value string) (string, error) {
+		values[path] = value
+		return value, nil
+	}); err != nil {
+		return updated
+	}
+	restored, err := rewriteManifest(updated, func(path, value string) (string, error) {
+		if stored, ok := values[path]; ok && value == Redacted {
+			return stored, nil
+		}
+		return value, nil
+	})
+	if err != nil {
+		return updated
+	}
+	return restored
+}
+
+// rewriteManifest rewrites the string values of the sensitive fields of a manifest, the rewrite function is
+// given the concrete path of each value. Manifests without sensitive fields are returned unchanged.
+func rewriteManifest(manifest string, rewrite func(path, value string) (string, error)) (string, error) {
+	object := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(manifest), &object); err != nil {
+		// manifests are validated at ingestion, the ones that cannot be parsed have no field to rewrite
+		return manifest, nil
+	}
+	kind, _ := object["kind"].(string)
+
+	changed := false
+	for _, field := range sensitiveFields() {
+		if field.kind != kind {
+			continue
+		}
+		var err error
+		visit(object, field.path, "", func(path string, value string) string {
+			if err != nil {
+				return value
+			}
+			rewritten, rewriteErr := rewrite(path, value)
+			if rewriteErr != nil {
+				err = fmt.Errorf("field %s of %s: %w", path, kind, rewriteErr)
+				return value
+			}
+			changed = changed || rewritten != value
+			return rewritten
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if !changed {
+		return manifest, nil
+	}
+
+	rewritten, err := yaml.Marshal(object)
+	return string(rewritten), err
+}
+
+// visit calls the function on the string values at the path of the node, replacing them with its result
+func visit(node interface{}, path []string, prefix string, fn func(path string, value string) string) interface{} {
+	if len(path) == 0 {
+		if value, ok := node.(string); ok {
+			return fn(prefix, value)
+		}
+		return node
+	}
+	segment, rest := path[0], path[1:]
+	switch node := node.(type) {
+	case map[string]interface{}:
+		for key, child := range node {
+			if segment == "*" || segment == key {
+				node[key] = visit(child, rest, prefix+"."+key, fn)
+			}
+		}
+	case []interface{}:
+		for i, child := range node {
+			if segment == "*" || segment == strconv.Itoa(i) {
+				node[i] = visit(child, rest, prefix+"."+strconv.Itoa(i), fn)
+			}
+		}
+	}
+	return node
+}
@@ -0,0 +1,201 @@
+/*
+  JOB-MANAGER
+  Copyright © 2022-2024 EVIDEN
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+  This work has received funding from the European Union's HORIZON research
+  and innovation programme under grant agreement No. 101070177.
+*/
+
+// Package secrets encrypts the sensitive fields of the manifests at rest with envelope encryption: every value
+// is encrypted with its own data key, which is stored wrapped by the master key next to the value.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"icos/server/jobmanager-service/utils/logs"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	sealedPrefix = "ENC[v1,"
+	sealedSuffix = "]"
+	keySize      = 32
+)
+
+// ErrNoKey is returned when a sealed value is opened while no master key is configured
+var ErrNoKey = errors.New("no manifest encryption key is configured")
+
+// Sealer seals values with a master key, sealed values read ENC[v1,<key id>,<wrapped data key>,<ciphertext>]
+type Sealer struct {
+	master cipher.AEAD
+	keyID  string
+}
+
+// NewSealer returns a sealer for a 32 bytes master key
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("manifest encryption key must be %d bytes long, got %d", keySize, len(key))
+	}
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(key)
+	return &Sealer{master: master, keyID: hex.EncodeToString(sum[:4])}, nil
+}
+
+// LoadSealer reads the master key from a key file, holding either the raw key or its base64 encoding
+func LoadSealer(path string) (*Sealer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content))); err == nil && len(key) == keySize {
+		return NewSealer(key)
+	}
+	return NewSealer(content)
+}
+
+var (
+	sealerMutex sync.Mutex
+	sealer      *Sealer
+	sealerSet   bool
+)
+
+// UseSealer sets the sealer of the manifests in place of the key file of MANIFEST_ENCRYPTION_KEY_FILE, nil
+// disables the encryption
+func UseSealer(s *Sealer) {
+	sealerMutex.Lock()
+	defer sealerMutex.Unlock()
+	sealer, sealerSet = s, true
+}
+
+// Enabled tells whether the sensitive fields are encrypted, the key file is loaded on the first call
+func Enabled() bool {
+	return defaultSealer() != nil
+}
+
+// defaultSealer is loaded from the key file of MANIFEST_ENCRYPTION_KEY_FILE, values are not sealed without it
+func defaultSealer() *Sealer {
+	sealerMutex.Lock()
+	defer sealerMutex.Unlock()
+	if sealerSet {
+		return sealer
+	}
+	sealerSet = true
+
+	path := os.Getenv("MANIFEST_ENCRYPTION_KEY_FILE")
+	if path == "" {
+		logs.Logger.Println("WARNING no manifest encryption key file, sensitive fields are stored in plaintext")
+		return nil
+	}
+	loaded, err := LoadSealer(path)
+	if err != nil {
+		// storing secrets in plaintext is not an option once encryption is configured
+		logs.Logger.Fatalf("ERROR loading the manifest encryption key: %v", err)
+	}
+	sealer = loaded
+	return sealer
+}
+
+// IsSealed tells whether a value is sealed
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix) && strings.HasSuffix(value, sealedSuffix)
+}
+
+// Seal encrypts a value with a new data key
+func (s *Sealer) Seal(value string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(s.master, dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(data, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + s.keyID + "," + base64.StdEncoding.EncodeToString(wrappedKey) + "," +
+		base64.StdEncoding.EncodeToString(ciphertext) + sealedSuffix, nil
+}
+
+// Open decrypts a sealed value
+func (s *Sealer) Open(sealed string) (string, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(sealed, sealedPrefix), sealedSuffix), ",")
+	if !IsSealed(sealed) || len(parts) != 3 {
+		return "", errors.New("value is not sealed")
+	}
+	if parts[0] != s.keyID {
+		return "", fmt.Errorf("value is sealed with key %s, the configured key is %s", parts[0], s.keyID)
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(s.master, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	value, err := open(data, ciphertext)
+	return string(value), err
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, the nonce prefixes the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
@@ -45,6 +45,11 @@ import (
 //	@name						Authorization
 //	@description				"Type 'Bearer TOKEN' to correctly set the API Key"
 
+//	@securityDefinitions.apikey	ApiKey
+//	@in							header
+//	@name						X-API-Key
+//	@description				"Job Manager issued API key of an orchestrator agent service account"
+
 //	@externalDocs.description	OpenAPI
 //	@externalDocs.url			https://swagger.io/resources/open-api/
 func main() {